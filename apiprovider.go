@@ -0,0 +1,155 @@
+package crypto
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// apiProviders are the names of the Api providers, indexed by their value;
+// the built in providers (and ApiProviderSentinal, which marks their end and
+// is not a provider) are followed by those registered with
+// RegisterApiProvider
+var apiProviders = struct {
+	sync.RWMutex
+	names  []string
+	byName map[string]ApiProvider
+}{
+	names: []string{
+		"unknown",
+		"dummy_exchange",
+		"luno",
+		"binance",
+		"dummy_exchange_binance_market",
+		"replay",
+		"binance_testnet",
+		"sentinal",
+	},
+}
+
+// reservedApiProviderNames cannot be registered; "unknown" is the name of
+// ApiProviderUnknown, and the sentinel's name is only used to format it
+var reservedApiProviderNames = map[string]bool{
+	ApiProviderUnknown.String():  true,
+	ApiProviderSentinal.String(): true,
+}
+
+func init() {
+
+	apiProviders.byName = make(map[string]ApiProvider, len(apiProviders.names))
+	for i, name := range apiProviders.names {
+		if ApiProvider(i) == ApiProviderSentinal {
+			continue
+		}
+		apiProviders.byName[name] = ApiProvider(i)
+	}
+}
+
+// RegisterApiProvider returns the ApiProvider with the given name, adding a
+// new one if there is none (e.g. for a venue whose client and market follower
+// are provided outside of this module), so that it can be used in exchanges
+// and parsed from strings and JSON like the built in providers.
+// It panics if name is empty or reserved (i.e. "unknown" or the name of
+// ApiProviderSentinal).
+func RegisterApiProvider(name string) ApiProvider {
+
+	if name == "" {
+		panic("crypto: RegisterApiProvider with empty name")
+	}
+	if reservedApiProviderNames[name] {
+		panic("crypto: RegisterApiProvider with reserved name " + name)
+	}
+
+	apiProviders.Lock()
+	defer apiProviders.Unlock()
+
+	if p, ok := apiProviders.byName[name]; ok {
+		return p
+	}
+	p := ApiProvider(len(apiProviders.names))
+	apiProviders.names = append(apiProviders.names, name)
+	apiProviders.byName[name] = p
+	return p
+}
+
+func (i ApiProvider) String() string {
+
+	apiProviders.RLock()
+	defer apiProviders.RUnlock()
+
+	if i < 0 || int(i) >= len(apiProviders.names) {
+		return fmt.Sprintf("ApiProvider(%d)", i)
+	}
+	return apiProviders.names[i]
+}
+
+// ApiProviderString returns the ApiProvider with the given name; it returns an
+// error if there is none (including for the name of ApiProviderSentinal)
+func ApiProviderString(s string) (ApiProvider, error) {
+
+	apiProviders.RLock()
+	defer apiProviders.RUnlock()
+
+	if p, ok := apiProviders.byName[s]; ok {
+		return p, nil
+	}
+	return 0, fmt.Errorf("%s does not belong to ApiProvider values", s)
+}
+
+// ApiProviderValues returns all of the built in and registered ApiProviders
+func ApiProviderValues() []ApiProvider {
+
+	apiProviders.RLock()
+	defer apiProviders.RUnlock()
+
+	values := make([]ApiProvider, 0, len(apiProviders.names)-1)
+	for i := range apiProviders.names {
+		if ApiProvider(i) != ApiProviderSentinal {
+			values = append(values, ApiProvider(i))
+		}
+	}
+	return values
+}
+
+// IsAApiProvider returns true if i is a built in or registered ApiProvider
+func (i ApiProvider) IsAApiProvider() bool {
+
+	apiProviders.RLock()
+	defer apiProviders.RUnlock()
+
+	return i >= 0 && int(i) < len(apiProviders.names) && i != ApiProviderSentinal
+}
+
+// MarshalJSON implements the json.Marshaler interface for ApiProvider
+func (i ApiProvider) MarshalJSON() ([]byte, error) {
+
+	return json.Marshal(i.String())
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface for ApiProvider
+func (i *ApiProvider) UnmarshalJSON(data []byte) error {
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("ApiProvider should be a string, got %s", data)
+	}
+
+	var err error
+	*i, err = ApiProviderString(s)
+	return err
+}
+
+// MarshalText implements the encoding.TextMarshaler interface for ApiProvider
+func (i ApiProvider) MarshalText() ([]byte, error) {
+
+	return []byte(i.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface for
+// ApiProvider
+func (i *ApiProvider) UnmarshalText(text []byte) error {
+
+	var err error
+	*i, err = ApiProviderString(string(text))
+	return err
+}
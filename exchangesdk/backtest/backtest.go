@@ -0,0 +1,250 @@
+// Package backtest runs trading strategies against recorded market data
+// using a simulated exchange and a simulated clock, without any network
+// access.
+package backtest
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/recording"
+	"github.com/thecodedproject/crypto/exchangesdk/simulator"
+	"github.com/thecodedproject/crypto/profitloss"
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+// Strategy is called with each market event in turn, and places orders via
+// the given client.
+// Returning an error stops the backtest.
+type Strategy interface {
+	OnOrderBook(ctx context.Context, c exchangesdk.Client, ob exchangesdk.OrderBook) error
+	OnTrade(ctx context.Context, c exchangesdk.Client, t exchangesdk.OrderBookTrade) error
+}
+
+// EventSource supplies market events in time order, returning io.EOF after
+// the last event (e.g. a recording.Reader)
+type EventSource interface {
+	Next() (recording.Event, error)
+}
+
+type Result struct {
+	// Snapshot is the profit/loss of all fills, valued at the latest price
+	// at the end of the backtest
+	Snapshot profitloss.Snapshot
+	// Fills is every fill of the strategy's orders, oldest first
+	Fills []exchangesdk.Trade
+	// Pending is the order placements and cancellations which had not
+	// reached the simulated exchange (because of latency) by the end of the
+	// events, and so had no effect, in the order they were made
+	Pending []PendingAction
+}
+
+// PendingAction is an order placement or cancellation made by the strategy
+// which was still being delayed by latency at the end of a backtest
+type PendingAction struct {
+	OrderId string
+	Cancel  bool
+	// Due is when it would have reached the simulated exchange
+	Due time.Time
+}
+
+type options struct {
+	exchange         crypto.Exchange
+	makerFee         decimal.Decimal
+	takerFee         decimal.Decimal
+	counterPrecision int32
+	basePrecision    int32
+	latency          time.Duration
+	report           profitloss.Report
+	clock            *utiltime.SimulatedClock
+}
+
+type Option func(*options)
+
+func WithExchange(exchange crypto.Exchange) Option {
+
+	return func(o *options) {
+		o.exchange = exchange
+	}
+}
+
+// WithFees sets the maker and taker fees (as ratios) charged on fills
+func WithFees(maker, taker decimal.Decimal) Option {
+
+	return func(o *options) {
+		o.makerFee = maker
+		o.takerFee = taker
+	}
+}
+
+func WithPrecision(counter, base int32) Option {
+
+	return func(o *options) {
+		o.counterPrecision = counter
+		o.basePrecision = base
+	}
+}
+
+// WithLatency sets the delay (in market time) between the strategy placing
+// or cancelling an order and it reaching the simulated exchange
+func WithLatency(latency time.Duration) Option {
+
+	return func(o *options) {
+		o.latency = latency
+	}
+}
+
+// WithClock sets the clock which is moved to the time of each event as it is
+// processed; strategies which tell the time or wait on timers (e.g. via
+// execution.WithClock) should use the same clock, and may use the client
+// given to them from the goroutines woken by its timers. By default a new
+// clock is used for each run.
+func WithClock(clock *utiltime.SimulatedClock) Option {
+
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+func WithInitialBalances(base, counter decimal.Decimal) Option {
+
+	return func(o *options) {
+		o.report.InitialBaseBalance = base
+		o.report.InitialCounterBalance = counter
+	}
+}
+
+// RunFile runs s against the recording at path (see Run)
+func RunFile(
+	ctx context.Context,
+	path string,
+	s Strategy,
+	opts ...Option,
+) (Result, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return Result{}, err
+	}
+	defer f.Close()
+
+	reader, err := recording.NewReader(f)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Run(ctx, reader, s, opts...)
+}
+
+// Run feeds each event from events to a simulated exchange and then to s,
+// returning the strategy's fills once all events have been processed.
+// Before each event is processed the run's clock (see WithClock) is moved to
+// the time at which it was received, so backtests with their own clocks may
+// be run concurrently.
+func Run(
+	ctx context.Context,
+	events EventSource,
+	s Strategy,
+	opts ...Option,
+) (Result, error) {
+
+	o := options{
+		counterPrecision: 2,
+		basePrecision:    6,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.clock == nil {
+		o.clock = utiltime.NewSimulatedClock(time.Time{})
+	}
+
+	sim := simulator.New(
+		o.exchange,
+		simulator.WithFees(o.makerFee, o.takerFee),
+		simulator.WithPrecision(o.counterPrecision, o.basePrecision),
+		simulator.WithClock(o.clock),
+	)
+	c := newClient(sim, o.clock, o.latency)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return Result{}, err
+		}
+
+		e, err := events.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return Result{}, err
+		}
+
+		if t := eventTime(e); !t.IsZero() {
+			o.clock.Set(t)
+		}
+		c.applyDue(o.clock.Now())
+
+		if e.OrderBook != nil {
+			sim.UpdateOrderBook(*e.OrderBook)
+			err = s.OnOrderBook(ctx, c, *e.OrderBook)
+		} else if e.Trade != nil {
+			sim.AddTrade(*e.Trade)
+			err = s.OnTrade(ctx, c, *e.Trade)
+		}
+		if err != nil {
+			return Result{}, err
+		}
+	}
+
+	return result(ctx, c, o.report)
+}
+
+func eventTime(e recording.Event) time.Time {
+
+	if !e.Received.IsZero() {
+		return e.Received
+	}
+	if e.OrderBook != nil {
+		return e.OrderBook.Timestamp
+	}
+	if e.Trade != nil {
+		return e.Trade.Timestamp
+	}
+	return time.Time{}
+}
+
+func result(
+	ctx context.Context,
+	c *client,
+	report profitloss.Report,
+) (Result, error) {
+
+	var fills []exchangesdk.Trade
+	for page := int64(1); ; page++ {
+		trades, err := c.GetTrades(ctx, page)
+		if err != nil {
+			return Result{}, err
+		}
+		if len(trades) == 0 {
+			break
+		}
+		fills = append(fills, trades...)
+	}
+
+	// With no market data the fills (if any) are valued at zero
+	price, _ := c.LatestPrice(ctx)
+
+	return Result{
+		Snapshot: profitloss.GenerateSnapshot(
+			profitloss.Add(report, fills...),
+			price,
+		),
+		Fills:   fills,
+		Pending: c.pendingActions(),
+	}, nil
+}
@@ -0,0 +1,399 @@
+package backtest_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/backtest"
+	"github.com/thecodedproject/crypto/exchangesdk/recording"
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+func D(f float64) decimal.Decimal {
+
+	return decimal.NewFromFloat(f)
+}
+
+type events []recording.Event
+
+func (e *events) Next() (recording.Event, error) {
+
+	if len(*e) == 0 {
+		return recording.Event{}, io.EOF
+	}
+	next := (*e)[0]
+	*e = (*e)[1:]
+	return next, nil
+}
+
+func bookEvent(sec int64, bid, ask float64) recording.Event {
+
+	return recording.Event{
+		Received: time.Unix(sec, 0),
+		OrderBook: &exchangesdk.OrderBook{
+			Timestamp: time.Unix(sec, 0),
+			Bids:      []exchangesdk.OrderBookOrder{{Price: bid, Volume: 10}},
+			Asks:      []exchangesdk.OrderBookOrder{{Price: ask, Volume: 10}},
+		},
+	}
+}
+
+type strategy struct {
+	onOrderBook func(context.Context, exchangesdk.Client, exchangesdk.OrderBook) error
+	onTrade     func(context.Context, exchangesdk.Client, exchangesdk.OrderBookTrade) error
+}
+
+func (s strategy) OnOrderBook(
+	ctx context.Context,
+	c exchangesdk.Client,
+	ob exchangesdk.OrderBook,
+) error {
+
+	if s.onOrderBook == nil {
+		return nil
+	}
+	return s.onOrderBook(ctx, c, ob)
+}
+
+func (s strategy) OnTrade(
+	ctx context.Context,
+	c exchangesdk.Client,
+	t exchangesdk.OrderBookTrade,
+) error {
+
+	if s.onTrade == nil {
+		return nil
+	}
+	return s.onTrade(ctx, c, t)
+}
+
+func TestRunProducesFillsAndProfitLoss(t *testing.T) {
+
+	es := events{
+		bookEvent(1, 99, 101),
+		bookEvent(2, 104, 106),
+	}
+
+	var orderIds []string
+	s := strategy{
+		onOrderBook: func(ctx context.Context, c exchangesdk.Client, ob exchangesdk.OrderBook) error {
+			order := exchangesdk.Order{
+				Type:   exchangesdk.OrderTypeBid,
+				Price:  D(ob.Asks[0].Price),
+				Volume: D(1),
+			}
+			if len(orderIds) > 0 {
+				order.Type = exchangesdk.OrderTypeAsk
+				order.Price = D(ob.Bids[0].Price)
+			}
+			id, err := c.PostLimitOrder(ctx, order)
+			orderIds = append(orderIds, id)
+			return err
+		},
+	}
+
+	res, err := backtest.Run(
+		context.Background(),
+		&es,
+		s,
+		backtest.WithFees(D(0.001), D(0.002)),
+		backtest.WithInitialBalances(D(0), D(1000)),
+	)
+	require.NoError(t, err)
+
+	require.Equal(t, 2, len(res.Fills))
+	assert.Equal(t, orderIds[0], res.Fills[0].OrderId)
+	assert.True(t, D(101).Equal(res.Fills[0].Price))
+	assert.True(t, D(0.002).Equal(res.Fills[0].BaseFee), "taker fee")
+	assert.True(t, time.Unix(1, 0).Equal(res.Fills[0].Timestamp))
+	assert.Equal(t, orderIds[1], res.Fills[1].OrderId)
+	assert.True(t, D(104).Equal(res.Fills[1].Price))
+	assert.True(t, D(0.208).Equal(res.Fills[1].CounterFee))
+
+	assert.Equal(t, int64(2), res.Snapshot.TradeCount)
+	assert.True(t, D(2.792).Equal(res.Snapshot.RealisedGain))
+	assert.True(t, D(1002.792).Equal(res.Snapshot.CounterBalance))
+	assert.True(t, D(-0.002).Equal(res.Snapshot.BaseBalance))
+}
+
+func TestRunAdvancesSimulatedClock(t *testing.T) {
+
+	es := events{
+		bookEvent(10, 99, 101),
+		{
+			Received: time.Unix(11, 0),
+			Trade:    &exchangesdk.OrderBookTrade{Price: 100, Volume: 1},
+		},
+		bookEvent(12, 99, 101),
+	}
+
+	clock := utiltime.NewSimulatedClock(time.Unix(0, 0))
+	var times []time.Time
+	var realTimes []time.Time
+	s := strategy{
+		onOrderBook: func(context.Context, exchangesdk.Client, exchangesdk.OrderBook) error {
+			times = append(times, clock.Now())
+			realTimes = append(realTimes, utiltime.Now())
+			return nil
+		},
+		onTrade: func(context.Context, exchangesdk.Client, exchangesdk.OrderBookTrade) error {
+			times = append(times, clock.Now())
+			realTimes = append(realTimes, utiltime.Now())
+			return nil
+		},
+	}
+
+	_, err := backtest.Run(context.Background(), &es, s, backtest.WithClock(clock))
+	require.NoError(t, err)
+
+	require.Equal(t, 3, len(times))
+	for i, ts := range times {
+		assert.True(t, time.Unix(int64(10+i), 0).Equal(ts))
+	}
+
+	// The global time is left alone
+	for _, ts := range realTimes {
+		assert.True(t, time.Since(ts) < time.Second)
+	}
+}
+
+func TestRunFiresTimersOfClock(t *testing.T) {
+
+	es := events{
+		bookEvent(10, 99, 101),
+		bookEvent(11, 99, 101),
+		bookEvent(13, 99, 101),
+	}
+
+	clock := utiltime.NewSimulatedClock(time.Unix(0, 0))
+	var timer utiltime.Timer
+	var fired []bool
+	s := strategy{
+		onOrderBook: func(context.Context, exchangesdk.Client, exchangesdk.OrderBook) error {
+			if timer == nil {
+				timer = clock.NewTimer(2 * time.Second)
+				return nil
+			}
+			select {
+			case <-timer.C():
+				fired = append(fired, true)
+			default:
+				fired = append(fired, false)
+			}
+			return nil
+		},
+	}
+
+	_, err := backtest.Run(context.Background(), &es, s, backtest.WithClock(clock))
+	require.NoError(t, err)
+
+	assert.Equal(t, []bool{false, true}, fired)
+}
+
+func TestRunClientMayBeUsedFromTimersOfClock(t *testing.T) {
+
+	es := events{
+		bookEvent(1, 99, 101),
+		bookEvent(2, 99, 101),
+		bookEvent(3, 99, 101),
+		bookEvent(4, 99, 101),
+		bookEvent(5, 99, 101),
+	}
+
+	clock := utiltime.NewSimulatedClock(time.Unix(0, 0))
+	posted := make(chan string, 1)
+	var orderId string
+	var status exchangesdk.OrderStatus
+	s := strategy{
+		onOrderBook: func(ctx context.Context, c exchangesdk.Client, ob exchangesdk.OrderBook) error {
+			switch ob.Timestamp.Unix() {
+			case 1:
+				// The order is placed from the timer's goroutine while the
+				// following events are replayed
+				timer := clock.NewTimer(time.Second)
+				go func() {
+					<-timer.C()
+					id, err := c.PostLimitOrder(ctx, exchangesdk.Order{
+						Type:   exchangesdk.OrderTypeBid,
+						Price:  D(90),
+						Volume: D(1),
+					})
+					if err == nil {
+						err = c.CancelOrder(ctx, id)
+					}
+					if err != nil {
+						id = ""
+					}
+					posted <- id
+				}()
+			case 3:
+				orderId = <-posted
+			case 5:
+				var err error
+				status, err = c.GetOrderStatus(ctx, orderId)
+				return err
+			}
+			return nil
+		},
+	}
+
+	res, err := backtest.Run(
+		context.Background(),
+		&es,
+		s,
+		backtest.WithClock(clock),
+		backtest.WithLatency(500*time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	require.NotEqual(t, "", orderId)
+	assert.Equal(t, exchangesdk.OrderStateCancelled, status.State)
+	assert.Empty(t, res.Pending)
+}
+
+func TestRunDelaysOrdersByLatency(t *testing.T) {
+
+	es := events{
+		bookEvent(1, 99, 101),
+		bookEvent(2, 98, 100),
+		bookEvent(3, 102, 104),
+	}
+
+	var orderId string
+	var statuses []exchangesdk.OrderStatus
+	s := strategy{
+		onOrderBook: func(ctx context.Context, c exchangesdk.Client, ob exchangesdk.OrderBook) error {
+			if orderId == "" {
+				var err error
+				orderId, err = c.PostLimitOrder(ctx, exchangesdk.Order{
+					Type:   exchangesdk.OrderTypeBid,
+					Price:  D(100),
+					Volume: D(1),
+				})
+				return err
+			}
+			status, err := c.GetOrderStatus(ctx, orderId)
+			statuses = append(statuses, status)
+			return err
+		},
+	}
+
+	res, err := backtest.Run(
+		context.Background(),
+		&es,
+		s,
+		backtest.WithLatency(1500*time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	require.Equal(t, 2, len(statuses))
+	assert.Equal(t, exchangesdk.OrderStateInOrderBook, statuses[0].State)
+	assert.True(t, statuses[0].FillAmountBase.IsZero())
+
+	// The order arrives before the third book, while the ask is at 100
+	assert.Equal(t, exchangesdk.OrderStateFilled, statuses[1].State)
+	require.Equal(t, 1, len(res.Fills))
+	assert.True(t, D(100).Equal(res.Fills[0].Price))
+}
+
+func TestRunCancelIsDelayedByLatency(t *testing.T) {
+
+	es := events{
+		bookEvent(1, 99, 101),
+		bookEvent(2, 99, 101),
+		bookEvent(3, 98, 99),
+		bookEvent(4, 98, 99),
+	}
+
+	var orderId string
+	var statuses []exchangesdk.OrderStatus
+	s := strategy{
+		onOrderBook: func(ctx context.Context, c exchangesdk.Client, ob exchangesdk.OrderBook) error {
+			switch ob.Timestamp.Unix() {
+			case 1:
+				var err error
+				orderId, err = c.PostLimitOrder(ctx, exchangesdk.Order{
+					Type:   exchangesdk.OrderTypeBid,
+					Price:  D(100),
+					Volume: D(1),
+				})
+				return err
+			case 2:
+				return c.CancelOrder(ctx, orderId)
+			default:
+				status, err := c.GetOrderStatus(ctx, orderId)
+				statuses = append(statuses, status)
+				return err
+			}
+		},
+	}
+
+	_, err := backtest.Run(
+		context.Background(),
+		&es,
+		s,
+		backtest.WithLatency(1500*time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	// The book moves through the order before the cancel arrives
+	require.Equal(t, 2, len(statuses))
+	assert.Equal(t, exchangesdk.OrderStateFilled, statuses[0].State)
+	assert.Equal(t, exchangesdk.OrderStateFilled, statuses[1].State)
+}
+
+func TestRunReturnsActionsStillDelayedByLatency(t *testing.T) {
+
+	es := events{
+		bookEvent(1, 99, 101),
+		bookEvent(2, 99, 101),
+		bookEvent(3, 99, 101),
+	}
+
+	var orderIds []string
+	s := strategy{
+		onOrderBook: func(ctx context.Context, c exchangesdk.Client, ob exchangesdk.OrderBook) error {
+			switch ob.Timestamp.Unix() {
+			case 1, 3:
+				id, err := c.PostLimitOrder(ctx, exchangesdk.Order{
+					Type:   exchangesdk.OrderTypeBid,
+					Price:  D(90),
+					Volume: D(1),
+				})
+				orderIds = append(orderIds, id)
+				return err
+			default:
+				return c.CancelOrder(ctx, orderIds[0])
+			}
+		},
+	}
+
+	res, err := backtest.Run(
+		context.Background(),
+		&es,
+		s,
+		backtest.WithLatency(1500*time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	// The first order arrives before the third book, but neither the cancel
+	// nor the second order arrive before the end of the events
+	require.Equal(t, 2, len(orderIds))
+	assert.Equal(t, []backtest.PendingAction{
+		{
+			OrderId: orderIds[0],
+			Cancel:  true,
+			Due:     time.Unix(3, 500000000),
+		},
+		{
+			OrderId: orderIds[1],
+			Due:     time.Unix(4, 500000000),
+		},
+	}, res.Pending)
+}
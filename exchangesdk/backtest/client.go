@@ -0,0 +1,270 @@
+package backtest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/simulator"
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+// client is the exchangesdk.Client given to strategies.
+// It models order latency by holding back order placements and cancellations
+// until the simulated clock has advanced by the configured latency; until an
+// order reaches the simulated exchange its status is reported as just placed.
+//
+// It is safe for concurrent use, so that strategies may place orders from
+// goroutines woken by the clock's timers while events are being replayed.
+type client struct {
+	sim     *simulator.Exchange
+	clock   utiltime.Clock
+	latency time.Duration
+
+	// mu guards the fields below; the actions applied by applyDue are run
+	// with it held
+	mu          sync.Mutex
+	nextOrderId int64
+	pending     []action
+	// simOrderIds maps the order ids given to the strategy to the ids of the
+	// orders on the simulated exchange, for orders which have arrived there
+	simOrderIds map[string]string
+	orderIds    map[string]string
+	// placed is the status of each order given to the strategy before it
+	// arrives at the simulated exchange (or if it was rejected)
+	placed map[string]exchangesdk.OrderStatus
+}
+
+type action struct {
+	due     time.Time
+	orderId string
+	cancel  bool
+	apply   func()
+}
+
+var _ exchangesdk.Client = (*client)(nil)
+
+func newClient(
+	sim *simulator.Exchange,
+	clock utiltime.Clock,
+	latency time.Duration,
+) *client {
+
+	return &client{
+		sim:         sim,
+		clock:       clock,
+		latency:     latency,
+		simOrderIds: make(map[string]string),
+		orderIds:    make(map[string]string),
+		placed:      make(map[string]exchangesdk.OrderStatus),
+	}
+}
+
+func (c *client) schedule(orderId string, cancel bool, apply func()) {
+
+	if c.latency <= 0 {
+		apply()
+		return
+	}
+
+	c.pending = append(c.pending, action{
+		due:     c.clock.Now().Add(c.latency),
+		orderId: orderId,
+		cancel:  cancel,
+		apply:   apply,
+	})
+}
+
+// applyDue applies, in the order they were made, all order placements and
+// cancellations which have reached the simulated exchange by now
+func (c *client) applyDue(now time.Time) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.pending) > 0 && !c.pending[0].due.After(now) {
+		a := c.pending[0]
+		c.pending = c.pending[1:]
+		a.apply()
+	}
+}
+
+// pendingActions returns the order placements and cancellations which have
+// not yet reached the simulated exchange
+func (c *client) pendingActions() []PendingAction {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var pending []PendingAction
+	for _, a := range c.pending {
+		pending = append(pending, PendingAction{
+			OrderId: a.orderId,
+			Cancel:  a.cancel,
+			Due:     a.due,
+		})
+	}
+	return pending
+}
+
+func (c *client) Exchange() crypto.Exchange {
+
+	return c.sim.Exchange()
+}
+
+func (c *client) LatestPrice(ctx context.Context) (decimal.Decimal, error) {
+
+	return c.sim.LatestPrice(ctx)
+}
+
+func (c *client) PostLimitOrder(ctx context.Context, order exchangesdk.Order) (string, error) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := c.newOrderId(exchangesdk.OrderStatus{
+		State: exchangesdk.OrderStateInOrderBook,
+		Type:  order.Type,
+	})
+
+	c.schedule(id, false, func() {
+		simId, err := c.sim.PostLimitOrder(ctx, order)
+		c.arrived(id, simId, err)
+	})
+
+	return id, nil
+}
+
+func (c *client) PostStopLimitOrder(ctx context.Context, order exchangesdk.StopLimitOrder) (string, error) {
+
+	orderType := exchangesdk.OrderTypeBid
+	if order.Side == exchangesdk.OrderBookSideAsk {
+		orderType = exchangesdk.OrderTypeAsk
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := c.newOrderId(exchangesdk.OrderStatus{
+		State: exchangesdk.OrderStateAwaitingTrigger,
+		Type:  orderType,
+	})
+
+	c.schedule(id, false, func() {
+		simId, err := c.sim.PostStopLimitOrder(ctx, order)
+		c.arrived(id, simId, err)
+	})
+
+	return id, nil
+}
+
+func (c *client) newOrderId(status exchangesdk.OrderStatus) string {
+
+	c.nextOrderId++
+	id := fmt.Sprintf("backtest-%d", c.nextOrderId)
+	c.placed[id] = status
+	return id
+}
+
+// arrived records that order id has reached the simulated exchange.
+// Orders rejected by the simulated exchange are reported as cancelled.
+func (c *client) arrived(id, simId string, err error) {
+
+	if err != nil {
+		status := c.placed[id]
+		status.State = exchangesdk.OrderStateCancelled
+		c.placed[id] = status
+		return
+	}
+
+	delete(c.placed, id)
+	c.simOrderIds[id] = simId
+	c.orderIds[simId] = id
+}
+
+// CancelOrder cancels the order once the cancellation reaches the simulated
+// exchange; if the order has been filled by then the cancellation has no
+// effect.
+func (c *client) CancelOrder(ctx context.Context, orderId string) error {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.placed[orderId]; !ok {
+		if _, ok := c.simOrderIds[orderId]; !ok {
+			return fmt.Errorf("unknown order id `%s`", orderId)
+		}
+	}
+
+	c.schedule(orderId, true, func() {
+		if simId, ok := c.simOrderIds[orderId]; ok {
+			// Errors here are orders which are already complete
+			_ = c.sim.CancelOrder(ctx, simId)
+			return
+		}
+		status := c.placed[orderId]
+		status.State = exchangesdk.OrderStateCancelled
+		c.placed[orderId] = status
+	})
+
+	return nil
+}
+
+func (c *client) GetOrderStatus(
+	ctx context.Context,
+	orderId string,
+) (exchangesdk.OrderStatus, error) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if simId, ok := c.simOrderIds[orderId]; ok {
+		return c.sim.GetOrderStatus(ctx, simId)
+	}
+
+	status, ok := c.placed[orderId]
+	if !ok {
+		return exchangesdk.OrderStatus{}, fmt.Errorf("unknown order id `%s`", orderId)
+	}
+	return status, nil
+}
+
+func (c *client) GetTrades(ctx context.Context, page int64) ([]exchangesdk.Trade, error) {
+
+	trades, err := c.sim.GetTrades(ctx, page)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := range trades {
+		trades[i].OrderId = c.orderIds[trades[i].OrderId]
+	}
+	return trades, nil
+}
+
+func (c *client) MakerFee() decimal.Decimal {
+
+	return c.sim.MakerFee()
+}
+
+func (c *client) TakerFee() decimal.Decimal {
+
+	return c.sim.TakerFee()
+}
+
+func (c *client) CounterPrecision() int32 {
+
+	return c.sim.CounterPrecision()
+}
+
+func (c *client) BasePrecision() int32 {
+
+	return c.sim.BasePrecision()
+}
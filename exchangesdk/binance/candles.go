@@ -0,0 +1,187 @@
+package binance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/requestutil"
+)
+
+const (
+	maxKlinesPerRequest = 1000
+)
+
+var _ exchangesdk.HistoricalCandlesClient = (*client)(nil)
+
+func (c *client) HistoricalCandles(
+	ctx context.Context,
+	interval time.Duration,
+	from time.Time,
+	to time.Time,
+) ([]exchangesdk.Candle, error) {
+
+	intervalStr, err := klineInterval(interval)
+	if err != nil {
+		return nil, err
+	}
+
+	candles := make([]exchangesdk.Candle, 0)
+	start := from
+	for start.Before(to) {
+
+		page, err := c.getKlines(ctx, intervalStr, start, to)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, candle := range page {
+			if !candle.Timestamp.Before(to) {
+				continue
+			}
+			candles = append(candles, candle)
+		}
+
+		if len(page) < maxKlinesPerRequest {
+			break
+		}
+
+		start = page[len(page)-1].Timestamp.Add(interval)
+	}
+
+	return candles, nil
+}
+
+func (c *client) getKlines(
+	ctx context.Context,
+	interval string,
+	start time.Time,
+	end time.Time,
+) ([]exchangesdk.Candle, error) {
+
+	path, err := requestutil.FullPath(c.baseUrl, "/api/v3/klines")
+	if err != nil {
+		return nil, err
+	}
+	values := url.Values{}
+	values.Add("symbol", c.tradingPair)
+	values.Add("interval", interval)
+	values.Add("startTime", strconv.FormatInt(timeToMs(start), 10))
+	values.Add("endTime", strconv.FormatInt(timeToMs(end)-1, 10))
+	values.Add("limit", strconv.Itoa(maxKlinesPerRequest))
+	path.RawQuery = values.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", path.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := GetBody(c.httpClient.Do(req))
+	if err != nil {
+		return nil, err
+	}
+
+	var klines [][]json.RawMessage
+	err = json.Unmarshal(body, &klines)
+	if err != nil {
+		return nil, err
+	}
+
+	candles := make([]exchangesdk.Candle, 0, len(klines))
+	for _, kline := range klines {
+		candle, err := decodeKline(kline)
+		if err != nil {
+			return nil, err
+		}
+		candles = append(candles, candle)
+	}
+
+	return candles, nil
+}
+
+// decodeKline converts a single binance kline, which is an array of the form
+// [openTime, open, high, low, close, volume, closeTime, ...], to a Candle
+func decodeKline(kline []json.RawMessage) (exchangesdk.Candle, error) {
+
+	if len(kline) < 6 {
+		return exchangesdk.Candle{}, fmt.Errorf(
+			"kline has %d fields; expected at least 6",
+			len(kline),
+		)
+	}
+
+	var openTimeMs int64
+	err := json.Unmarshal(kline[0], &openTimeMs)
+	if err != nil {
+		return exchangesdk.Candle{}, err
+	}
+
+	var values [5]float64
+	for i := range values {
+		var s string
+		err := json.Unmarshal(kline[i+1], &s)
+		if err != nil {
+			return exchangesdk.Candle{}, err
+		}
+
+		values[i], err = strconv.ParseFloat(s, 64)
+		if err != nil {
+			return exchangesdk.Candle{}, err
+		}
+	}
+
+	return exchangesdk.Candle{
+		Timestamp: time.Unix(0, openTimeMs*int64(time.Millisecond)),
+		Open:      values[0],
+		High:      values[1],
+		Low:       values[2],
+		Close:     values[3],
+		Volume:    values[4],
+	}, nil
+}
+
+func klineInterval(interval time.Duration) (string, error) {
+
+	switch interval {
+	case time.Minute:
+		return "1m", nil
+	case 3 * time.Minute:
+		return "3m", nil
+	case 5 * time.Minute:
+		return "5m", nil
+	case 15 * time.Minute:
+		return "15m", nil
+	case 30 * time.Minute:
+		return "30m", nil
+	case time.Hour:
+		return "1h", nil
+	case 2 * time.Hour:
+		return "2h", nil
+	case 4 * time.Hour:
+		return "4h", nil
+	case 6 * time.Hour:
+		return "6h", nil
+	case 8 * time.Hour:
+		return "8h", nil
+	case 12 * time.Hour:
+		return "12h", nil
+	case 24 * time.Hour:
+		return "1d", nil
+	case 3 * 24 * time.Hour:
+		return "3d", nil
+	case 7 * 24 * time.Hour:
+		return "1w", nil
+	default:
+		return "", fmt.Errorf("candle interval %s is not supported by Binance", interval)
+	}
+}
+
+func timeToMs(t time.Time) int64 {
+
+	return t.Round(time.Millisecond).UnixNano() / 1e6
+}
@@ -0,0 +1,237 @@
+package binance_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/binance"
+	"github.com/thecodedproject/crypto/exchangesdk/requestutil"
+)
+
+func klinesJson(startMs int64, intervalMs int64, n int) string {
+
+	klines := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		openTime := startMs + int64(i)*intervalMs
+		klines = append(klines, fmt.Sprintf(
+			"[%d, \"%d.1\", \"%d.2\", \"%d.3\", \"%d.4\", \"%d.5\", %d, \"0\", 1, \"0\", \"0\", \"0\"]",
+			openTime,
+			i, i, i, i, i,
+			openTime+intervalMs-1,
+		))
+	}
+	return "[" + strings.Join(klines, ",") + "]"
+}
+
+func TestHistoricalCandlesWithUnsupportedIntervalReturnsError(t *testing.T) {
+
+	c := binance.NewClientForTesting(t, "k", "s", "BTCEUR", func(req *http.Request) *http.Response {
+
+		t.Fatal("unexpected request")
+		return nil
+	})
+
+	_, err := c.HistoricalCandles(
+		context.Background(),
+		7*time.Second,
+		time.Unix(0, 0),
+		time.Unix(1000, 0),
+	)
+	require.Error(t, err)
+}
+
+func TestHistoricalCandlesSinglePage(t *testing.T) {
+
+	from := time.Unix(1600000000, 0)
+	to := from.Add(3 * time.Minute)
+
+	handlerCalls := 0
+	c := binance.NewClientForTesting(t, "k", "s", "BTCEUR", func(req *http.Request) *http.Response {
+
+		handlerCalls++
+		assert.Equal(t, "GET", req.Method)
+		assert.Contains(t, req.URL.String(), "https://api.binance.com/api/v3/klines")
+
+		values := req.URL.Query()
+		assert.Equal(t, "BTCEUR", values.Get("symbol"))
+		assert.Equal(t, "1m", values.Get("interval"))
+		assert.Equal(t, "1600000000000", values.Get("startTime"))
+		assert.Equal(t, "1600000179999", values.Get("endTime"))
+		assert.Equal(t, "1000", values.Get("limit"))
+
+		return &http.Response{
+			StatusCode: 200,
+			Body: requestutil.ResBodyFromJsonf(
+				t,
+				"%s",
+				klinesJson(1600000000000, 60000, 3),
+			),
+		}
+	})
+
+	candles, err := c.HistoricalCandles(context.Background(), time.Minute, from, to)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, handlerCalls)
+	expected := []exchangesdk.Candle{
+		{
+			Timestamp: from,
+			Open:      0.1,
+			High:      0.2,
+			Low:       0.3,
+			Close:     0.4,
+			Volume:    0.5,
+		},
+		{
+			Timestamp: from.Add(time.Minute),
+			Open:      1.1,
+			High:      1.2,
+			Low:       1.3,
+			Close:     1.4,
+			Volume:    1.5,
+		},
+		{
+			Timestamp: from.Add(2 * time.Minute),
+			Open:      2.1,
+			High:      2.2,
+			Low:       2.3,
+			Close:     2.4,
+			Volume:    2.5,
+		},
+	}
+	require.Equal(t, len(expected), len(candles))
+	for i := range expected {
+		assert.True(t, expected[i].Timestamp.Equal(candles[i].Timestamp))
+		assert.Equal(t, expected[i].Open, candles[i].Open)
+		assert.Equal(t, expected[i].High, candles[i].High)
+		assert.Equal(t, expected[i].Low, candles[i].Low)
+		assert.Equal(t, expected[i].Close, candles[i].Close)
+		assert.Equal(t, expected[i].Volume, candles[i].Volume)
+	}
+}
+
+func TestHistoricalCandlesPaginatesOverMultipleRequests(t *testing.T) {
+
+	fromMs := int64(1600000000000)
+	intervalMs := int64(60000)
+	from := time.Unix(0, fromMs*int64(time.Millisecond))
+	to := from.Add(1500 * time.Minute)
+
+	var startTimes []string
+	c := binance.NewClientForTesting(t, "k", "s", "BTCEUR", func(req *http.Request) *http.Response {
+
+		values := req.URL.Query()
+		startTimes = append(startTimes, values.Get("startTime"))
+
+		startMs, err := strconv.ParseInt(values.Get("startTime"), 10, 64)
+		require.NoError(t, err)
+		endMs, err := strconv.ParseInt(values.Get("endTime"), 10, 64)
+		require.NoError(t, err)
+
+		n := int((endMs - startMs + 1) / intervalMs)
+		if n > 1000 {
+			n = 1000
+		}
+
+		return &http.Response{
+			StatusCode: 200,
+			Body: requestutil.ResBodyFromJsonf(
+				t,
+				"%s",
+				klinesJson(startMs, intervalMs, n),
+			),
+		}
+	})
+
+	candles, err := c.HistoricalCandles(context.Background(), time.Minute, from, to)
+	require.NoError(t, err)
+
+	assert.Equal(
+		t,
+		[]string{
+			strconv.FormatInt(fromMs, 10),
+			strconv.FormatInt(fromMs+1000*intervalMs, 10),
+		},
+		startTimes,
+	)
+	require.Equal(t, 1500, len(candles))
+	for i, candle := range candles {
+		assert.True(t, from.Add(time.Duration(i)*time.Minute).Equal(candle.Timestamp))
+	}
+}
+
+func TestHistoricalCandlesStopsPaginatingWhenContextCancelled(t *testing.T) {
+
+	fromMs := int64(1600000000000)
+	intervalMs := int64(60000)
+	from := time.Unix(0, fromMs*int64(time.Millisecond))
+	to := from.Add(5000 * time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		requests++
+		startMs, err := strconv.ParseInt(req.URL.Query().Get("startTime"), 10, 64)
+		require.NoError(t, err)
+
+		// The download is cancelled while the first page is being fetched
+		cancel()
+		fmt.Fprint(w, klinesJson(startMs, intervalMs, 1000))
+	}))
+	defer server.Close()
+
+	c, err := binance.NewClient(
+		"k",
+		"s",
+		crypto.PairBTCEUR,
+		binance.WithBaseUrl(server.URL),
+	)
+	require.NoError(t, err)
+
+	_, err = c.HistoricalCandles(ctx, time.Minute, from, to)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.Canceled), err)
+
+	// Close waits for any request still being handled
+	server.Close()
+	assert.Equal(t, 1, requests)
+}
+
+func TestHistoricalCandlesWhenBinanceReturnsErrorReturnsError(t *testing.T) {
+
+	errorMsg := "some error"
+	c := binance.NewClientForTesting(t, "k", "s", "BTCEUR", func(req *http.Request) *http.Response {
+
+		return &http.Response{
+			StatusCode: 400,
+			Body: requestutil.ResBodyFromJsonf(
+				t,
+				"{\"code\": -1121, \"msg\": \"%s\"}",
+				errorMsg,
+			),
+		}
+	})
+
+	_, err := c.HistoricalCandles(
+		context.Background(),
+		time.Hour,
+		time.Unix(0, 0),
+		time.Unix(10000, 0),
+	)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), errorMsg)
+}
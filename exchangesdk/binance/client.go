@@ -14,23 +14,26 @@ import (
 	"testing"
 	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/shopspring/decimal"
 	"github.com/thecodedproject/crypto"
 	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/logging"
 	"github.com/thecodedproject/crypto/exchangesdk/requestutil"
 	utiltime "github.com/thecodedproject/crypto/util/time"
 )
 
-const (
-	baseUrl = "https://api.binance.com"
-)
-
 type client struct {
+	provider    crypto.ApiProvider
+	baseUrl     string
+	wsUrl       string
 	apiKey      string
 	apiSecret   string
 	httpClient  *http.Client
+	dialer      *websocket.Dialer
 	tradingPair string
 	pair        crypto.Pair
+	logger      logging.Logger
 }
 
 var _ exchangesdk.Client = (*client)(nil)
@@ -39,6 +42,7 @@ func NewClient(
 	apiKey string,
 	apiSecret string,
 	pair crypto.Pair,
+	opts ...Option,
 ) (*client, error) {
 
 	tradingPair, err := getBinanceTradingPair(pair)
@@ -46,12 +50,19 @@ func NewClient(
 		return nil, err
 	}
 
+	o := resolveOptions(opts)
+
 	return &client{
+		provider:    o.provider,
+		baseUrl:     o.baseUrl,
+		wsUrl:       o.wsUrl,
 		apiKey:      apiKey,
 		apiSecret:   apiSecret,
-		httpClient:  http.DefaultClient,
+		httpClient:  o.client(),
+		dialer:      o.dialer(),
 		tradingPair: tradingPair,
 		pair: pair,
+		logger:      o.logger,
 	}, nil
 }
 
@@ -64,12 +75,17 @@ func NewClientForTesting(
 ) *client {
 
 	return &client{
+		provider:  crypto.ApiProviderBinance,
+		baseUrl:   defaultBaseUrl,
+		wsUrl:     defaultWsUrl,
 		apiKey:    apiKey,
 		apiSecret: apiSecret,
 		httpClient: &http.Client{
 			Transport: requestutil.RoundTripFunc(handler),
 		},
+		dialer:      websocket.DefaultDialer,
 		tradingPair: tradingPair,
+		logger:      logging.Std,
 	}
 }
 
@@ -96,14 +112,17 @@ func getBinanceTradingPair(pair crypto.Pair) (string, error) {
 func (c *client) Exchange() crypto.Exchange {
 
 	return crypto.Exchange{
-		Provider: crypto.ApiProviderBinance,
+		Provider: c.provider,
 		Pair:     c.pair,
 	}
 }
 
 func (c *client) LatestPrice(ctx context.Context) (decimal.Decimal, error) {
 
-	path := requestutil.FullPath(baseUrl, "/api/v3/ticker/price")
+	path, err := requestutil.FullPath(c.baseUrl, "/api/v3/ticker/price")
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
 	values := url.Values{}
 	values.Add("symbol", c.tradingPair)
 	path.RawQuery = values.Encode()
@@ -144,6 +163,7 @@ func (c *client) PostLimitOrder(
 
 	body, err := requestToOrderEndpointWithAuth(
 		"POST",
+		c.baseUrl,
 		c.httpClient,
 		c.apiKey,
 		c.apiSecret,
@@ -183,6 +203,7 @@ func (c *client) PostStopLimitOrder(
 
 	body, err := requestToOrderEndpointWithAuth(
 		"POST",
+		c.baseUrl,
 		c.httpClient,
 		c.apiKey,
 		c.apiSecret,
@@ -212,6 +233,7 @@ func (c *client) CancelOrder(ctx context.Context, orderId string) error {
 
 	_, err := requestToOrderEndpointWithAuth(
 		"DELETE",
+		c.baseUrl,
 		c.httpClient,
 		c.apiKey,
 		c.apiSecret,
@@ -231,6 +253,7 @@ func (c *client) GetOrderStatus(
 
 	body, err := requestToOrderEndpointWithAuth(
 		"GET",
+		c.baseUrl,
 		c.httpClient,
 		c.apiKey,
 		c.apiSecret,
@@ -254,18 +277,7 @@ func (c *client) GetOrderStatus(
 		return exchangesdk.OrderStatus{}, err
 	}
 
-	state := exchangesdk.OrderStateUnknown
-	if res.Status == "NEW" {
-		if res.IsWorking {
-			state = exchangesdk.OrderStateInOrderBook
-		} else {
-			state = exchangesdk.OrderStateAwaitingTrigger
-		}
-	} else if res.Status == "PARTIALLY_FILLED" {
-		state = exchangesdk.OrderStateInOrderBook
-	} else if res.Status == "FILLED" {
-		state = exchangesdk.OrderStateFilled
-	}
+	state := orderState(res.Status, res.IsWorking)
 
 	orderType := exchangesdk.OrderTypeBid
 	if res.Side == "SELL" {
@@ -280,6 +292,27 @@ func (c *client) GetOrderStatus(
 	}, nil
 }
 
+// orderState converts a Binance order status to an OrderState; orders which
+// are NEW but not yet working are stop orders awaiting their trigger
+func orderState(status string, isWorking bool) exchangesdk.OrderState {
+
+	switch status {
+	case "NEW":
+		if isWorking {
+			return exchangesdk.OrderStateInOrderBook
+		}
+		return exchangesdk.OrderStateAwaitingTrigger
+	case "PARTIALLY_FILLED":
+		return exchangesdk.OrderStateInOrderBook
+	case "FILLED":
+		return exchangesdk.OrderStateFilled
+	case "CANCELED", "REJECTED", "EXPIRED":
+		return exchangesdk.OrderStateCancelled
+	default:
+		return exchangesdk.OrderStateUnknown
+	}
+}
+
 func (c *client) GetTrades(ctx context.Context, page int64) ([]exchangesdk.Trade, error) {
 
 	panic("not implemented")
@@ -307,6 +340,7 @@ func (l *client) BasePrecision() int32 {
 
 func requestToOrderEndpointWithAuth(
 	reqMethod string,
+	baseUrl string,
 	httpClient *http.Client,
 	apiKey string,
 	apiSecret string,
@@ -314,14 +348,10 @@ func requestToOrderEndpointWithAuth(
 	values url.Values,
 ) ([]byte, error) {
 
-	path := requestutil.FullPath(baseUrl, "/api/v3/order")
-
-	nowMs := utiltime.Now().Round(time.Millisecond).UnixNano() / 1e6
-	timestampStr := strconv.FormatInt(nowMs, 10)
-	values.Add("timestamp", timestampStr)
-	values.Add("symbol", pair)
-
-	path.RawQuery = values.Encode()
+	path, err := orderEndpointPath(baseUrl, "/api/v3/order", pair, values)
+	if err != nil {
+		return nil, err
+	}
 
 	return requestWithHmacAuth(
 		reqMethod,
@@ -332,6 +362,29 @@ func requestToOrderEndpointWithAuth(
 	)
 }
 
+// orderEndpointPath returns the url of an order endpoint with values, the
+// symbol and a timestamp in its query
+func orderEndpointPath(
+	baseUrl string,
+	endpoint string,
+	pair string,
+	values url.Values,
+) (*url.URL, error) {
+
+	path, err := requestutil.FullPath(baseUrl, endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	nowMs := utiltime.Now().Round(time.Millisecond).UnixNano() / 1e6
+	timestampStr := strconv.FormatInt(nowMs, 10)
+	values.Add("timestamp", timestampStr)
+	values.Add("symbol", pair)
+
+	path.RawQuery = values.Encode()
+	return path, nil
+}
+
 func requestWithHmacAuth(
 	reqMethod string,
 	c *http.Client,
@@ -340,6 +393,23 @@ func requestWithHmacAuth(
 	fullUrl *url.URL,
 ) ([]byte, error) {
 
+	req, err := newRequestWithHmacAuth(reqMethod, key, secret, fullUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	return GetBody(c.Do(req))
+}
+
+// newRequestWithHmacAuth returns a request to fullUrl with its query signed
+// by secret
+func newRequestWithHmacAuth(
+	reqMethod string,
+	key string,
+	secret string,
+	fullUrl *url.URL,
+) (*http.Request, error) {
+
 	msgToSign := fullUrl.RawQuery
 
 	mac := hmac.New(sha256.New, []byte(secret))
@@ -359,7 +429,7 @@ func requestWithHmacAuth(
 
 	req.Header.Add("X-MBX-APIKEY", key)
 
-	return GetBody(c.Do(req))
+	return req, nil
 }
 
 func GetBody(res *http.Response, err error) ([]byte, error) {
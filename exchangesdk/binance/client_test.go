@@ -10,6 +10,7 @@ import (
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/thecodedproject/crypto"
 	"github.com/thecodedproject/crypto/exchangesdk"
 	"github.com/thecodedproject/crypto/exchangesdk/binance"
 	"github.com/thecodedproject/crypto/exchangesdk/requestutil"
@@ -55,6 +56,54 @@ func TestLatestPriceWhenBinanceReturns200(t *testing.T) {
 	util.LogicallyEqual(t, decimal.New(1234, -1), val)
 }
 
+func TestClientWithTestnetOption(t *testing.T) {
+
+	handlerCalled := false
+	httpClient := &http.Client{
+		Transport: requestutil.RoundTripFunc(func(req *http.Request) *http.Response {
+
+			handlerCalled = true
+			assert.Equal(
+				t,
+				"https://testnet.binance.vision/api/v3/ticker/price?symbol=BTCEUR",
+				req.URL.String(),
+			)
+
+			return &http.Response{
+				StatusCode: 200,
+				Body: requestutil.ResBodyFromJsonf(
+					t,
+					"{\"price\": \"123.4\"}",
+				),
+			}
+		}),
+	}
+
+	c, err := binance.NewClient(
+		"k",
+		"s",
+		crypto.PairBTCEUR,
+		binance.WithTestnet(),
+		binance.WithHttpClient(httpClient),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(
+		t,
+		crypto.Exchange{
+			Provider: crypto.ApiProviderBinanceTestnet,
+			Pair:     crypto.PairBTCEUR,
+		},
+		c.Exchange(),
+	)
+
+	val, err := c.LatestPrice(context.Background())
+	require.NoError(t, err)
+
+	assert.True(t, handlerCalled)
+	util.LogicallyEqual(t, decimal.New(1234, -1), val)
+}
+
 func TestLatestPriceWhenBinanceReturns400WithError(t *testing.T) {
 
 	pair := "BTCEUR"
@@ -484,6 +533,16 @@ func TestSuccessfulGetOrderStatus(t *testing.T) {
 				FillAmountCounter: decimal.New(389, -2),
 			},
 		},
+		{
+			name:    "Ask order, cancelled, partial fill",
+			resBody: "{\"executedQty\": \"0.5\", \"status\": \"CANCELED\", \"side\": \"SELL\", \"isWorking\": true, \"cummulativeQuoteQty\": \"1.5\"}",
+			expectedStatus: exchangesdk.OrderStatus{
+				State:             exchangesdk.OrderStateCancelled,
+				Type:              exchangesdk.OrderTypeAsk,
+				FillAmountBase:    decimal.New(5, -1),
+				FillAmountCounter: decimal.New(15, -1),
+			},
+		},
 	}
 
 	for _, test := range testCases {
@@ -537,3 +596,290 @@ func TestSuccessfulGetOrderStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestReplaceOrder(t *testing.T) {
+
+	testCases := []struct {
+		name           string
+		resStatus      int
+		resBody        string
+		expectedResult exchangesdk.ReplaceResult
+		expectedErr    string
+	}{
+		{
+			name:      "Both legs succeed",
+			resStatus: 200,
+			resBody: `{
+				"cancelResult": "SUCCESS",
+				"newOrderResult": "SUCCESS",
+				"cancelResponse": {
+					"origClientOrderId": "order1",
+					"status": "CANCELED",
+					"side": "BUY",
+					"executedQty": "0.25",
+					"cummulativeQuoteQty": "25.5"
+				},
+				"newOrderResponse": {
+					"clientOrderId": "order2",
+					"status": "NEW"
+				}
+			}`,
+			expectedResult: exchangesdk.ReplaceResult{
+				Cancelled: true,
+				Original: exchangesdk.OrderStatus{
+					State:             exchangesdk.OrderStateCancelled,
+					Type:              exchangesdk.OrderTypeBid,
+					FillAmountBase:    decimal.RequireFromString("0.25"),
+					FillAmountCounter: decimal.RequireFromString("25.5"),
+				},
+				NewOrderId: "order2",
+			},
+		},
+		{
+			name:      "Cancel fails so new order is not attempted",
+			resStatus: 400,
+			resBody: `{
+				"code": -2022,
+				"msg": "Order cancel-replace failed.",
+				"data": {
+					"cancelResult": "FAILURE",
+					"newOrderResult": "NOT_ATTEMPTED",
+					"cancelResponse": {
+						"code": -2011,
+						"msg": "Unknown order sent."
+					},
+					"newOrderResponse": null
+				}
+			}`,
+			expectedErr: "-2022 Order cancel-replace failed.; cancel: FAILURE (Unknown order sent.); new order: NOT_ATTEMPTED",
+		},
+		{
+			name:      "Cancel succeeds and new order fails",
+			resStatus: 409,
+			resBody: `{
+				"code": -2021,
+				"msg": "Order cancel-replace partially failed.",
+				"data": {
+					"cancelResult": "SUCCESS",
+					"newOrderResult": "FAILURE",
+					"cancelResponse": {
+						"origClientOrderId": "order1",
+						"status": "CANCELED",
+						"side": "SELL",
+						"executedQty": "0",
+						"cummulativeQuoteQty": "0"
+					},
+					"newOrderResponse": {
+						"code": -2010,
+						"msg": "Account has insufficient balance for requested action."
+					}
+				}
+			}`,
+			expectedResult: exchangesdk.ReplaceResult{
+				Cancelled: true,
+				Original: exchangesdk.OrderStatus{
+					State:             exchangesdk.OrderStateCancelled,
+					Type:              exchangesdk.OrderTypeAsk,
+					FillAmountBase:    decimal.RequireFromString("0"),
+					FillAmountCounter: decimal.RequireFromString("0"),
+				},
+			},
+			expectedErr: "new order: FAILURE (Account has insufficient balance for requested action.)",
+		},
+		{
+			name:        "Error without leg results",
+			resStatus:   400,
+			resBody:     `{"code": -1100, "msg": "Illegal characters found in parameter."}`,
+			expectedErr: "Illegal characters found in parameter.",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+
+			nowTime := time.Unix(13876, 0)
+			reset := utiltime.SetTimeNowForTesting(t, nowTime)
+			defer reset()
+
+			c := binance.NewClientForTesting(t, "k", "s", "BTCEUR", func(req *http.Request) *http.Response {
+
+				assert.Equal(t, "https://api.binance.com/api/v3/order/cancelReplace", req.URL.Scheme+"://"+req.URL.Host+req.URL.Path)
+				assert.Equal(t, "POST", req.Method)
+
+				values := req.URL.Query()
+				assert.NotEmpty(t, values.Get("signature"))
+				assert.Equal(t, timeAsMsStr(nowTime), values.Get("timestamp"))
+				assert.Equal(t, "BTCEUR", values.Get("symbol"))
+				assert.Equal(t, "STOP_ON_FAILURE", values.Get("cancelReplaceMode"))
+				assert.Equal(t, "order1", values.Get("cancelOrigClientOrderId"))
+				assert.Equal(t, "LIMIT", values.Get("type"))
+				assert.Equal(t, "SELL", values.Get("side"))
+				assert.Equal(t, "GTC", values.Get("timeInForce"))
+				assert.Equal(t, "1.5", values.Get("quantity"))
+				assert.Equal(t, "101.25", values.Get("price"))
+				assert.Equal(t, "k", req.Header.Get("X-MBX-APIKEY"))
+
+				return &http.Response{
+					StatusCode: test.resStatus,
+					Body:       requestutil.ResBodyFromJsonf(t, test.resBody),
+				}
+			})
+
+			res, err := c.ReplaceOrder(context.Background(), "order1", exchangesdk.Order{
+				Type:   exchangesdk.OrderTypeAsk,
+				Price:  decimal.RequireFromString("101.25"),
+				Volume: decimal.RequireFromString("1.5"),
+			})
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, test.expectedResult.Cancelled, res.Cancelled)
+			assert.Equal(t, test.expectedResult.NewOrderId, res.NewOrderId)
+			assert.Equal(t, test.expectedResult.Original.State, res.Original.State)
+			assert.Equal(t, test.expectedResult.Original.Type, res.Original.Type)
+			assert.True(t, test.expectedResult.Original.FillAmountBase.Equal(res.Original.FillAmountBase))
+			assert.True(t, test.expectedResult.Original.FillAmountCounter.Equal(res.Original.FillAmountCounter))
+		})
+	}
+}
+
+func TestPostOCOOrder(t *testing.T) {
+
+	nowTime := time.Unix(13876, 0)
+	reset := utiltime.SetTimeNowForTesting(t, nowTime)
+	defer reset()
+
+	handlerCalled := false
+	c := binance.NewClientForTesting(t, "k", "s", "BTCEUR", func(req *http.Request) *http.Response {
+
+		handlerCalled = true
+		assert.Equal(t, "https://api.binance.com/api/v3/order/oco", req.URL.Scheme+"://"+req.URL.Host+req.URL.Path)
+		assert.Equal(t, "POST", req.Method)
+
+		values := req.URL.Query()
+		assert.NotEmpty(t, values.Get("signature"))
+		assert.Equal(t, timeAsMsStr(nowTime), values.Get("timestamp"))
+		assert.Equal(t, "BTCEUR", values.Get("symbol"))
+		assert.Equal(t, "SELL", values.Get("side"))
+		assert.Equal(t, "0.5", values.Get("quantity"))
+		assert.Equal(t, "110", values.Get("price"))
+		assert.Equal(t, "95", values.Get("stopPrice"))
+		assert.Equal(t, "94.5", values.Get("stopLimitPrice"))
+		assert.Equal(t, "GTC", values.Get("stopLimitTimeInForce"))
+		assert.Equal(t, "k", req.Header.Get("X-MBX-APIKEY"))
+
+		return &http.Response{
+			StatusCode: 200,
+			Body: requestutil.ResBodyFromJsonf(t, `{
+				"orderListId": 0,
+				"listClientOrderId": "list1",
+				"orders": [
+					{"symbol": "BTCEUR", "orderId": 2, "clientOrderId": "stop1"},
+					{"symbol": "BTCEUR", "orderId": 3, "clientOrderId": "limit1"}
+				],
+				"orderReports": [
+					{"clientOrderId": "stop1", "type": "STOP_LOSS_LIMIT", "status": "NEW"},
+					{"clientOrderId": "limit1", "type": "LIMIT_MAKER", "status": "NEW"}
+				]
+			}`),
+		}
+	})
+
+	ids, err := c.PostOCOOrder(context.Background(), exchangesdk.OCOOrder{
+		Side:           exchangesdk.OrderBookSideAsk,
+		Volume:         decimal.RequireFromString("0.5"),
+		LimitPrice:     decimal.RequireFromString("110"),
+		StopPrice:      decimal.RequireFromString("95"),
+		StopLimitPrice: decimal.RequireFromString("94.5"),
+	})
+	require.NoError(t, err)
+	assert.True(t, handlerCalled)
+	assert.Equal(t, exchangesdk.OCOOrderIds{
+		LimitOrderId: "limit1",
+		StopOrderId:  "stop1",
+	}, ids)
+}
+
+func TestPostOCOOrderWithInvalidPricesReturnsError(t *testing.T) {
+
+	c := binance.NewClientForTesting(t, "k", "s", "BTCEUR", func(req *http.Request) *http.Response {
+
+		require.Fail(t, "unexpected request")
+		return nil
+	})
+
+	_, err := c.PostOCOOrder(context.Background(), exchangesdk.OCOOrder{
+		Side:       exchangesdk.OrderBookSideBid,
+		Volume:     decimal.RequireFromString("0.5"),
+		LimitPrice: decimal.RequireFromString("110"),
+		StopPrice:  decimal.RequireFromString("95"),
+	})
+	require.Error(t, err)
+}
+
+func TestOpenOrders(t *testing.T) {
+
+	nowTime := time.Unix(13876, 0)
+	reset := utiltime.SetTimeNowForTesting(t, nowTime)
+	defer reset()
+
+	handlerCalled := false
+	c := binance.NewClientForTesting(t, "k", "s", "BTCEUR", func(req *http.Request) *http.Response {
+
+		handlerCalled = true
+		assert.Equal(t, "https://api.binance.com/api/v3/openOrders", req.URL.Scheme+"://"+req.URL.Host+req.URL.Path)
+		assert.Equal(t, "GET", req.Method)
+
+		values := req.URL.Query()
+		assert.NotEmpty(t, values.Get("signature"))
+		assert.Equal(t, timeAsMsStr(nowTime), values.Get("timestamp"))
+		assert.Equal(t, "BTCEUR", values.Get("symbol"))
+		assert.Equal(t, "k", req.Header.Get("X-MBX-APIKEY"))
+
+		return &http.Response{
+			StatusCode: 200,
+			Body: requestutil.ResBodyFromJsonf(t, `[
+				{
+					"symbol": "BTCEUR",
+					"clientOrderId": "bid1",
+					"price": "99.5",
+					"origQty": "1.5",
+					"executedQty": "0.5",
+					"status": "PARTIALLY_FILLED",
+					"side": "BUY",
+					"isWorking": true
+				},
+				{
+					"symbol": "BTCEUR",
+					"clientOrderId": "stop1",
+					"price": "94.5",
+					"origQty": "1",
+					"executedQty": "0",
+					"status": "NEW",
+					"side": "SELL",
+					"isWorking": false
+				}
+			]`),
+		}
+	})
+
+	open, err := c.OpenOrders(context.Background())
+	require.NoError(t, err)
+	assert.True(t, handlerCalled)
+
+	require.Len(t, open, 2)
+	assert.Equal(t, "bid1", open[0].Id)
+	assert.Equal(t, exchangesdk.OrderBookSideBid, open[0].Side)
+	assert.Equal(t, exchangesdk.OrderStateInOrderBook, open[0].State)
+	assert.True(t, decimal.RequireFromString("99.5").Equal(open[0].LimitPrice))
+	assert.True(t, decimal.RequireFromString("1.5").Equal(open[0].Volume))
+	assert.True(t, decimal.RequireFromString("0.5").Equal(open[0].FillAmountBase))
+
+	assert.Equal(t, "stop1", open[1].Id)
+	assert.Equal(t, exchangesdk.OrderBookSideAsk, open[1].Side)
+	assert.Equal(t, exchangesdk.OrderStateAwaitingTrigger, open[1].State)
+}
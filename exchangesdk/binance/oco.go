@@ -0,0 +1,90 @@
+package binance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/thecodedproject/crypto/exchangesdk"
+)
+
+var _ exchangesdk.OCOClient = (*client)(nil)
+
+// PostOCOOrder places an OCO order list, of a LIMIT_MAKER leg at
+// o.LimitPrice and a STOP_LOSS_LIMIT leg at o.StopPrice
+func (c *client) PostOCOOrder(
+	ctx context.Context,
+	o exchangesdk.OCOOrder,
+) (exchangesdk.OCOOrderIds, error) {
+
+	err := o.Validate()
+	if err != nil {
+		return exchangesdk.OCOOrderIds{}, err
+	}
+
+	side, err := sideFromOrderBookSide(o.Side)
+	if err != nil {
+		return exchangesdk.OCOOrderIds{}, err
+	}
+
+	values := url.Values{}
+	values.Add("side", side)
+	values.Add("quantity", o.Volume.String())
+	values.Add("price", o.LimitPrice.String())
+	values.Add("stopPrice", o.StopPrice.String())
+	values.Add("stopLimitPrice", o.StopLimitPrice.String())
+	values.Add("stopLimitTimeInForce", "GTC")
+
+	path, err := orderEndpointPath(
+		c.baseUrl,
+		"/api/v3/order/oco",
+		c.tradingPair,
+		values,
+	)
+	if err != nil {
+		return exchangesdk.OCOOrderIds{}, err
+	}
+
+	body, err := requestWithHmacAuth(
+		"POST",
+		c.httpClient,
+		c.apiKey,
+		c.apiSecret,
+		path,
+	)
+	if err != nil {
+		return exchangesdk.OCOOrderIds{}, err
+	}
+
+	res := struct {
+		OrderReports []struct {
+			ClientOrderId string `json:"clientOrderId"`
+			Type          string `json:"type"`
+		} `json:"orderReports"`
+	}{}
+
+	err = json.Unmarshal(body, &res)
+	if err != nil {
+		return exchangesdk.OCOOrderIds{}, err
+	}
+
+	var ids exchangesdk.OCOOrderIds
+	for _, r := range res.OrderReports {
+		switch r.Type {
+		case "LIMIT_MAKER":
+			ids.LimitOrderId = r.ClientOrderId
+		case "STOP_LOSS_LIMIT", "TAKE_PROFIT_LIMIT":
+			ids.StopOrderId = r.ClientOrderId
+		}
+	}
+
+	if ids.LimitOrderId == "" || ids.StopOrderId == "" {
+		return exchangesdk.OCOOrderIds{}, fmt.Errorf(
+			"binance OCO response is missing an order report: %s",
+			body,
+		)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,71 @@
+package binance
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+
+	"github.com/shopspring/decimal"
+	"github.com/thecodedproject/crypto/exchangesdk"
+)
+
+var _ exchangesdk.OpenOrdersClient = (*client)(nil)
+
+// OpenOrders returns the open orders on the client's pair, identified by
+// their client order ids (as with the ids returned by PostLimitOrder)
+func (c *client) OpenOrders(ctx context.Context) ([]exchangesdk.OpenOrder, error) {
+
+	path, err := orderEndpointPath(
+		c.baseUrl,
+		"/api/v3/openOrders",
+		c.tradingPair,
+		url.Values{},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := requestWithHmacAuth(
+		"GET",
+		c.httpClient,
+		c.apiKey,
+		c.apiSecret,
+		path,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []struct {
+		ClientOrderId string          `json:"clientOrderId"`
+		Price         decimal.Decimal `json:"price"`
+		OrigQty       decimal.Decimal `json:"origQty"`
+		ExecutedQty   decimal.Decimal `json:"executedQty"`
+		Status        string          `json:"status"`
+		Side          string          `json:"side"`
+		IsWorking     bool            `json:"isWorking"`
+	}
+
+	err = json.Unmarshal(body, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	open := make([]exchangesdk.OpenOrder, 0, len(res))
+	for _, o := range res {
+		side := exchangesdk.OrderBookSideBid
+		if o.Side == "SELL" {
+			side = exchangesdk.OrderBookSideAsk
+		}
+
+		open = append(open, exchangesdk.OpenOrder{
+			Id:             o.ClientOrderId,
+			Side:           side,
+			State:          orderState(o.Status, o.IsWorking),
+			LimitPrice:     o.Price,
+			Volume:         o.OrigQty,
+			FillAmountBase: o.ExecutedQty,
+		})
+	}
+	return open, nil
+}
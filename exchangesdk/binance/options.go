@@ -0,0 +1,167 @@
+package binance
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/logging"
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+const (
+	defaultBaseUrl = "https://api.binance.com"
+	defaultWsUrl   = "wss://stream.binance.com:9443"
+
+	testnetBaseUrl = "https://testnet.binance.vision"
+	testnetWsUrl   = "wss://testnet.binance.vision"
+)
+
+type options struct {
+	provider   crypto.ApiProvider
+	baseUrl    string
+	wsUrl      string
+	httpClient *http.Client
+	timeout    time.Duration
+	transport  func(http.RoundTripper) http.RoundTripper
+	observer   exchangesdk.FollowerObserver
+	clock      utiltime.Clock
+	logger     logging.Logger
+}
+
+// Option configures the endpoints used by the Binance client and market
+// follower
+type Option func(*options)
+
+func resolveOptions(opts []Option) options {
+
+	o := options{
+		provider:   crypto.ApiProviderBinance,
+		baseUrl:    defaultBaseUrl,
+		wsUrl:      defaultWsUrl,
+		httpClient: http.DefaultClient,
+		clock:      utiltime.Real,
+		logger:     logging.Std,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithTestnet points the client and market follower at the Binance spot
+// testnet; the client reports its exchange as crypto.ApiProviderBinanceTestnet.
+// Options after WithTestnet may still override the testnet endpoints.
+func WithTestnet() Option {
+
+	return func(o *options) {
+		o.provider = crypto.ApiProviderBinanceTestnet
+		o.baseUrl = testnetBaseUrl
+		o.wsUrl = testnetWsUrl
+	}
+}
+
+// WithBaseUrl sets the base URL of the REST API (e.g. a local fake exchange)
+func WithBaseUrl(baseUrl string) Option {
+
+	return func(o *options) {
+		o.baseUrl = baseUrl
+	}
+}
+
+// WithWsUrl sets the base URL of the websocket streams
+func WithWsUrl(wsUrl string) Option {
+
+	return func(o *options) {
+		o.wsUrl = wsUrl
+	}
+}
+
+// WithHttpClient sets the HTTP client used for REST requests; by default
+// http.DefaultClient is used
+func WithHttpClient(httpClient *http.Client) Option {
+
+	return func(o *options) {
+		o.httpClient = httpClient
+	}
+}
+
+// WithTimeout sets the timeout of REST requests and of websocket handshakes
+func WithTimeout(timeout time.Duration) Option {
+
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// WithClock sets the clock used by the market follower to time the rotation
+// of its websocket connections; by default the real clock is used
+func WithClock(clock utiltime.Clock) Option {
+
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// WithTransport wraps the transport of the HTTP client used for REST requests
+// (e.g. to instrument them); the configured client is left unchanged
+func WithTransport(wrap func(http.RoundTripper) http.RoundTripper) Option {
+
+	return func(o *options) {
+		o.transport = wrap
+	}
+}
+
+// WithFollowerObserver sets the observer which is notified of reconnects and sequence gaps within the
+// market follower
+func WithFollowerObserver(observer exchangesdk.FollowerObserver) Option {
+
+	return func(o *options) {
+		o.observer = observer
+	}
+}
+
+// WithLogger sets the logger which errors of the market follower and of the
+// client's order updates stream are reported to; by default logging.Std is
+// used
+func WithLogger(logger logging.Logger) Option {
+
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// client returns the HTTP client for REST requests, applying the timeout
+// (if set) to a copy so that the configured client is left unchanged
+func (o options) client() *http.Client {
+
+	c := *o.httpClient
+	if o.timeout > 0 {
+		c.Timeout = o.timeout
+	}
+	if o.transport != nil {
+		c.Transport = o.transport(transportOrDefault(c.Transport))
+	}
+	return &c
+}
+
+func (o options) dialer() *websocket.Dialer {
+
+	d := *websocket.DefaultDialer
+	if o.timeout > 0 {
+		d.HandshakeTimeout = o.timeout
+	}
+	return &d
+}
+
+// transportOrDefault returns t, or the transport which http.Client uses when
+// its transport is nil
+func transportOrDefault(t http.RoundTripper) http.RoundTripper {
+
+	if t == nil {
+		return http.DefaultTransport
+	}
+	return t
+}
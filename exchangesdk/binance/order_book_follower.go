@@ -3,26 +3,33 @@ package binance
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"math"
 	"net/http"
 	"net/url"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/thecodedproject/crypto"
 	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/logging"
 	"github.com/thecodedproject/crypto/exchangesdk/requestutil"
+	utiltime "github.com/thecodedproject/crypto/util/time"
 )
 
 const (
 	WEBSOCKET_LIFETIME = 55 * time.Minute
 )
 
+// errMissedUpdates is returned when an order book update does not follow on
+// from the last update applied to the book
+var errMissedUpdates = errors.New("missed some updates")
+
 type ExchangeConfig struct {
 	OrderBookStream string
 	TradesStream    string
@@ -40,6 +47,7 @@ func NewMarketFollower(
 	ctx context.Context,
 	wg *sync.WaitGroup,
 	pair crypto.Pair,
+	opts ...Option,
 ) (<-chan exchangesdk.OrderBook, <-chan exchangesdk.OrderBookTrade, error) {
 
 	exConf, err := getExchangeConfig(pair)
@@ -50,7 +58,9 @@ func NewMarketFollower(
 	return followForever(
 		ctx,
 		wg,
+		pair,
 		exConf,
+		resolveOptions(opts),
 	)
 }
 
@@ -110,10 +120,11 @@ func getExchangeConfig(pair crypto.Pair) (ExchangeConfig, error) {
 	}
 }
 
-func buildWsUrl(exConf ExchangeConfig) string {
+func buildWsUrl(baseWsUrl string, exConf ExchangeConfig) string {
 
 	wsUrl := fmt.Sprintf(
-		"wss://stream.binance.com:9443/stream?streams=%s/%s",
+		"%s/stream?streams=%s/%s",
+		strings.TrimRight(baseWsUrl, "/"),
 		exConf.OrderBookStream,
 		exConf.TradesStream,
 	)
@@ -123,7 +134,9 @@ func buildWsUrl(exConf ExchangeConfig) string {
 func followForever(
 	ctx context.Context,
 	wg *sync.WaitGroup,
+	pair crypto.Pair,
 	exConf ExchangeConfig,
+	opts options,
 ) (<-chan exchangesdk.OrderBook, <-chan exchangesdk.OrderBookTrade, error) {
 
 	obf := make(chan exchangesdk.OrderBook, 1)
@@ -132,44 +145,76 @@ func followForever(
 	var nextWs *websocket.Conn
 	wsAge := time.Time{}
 	nextWsAge := time.Time{}
-	wsUrl := buildWsUrl(exConf)
+	wsUrl := buildWsUrl(opts.wsUrl, exConf)
+	dialer := opts.dialer()
+	httpClient := opts.client()
+
+	logError := func(msg string, err error, args ...interface{}) {
+		fields := []interface{}{
+			logging.KeyProvider, opts.provider,
+			logging.KeyPair, pair,
+		}
+		fields = append(fields, args...)
+		fields = append(fields, logging.KeyError, err)
+		opts.logger.Error(msg, fields...)
+	}
+
+	// fail reports an error which stops the follower; both streams are
+	// closed so that consumers of either see that it has stopped
+	fail := func(msg string, err error, args ...interface{}) {
+		logError(msg, err, args...)
+		close(obf)
+		close(tradeStream)
+	}
 
 	go func() {
 
+		defer wg.Done()
+
+		// The connections are closed once the follower is cancelled, to
+		// interrupt a blocked read, or once it has stopped
+		conns := &wsConns{}
+		stopped := make(chan struct{})
+		defer close(stopped)
+		go func() {
+			select {
+			case <-ctx.Done():
+			case <-stopped:
+			}
+			conns.closeAll()
+		}()
+
 		var err error
-		ws, wsAge, err = newWebsocket(wsUrl)
+		ws, wsAge, err = newWebsocket(dialer, wsUrl, opts.clock)
 		if err != nil {
-			log.Println("OrderBookFollower error:", err)
-			close(obf)
-			wg.Done()
+			fail("Binance order book follower cannot connect", err)
 			return
 		}
-		defer ws.Close()
+		conns.add(ws)
 
-		ob, err := getLatestSnapshot(exConf.PairCode)
+		ob, err := getLatestSnapshot(httpClient, opts.baseUrl, exConf.PairCode)
 		if err != nil {
-			log.Println("OrderBookFollower error:", err)
-			close(obf)
-			wg.Done()
+			fail("Binance order book follower cannot get order book snapshot", err)
 			return
 		}
 
 		for {
-			if nextWs == nil && time.Since(wsAge) > WEBSOCKET_LIFETIME {
-				nextWs, nextWsAge, err = newWebsocket(wsUrl)
+			if nextWs == nil && opts.clock.Now().Sub(wsAge) > WEBSOCKET_LIFETIME {
+				nextWs, nextWsAge, err = newWebsocket(dialer, wsUrl, opts.clock)
 				if err != nil {
-					log.Println("OrderBookFollower error:", err)
-					close(obf)
-					wg.Done()
+					fail("Binance order book follower cannot reconnect", err)
 					return
 				}
+				conns.add(nextWs)
 			}
 
 			_, msg, err := ws.ReadMessage()
 			if err != nil {
-				log.Println("OrderBookFollower error:", err)
-				close(obf)
-				wg.Done()
+				if ctx.Err() != nil {
+					// The connection was closed after the follower was cancelled
+					return
+				}
+				fail("Binance order book follower cannot read message", err)
 				return
 			}
 
@@ -180,44 +225,56 @@ func followForever(
 
 			err = json.Unmarshal(msg, &update)
 			if err != nil {
-				log.Println("OrderBookFollower error:", err, string(msg))
-				close(obf)
-				wg.Done()
+				fail("Binance order book follower cannot decode message", err, "message", string(msg))
 				return
 			}
 
 			switch update.Stream {
 			case exConf.OrderBookStream:
 				err := handleOrderBookUpdate(&ob, update.Data, exConf)
+				if errors.Is(err, errMissedUpdates) && opts.observer != nil {
+					opts.observer.SequenceGap()
+				}
 				if err != nil {
-					log.Println("OrderBookFollower error:", err)
-					close(obf)
-					wg.Done()
+					fail(
+						"Binance order book follower cannot apply order book update",
+						err,
+						logging.KeySequence, ob.lastUpdateId,
+					)
 					return
 				}
 
-				obf <- ob.OrderBook
+				select {
+				case obf <- ob.OrderBook:
+				case <-ctx.Done():
+					return
+				}
 			case exConf.TradesStream:
 				trade, err := decodeTrade(update.Data)
 				if err != nil {
-					log.Println("OrderBookFollower error:", err)
-					close(tradeStream)
-					wg.Done()
+					fail("Binance order book follower cannot decode trade", err)
+					return
+				}
+
+				select {
+				case tradeStream <- trade:
+				case <-ctx.Done():
 					return
 				}
-				tradeStream <- trade
 			}
 
-			if nextWs != nil && time.Since(nextWsAge) > time.Second {
-				ws.Close()
+			if nextWs != nil && opts.clock.Now().Sub(nextWsAge) > time.Second {
+				conns.remove(ws)
 				ws = nextWs
 				nextWs = nil
 				wsAge = nextWsAge
+				if opts.observer != nil {
+					opts.observer.Reconnected()
+				}
 			}
 
 			select {
 			case <-ctx.Done():
-				wg.Done()
 				return
 			default:
 				continue
@@ -228,15 +285,22 @@ func followForever(
 	return obf, tradeStream, nil
 }
 
-func getLatestSnapshot(pairCode string) (internalOrderBook, error) {
+func getLatestSnapshot(
+	httpClient *http.Client,
+	baseUrl string,
+	pairCode string,
+) (internalOrderBook, error) {
 
-	path := requestutil.FullPath(baseUrl, "api/v3/depth")
+	path, err := requestutil.FullPath(baseUrl, "api/v3/depth")
+	if err != nil {
+		return internalOrderBook{}, err
+	}
 	values := url.Values{}
 	values.Add("symbol", pairCode)
 	values.Add("limit", "1000")
 	path.RawQuery = values.Encode()
 
-	body, err := GetBody(http.DefaultClient.Get(path.String()))
+	body, err := GetBody(httpClient.Get(path.String()))
 	if err != nil {
 		return internalOrderBook{}, err
 	}
@@ -303,7 +367,8 @@ func handleOrderBookUpdate(
 
 	if update.FirstUpdateId > ob.lastUpdateId+1 {
 		return fmt.Errorf(
-			"missed some updates; got update %d but last ob update is %d",
+			"%w; got update %d but last ob update is %d",
+			errMissedUpdates,
 			update.FirstUpdateId,
 			ob.lastUpdateId,
 		)
@@ -485,11 +550,64 @@ func sortOrders(orders *[]exchangesdk.OrderBookOrder, ordering sortOrdering) err
 	}
 }
 
-func newWebsocket(wsUrl string) (*websocket.Conn, time.Time, error) {
+// wsConns are the open websocket connections of a market follower, which
+// may be closed from another goroutine
+type wsConns struct {
+	mu     sync.Mutex
+	conns  []*websocket.Conn
+	closed bool
+}
+
+// add adds ws to the connections; if they have already been closed, ws is
+// closed immediately
+func (c *wsConns) add(ws *websocket.Conn) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		ws.Close()
+		return
+	}
+	c.conns = append(c.conns, ws)
+}
+
+// remove closes ws and removes it from the connections
+func (c *wsConns) remove(ws *websocket.Conn) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, conn := range c.conns {
+		if conn == ws {
+			c.conns = append(c.conns[:i], c.conns[i+1:]...)
+			break
+		}
+	}
+	ws.Close()
+}
+
+func (c *wsConns) closeAll() {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, ws := range c.conns {
+		ws.Close()
+	}
+	c.conns = nil
+	c.closed = true
+}
+
+func newWebsocket(
+	dialer *websocket.Dialer,
+	wsUrl string,
+	clock utiltime.Clock,
+) (*websocket.Conn, time.Time, error) {
 
-	ws, _, err := websocket.DefaultDialer.Dial(wsUrl, nil)
+	ws, _, err := dialer.Dial(wsUrl, nil)
 	if err != nil {
 		return nil, time.Time{}, err
 	}
-	return ws, time.Now(), nil
+	return ws, clock.Now(), nil
 }
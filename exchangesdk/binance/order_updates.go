@@ -0,0 +1,255 @@
+package binance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/logging"
+	"github.com/thecodedproject/crypto/exchangesdk/requestutil"
+)
+
+const (
+	// Listen keys expire after 60 minutes unless kept alive
+	listenKeyKeepAlivePeriod = 30 * time.Minute
+
+	userStreamReconnectDelay = time.Second
+)
+
+var _ exchangesdk.OrderUpdatesClient = (*client)(nil)
+
+// executionReport is an order update on the user data stream.
+//
+// encoding/json matches keys case insensitively, so both keys of each pair
+// which differ only by case (e.g. "c" and "C") are declared, to stop one
+// being decoded into the field of the other.
+type executionReport struct {
+	EventType         string          `json:"e"`
+	EventTime         int64           `json:"E"`
+	Symbol            string          `json:"s"`
+	Side              string          `json:"S"`
+	ClientOrderId     string          `json:"c"`
+	OrigClientOrderId string          `json:"C"`
+	ExecutionType     string          `json:"x"`
+	Status            string          `json:"X"`
+	LastQty           decimal.Decimal `json:"l"`
+	LastPrice         decimal.Decimal `json:"L"`
+	CumQty            decimal.Decimal `json:"z"`
+	CumQuoteQty       decimal.Decimal `json:"Z"`
+	Commission        decimal.Decimal `json:"n"`
+	CommissionAsset   string          `json:"N"`
+	TradeId           int64           `json:"t"`
+	TransactionTime   int64           `json:"T"`
+	IsWorking         bool            `json:"w"`
+	WorkingTime       int64           `json:"W"`
+}
+
+// OrderUpdates streams updates to the client's orders from the Binance user
+// data stream, keeping the stream's listen key alive and reconnecting with a
+// new listen key if the stream is dropped.
+// Fees paid in an asset other than the base or counter (e.g. BNB) are not
+// reported.
+func (c *client) OrderUpdates(
+	ctx context.Context,
+) (<-chan exchangesdk.OrderUpdate, error) {
+
+	listenKey, err := c.listenKey(http.MethodPost, "")
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(chan exchangesdk.OrderUpdate)
+	go func() {
+
+		defer close(updates)
+
+		for {
+			err := c.followUserStream(ctx, listenKey, updates)
+			if ctx.Err() != nil {
+				c.listenKey(http.MethodDelete, listenKey)
+				return
+			}
+			c.logError("Binance user stream failed", err)
+
+			for {
+				select {
+				case <-time.After(userStreamReconnectDelay):
+				case <-ctx.Done():
+					return
+				}
+
+				listenKey, err = c.listenKey(http.MethodPost, "")
+				if err == nil {
+					break
+				}
+				c.logError("Binance user stream cannot reconnect", err)
+			}
+		}
+	}()
+
+	return updates, nil
+}
+
+// followUserStream sends the order updates from the user data stream for
+// listenKey until the stream fails or ctx is cancelled
+func (c *client) followUserStream(
+	ctx context.Context,
+	listenKey string,
+	updates chan<- exchangesdk.OrderUpdate,
+) error {
+
+	ws, _, err := c.dialer.Dial(
+		strings.TrimRight(c.wsUrl, "/")+"/ws/"+listenKey,
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		keepAlive := time.NewTicker(listenKeyKeepAlivePeriod)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case <-keepAlive.C:
+				_, err := c.listenKey(http.MethodPut, listenKey)
+				if err != nil {
+					c.logError("Binance user stream cannot keep listen key alive", err)
+				}
+			case <-streamCtx.Done():
+				ws.Close()
+				return
+			}
+		}
+	}()
+
+	for {
+		_, msg, err := ws.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		var report executionReport
+		err = json.Unmarshal(msg, &report)
+		if err != nil {
+			return err
+		}
+
+		switch report.EventType {
+		case "listenKeyExpired":
+			return fmt.Errorf("listen key expired")
+		case "executionReport":
+		default:
+			continue
+		}
+
+		if report.Symbol != c.tradingPair {
+			continue
+		}
+
+		for _, u := range c.orderUpdates(report) {
+			select {
+			case updates <- u:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+func (c *client) orderUpdates(r executionReport) []exchangesdk.OrderUpdate {
+
+	orderId := r.ClientOrderId
+	if r.ExecutionType == "CANCELED" && r.OrigClientOrderId != "" {
+		// Cancels are reported with the client order id of the cancel request
+		orderId = r.OrigClientOrderId
+	}
+
+	stateChange := exchangesdk.OrderUpdate{
+		Type:              exchangesdk.OrderUpdateTypeStateChange,
+		OrderId:           orderId,
+		Timestamp:         time.Unix(0, r.TransactionTime*int64(time.Millisecond)),
+		State:             orderState(r.Status, r.IsWorking),
+		FillAmountBase:    r.CumQty,
+		FillAmountCounter: r.CumQuoteQty,
+	}
+
+	switch r.ExecutionType {
+	case "NEW", "CANCELED", "REJECTED", "EXPIRED":
+		return []exchangesdk.OrderUpdate{stateChange}
+	case "TRADE":
+		fill := stateChange
+		fill.Type = exchangesdk.OrderUpdateTypeFill
+		fill.FillPrice = r.LastPrice
+		fill.FillVolume = r.LastQty
+		if r.CommissionAsset != "" && strings.HasPrefix(c.tradingPair, r.CommissionAsset) {
+			fill.BaseFee = r.Commission
+		} else if r.CommissionAsset != "" && strings.HasSuffix(c.tradingPair, r.CommissionAsset) {
+			fill.CounterFee = r.Commission
+		}
+
+		if fill.State == exchangesdk.OrderStateFilled {
+			return []exchangesdk.OrderUpdate{fill, stateChange}
+		}
+		return []exchangesdk.OrderUpdate{fill}
+	default:
+		return nil
+	}
+}
+
+// listenKey makes a request to the user data stream endpoint, which creates a
+// listen key (POST), keeps listenKey alive (PUT) or closes it (DELETE)
+func (c *client) listenKey(method string, listenKey string) (string, error) {
+
+	path, err := requestutil.FullPath(c.baseUrl, "/api/v3/userDataStream")
+	if err != nil {
+		return "", err
+	}
+	if listenKey != "" {
+		values := url.Values{}
+		values.Add("listenKey", listenKey)
+		path.RawQuery = values.Encode()
+	}
+
+	req, err := http.NewRequest(method, path.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Add("X-MBX-APIKEY", c.apiKey)
+
+	body, err := GetBody(c.httpClient.Do(req))
+	if err != nil {
+		return "", err
+	}
+
+	res := struct {
+		ListenKey string `json:"listenKey"`
+	}{}
+
+	err = json.Unmarshal(body, &res)
+	if err != nil {
+		return "", err
+	}
+
+	return res.ListenKey, nil
+}
+
+func (c *client) logError(msg string, err error) {
+
+	c.logger.Error(
+		msg,
+		logging.KeyProvider, c.provider,
+		logging.KeyPair, c.pair,
+		logging.KeyError, err,
+	)
+}
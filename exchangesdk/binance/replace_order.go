@@ -0,0 +1,157 @@
+package binance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/shopspring/decimal"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/requestutil"
+)
+
+var _ exchangesdk.ReplaceOrderClient = (*client)(nil)
+
+type cancelReplaceResponse struct {
+	CancelResult   string `json:"cancelResult"`
+	NewOrderResult string `json:"newOrderResult"`
+
+	CancelResponse struct {
+		ErrCode             *int64          `json:"code"`
+		ErrMsg              string          `json:"msg"`
+		Status              string          `json:"status"`
+		Side                string          `json:"side"`
+		ExecutedQty         decimal.Decimal `json:"executedQty"`
+		CummulativeQuoteQty decimal.Decimal `json:"cummulativeQuoteQty"`
+	} `json:"cancelResponse"`
+
+	NewOrderResponse struct {
+		ErrCode       *int64 `json:"code"`
+		ErrMsg        string `json:"msg"`
+		ClientOrderId string `json:"clientOrderId"`
+	} `json:"newOrderResponse"`
+}
+
+// ReplaceOrder cancels orderId and posts order in its place with a single
+// cancelReplace request, which only posts order if the cancel succeeds.
+func (c *client) ReplaceOrder(
+	ctx context.Context,
+	orderId string,
+	order exchangesdk.Order,
+) (exchangesdk.ReplaceResult, error) {
+
+	side := "BUY"
+	if order.Type == exchangesdk.OrderTypeAsk {
+		side = "SELL"
+	}
+
+	values := url.Values{}
+	values.Add("cancelReplaceMode", "STOP_ON_FAILURE")
+	values.Add("cancelOrigClientOrderId", orderId)
+	values.Add("type", "LIMIT")
+	values.Add("side", side)
+	values.Add("timeInForce", "GTC")
+	values.Add("quantity", order.Volume.String())
+	values.Add("price", order.Price.String())
+
+	path, err := orderEndpointPath(
+		c.baseUrl,
+		"/api/v3/order/cancelReplace",
+		c.tradingPair,
+		values,
+	)
+	if err != nil {
+		return exchangesdk.ReplaceResult{}, err
+	}
+
+	req, err := newRequestWithHmacAuth("POST", c.apiKey, c.apiSecret, path)
+	if err != nil {
+		return exchangesdk.ReplaceResult{}, err
+	}
+
+	res, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return exchangesdk.ReplaceResult{}, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return exchangesdk.ReplaceResult{}, err
+	}
+
+	var replace cancelReplaceResponse
+	var replaceErr error
+	if res.StatusCode == http.StatusOK {
+		err = json.Unmarshal(body, &replace)
+		if err != nil {
+			return exchangesdk.ReplaceResult{}, err
+		}
+	} else {
+		// When either leg fails the legs' results are returned in the
+		// error's data
+		errStruct := struct {
+			ErrCode *int64                 `json:"code"`
+			ErrMsg  string                 `json:"msg"`
+			Data    *cancelReplaceResponse `json:"data"`
+		}{}
+
+		err = json.Unmarshal(body, &errStruct)
+		if err != nil {
+			return exchangesdk.ReplaceResult{}, requestutil.HttpStatusError(
+				res,
+				"Error decoding errMsg:",
+				err,
+			)
+		}
+		if errStruct.ErrCode == nil || errStruct.Data == nil {
+			return exchangesdk.ReplaceResult{}, requestutil.HttpStatusError(
+				res,
+				errStruct.ErrMsg,
+			)
+		}
+
+		replace = *errStruct.Data
+		replaceErr = requestutil.HttpStatusError(res, fmt.Sprintf(
+			"%d %s; cancel: %s; new order: %s",
+			*errStruct.ErrCode,
+			errStruct.ErrMsg,
+			legResult(replace.CancelResult, replace.CancelResponse.ErrMsg),
+			legResult(replace.NewOrderResult, replace.NewOrderResponse.ErrMsg),
+		))
+	}
+
+	var result exchangesdk.ReplaceResult
+	if replace.CancelResult == "SUCCESS" {
+		cancelled := replace.CancelResponse
+
+		orderType := exchangesdk.OrderTypeBid
+		if cancelled.Side == "SELL" {
+			orderType = exchangesdk.OrderTypeAsk
+		}
+
+		result.Cancelled = true
+		result.Original = exchangesdk.OrderStatus{
+			State:             orderState(cancelled.Status, false),
+			Type:              orderType,
+			FillAmountBase:    cancelled.ExecutedQty,
+			FillAmountCounter: cancelled.CummulativeQuoteQty,
+		}
+	}
+	if replace.NewOrderResult == "SUCCESS" {
+		result.NewOrderId = replace.NewOrderResponse.ClientOrderId
+	}
+
+	return result, replaceErr
+}
+
+func legResult(result string, errMsg string) string {
+
+	if errMsg == "" {
+		return result
+	}
+	return result + " (" + errMsg + ")"
+}
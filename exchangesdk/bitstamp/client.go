@@ -20,25 +20,29 @@ import (
 	utiltime "github.com/thecodedproject/crypto/util/time"
 )
 
-const (
-	httpsPrefix    = "https://"
-	bitstampDomain = "www.bitstamp.net"
-)
-
 var ErrBadCheckSignature = fmt.Errorf("Bad check signature on response")
 
 type client struct {
+	baseUrl    *url.URL
 	apiKey     string
 	apiSecret  string
 	httpClient *http.Client
 }
 
-func NewClient(apiKey, apiSecret string) (*client, error) {
+func NewClient(apiKey, apiSecret string, opts ...Option) (*client, error) {
+
+	o := resolveOptions(opts)
+
+	baseUrl, err := url.Parse(o.baseUrl)
+	if err != nil {
+		return nil, err
+	}
 
 	return &client{
+		baseUrl:    baseUrl,
 		apiKey:     apiKey,
 		apiSecret:  apiSecret,
-		httpClient: http.DefaultClient,
+		httpClient: o.client(),
 	}, nil
 }
 
@@ -56,7 +60,13 @@ func NewClientForTesting(
 	handler func(req *http.Request) *http.Response,
 ) *client {
 
+	baseUrl, err := url.Parse(defaultBaseUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	return &client{
+		baseUrl:   baseUrl,
 		apiKey:    apiKey,
 		apiSecret: apiSecret,
 		httpClient: &http.Client{
@@ -69,7 +79,7 @@ func (c *client) LatestPrice(ctx context.Context) (decimal.Decimal, error) {
 
 	req, err := http.NewRequest(
 		"GET",
-		makeFullUrl("/api/v2/ticker/btceur/"),
+		makeFullUrl(c.baseUrl, "/api/v2/ticker/btceur/"),
 		nil,
 	)
 	if err != nil {
@@ -122,6 +132,7 @@ func (c *client) PostLimitOrder(ctx context.Context, order exchangesdk.Order) (s
 
 	resBody, err := postRequestWithAuth(
 		c.httpClient,
+		c.baseUrl,
 		c.apiKey,
 		c.apiSecret,
 		path,
@@ -163,6 +174,7 @@ func (c *client) StopOrder(ctx context.Context, orderId string) error {
 
 	resBody, err := postRequestWithAuth(
 		c.httpClient,
+		c.baseUrl,
 		c.apiKey,
 		c.apiSecret,
 		path,
@@ -188,20 +200,21 @@ func (c *client) StopOrder(ctx context.Context, orderId string) error {
 	return nil
 }
 
-func makeFullUrl(path string) string {
+func makeFullUrl(baseUrl *url.URL, path string) string {
 
-	return fmt.Sprint(httpsPrefix, bitstampDomain, path)
+	return strings.TrimRight(baseUrl.String(), "/") + path
 }
 
 func postRequestWithAuth(
 	client *http.Client,
+	baseUrl *url.URL,
 	apiKey string,
 	apiSecret string,
 	path string,
 	values url.Values,
 ) ([]byte, error) {
 
-	fullUrl := makeFullUrl(path)
+	fullUrl := makeFullUrl(baseUrl, path)
 
 	// Bitstamp seems to return an authentication error if the
 	// request body is empty (not sure why)
@@ -230,7 +243,7 @@ func postRequestWithAuth(
 	msg := fmt.Sprint(
 		authHeader,
 		reqMethod,
-		bitstampDomain,
+		baseUrl.Host,
 		path,
 		contentType,
 		nonce,
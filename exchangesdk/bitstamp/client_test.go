@@ -17,6 +17,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/thecodedproject/crypto/exchangesdk"
 	"github.com/thecodedproject/crypto/exchangesdk/bitstamp"
+	"github.com/thecodedproject/crypto/exchangesdk/requestutil"
 	"github.com/thecodedproject/crypto/util"
 	utiltime "github.com/thecodedproject/crypto/util/time"
 )
@@ -48,6 +49,49 @@ func TestLatestPriceWhenBitstampReturns200(t *testing.T) {
 	util.LogicallyEqual(t, decimal.New(1234, -1), val)
 }
 
+func TestLatestPriceWithBaseUrlAndHttpClientOptions(t *testing.T) {
+
+	handlerCalled := false
+	httpClient := &http.Client{
+		Transport: requestutil.RoundTripFunc(func(req *http.Request) *http.Response {
+
+			handlerCalled = true
+			assert.Equal(
+				t,
+				"http://localhost:8080/api/v2/ticker/btceur/",
+				req.URL.String(),
+			)
+
+			return &http.Response{
+				StatusCode: 200,
+				Body:       resBodyFromJsonf("{\"last\": \"123.4\"}"),
+			}
+		}),
+	}
+
+	c, err := bitstamp.NewClient(
+		"k",
+		"s",
+		bitstamp.WithBaseUrl("http://localhost:8080"),
+		bitstamp.WithHttpClient(httpClient),
+		bitstamp.WithTimeout(time.Second),
+	)
+	require.NoError(t, err)
+
+	val, err := c.LatestPrice(context.Background())
+	require.NoError(t, err)
+
+	assert.True(t, handlerCalled)
+	util.LogicallyEqual(t, decimal.New(1234, -1), val)
+	assert.Equal(t, time.Duration(0), httpClient.Timeout)
+}
+
+func TestNewClientWithInvalidBaseUrlReturnsError(t *testing.T) {
+
+	_, err := bitstamp.NewClient("k", "s", bitstamp.WithBaseUrl(":invalid"))
+	require.Error(t, err)
+}
+
 func TestLatestPriceWhenBitstampReturns4XXReturnsError(t *testing.T) {
 
 	handlerCalled := false
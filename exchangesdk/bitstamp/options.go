@@ -0,0 +1,65 @@
+package bitstamp
+
+import (
+	"net/http"
+	"time"
+)
+
+const defaultBaseUrl = "https://www.bitstamp.net"
+
+type options struct {
+	baseUrl    string
+	httpClient *http.Client
+	timeout    time.Duration
+}
+
+// Option configures the endpoint used by the Bitstamp client
+type Option func(*options)
+
+func resolveOptions(opts []Option) options {
+
+	o := options{
+		baseUrl:    defaultBaseUrl,
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithBaseUrl sets the base URL of the REST API
+func WithBaseUrl(baseUrl string) Option {
+
+	return func(o *options) {
+		o.baseUrl = baseUrl
+	}
+}
+
+// WithHttpClient sets the HTTP client used for REST requests; by default
+// http.DefaultClient is used
+func WithHttpClient(httpClient *http.Client) Option {
+
+	return func(o *options) {
+		o.httpClient = httpClient
+	}
+}
+
+// WithTimeout sets the timeout of REST requests
+func WithTimeout(timeout time.Duration) Option {
+
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// client returns the HTTP client for REST requests, applying the timeout
+// (if set) to a copy so that the configured client is left unchanged
+func (o options) client() *http.Client {
+
+	c := *o.httpClient
+	if o.timeout > 0 {
+		c.Timeout = o.timeout
+	}
+	return &c
+}
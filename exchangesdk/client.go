@@ -50,3 +50,68 @@ type Client interface {
 	CounterPrecision() int32
 	BasePrecision() int32
 }
+
+// HistoricalCandlesClient is implemented by clients which are able to download
+// historical market data.
+type HistoricalCandlesClient interface {
+	// HistoricalCandles returns all candles of the given interval with a start
+	// time in [from, to), ordered by time. Requests are paginated as
+	// required by the exchange.
+	HistoricalCandles(
+		ctx context.Context,
+		interval time.Duration,
+		from time.Time,
+		to time.Time,
+	) ([]Candle, error)
+}
+
+// OrderUpdatesClient is implemented by clients which are able to stream
+// updates to our orders, rather than requiring GetOrderStatus to be polled.
+type OrderUpdatesClient interface {
+	// OrderUpdates streams updates to all of the client's orders until ctx
+	// is cancelled, after which the channel is closed.
+	// A fill which completes an order is sent as an OrderUpdateTypeFill
+	// update followed by an OrderUpdateTypeStateChange update.
+	// Dropped connections are reconnected automatically; updates which
+	// occur while disconnected may be missed, so GetOrderStatus should be
+	// used to resynchronise if required.
+	OrderUpdates(ctx context.Context) (<-chan OrderUpdate, error)
+}
+
+// ReplaceOrderClient is implemented by clients which are able to cancel an
+// order and post its replacement in a single request; see ReplaceOrder.
+type ReplaceOrderClient interface {
+	// ReplaceOrder cancels orderId and posts order in its place, only
+	// posting order once orderId has been cancelled.
+	ReplaceOrder(
+		ctx context.Context,
+		orderId string,
+		order Order,
+	) (ReplaceResult, error)
+}
+
+// OCOClient is implemented by clients which are able to place one-cancels-
+// other orders natively, with the exchange cancelling one leg once the other
+// is filled or triggered.
+type OCOClient interface {
+	PostOCOOrder(ctx context.Context, o OCOOrder) (OCOOrderIds, error)
+}
+
+// OpenOrdersClient is implemented by clients which are able to list our open
+// orders on their exchange pair (e.g. to show them alongside the market).
+type OpenOrdersClient interface {
+	OpenOrders(ctx context.Context) ([]OpenOrder, error)
+}
+
+// FollowerObserver is notified of events within a market follower which are
+// not visible in its output (e.g. to export them as metrics).
+// Its methods are called from the follower's goroutine, so they should not
+// block.
+type FollowerObserver interface {
+	// Reconnected is called when the follower replaces its connection to the
+	// exchange
+	Reconnected()
+	// SequenceGap is called when the follower detects that it has missed
+	// updates to the order book
+	SequenceGap()
+}
@@ -7,45 +7,83 @@ import (
 
 	"github.com/thecodedproject/crypto"
 	"github.com/thecodedproject/crypto/exchangesdk"
+	utiltime "github.com/thecodedproject/crypto/util/time"
 )
 
+type options struct {
+	clock utiltime.Clock
+}
+
+// Option configures the dummy market follower
+type Option func(*options)
+
+// WithClock sets the clock which paces the dummy market, and timestamps its
+// order books and trades; by default the real clock is used
+func WithClock(clock utiltime.Clock) Option {
+
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
 func NewMarketFollower(
 	ctx context.Context,
 	wg *sync.WaitGroup,
 	_ crypto.Pair,
+	opts ...Option,
 ) (<-chan exchangesdk.OrderBook, <-chan exchangesdk.OrderBookTrade, error) {
 
+	o := options{
+		clock: utiltime.Real,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	obf := make(chan exchangesdk.OrderBook, 1)
 	tradeFollower := make(chan exchangesdk.OrderBookTrade, 1)
 
 	go func() {
+		defer wg.Done()
+
 		for {
 			select {
 			case <-ctx.Done():
-				wg.Done()
 				return
-			case <-time.After(time.Second):
-				obf <- exchangesdk.OrderBook{
-					Timestamp: time.Now(),
-					Bids: []exchangesdk.OrderBookOrder{
-						{
-							Price:  100.0,
-							Volume: 1.0,
-						},
+			case <-o.clock.After(time.Second):
+			}
+
+			ob := exchangesdk.OrderBook{
+				Timestamp: o.clock.Now(),
+				Bids: []exchangesdk.OrderBookOrder{
+					{
+						Price:  100.0,
+						Volume: 1.0,
 					},
-					Asks: []exchangesdk.OrderBookOrder{
-						{
-							Price:  200.0,
-							Volume: 1.0,
-						},
+				},
+				Asks: []exchangesdk.OrderBookOrder{
+					{
+						Price:  200.0,
+						Volume: 1.0,
 					},
-				}
-				tradeFollower <- exchangesdk.OrderBookTrade{
-					Timestamp: time.Now(),
-					MakerSide: exchangesdk.OrderBookSideBid,
-					Price:     150.0,
-					Volume:    0.1,
-				}
+				},
+			}
+			select {
+			case obf <- ob:
+			case <-ctx.Done():
+				return
+			}
+
+			trade := exchangesdk.OrderBookTrade{
+				Timestamp: o.clock.Now(),
+				MakerSide: exchangesdk.OrderBookSideBid,
+				Price:     150.0,
+				Volume:    0.1,
+			}
+			select {
+			case tradeFollower <- trade:
+			case <-ctx.Done():
+				return
 			}
 		}
 	}()
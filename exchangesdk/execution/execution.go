@@ -0,0 +1,329 @@
+// Package execution implements algorithms which execute a large (parent)
+// order as a schedule of smaller child limit orders over a time window, on
+// any exchangesdk.Client.
+//
+// Each child order is live until the next child is due, when any unfilled
+// volume is cancelled and rolled into the next child.
+package execution
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+// Order is a parent order
+type Order struct {
+	Side   exchangesdk.OrderBookSide
+	Volume decimal.Decimal
+
+	// LimitPrice is the worst price at which child orders are placed (the
+	// highest for a bid, the lowest for an ask); zero for no limit
+	LimitPrice decimal.Decimal
+}
+
+// Progress is the progress of executing a parent order
+type Progress struct {
+	Volume decimal.Decimal
+
+	FillAmountBase    decimal.Decimal
+	FillAmountCounter decimal.Decimal
+
+	// ArrivalPrice is the client's LatestPrice when execution started
+	ArrivalPrice decimal.Decimal
+
+	ChildOrders int
+}
+
+// Remaining returns the volume of the parent order which is not yet filled
+func (p Progress) Remaining() decimal.Decimal {
+
+	return p.Volume.Sub(p.FillAmountBase)
+}
+
+// AverageFillPrice returns the volume weighted average price of the fills so
+// far, or zero if there have been none
+func (p Progress) AverageFillPrice() decimal.Decimal {
+
+	if p.FillAmountBase.IsZero() {
+		return decimal.Decimal{}
+	}
+	return p.FillAmountCounter.Div(p.FillAmountBase)
+}
+
+// Slippage returns how much worse the average fill price is than the
+// arrival price, as a ratio of the arrival price (i.e. 0.1% as 0.001);
+// negative if it is better
+func (p Progress) Slippage(side exchangesdk.OrderBookSide) decimal.Decimal {
+
+	if p.FillAmountBase.IsZero() || p.ArrivalPrice.IsZero() {
+		return decimal.Decimal{}
+	}
+
+	diff := p.AverageFillPrice().Sub(p.ArrivalPrice)
+	if side == exchangesdk.OrderBookSideAsk {
+		diff = diff.Neg()
+	}
+	return diff.Div(p.ArrivalPrice)
+}
+
+type options struct {
+	minSize     decimal.Decimal
+	priceOffset decimal.Decimal
+	onProgress  []func(Progress)
+	clock       utiltime.Clock
+}
+
+type Option func(*options)
+
+// WithMinSize sets the minimum volume of a child order (e.g. the pair's
+// minimum order size on the exchange); smaller children are deferred until
+// they reach it. By default it is the smallest volume allowed by the
+// client's BasePrecision.
+func WithMinSize(size decimal.Decimal) Option {
+
+	return func(o *options) {
+		o.minSize = size
+	}
+}
+
+// WithPriceOffset prices child orders offset from the client's LatestPrice
+// towards the other side of the market (e.g. to cross the spread); by
+// default they are priced at the LatestPrice
+func WithPriceOffset(offset decimal.Decimal) Option {
+
+	return func(o *options) {
+		o.priceOffset = offset
+	}
+}
+
+// WithClock sets the clock by which child orders are scheduled (e.g. a
+// simulated clock, to execute against historical data); by default the real
+// clock is used
+func WithClock(clock utiltime.Clock) Option {
+
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// OnProgress registers a callback which is called with the progress of the
+// execution after each child order is settled
+func OnProgress(f func(Progress)) Option {
+
+	return func(o *options) {
+		o.onProgress = append(o.onProgress, f)
+	}
+}
+
+// executor places and settles the child orders of a parent order
+type executor struct {
+	client exchangesdk.Client
+	order  Order
+	opts   options
+
+	progress Progress
+	childId  string
+}
+
+func newExecutor(
+	ctx context.Context,
+	client exchangesdk.Client,
+	o Order,
+	opts []Option,
+) (*executor, error) {
+
+	if o.Side != exchangesdk.OrderBookSideBid && o.Side != exchangesdk.OrderBookSideAsk {
+		return nil, errors.New("execution order side must be bid or ask")
+	}
+	if !o.Volume.IsPositive() {
+		return nil, errors.New("execution order volume must be positive")
+	}
+
+	opt := options{
+		minSize: decimal.New(1, -client.BasePrecision()),
+		clock:   utiltime.Real,
+	}
+	for _, f := range opts {
+		f(&opt)
+	}
+
+	arrivalPrice, err := client.LatestPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &executor{
+		client: client,
+		order:  o,
+		opts:   opt,
+		progress: Progress{
+			Volume:       o.Volume,
+			ArrivalPrice: arrivalPrice,
+		},
+	}, nil
+}
+
+// placeUpTo settles the current child order and places a new child for the
+// volume required for the total filled to reach target
+func (e *executor) placeUpTo(ctx context.Context, target decimal.Decimal) error {
+
+	err := e.settle(ctx)
+	if err != nil {
+		return err
+	}
+
+	target = decimal.Min(target, e.order.Volume)
+	size := target.Sub(e.progress.FillAmountBase).Truncate(e.client.BasePrecision())
+	if size.LessThan(e.opts.minSize) || !size.IsPositive() {
+		return nil
+	}
+
+	price, err := e.childPrice(ctx)
+	if err != nil {
+		return err
+	}
+
+	orderType := exchangesdk.OrderTypeBid
+	if e.order.Side == exchangesdk.OrderBookSideAsk {
+		orderType = exchangesdk.OrderTypeAsk
+	}
+
+	id, err := e.client.PostLimitOrder(ctx, exchangesdk.Order{
+		Type:   orderType,
+		Price:  price,
+		Volume: size,
+	})
+	if err != nil {
+		return err
+	}
+
+	e.childId = id
+	e.progress.ChildOrders++
+	return nil
+}
+
+func (e *executor) childPrice(ctx context.Context) (decimal.Decimal, error) {
+
+	price, err := e.client.LatestPrice(ctx)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+
+	limit := e.order.LimitPrice
+	if e.order.Side == exchangesdk.OrderBookSideBid {
+		price = price.Add(e.opts.priceOffset)
+		if !limit.IsZero() {
+			price = decimal.Min(price, limit)
+		}
+	} else {
+		price = price.Sub(e.opts.priceOffset)
+		if !limit.IsZero() {
+			price = decimal.Max(price, limit)
+		}
+	}
+
+	return price.Round(e.client.CounterPrecision()), nil
+}
+
+// settle cancels the current child order (if any) and, once it has left the
+// order book, adds its fills to the progress
+func (e *executor) settle(ctx context.Context) error {
+
+	if e.childId == "" {
+		return nil
+	}
+
+	var status exchangesdk.OrderStatus
+	cancelErr := e.client.CancelOrder(ctx, e.childId)
+	if cancelErr != nil {
+		// The cancel fails if the child was filled first
+		var err error
+		status, err = e.client.GetOrderStatus(ctx, e.childId)
+		if err != nil {
+			return err
+		}
+		if status.State != exchangesdk.OrderStateFilled &&
+			status.State != exchangesdk.OrderStateCancelled {
+
+			return cancelErr
+		}
+	} else {
+		// The child may still be filled until the exchange has removed it
+		// from the order book, so its fills are only final once it has
+		var err error
+		status, err = exchangesdk.AwaitOutOfBook(
+			ctx,
+			e.client,
+			e.childId,
+			exchangesdk.WithClock(e.opts.clock),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	e.childId = ""
+	e.progress.FillAmountBase = e.progress.FillAmountBase.Add(status.FillAmountBase)
+	e.progress.FillAmountCounter = e.progress.FillAmountCounter.Add(status.FillAmountCounter)
+
+	for _, f := range e.opts.onProgress {
+		f(e.progress)
+	}
+	return nil
+}
+
+// finish settles the current child order; if ctx has been cancelled then the
+// child is settled with a new context and ctx.Err() returned
+func (e *executor) finish(ctx context.Context) (Progress, error) {
+
+	if ctx.Err() != nil {
+		// Settle with a fresh context, so that the child is still cancelled
+		settleCtx, cancel := context.WithTimeout(context.Background(), settleTimeout)
+		defer cancel()
+
+		err := e.settle(settleCtx)
+		if err != nil {
+			return e.progress, err
+		}
+		return e.progress, ctx.Err()
+	}
+
+	err := e.settle(ctx)
+	return e.progress, err
+}
+
+// abort settles the current child order after err, returning the progress
+// and err (or ctx.Err() if ctx has been cancelled)
+func (e *executor) abort(ctx context.Context, err error) (Progress, error) {
+
+	if ctx.Err() != nil {
+		return e.finish(ctx)
+	}
+
+	e.finish(ctx)
+	return e.progress, err
+}
+
+// settleTimeout bounds settling the last child order after the execution's
+// context is cancelled
+const settleTimeout = 30 * time.Second
+
+// sleepUntil waits until t by the executor's clock, returning ctx.Err() if
+// ctx is cancelled first
+func (e *executor) sleepUntil(ctx context.Context, t time.Time) error {
+
+	timer := e.opts.clock.NewTimer(t.Sub(e.opts.clock.Now()))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,384 @@
+package execution_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/execution"
+	"github.com/thecodedproject/crypto/exchangesdk/mockery"
+	"github.com/thecodedproject/crypto/exchangesdk/simulator"
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+func D(f float64) decimal.Decimal {
+
+	return decimal.NewFromFloat(f)
+}
+
+// simulatedExchange is embedded under another name, so that its Exchange
+// method is promoted
+type simulatedExchange = simulator.Exchange
+
+// recordingClient is a simulated exchange which records the child orders
+// posted to it
+type recordingClient struct {
+	*simulatedExchange
+
+	mu     sync.Mutex
+	orders []exchangesdk.Order
+	ids    []string
+	onPost func()
+}
+
+func newRecordingClient() *recordingClient {
+
+	sim := simulator.New(crypto.Exchange{}, simulator.WithPrecision(2, 6))
+	sim.UpdateOrderBook(exchangesdk.OrderBook{
+		Bids: []exchangesdk.OrderBookOrder{{Price: 99, Volume: 100}},
+		Asks: []exchangesdk.OrderBookOrder{{Price: 101, Volume: 100}},
+	})
+
+	return &recordingClient{simulatedExchange: sim}
+}
+
+func (c *recordingClient) PostLimitOrder(
+	ctx context.Context,
+	o exchangesdk.Order,
+) (string, error) {
+
+	id, err := c.simulatedExchange.PostLimitOrder(ctx, o)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	c.orders = append(c.orders, o)
+	c.ids = append(c.ids, id)
+	c.mu.Unlock()
+
+	if c.onPost != nil {
+		c.onPost()
+	}
+	return id, nil
+}
+
+func (c *recordingClient) volumes() []decimal.Decimal {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var volumes []decimal.Decimal
+	for _, o := range c.orders {
+		volumes = append(volumes, o.Volume)
+	}
+	return volumes
+}
+
+func assertDecimals(t *testing.T, expected []float64, actual []decimal.Decimal) {
+
+	require.Len(t, actual, len(expected))
+	for i := range expected {
+		assert.True(t, D(expected[i]).Equal(actual[i]),
+			"index %d: expected %v, got %s", i, expected[i], actual[i])
+	}
+}
+
+func TestTWAPSlicesEvenlyAndReportsProgress(t *testing.T) {
+
+	client := newRecordingClient()
+
+	var progress []decimal.Decimal
+	res, err := execution.TWAP(
+		context.Background(),
+		client,
+		execution.Order{
+			Side:   exchangesdk.OrderBookSideBid,
+			Volume: D(1),
+		},
+		40*time.Millisecond,
+		4,
+		// Cross the spread, so each child fills at the ask of 101
+		execution.WithPriceOffset(D(2)),
+		execution.OnProgress(func(p execution.Progress) {
+			progress = append(progress, p.FillAmountBase)
+		}),
+	)
+	require.NoError(t, err)
+
+	assertDecimals(t, []float64{0.25, 0.25, 0.25, 0.25}, client.volumes())
+	assertDecimals(t, []float64{0.25, 0.5, 0.75, 1}, progress)
+
+	assert.Equal(t, 4, res.ChildOrders)
+	assert.True(t, D(1).Equal(res.FillAmountBase))
+	assert.True(t, res.Remaining().IsZero())
+	assert.True(t, D(100).Equal(res.ArrivalPrice))
+	assert.True(t, D(101).Equal(res.AverageFillPrice()))
+	assert.True(t, D(0.01).Equal(res.Slippage(exchangesdk.OrderBookSideBid)))
+}
+
+func TestTWAPRollsUnfilledVolumeIntoNextChild(t *testing.T) {
+
+	client := newRecordingClient()
+
+	res, err := execution.TWAP(
+		context.Background(),
+		client,
+		execution.Order{
+			Side:       exchangesdk.OrderBookSideAsk,
+			Volume:     D(1),
+			LimitPrice: D(100.5),
+		},
+		20*time.Millisecond,
+		4,
+	)
+	require.NoError(t, err)
+
+	// Children are placed at the limit price, above the market, and so
+	// never fill
+	assertDecimals(t, []float64{0.25, 0.5, 0.75, 1}, client.volumes())
+	for _, o := range client.orders {
+		assert.Equal(t, exchangesdk.OrderTypeAsk, o.Type)
+		assert.True(t, D(100.5).Equal(o.Price))
+	}
+	for _, id := range client.ids {
+		status, err := client.GetOrderStatus(context.Background(), id)
+		require.NoError(t, err)
+		assert.Equal(t, exchangesdk.OrderStateCancelled, status.State)
+	}
+
+	assert.True(t, res.FillAmountBase.IsZero())
+	assert.True(t, res.AverageFillPrice().IsZero())
+	assert.True(t, res.Slippage(exchangesdk.OrderBookSideAsk).IsZero())
+}
+
+func TestTWAPDefersChildrenBelowMinSize(t *testing.T) {
+
+	client := newRecordingClient()
+
+	res, err := execution.TWAP(
+		context.Background(),
+		client,
+		execution.Order{
+			Side:   exchangesdk.OrderBookSideAsk,
+			Volume: D(1),
+		},
+		20*time.Millisecond,
+		4,
+		execution.WithPriceOffset(D(2)),
+		execution.WithMinSize(D(0.3)),
+	)
+	require.NoError(t, err)
+
+	assertDecimals(t, []float64{0.5, 0.5}, client.volumes())
+	assert.Equal(t, 2, res.ChildOrders)
+	assert.True(t, D(1).Equal(res.FillAmountBase))
+	assert.True(t, D(99).Equal(res.AverageFillPrice()))
+}
+
+func TestTWAPCancelsScheduleWhenContextCancelled(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := newRecordingClient()
+	client.onPost = cancel
+
+	res, err := execution.TWAP(
+		ctx,
+		client,
+		execution.Order{
+			Side:   exchangesdk.OrderBookSideBid,
+			Volume: D(1),
+		},
+		time.Hour,
+		2,
+	)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, res.ChildOrders)
+
+	require.Len(t, client.ids, 1)
+	status, err := client.GetOrderStatus(context.Background(), client.ids[0])
+	require.NoError(t, err)
+	assert.Equal(t, exchangesdk.OrderStateCancelled, status.State)
+}
+
+func TestTWAPIsScheduledByClock(t *testing.T) {
+
+	start := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := utiltime.NewSimulatedClock(start)
+
+	client := newRecordingClient()
+	var posted []time.Time
+	client.onPost = func() {
+		posted = append(posted, clock.Now())
+	}
+
+	type result struct {
+		progress execution.Progress
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		res, err := execution.TWAP(
+			context.Background(),
+			client,
+			execution.Order{
+				Side:   exchangesdk.OrderBookSideBid,
+				Volume: D(1),
+			},
+			time.Hour,
+			4,
+			execution.WithClock(clock),
+		)
+		done <- result{res, err}
+	}()
+
+	// Each child is live for a quarter of the window, after the last of
+	// which the execution finishes
+	for i := 0; i < 4; i++ {
+		require.Eventually(t, func() bool {
+			return clock.Waiters() == 1
+		}, time.Second, time.Millisecond)
+		clock.Advance(15 * time.Minute)
+	}
+
+	var res result
+	select {
+	case res = <-done:
+	case <-time.After(time.Second):
+		t.Fatal("TWAP did not finish")
+	}
+	require.NoError(t, res.err)
+	assert.Equal(t, 4, res.progress.ChildOrders)
+	assert.Equal(t, []time.Time{
+		start,
+		start.Add(15 * time.Minute),
+		start.Add(30 * time.Minute),
+		start.Add(45 * time.Minute),
+	}, posted)
+}
+
+func TestChildFillsAreSettledOnceChildLeavesOrderBook(t *testing.T) {
+
+	client := new(mockery.Client).TSetup(t)
+	client.On("BasePrecision").Return(int32(6))
+	client.On("CounterPrecision").Return(int32(2))
+	client.On("LatestPrice", mock.Anything).Return(D(100), nil)
+	client.On("PostLimitOrder", mock.Anything, mock.Anything).Return("child1", nil).Once()
+	client.On("CancelOrder", mock.Anything, "child1").Return(nil).Once()
+	// The child is filled further after the cancel is accepted, but before
+	// it leaves the order book
+	client.On("GetOrderStatus", mock.Anything, "child1").
+		Return(exchangesdk.OrderStatus{
+			State:             exchangesdk.OrderStateInOrderBook,
+			FillAmountBase:    D(0.2),
+			FillAmountCounter: D(20),
+		}, nil).
+		Once()
+	client.On("GetOrderStatus", mock.Anything, "child1").
+		Return(exchangesdk.OrderStatus{
+			State:             exchangesdk.OrderStateCancelled,
+			FillAmountBase:    D(0.5),
+			FillAmountCounter: D(50),
+		}, nil).
+		Once()
+
+	res, err := execution.TWAP(
+		context.Background(),
+		client,
+		execution.Order{
+			Side:   exchangesdk.OrderBookSideBid,
+			Volume: D(1),
+		},
+		time.Millisecond,
+		1,
+	)
+	require.NoError(t, err)
+	assert.True(t, D(0.5).Equal(res.FillAmountBase), res.FillAmountBase.String())
+	assert.True(t, D(50).Equal(res.FillAmountCounter))
+}
+
+func TestVWAPParticipatesInMarketVolume(t *testing.T) {
+
+	client := newRecordingClient()
+
+	trades := make(chan exchangesdk.OrderBookTrade, 4)
+	for i := 0; i < 4; i++ {
+		trades <- exchangesdk.OrderBookTrade{Price: 100, Volume: 2.5}
+	}
+
+	res, err := execution.VWAP(
+		context.Background(),
+		client,
+		execution.Order{
+			Side:   exchangesdk.OrderBookSideBid,
+			Volume: D(2),
+		},
+		trades,
+		D(0.1),
+		50*time.Millisecond,
+		5*time.Millisecond,
+		execution.WithPriceOffset(D(2)),
+	)
+	require.NoError(t, err)
+
+	assert.True(t, D(1).Equal(res.FillAmountBase))
+	assert.True(t, D(1).Equal(res.Remaining()))
+	assert.Equal(t, 1, res.ChildOrders)
+}
+
+func TestVWAPReturnsOnceFilled(t *testing.T) {
+
+	client := newRecordingClient()
+
+	trades := make(chan exchangesdk.OrderBookTrade, 1)
+	trades <- exchangesdk.OrderBookTrade{Price: 100, Volume: 10}
+	close(trades)
+
+	res, err := execution.VWAP(
+		context.Background(),
+		client,
+		execution.Order{
+			Side:   exchangesdk.OrderBookSideAsk,
+			Volume: D(0.5),
+		},
+		trades,
+		D(0.5),
+		time.Hour,
+		5*time.Millisecond,
+		execution.WithPriceOffset(D(2)),
+	)
+	require.NoError(t, err)
+
+	assertDecimals(t, []float64{0.5}, client.volumes())
+	assert.True(t, res.Remaining().IsZero())
+}
+
+func TestInvalidExecutionReturnsError(t *testing.T) {
+
+	client := newRecordingClient()
+	ctx := context.Background()
+	order := execution.Order{
+		Side:   exchangesdk.OrderBookSideBid,
+		Volume: D(1),
+	}
+
+	_, err := execution.TWAP(ctx, client, order, time.Second, 0)
+	assert.Error(t, err)
+
+	_, err = execution.TWAP(ctx, client, execution.Order{Volume: D(1)}, time.Second, 1)
+	assert.Error(t, err)
+
+	_, err = execution.VWAP(ctx, client, order, nil, D(1.5), time.Second, time.Millisecond)
+	assert.Error(t, err)
+
+	assert.Empty(t, client.volumes())
+}
@@ -0,0 +1,61 @@
+package execution
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/thecodedproject/crypto/exchangesdk"
+)
+
+// TWAP executes o as slices child orders, evenly spaced over window, each
+// for an even share of its volume (plus any volume left unfilled by the
+// previous child).
+//
+// It returns the progress once the last child has been live for its share
+// of the window. If ctx is cancelled first, the remaining schedule is
+// abandoned and the live child cancelled, and ctx.Err() is returned with the
+// progress.
+func TWAP(
+	ctx context.Context,
+	client exchangesdk.Client,
+	o Order,
+	window time.Duration,
+	slices int,
+	opts ...Option,
+) (Progress, error) {
+
+	if slices < 1 {
+		return Progress{}, errors.New("TWAP must have at least one slice")
+	}
+
+	e, err := newExecutor(ctx, client, o, opts)
+	if err != nil {
+		return Progress{}, err
+	}
+
+	start := e.opts.clock.Now()
+	interval := window / time.Duration(slices)
+
+	for i := 0; i < slices; i++ {
+		err := e.sleepUntil(ctx, start.Add(time.Duration(i)*interval))
+		if err != nil {
+			return e.finish(ctx)
+		}
+
+		target := o.Volume.
+			Mul(decimal.NewFromInt(int64(i + 1))).
+			Div(decimal.NewFromInt(int64(slices)))
+
+		err = e.placeUpTo(ctx, target)
+		if err != nil {
+			return e.abort(ctx, err)
+		}
+	}
+
+	// The last child is live until the end of the window (or until ctx is
+	// cancelled, which finish reports)
+	e.sleepUntil(ctx, start.Add(window))
+	return e.finish(ctx)
+}
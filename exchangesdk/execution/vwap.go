@@ -0,0 +1,74 @@
+package execution
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/thecodedproject/crypto/exchangesdk"
+)
+
+// VWAP executes o in proportion to the market's volume; every interval a
+// child order is placed so that the total volume filled reaches participation
+// (e.g. 0.1 for 10%) of the volume traded in the market (as reported by
+// trades) since execution started.
+//
+// It returns the progress once o is filled or window has passed. If ctx is
+// cancelled first, the remaining schedule is abandoned and the live child
+// cancelled, and ctx.Err() is returned with the progress.
+func VWAP(
+	ctx context.Context,
+	client exchangesdk.Client,
+	o Order,
+	trades <-chan exchangesdk.OrderBookTrade,
+	participation decimal.Decimal,
+	window time.Duration,
+	interval time.Duration,
+	opts ...Option,
+) (Progress, error) {
+
+	if !participation.IsPositive() || participation.GreaterThan(decimal.NewFromInt(1)) {
+		return Progress{}, errors.New("VWAP participation must be in (0, 1]")
+	}
+	if interval <= 0 {
+		return Progress{}, errors.New("VWAP interval must be positive")
+	}
+
+	e, err := newExecutor(ctx, client, o, opts)
+	if err != nil {
+		return Progress{}, err
+	}
+
+	end := e.opts.clock.Now().Add(window)
+	ticker := e.opts.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	var marketVolume decimal.Decimal
+	for {
+		select {
+		case t, ok := <-trades:
+			if !ok {
+				trades = nil
+				continue
+			}
+			marketVolume = marketVolume.Add(decimal.NewFromFloat(t.Volume))
+
+		case now := <-ticker.C():
+			if !now.Before(end) {
+				return e.finish(ctx)
+			}
+
+			err := e.placeUpTo(ctx, marketVolume.Mul(participation))
+			if err != nil {
+				return e.abort(ctx, err)
+			}
+			if !e.progress.Remaining().IsPositive() {
+				return e.progress, nil
+			}
+
+		case <-ctx.Done():
+			return e.finish(ctx)
+		}
+	}
+}
@@ -1,54 +1,78 @@
 package factory
 
 import (
-	"fmt"
-
 	"github.com/thecodedproject/crypto"
 	"github.com/thecodedproject/crypto/exchangesdk"
 	"github.com/thecodedproject/crypto/exchangesdk/binance"
-	"github.com/thecodedproject/crypto/exchangesdk/dummyclient"
 	"github.com/thecodedproject/crypto/exchangesdk/luno"
+	"github.com/thecodedproject/crypto/exchangesdk/simulator"
 )
 
+// NewClient creates a client of exchange with the constructor of its provider
+// (see Register)
 func NewClient(
 	exchange crypto.Exchange,
 	apiKey string,
 	apiSecret string,
+	opts ...Option,
 ) (exchangesdk.Client, error) {
 
-	switch exchange.Provider {
-	case crypto.ApiProviderLuno:
-		return luno.NewClient(
-			apiKey,
-			apiSecret,
-			exchange.Pair,
-		)
-	case crypto.ApiProviderBinance:
-		return binance.NewClient(
-			apiKey,
-			apiSecret,
-			exchange.Pair,
-		)
-	case crypto.ApiProviderDummyExchange:
-		return dummyclient.NewClient(
-			apiKey,
-			apiSecret,
-			exchange,
-		)
-	case crypto.ApiProviderDummyExchangeBinanceMarket:
-		return dummyclient.NewClient(
-			apiKey,
-			apiSecret,
-			crypto.Exchange{
-				// When using a dummy exchange with a binance market follower, make
-				// sdk client appear to be for binance in order to pass validation
-				// checks for stats pipes
-				Provider: crypto.ApiProviderBinance,
-				Pair:     exchange.Pair,
-			},
-		)
-	default:
-		return nil, fmt.Errorf("Cannot create client; Unknown Api provider %s", exchange.Provider)
+	newClient, err := lookupClient(exchange.Provider)
+	if err != nil {
+		return nil, err
 	}
+	return newClient(exchange, apiKey, apiSecret, resolveOptions(opts))
+}
+
+func newLunoClient(
+	exchange crypto.Exchange,
+	apiKey string,
+	apiSecret string,
+	o options,
+) (exchangesdk.Client, error) {
+
+	return luno.NewClient(
+		apiKey,
+		apiSecret,
+		exchange.Pair,
+		o.lunoOptsFor(exchange)...,
+	)
+}
+
+// newBinanceClient creates a client of any of the Binance backed providers
+func newBinanceClient(
+	exchange crypto.Exchange,
+	apiKey string,
+	apiSecret string,
+	o options,
+) (exchangesdk.Client, error) {
+
+	return binance.NewClient(
+		apiKey,
+		apiSecret,
+		exchange.Pair,
+		o.binanceOptsFor(exchange)...,
+	)
+}
+
+func newDummyClient(
+	exchange crypto.Exchange,
+	_ string,
+	_ string,
+	o options,
+) (exchangesdk.Client, error) {
+
+	return dummyExchange(exchange, o), nil
+}
+
+func newReplayClient(
+	exchange crypto.Exchange,
+	_ string,
+	_ string,
+	o options,
+) (exchangesdk.Client, error) {
 
+	return o.simulated.get(exchange, func() *simulator.Exchange {
+		return simulator.New(exchange, o.simulatorOpts...)
+	}), nil
 }
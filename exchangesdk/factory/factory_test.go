@@ -0,0 +1,449 @@
+package factory_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/binance"
+	"github.com/thecodedproject/crypto/exchangesdk/factory"
+	"github.com/thecodedproject/crypto/exchangesdk/fakeexchange"
+	"github.com/thecodedproject/crypto/exchangesdk/luno"
+	"github.com/thecodedproject/crypto/exchangesdk/metrics"
+	"github.com/thecodedproject/crypto/exchangesdk/requestutil"
+)
+
+func book(bid, ask float64) exchangesdk.OrderBook {
+
+	return exchangesdk.OrderBook{
+		Bids: []exchangesdk.OrderBookOrder{{Price: bid, Volume: 1}},
+		Asks: []exchangesdk.OrderBookOrder{{Price: ask, Volume: 1}},
+	}
+}
+
+type fakeExchange interface {
+	SetOrderBook(string, exchangesdk.OrderBook)
+	MarketFollowers() int
+}
+
+func TestClientAndMarketFollowerAgainstFakeExchange(t *testing.T) {
+
+	binanceFake := fakeexchange.NewBinance("key", "secret")
+	defer binanceFake.Close()
+	lunoFake := fakeexchange.NewLuno("key", "secret")
+	defer lunoFake.Close()
+
+	opts := []factory.Option{
+		factory.WithBinanceOptions(
+			binance.WithBaseUrl(binanceFake.URL()),
+			binance.WithWsUrl(binanceFake.WsURL()),
+		),
+		factory.WithLunoOptions(
+			luno.WithBaseUrl(lunoFake.URL()),
+			luno.WithWsUrl(lunoFake.WsURL()),
+		),
+	}
+
+	testCases := []struct {
+		Name     string
+		Provider crypto.ApiProvider
+		Fake     fakeExchange
+		Market   string
+	}{
+		{
+			Name:     "Binance",
+			Provider: crypto.ApiProviderBinance,
+			Fake:     binanceFake,
+			Market:   "BTCEUR",
+		},
+		{
+			Name:     "Luno",
+			Provider: crypto.ApiProviderLuno,
+			Fake:     lunoFake,
+			Market:   "XBTEUR",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+
+			exchange := crypto.Exchange{
+				Provider: test.Provider,
+				Pair:     crypto.PairBTCEUR,
+			}
+			test.Fake.SetOrderBook(test.Market, book(99, 101))
+
+			c, err := factory.NewClient(exchange, "key", "secret", opts...)
+			require.NoError(t, err)
+			assert.Equal(t, exchange, c.Exchange())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			orderId, err := c.PostLimitOrder(ctx, exchangesdk.Order{
+				Type:   exchangesdk.OrderTypeBid,
+				Price:  decimal.NewFromFloat(100),
+				Volume: decimal.NewFromFloat(1),
+			})
+			require.NoError(t, err)
+
+			var wg sync.WaitGroup
+			wg.Add(1)
+			obf, _, err := factory.NewMarketFollower(
+				ctx,
+				&wg,
+				exchange,
+				crypto.AuthConfig{Key: "key", Secret: "secret"},
+				opts...,
+			)
+			require.NoError(t, err)
+
+			require.Eventually(t, func() bool {
+				return test.Fake.MarketFollowers() == 1
+			}, time.Second, time.Millisecond)
+
+			test.Fake.SetOrderBook(test.Market, book(98, 99))
+
+			var ob exchangesdk.OrderBook
+			for len(ob.Asks) == 0 || ob.Asks[0].Price != 99 {
+				ob = <-obf
+			}
+
+			status, err := c.GetOrderStatus(ctx, orderId)
+			require.NoError(t, err)
+			assert.Equal(t, exchangesdk.OrderStateFilled, status.State)
+		})
+	}
+}
+
+func TestUnmarshalEndpoints(t *testing.T) {
+
+	testCases := []struct {
+		Name        string
+		Json        string
+		Expected    factory.Endpoints
+		ExpectedErr bool
+	}{
+		{
+			Name: "Timeout as duration string",
+			Json: `{"base_url":"http://a","ws_url":"ws://b","timeout":"5s"}`,
+			Expected: factory.Endpoints{
+				BaseUrl: "http://a",
+				WsUrl:   "ws://b",
+				Timeout: 5 * time.Second,
+			},
+		},
+		{
+			Name:     "Timeout as nanoseconds",
+			Json:     `{"timeout":1500000000}`,
+			Expected: factory.Endpoints{Timeout: 1500 * time.Millisecond},
+		},
+		{
+			Name:     "No timeout",
+			Json:     `{"base_url":"http://a"}`,
+			Expected: factory.Endpoints{BaseUrl: "http://a"},
+		},
+		{
+			Name:        "Invalid duration string",
+			Json:        `{"timeout":"5 seconds"}`,
+			ExpectedErr: true,
+		},
+		{
+			Name:        "Timeout of wrong type",
+			Json:        `{"timeout":true}`,
+			ExpectedErr: true,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+
+			var e factory.Endpoints
+			err := json.Unmarshal([]byte(test.Json), &e)
+			if test.ExpectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.Expected, e)
+		})
+	}
+
+	// Endpoints within a config file are unmarshalled the same way
+	var config map[string]factory.Endpoints
+	err := json.Unmarshal([]byte(`{"luno":{"timeout":"250ms"}}`), &config)
+	require.NoError(t, err)
+	assert.Equal(t, 250*time.Millisecond, config["luno"].Timeout)
+}
+
+func TestWithEndpointsAndHttpClient(t *testing.T) {
+
+	binanceFake := fakeexchange.NewBinance("key", "secret")
+	defer binanceFake.Close()
+	lunoFake := fakeexchange.NewLuno("key", "secret")
+	defer lunoFake.Close()
+
+	var requests int
+	var mu sync.Mutex
+	httpClient := &http.Client{
+		Transport: requestutil.RoundTripFunc(func(req *http.Request) *http.Response {
+
+			mu.Lock()
+			requests++
+			mu.Unlock()
+
+			res, err := http.DefaultTransport.RoundTrip(req)
+			require.NoError(t, err)
+			return res
+		}),
+	}
+
+	opts := []factory.Option{
+		factory.WithEndpoints(crypto.ApiProviderBinance, factory.Endpoints{
+			BaseUrl: binanceFake.URL(),
+			WsUrl:   binanceFake.WsURL(),
+			Timeout: time.Second,
+		}),
+		factory.WithEndpoints(crypto.ApiProviderLuno, factory.Endpoints{
+			BaseUrl: lunoFake.URL(),
+			WsUrl:   lunoFake.WsURL(),
+		}),
+		factory.WithEndpoints(crypto.ApiProviderBinanceTestnet, factory.Endpoints{
+			BaseUrl: binanceFake.URL(),
+			WsUrl:   binanceFake.WsURL(),
+		}),
+		factory.WithHttpClient(httpClient),
+	}
+
+	testCases := []struct {
+		Name     string
+		Provider crypto.ApiProvider
+		Fake     fakeExchange
+		Market   string
+	}{
+		{
+			Name:     "Binance",
+			Provider: crypto.ApiProviderBinance,
+			Fake:     binanceFake,
+			Market:   "BTCEUR",
+		},
+		{
+			Name:     "Luno",
+			Provider: crypto.ApiProviderLuno,
+			Fake:     lunoFake,
+			Market:   "XBTEUR",
+		},
+		{
+			Name:     "Binance testnet",
+			Provider: crypto.ApiProviderBinanceTestnet,
+			Fake:     binanceFake,
+			Market:   "BTCEUR",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+
+			mu.Lock()
+			requests = 0
+			mu.Unlock()
+
+			exchange := crypto.Exchange{
+				Provider: test.Provider,
+				Pair:     crypto.PairBTCEUR,
+			}
+			test.Fake.SetOrderBook(test.Market, book(99, 101))
+
+			c, err := factory.NewClient(exchange, "key", "secret", opts...)
+			require.NoError(t, err)
+			assert.Equal(t, exchange, c.Exchange())
+
+			_, err = c.LatestPrice(context.Background())
+			require.NoError(t, err)
+
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, 1, requests)
+		})
+	}
+}
+
+func TestBinanceTestnetDoesNotUseBinanceEndpoints(t *testing.T) {
+
+	prodFake := fakeexchange.NewBinance("key", "secret")
+	defer prodFake.Close()
+	prodFake.SetOrderBook("BTCEUR", book(99, 101))
+	testnetFake := fakeexchange.NewBinance("key", "secret")
+	defer testnetFake.Close()
+	testnetFake.SetOrderBook("BTCEUR", book(49, 51))
+
+	opts := []factory.Option{
+		factory.WithEndpoints(crypto.ApiProviderBinance, factory.Endpoints{
+			BaseUrl: prodFake.URL(),
+			WsUrl:   prodFake.WsURL(),
+		}),
+		factory.WithEndpoints(crypto.ApiProviderBinanceTestnet, factory.Endpoints{
+			BaseUrl: testnetFake.URL(),
+			WsUrl:   testnetFake.WsURL(),
+		}),
+	}
+
+	testCases := []struct {
+		Name     string
+		Provider crypto.ApiProvider
+		Price    float64
+	}{
+		{
+			Name:     "Binance",
+			Provider: crypto.ApiProviderBinance,
+			Price:    100,
+		},
+		{
+			Name:     "Binance testnet",
+			Provider: crypto.ApiProviderBinanceTestnet,
+			Price:    50,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+
+			c, err := factory.NewClient(
+				crypto.Exchange{Provider: test.Provider, Pair: crypto.PairBTCEUR},
+				"key",
+				"secret",
+				opts...,
+			)
+			require.NoError(t, err)
+
+			price, err := c.LatestPrice(context.Background())
+			require.NoError(t, err)
+			assert.True(t, decimal.NewFromFloat(test.Price).Equal(price), price.String())
+		})
+	}
+}
+
+func TestBinanceTestnetWithoutEndpointsDoesNotUseBinanceEndpoints(t *testing.T) {
+
+	prodFake := fakeexchange.NewBinance("key", "secret")
+	defer prodFake.Close()
+
+	// Requests are recorded rather than sent, as they go to the testnet
+	var hosts []string
+	httpClient := &http.Client{
+		Transport: requestutil.RoundTripFunc(func(req *http.Request) *http.Response {
+			hosts = append(hosts, req.URL.Host)
+			return &http.Response{
+				StatusCode: http.StatusServiceUnavailable,
+				Body:       requestutil.ResBodyFromJsonf(t, `{}`),
+				Request:    req,
+			}
+		}),
+	}
+
+	c, err := factory.NewClient(
+		crypto.Exchange{Provider: crypto.ApiProviderBinanceTestnet, Pair: crypto.PairBTCEUR},
+		"key",
+		"secret",
+		factory.WithEndpoints(crypto.ApiProviderBinance, factory.Endpoints{
+			BaseUrl: prodFake.URL(),
+			WsUrl:   prodFake.WsURL(),
+		}),
+		factory.WithHttpClient(httpClient),
+	)
+	require.NoError(t, err)
+
+	_, err = c.LatestPrice(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, []string{"testnet.binance.vision"}, hosts)
+}
+
+func TestWithMetricsInstrumentsClientAndMarketFollower(t *testing.T) {
+
+	binanceFake := fakeexchange.NewBinance("key", "secret")
+	defer binanceFake.Close()
+	binanceFake.SetOrderBook("BTCEUR", book(99, 101))
+
+	m := metrics.New()
+	opts := []factory.Option{
+		factory.WithBinanceOptions(
+			binance.WithBaseUrl(binanceFake.URL()),
+			binance.WithWsUrl(binanceFake.WsURL()),
+		),
+		factory.WithMetrics(m),
+	}
+	exchange := crypto.Exchange{
+		Provider: crypto.ApiProviderBinance,
+		Pair:     crypto.PairBTCEUR,
+	}
+
+	c, err := factory.NewClient(exchange, "key", "secret", opts...)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err = c.PostLimitOrder(ctx, exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeBid,
+		Price:  decimal.NewFromFloat(90),
+		Volume: decimal.NewFromFloat(1),
+	})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	obf, _, err := factory.NewMarketFollower(
+		ctx,
+		&wg,
+		exchange,
+		crypto.AuthConfig{},
+		opts...,
+	)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return binanceFake.MarketFollowers() == 1
+	}, time.Second, time.Millisecond)
+
+	binanceFake.SetOrderBook("BTCEUR", book(98, 99))
+
+	var ob exchangesdk.OrderBook
+	for len(ob.Asks) == 0 || ob.Asks[0].Price != 99 {
+		ob = <-obf
+	}
+
+	var b strings.Builder
+	require.NoError(t, m.Registry().WriteText(&b))
+	out := b.String()
+
+	assert.Contains(t, out, `crypto_rest_requests_total{provider="binance",method="POST /api/v3/order",class="ok"} 1`)
+	assert.Contains(t, out, `crypto_rest_requests_total{provider="binance",method="GET /api/v3/depth",class="ok"} 1`)
+	assert.Contains(t, out, `crypto_follower_messages_total{provider="binance",pair="btceur",stream="order_book"}`)
+	assert.Contains(t, out, `crypto_market_best_ask{provider="binance",pair="btceur"} 99`)
+	assert.Contains(t, out, `crypto_market_best_bid{provider="binance",pair="btceur"} 98`)
+
+	cancel()
+	wg.Wait()
+}
+
+func TestNewMarketFollowerWithUnknownProviderReturnsError(t *testing.T) {
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	_, _, err := factory.NewMarketFollower(
+		context.Background(),
+		&wg,
+		crypto.Exchange{Provider: crypto.ApiProvider(100), Pair: crypto.PairBTCEUR},
+		crypto.AuthConfig{},
+	)
+	assert.Error(t, err)
+}
@@ -2,7 +2,6 @@ package factory
 
 import (
 	"context"
-	"log"
 	"sync"
 
 	"github.com/thecodedproject/crypto"
@@ -12,42 +11,199 @@ import (
 	"github.com/thecodedproject/crypto/exchangesdk/luno"
 )
 
+// NewMarketFollower starts the market follower of exchange's provider (see
+// MarketFollowerFunc for the contract of wg); Follow is simpler to use where
+// the follower is not one of many sharing a wait group.
 func NewMarketFollower(
 	ctx context.Context,
 	wg *sync.WaitGroup,
 	exchange crypto.Exchange,
 	apiAuth crypto.AuthConfig,
+	opts ...Option,
 ) (<-chan exchangesdk.OrderBook, <-chan exchangesdk.OrderBookTrade, error) {
 
-	switch exchange.Provider {
-	case crypto.ApiProviderDummyExchange:
-		return dummyclient.NewMarketFollower(
-			ctx,
-			wg,
-			exchange.Pair,
-		)
-	case crypto.ApiProviderDummyExchangeBinanceMarket:
-		return binance.NewMarketFollower(
-			ctx,
-			wg,
-			exchange.Pair,
-		)
-	case crypto.ApiProviderLuno:
-		return luno.NewOrderBookFollowerAndTradeStream(
-			ctx,
-			wg,
-			exchange.Pair,
-			apiAuth.Key,
-			apiAuth.Secret,
-		)
-	case crypto.ApiProviderBinance:
-		return binance.NewMarketFollower(
-			ctx,
-			wg,
-			exchange.Pair,
-		)
-	default:
-		log.Fatal("NewMarketFollower: Unknown exchange")
-		return nil, nil, nil
+	follow, err := lookupMarketFollower(exchange.Provider)
+	if err != nil {
+		return nil, nil, err
 	}
+
+	o := resolveOptions(opts)
+	if o.metrics == nil {
+		return follow(ctx, wg, exchange, apiAuth, o)
+	}
+
+	var followerWg sync.WaitGroup
+	followerWg.Add(1)
+	obf, tradeStream, err := follow(ctx, &followerWg, exchange, apiAuth, o)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	followerWg.Add(1)
+	obfOut, tradeStreamOut := o.metrics.FollowMarket(
+		ctx,
+		&followerWg,
+		exchange,
+		obf,
+		tradeStream,
+	)
+
+	go func() {
+		followerWg.Wait()
+		wg.Done()
+	}()
+
+	return obfOut, tradeStreamOut, nil
+}
+
+// Follower is a running market follower, which owns its goroutines
+type Follower struct {
+	orderBooks <-chan exchangesdk.OrderBook
+	trades     <-chan exchangesdk.OrderBookTrade
+	cancel     context.CancelFunc
+	done       chan struct{}
+}
+
+// Follow starts the market follower of exchange's provider, which runs until
+// ctx is cancelled or it is closed
+func Follow(
+	ctx context.Context,
+	exchange crypto.Exchange,
+	apiAuth crypto.AuthConfig,
+	opts ...Option,
+) (*Follower, error) {
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	obf, tradeStream, err := NewMarketFollower(ctx, &wg, exchange, apiAuth, opts...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	f := &Follower{
+		orderBooks: obf,
+		trades:     tradeStream,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+	}
+	go func() {
+		wg.Wait()
+		close(f.done)
+	}()
+	return f, nil
+}
+
+// OrderBooks returns the stream of order books; it is closed if the follower
+// fails, but not necessarily when it is closed
+func (f *Follower) OrderBooks() <-chan exchangesdk.OrderBook {
+
+	return f.orderBooks
+}
+
+// Trades returns the stream of trades; it is closed if the follower fails,
+// but not necessarily when it is closed
+func (f *Follower) Trades() <-chan exchangesdk.OrderBookTrade {
+
+	return f.trades
+}
+
+// Close stops the follower and waits for its goroutines to finish
+func (f *Follower) Close() {
+
+	f.cancel()
+	f.Wait()
+}
+
+// Wait waits for the follower's goroutines to finish, i.e. for it to be
+// closed, its context to be cancelled or it to fail
+func (f *Follower) Wait() {
+
+	<-f.done
+}
+
+// Done returns a channel which is closed once the follower's goroutines have
+// finished
+func (f *Follower) Done() <-chan struct{} {
+
+	return f.done
+}
+
+func followDummyMarket(
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	exchange crypto.Exchange,
+	_ crypto.AuthConfig,
+	o options,
+) (<-chan exchangesdk.OrderBook, <-chan exchangesdk.OrderBookTrade, error) {
+
+	return followSimulatedMarket(
+		ctx,
+		wg,
+		dummyExchange(exchange, o),
+		func(
+			ctx context.Context,
+			wg *sync.WaitGroup,
+		) (<-chan exchangesdk.OrderBook, <-chan exchangesdk.OrderBookTrade, error) {
+			return dummyclient.NewMarketFollower(ctx, wg, exchange.Pair, o.dummyOpts...)
+		},
+	)
+}
+
+func followDummyBinanceMarket(
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	exchange crypto.Exchange,
+	apiAuth crypto.AuthConfig,
+	o options,
+) (<-chan exchangesdk.OrderBook, <-chan exchangesdk.OrderBookTrade, error) {
+
+	return followSimulatedMarket(
+		ctx,
+		wg,
+		dummyExchange(exchange, o),
+		func(
+			ctx context.Context,
+			wg *sync.WaitGroup,
+		) (<-chan exchangesdk.OrderBook, <-chan exchangesdk.OrderBookTrade, error) {
+			return followBinance(ctx, wg, exchange, apiAuth, o)
+		},
+	)
+}
+
+func followLuno(
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	exchange crypto.Exchange,
+	apiAuth crypto.AuthConfig,
+	o options,
+) (<-chan exchangesdk.OrderBook, <-chan exchangesdk.OrderBookTrade, error) {
+
+	return luno.NewOrderBookFollowerAndTradeStream(
+		ctx,
+		wg,
+		exchange.Pair,
+		apiAuth.Key,
+		apiAuth.Secret,
+		o.lunoOptsFor(exchange)...,
+	)
+}
+
+// followBinance follows a market of any of the Binance backed providers
+func followBinance(
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	exchange crypto.Exchange,
+	_ crypto.AuthConfig,
+	o options,
+) (<-chan exchangesdk.OrderBook, <-chan exchangesdk.OrderBookTrade, error) {
+
+	return binance.NewMarketFollower(
+		ctx,
+		wg,
+		exchange.Pair,
+		o.binanceOptsFor(exchange)...,
+	)
 }
@@ -0,0 +1,138 @@
+package factory_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk/binance"
+	"github.com/thecodedproject/crypto/exchangesdk/factory"
+	"github.com/thecodedproject/crypto/exchangesdk/logging"
+	"github.com/thecodedproject/crypto/exchangesdk/metrics"
+)
+
+func TestFollowDummyExchangeReceivesOrderBooksAndStopsOnClose(t *testing.T) {
+
+	f, err := factory.Follow(
+		context.Background(),
+		crypto.Exchange{
+			Provider: crypto.ApiProviderDummyExchange,
+			Pair:     crypto.PairBTCEUR,
+		},
+		crypto.AuthConfig{},
+		factory.WithSimulatedExchanges(factory.NewSimulatedExchanges()),
+	)
+	require.NoError(t, err)
+
+	ob := <-f.OrderBooks()
+	assert.NotEmpty(t, ob.Bids)
+
+	// Nothing receives from the follower's streams while it is closed
+	closed := make(chan struct{})
+	go func() {
+		f.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close did not return")
+	}
+
+	select {
+	case <-f.Done():
+	default:
+		t.Fatal("Done not closed after Close")
+	}
+}
+
+func TestFollowStopsWhenContextCancelled(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f, err := factory.Follow(
+		ctx,
+		crypto.Exchange{
+			Provider: crypto.ApiProviderDummyExchange,
+			Pair:     crypto.PairBTCEUR,
+		},
+		crypto.AuthConfig{},
+		factory.WithSimulatedExchanges(factory.NewSimulatedExchanges()),
+	)
+	require.NoError(t, err)
+
+	cancel()
+	select {
+	case <-f.Done():
+	case <-time.After(time.Second):
+		t.Fatal("follower did not stop")
+	}
+	f.Wait()
+}
+
+func TestFollowWithUnknownProviderReturnsError(t *testing.T) {
+
+	_, err := factory.Follow(
+		context.Background(),
+		crypto.Exchange{Provider: crypto.ApiProvider(100), Pair: crypto.PairBTCEUR},
+		crypto.AuthConfig{},
+	)
+	assert.Error(t, err)
+}
+
+func TestFollowStopsAndClosesStreamsWhenFollowerFails(t *testing.T) {
+
+	testCases := []struct {
+		name     string
+		provider crypto.ApiProvider
+		opts     []factory.Option
+	}{
+		{
+			name:     "binance with metrics",
+			provider: crypto.ApiProviderBinance,
+			opts:     []factory.Option{factory.WithMetrics(metrics.New())},
+		},
+		{
+			name:     "dummy exchange with binance market",
+			provider: crypto.ApiProviderDummyExchangeBinanceMarket,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+
+			// Nothing listens on the endpoints, so the follower cannot
+			// connect
+			opts := append([]factory.Option{
+				factory.WithBinanceOptions(
+					binance.WithBaseUrl("http://127.0.0.1:1"),
+					binance.WithWsUrl("ws://127.0.0.1:1"),
+				),
+				factory.WithLogger(logging.Nop),
+				factory.WithSimulatedExchanges(factory.NewSimulatedExchanges()),
+			}, test.opts...)
+
+			f, err := factory.Follow(
+				context.Background(),
+				crypto.Exchange{Provider: test.provider, Pair: crypto.PairBTCEUR},
+				crypto.AuthConfig{},
+				opts...,
+			)
+			require.NoError(t, err)
+			defer f.Close()
+
+			select {
+			case <-f.Done():
+			case <-time.After(time.Second):
+				t.Fatal("follower did not stop after failing")
+			}
+
+			_, more := <-f.OrderBooks()
+			assert.False(t, more)
+			_, more = <-f.Trades()
+			assert.False(t, more)
+		})
+	}
+}
@@ -0,0 +1,316 @@
+package factory
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk/binance"
+	"github.com/thecodedproject/crypto/exchangesdk/dummyclient"
+	"github.com/thecodedproject/crypto/exchangesdk/logging"
+	"github.com/thecodedproject/crypto/exchangesdk/luno"
+	"github.com/thecodedproject/crypto/exchangesdk/metrics"
+	"github.com/thecodedproject/crypto/exchangesdk/recording"
+	"github.com/thecodedproject/crypto/exchangesdk/simulator"
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+type options struct {
+	replayPath  string
+	replaySpeed float64
+	binanceOpts []binance.Option
+	lunoOpts    []luno.Option
+	dummyOpts   []dummyclient.Option
+	metrics     *metrics.Metrics
+
+	// logger, clock and httpClient are the options which are passed to the
+	// constructors of registered providers (see Config)
+	logger     logging.Logger
+	clock      utiltime.Clock
+	httpClient *http.Client
+
+	// simulatorOpts and recordingOpts configure the simulated exchanges of
+	// the simulated providers, and the replaying and recording of market
+	// followers
+	simulatorOpts []simulator.Option
+	recordingOpts []recording.Option
+	simulated     *SimulatedExchanges
+
+	// binanceProdOpts and binanceTestnetOpts are the endpoints of the Binance
+	// production and testnet providers; neither is applied to the other, so
+	// that requests (including signed orders) are never sent to the wrong one
+	binanceProdOpts    []binance.Option
+	binanceTestnetOpts []binance.Option
+}
+
+// Option configures the clients and market followers built by the factory
+type Option func(*options)
+
+func resolveOptions(opts []Option) options {
+
+	o := options{
+		replaySpeed: recording.ReplaySpeedAsFastAsPossible,
+		simulated:   defaultSimulatedExchanges,
+		logger:      logging.Std,
+		clock:       utiltime.Real,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithReplayFile sets the recording which is replayed by the
+// crypto.ApiProviderReplay market follower
+func WithReplayFile(path string) Option {
+
+	return func(o *options) {
+		o.replayPath = path
+	}
+}
+
+// WithReplaySpeed sets the speed at which recordings are replayed (see
+// recording.NewMarketFollower); by default recordings are replayed as fast as
+// possible
+func WithReplaySpeed(speed float64) Option {
+
+	return func(o *options) {
+		o.replaySpeed = speed
+	}
+}
+
+// WithSimulatedExchanges sets the simulated exchanges which the clients and
+// market followers of the simulated providers use (e.g. a new one for each
+// test, so that orders placed by one are not seen by another); by default the
+// simulated exchanges are shared by everything the factory creates
+func WithSimulatedExchanges(s *SimulatedExchanges) Option {
+
+	return func(o *options) {
+		o.simulated = s
+	}
+}
+
+// WithBinanceOptions sets options for the Binance clients and market
+// followers (e.g. to point them at a fake exchange); these apply to all of the
+// Binance backed providers, including crypto.ApiProviderBinanceTestnet
+func WithBinanceOptions(binanceOpts ...binance.Option) Option {
+
+	return func(o *options) {
+		o.binanceOpts = append(o.binanceOpts, binanceOpts...)
+	}
+}
+
+// WithLunoOptions sets options for the Luno clients and market followers
+func WithLunoOptions(lunoOpts ...luno.Option) Option {
+
+	return func(o *options) {
+		o.lunoOpts = append(o.lunoOpts, lunoOpts...)
+	}
+}
+
+// Endpoints configures where the client and market follower of an exchange
+// connect to; empty fields leave the exchange's defaults unchanged.
+// In JSON the timeout is a duration string (e.g. "5s"), or a number of
+// nanoseconds.
+type Endpoints struct {
+	BaseUrl string        `json:"base_url"`
+	WsUrl   string        `json:"ws_url"`
+	Timeout time.Duration `json:"timeout"`
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface for Endpoints
+func (e *Endpoints) UnmarshalJSON(data []byte) error {
+
+	// endpoints has the fields of Endpoints but not its methods, so that it
+	// is unmarshalled as usual, apart from the timeout
+	type endpoints Endpoints
+	aux := struct {
+		*endpoints
+		Timeout json.RawMessage `json:"timeout"`
+	}{
+		endpoints: (*endpoints)(e),
+	}
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	if len(aux.Timeout) == 0 || string(aux.Timeout) == "null" {
+		return nil
+	}
+
+	var s string
+	if json.Unmarshal(aux.Timeout, &s) == nil {
+		e.Timeout, err = time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("invalid endpoints timeout: %w", err)
+		}
+		return nil
+	}
+
+	var nanos int64
+	if err := json.Unmarshal(aux.Timeout, &nanos); err != nil {
+		return fmt.Errorf(
+			"endpoints timeout should be a duration string or nanoseconds, got %s",
+			aux.Timeout,
+		)
+	}
+	e.Timeout = time.Duration(nanos)
+	return nil
+}
+
+// WithEndpoints sets the endpoints used for provider (e.g. to point the
+// Binance client at a recording proxy); it has no effect on providers which do
+// not connect to an exchange.
+// The endpoints of crypto.ApiProviderBinance are not used by
+// crypto.ApiProviderBinanceTestnet, which connects to the testnet unless its
+// own endpoints are set.
+func WithEndpoints(provider crypto.ApiProvider, e Endpoints) Option {
+
+	return func(o *options) {
+		switch provider {
+		case crypto.ApiProviderBinance:
+			o.binanceProdOpts = append(o.binanceProdOpts, binanceEndpoints(e)...)
+		case crypto.ApiProviderBinanceTestnet:
+			o.binanceTestnetOpts = append(o.binanceTestnetOpts, binanceEndpoints(e)...)
+		case crypto.ApiProviderLuno:
+			if e.BaseUrl != "" {
+				o.lunoOpts = append(o.lunoOpts, luno.WithBaseUrl(e.BaseUrl))
+			}
+			if e.WsUrl != "" {
+				o.lunoOpts = append(o.lunoOpts, luno.WithWsUrl(e.WsUrl))
+			}
+			if e.Timeout != 0 {
+				o.lunoOpts = append(o.lunoOpts, luno.WithTimeout(e.Timeout))
+			}
+		}
+	}
+}
+
+func binanceEndpoints(e Endpoints) []binance.Option {
+
+	var opts []binance.Option
+	if e.BaseUrl != "" {
+		opts = append(opts, binance.WithBaseUrl(e.BaseUrl))
+	}
+	if e.WsUrl != "" {
+		opts = append(opts, binance.WithWsUrl(e.WsUrl))
+	}
+	if e.Timeout != 0 {
+		opts = append(opts, binance.WithTimeout(e.Timeout))
+	}
+	return opts
+}
+
+// WithHttpClient sets the HTTP client used for REST requests by all of the
+// exchange clients and market followers
+func WithHttpClient(httpClient *http.Client) Option {
+
+	return func(o *options) {
+		o.httpClient = httpClient
+		o.binanceOpts = append(o.binanceOpts, binance.WithHttpClient(httpClient))
+		o.lunoOpts = append(o.lunoOpts, luno.WithHttpClient(httpClient))
+	}
+}
+
+// WithClock sets the clock used by the clients and market followers built by
+// the factory (e.g. a simulated clock, so that they can be driven by
+// historical data): the timers of the Binance, Luno and dummy providers, the
+// pacing of replayed recordings and the times of recorded events, and the
+// timestamps of the simulated exchanges.
+// Components which are built on the clients rather than by the factory take
+// the same clock through their own options (see ordermanager.WithClock,
+// trailingstop.WithClock, execution.WithClock and backtest.WithClock).
+func WithClock(clock utiltime.Clock) Option {
+
+	return func(o *options) {
+		o.clock = clock
+		o.binanceOpts = append(o.binanceOpts, binance.WithClock(clock))
+		o.lunoOpts = append(o.lunoOpts, luno.WithClock(clock))
+		o.dummyOpts = append(o.dummyOpts, dummyclient.WithClock(clock))
+		o.simulatorOpts = append(o.simulatorOpts, simulator.WithClock(clock))
+		o.recordingOpts = append(o.recordingOpts, recording.WithClock(clock))
+	}
+}
+
+// WithLogger sets the logger which the exchange clients and market followers
+// (including replayed and recorded market followers) report errors to; by
+// default logging.Std is used
+func WithLogger(logger logging.Logger) Option {
+
+	return func(o *options) {
+		o.logger = logger
+		o.binanceOpts = append(o.binanceOpts, binance.WithLogger(logger))
+		o.lunoOpts = append(o.lunoOpts, luno.WithLogger(logger))
+		o.recordingOpts = append(o.recordingOpts, recording.WithLogger(logger))
+	}
+}
+
+// WithMetrics instruments the clients and market followers with m: REST
+// requests, market follower messages, reconnects and sequence gaps, and the
+// prices of the market are recorded (see metrics.Metrics)
+func WithMetrics(m *metrics.Metrics) Option {
+
+	return func(o *options) {
+		o.metrics = m
+	}
+}
+
+// config returns the options which are passed to the constructors of
+// registered providers
+func (o options) config() Config {
+
+	return Config{
+		Logger:     o.logger,
+		Clock:      o.clock,
+		HttpClient: o.httpClient,
+		Metrics:    o.metrics,
+	}
+}
+
+// binanceOptsFor returns the options for the Binance components of exchange,
+// including its instrumentation if metrics are enabled
+func (o options) binanceOptsFor(exchange crypto.Exchange) []binance.Option {
+
+	var opts []binance.Option
+	if exchange.Provider == crypto.ApiProviderBinanceTestnet {
+		opts = o.testnetBinanceOpts()
+	} else {
+		opts = append(opts, o.binanceOpts...)
+		opts = append(opts, o.binanceProdOpts...)
+	}
+	if o.metrics != nil {
+		opts = append(
+			opts,
+			binance.WithTransport(o.metrics.Transport(exchange.Provider)),
+			binance.WithFollowerObserver(o.metrics.FollowerObserver(exchange)),
+		)
+	}
+	return opts
+}
+
+// lunoOptsFor returns the options for the Luno components of exchange,
+// including its instrumentation if metrics are enabled
+func (o options) lunoOptsFor(exchange crypto.Exchange) []luno.Option {
+
+	opts := append([]luno.Option(nil), o.lunoOpts...)
+	if o.metrics != nil {
+		opts = append(
+			opts,
+			luno.WithTransport(o.metrics.Transport(exchange.Provider)),
+			luno.WithFollowerObserver(o.metrics.FollowerObserver(exchange)),
+		)
+	}
+	return opts
+}
+
+// testnetBinanceOpts returns the options for the Binance components of
+// crypto.ApiProviderBinanceTestnet
+func (o options) testnetBinanceOpts() []binance.Option {
+
+	opts := []binance.Option{binance.WithTestnet()}
+	opts = append(opts, o.binanceOpts...)
+	return append(opts, o.binanceTestnetOpts...)
+}
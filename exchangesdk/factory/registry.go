@@ -0,0 +1,208 @@
+package factory
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/logging"
+	"github.com/thecodedproject/crypto/exchangesdk/metrics"
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+// ClientFunc creates a client of exchange, configured by the factory's
+// options in cfg
+type ClientFunc func(
+	exchange crypto.Exchange,
+	apiKey string,
+	apiSecret string,
+	cfg Config,
+) (exchangesdk.Client, error)
+
+// MarketFollowerFunc starts a market follower of exchange, configured by the
+// factory's options in cfg, which streams its order books and trades until
+// ctx is cancelled.
+// The caller calls wg.Add(1) beforehand; the follower calls wg.Done once all
+// of its goroutines have finished, unless it returns an error, in which case
+// it must not have started any.
+type MarketFollowerFunc func(
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	exchange crypto.Exchange,
+	apiAuth crypto.AuthConfig,
+	cfg Config,
+) (<-chan exchangesdk.OrderBook, <-chan exchangesdk.OrderBookTrade, error)
+
+// Provider is the constructors of the clients and market followers of an Api
+// provider; either may be nil if the provider does not support it
+type Provider struct {
+	NewClient         ClientFunc
+	NewMarketFollower MarketFollowerFunc
+}
+
+// Config is the factory's options which apply to the clients and market
+// followers of a registered provider, which its constructors should honour
+type Config struct {
+	// Logger is set by WithLogger; logging.Std by default
+	Logger logging.Logger
+	// Clock is set by WithClock; utiltime.Real by default
+	Clock utiltime.Clock
+	// HttpClient is set by WithHttpClient; nil by default, in which case the
+	// provider uses its own
+	HttpClient *http.Client
+	// Metrics is set by WithMetrics; nil by default.
+	// The market followers of the provider are instrumented by the factory,
+	// but its clients should instrument their HTTP requests with
+	// Metrics.Transport.
+	Metrics *metrics.Metrics
+}
+
+// clientFunc and followerFunc are a ClientFunc and MarketFollowerFunc which
+// are configured by the factory's options
+type clientFunc func(
+	exchange crypto.Exchange,
+	apiKey string,
+	apiSecret string,
+	o options,
+) (exchangesdk.Client, error)
+
+type followerFunc func(
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	exchange crypto.Exchange,
+	apiAuth crypto.AuthConfig,
+	o options,
+) (<-chan exchangesdk.OrderBook, <-chan exchangesdk.OrderBookTrade, error)
+
+type provider struct {
+	newClient clientFunc
+	follow    followerFunc
+}
+
+var providers = struct {
+	sync.RWMutex
+	m map[crypto.ApiProvider]provider
+}{
+	m: map[crypto.ApiProvider]provider{
+		crypto.ApiProviderDummyExchange: {
+			newClient: newDummyClient,
+			follow:    followDummyMarket,
+		},
+		crypto.ApiProviderDummyExchangeBinanceMarket: {
+			newClient: newDummyClient,
+			follow:    followDummyBinanceMarket,
+		},
+		crypto.ApiProviderLuno: {
+			newClient: newLunoClient,
+			follow:    followLuno,
+		},
+		crypto.ApiProviderBinance: {
+			newClient: newBinanceClient,
+			follow:    followBinance,
+		},
+		crypto.ApiProviderBinanceTestnet: {
+			newClient: newBinanceClient,
+			follow:    followBinance,
+		},
+		crypto.ApiProviderReplay: {
+			newClient: newReplayClient,
+			follow:    newReplayMarketFollower,
+		},
+	},
+}
+
+// Register makes the provider p available under name (see
+// crypto.RegisterApiProvider), so that NewClient, NewMarketFollower and
+// Follow create the clients and market followers of its exchanges, and
+// returns its ApiProvider.
+// As with database/sql drivers, it is intended to be called from the init
+// function of the provider's package; it panics if name is already
+// registered (including as a built in provider) or reserved, or p has no
+// constructors, in which case name is not registered at all.
+// The factory's options are passed to p's constructors as a Config, and the
+// market followers of p are instrumented by WithMetrics.
+func Register(name string, p Provider) crypto.ApiProvider {
+
+	if p.NewClient == nil && p.NewMarketFollower == nil {
+		panic("factory: Register of provider " + name + " without constructors")
+	}
+
+	providers.Lock()
+	defer providers.Unlock()
+
+	// The name is only added to the names of the Api providers once it is
+	// known to be valid, so that a panic does not leave it half registered
+	if existing, err := crypto.ApiProviderString(name); err == nil {
+		if _, ok := providers.m[existing]; ok {
+			panic("factory: Register called twice for provider " + name)
+		}
+	}
+	apiProvider := crypto.RegisterApiProvider(name)
+
+	var registered provider
+	if p.NewClient != nil {
+		registered.newClient = func(
+			exchange crypto.Exchange,
+			apiKey string,
+			apiSecret string,
+			o options,
+		) (exchangesdk.Client, error) {
+			return p.NewClient(exchange, apiKey, apiSecret, o.config())
+		}
+	}
+	if p.NewMarketFollower != nil {
+		registered.follow = func(
+			ctx context.Context,
+			wg *sync.WaitGroup,
+			exchange crypto.Exchange,
+			apiAuth crypto.AuthConfig,
+			o options,
+		) (<-chan exchangesdk.OrderBook, <-chan exchangesdk.OrderBookTrade, error) {
+			return p.NewMarketFollower(ctx, wg, exchange, apiAuth, o.config())
+		}
+	}
+	providers.m[apiProvider] = registered
+	return apiProvider
+}
+
+// Providers returns the sorted names of the built in and registered providers
+func Providers() []string {
+
+	providers.RLock()
+	defer providers.RUnlock()
+
+	names := make([]string, 0, len(providers.m))
+	for p := range providers.m {
+		names = append(names, p.String())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupClient(apiProvider crypto.ApiProvider) (clientFunc, error) {
+
+	providers.RLock()
+	defer providers.RUnlock()
+
+	p, ok := providers.m[apiProvider]
+	if !ok || p.newClient == nil {
+		return nil, fmt.Errorf("Cannot create client; Unknown Api provider %s", apiProvider)
+	}
+	return p.newClient, nil
+}
+
+func lookupMarketFollower(apiProvider crypto.ApiProvider) (followerFunc, error) {
+
+	providers.RLock()
+	defer providers.RUnlock()
+
+	p, ok := providers.m[apiProvider]
+	if !ok || p.follow == nil {
+		return nil, fmt.Errorf("Cannot create market follower; Unknown Api provider %s", apiProvider)
+	}
+	return p.follow, nil
+}
@@ -0,0 +1,227 @@
+package factory_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/factory"
+	"github.com/thecodedproject/crypto/exchangesdk/logging"
+	"github.com/thecodedproject/crypto/exchangesdk/metrics"
+	"github.com/thecodedproject/crypto/exchangesdk/mockery"
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+func TestRegisteredProviderIsUsedByNewClientAndFollow(t *testing.T) {
+
+	client := &mockery.Client{}
+	var gotClientArgs []interface{}
+	var gotFollowerArgs []interface{}
+
+	apiProvider := factory.Register("test_venue", factory.Provider{
+		NewClient: func(
+			exchange crypto.Exchange,
+			apiKey string,
+			apiSecret string,
+			_ factory.Config,
+		) (exchangesdk.Client, error) {
+
+			gotClientArgs = []interface{}{exchange, apiKey, apiSecret}
+			return client, nil
+		},
+		NewMarketFollower: func(
+			ctx context.Context,
+			wg *sync.WaitGroup,
+			exchange crypto.Exchange,
+			apiAuth crypto.AuthConfig,
+			_ factory.Config,
+		) (<-chan exchangesdk.OrderBook, <-chan exchangesdk.OrderBookTrade, error) {
+
+			gotFollowerArgs = []interface{}{exchange, apiAuth}
+
+			obf := make(chan exchangesdk.OrderBook, 1)
+			obf <- book(100, 101)
+			go func() {
+				defer wg.Done()
+				<-ctx.Done()
+			}()
+			return obf, make(chan exchangesdk.OrderBookTrade), nil
+		},
+	})
+	assert.Equal(t, "test_venue", apiProvider.String())
+
+	// Exchanges of the provider are parsed like those of the built in ones
+	var exchange crypto.Exchange
+	err := json.Unmarshal([]byte(`{"provider":"test_venue","pair":"btceur"}`), &exchange)
+	require.NoError(t, err)
+	assert.Equal(t, crypto.Exchange{Provider: apiProvider, Pair: crypto.PairBTCEUR}, exchange)
+
+	c, err := factory.NewClient(exchange, "key", "secret")
+	require.NoError(t, err)
+	assert.True(t, c == client)
+	assert.Equal(t, []interface{}{exchange, "key", "secret"}, gotClientArgs)
+
+	apiAuth := crypto.AuthConfig{Provider: apiProvider, Key: "key", Secret: "secret"}
+	f, err := factory.Follow(context.Background(), exchange, apiAuth)
+	require.NoError(t, err)
+	defer f.Close()
+
+	assert.Equal(t, book(100, 101), <-f.OrderBooks())
+	assert.Equal(t, []interface{}{exchange, apiAuth}, gotFollowerArgs)
+}
+
+func TestRegisteredProviderWithoutClientReturnsErrorFromNewClient(t *testing.T) {
+
+	apiProvider := factory.Register("test_venue_without_client", factory.Provider{
+		NewMarketFollower: func(
+			context.Context,
+			*sync.WaitGroup,
+			crypto.Exchange,
+			crypto.AuthConfig,
+			factory.Config,
+		) (<-chan exchangesdk.OrderBook, <-chan exchangesdk.OrderBookTrade, error) {
+			return nil, nil, nil
+		},
+	})
+
+	_, err := factory.NewClient(
+		crypto.Exchange{Provider: apiProvider, Pair: crypto.PairBTCEUR},
+		"",
+		"",
+	)
+	assert.Error(t, err)
+}
+
+func TestRegisterPanicsForRegisteredName(t *testing.T) {
+
+	p := factory.Provider{
+		NewClient: func(crypto.Exchange, string, string, factory.Config) (exchangesdk.Client, error) {
+			return nil, nil
+		},
+	}
+	factory.Register("test_venue_twice", p)
+
+	assert.Panics(t, func() {
+		factory.Register("test_venue_twice", p)
+	})
+	assert.Panics(t, func() {
+		factory.Register("binance", p)
+	})
+}
+
+func TestRegisterPanicsWithoutConstructors(t *testing.T) {
+
+	assert.Panics(t, func() {
+		factory.Register("test_venue_without_constructors", factory.Provider{})
+	})
+
+	// The name is not left registered
+	_, err := crypto.ApiProviderString("test_venue_without_constructors")
+	assert.Error(t, err)
+	assert.NotContains(t, factory.Providers(), "test_venue_without_constructors")
+}
+
+func TestProvidersIncludesBuiltInProviders(t *testing.T) {
+
+	providers := factory.Providers()
+
+	for _, name := range []string{
+		"binance",
+		"binance_testnet",
+		"dummy_exchange",
+		"dummy_exchange_binance_market",
+		"luno",
+		"replay",
+	} {
+		assert.Contains(t, providers, name)
+	}
+	assert.NotContains(t, providers, "unknown")
+}
+
+func TestRegisterPanicsForReservedName(t *testing.T) {
+
+	p := factory.Provider{
+		NewClient: func(crypto.Exchange, string, string, factory.Config) (exchangesdk.Client, error) {
+			return nil, nil
+		},
+	}
+
+	assert.Panics(t, func() {
+		factory.Register("sentinal", p)
+	})
+	assert.NotContains(t, factory.Providers(), "sentinal")
+}
+
+func TestRegisteredProviderIsGivenOptionsOfFactory(t *testing.T) {
+
+	var clientCfg, followerCfg factory.Config
+	apiProvider := factory.Register("test_venue_with_options", factory.Provider{
+		NewClient: func(
+			_ crypto.Exchange,
+			_ string,
+			_ string,
+			cfg factory.Config,
+		) (exchangesdk.Client, error) {
+
+			clientCfg = cfg
+			return &mockery.Client{}, nil
+		},
+		NewMarketFollower: func(
+			_ context.Context,
+			wg *sync.WaitGroup,
+			_ crypto.Exchange,
+			_ crypto.AuthConfig,
+			cfg factory.Config,
+		) (<-chan exchangesdk.OrderBook, <-chan exchangesdk.OrderBookTrade, error) {
+
+			followerCfg = cfg
+			wg.Done()
+			return make(chan exchangesdk.OrderBook), make(chan exchangesdk.OrderBookTrade), nil
+		},
+	})
+	exchange := crypto.Exchange{Provider: apiProvider, Pair: crypto.PairBTCEUR}
+
+	_, err := factory.NewClient(exchange, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, factory.Config{
+		Logger: logging.Std,
+		Clock:  utiltime.Real,
+	}, clientCfg)
+
+	clock := utiltime.NewSimulatedClock(time.Unix(1000, 0))
+	httpClient := &http.Client{}
+	m := metrics.New()
+	opts := []factory.Option{
+		factory.WithLogger(logging.Nop),
+		factory.WithClock(clock),
+		factory.WithHttpClient(httpClient),
+		factory.WithMetrics(m),
+	}
+	expected := factory.Config{
+		Logger:     logging.Nop,
+		Clock:      clock,
+		HttpClient: httpClient,
+		Metrics:    m,
+	}
+
+	_, err = factory.NewClient(exchange, "", "", opts...)
+	require.NoError(t, err)
+	assert.Equal(t, expected, clientCfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	_, _, err = factory.NewMarketFollower(ctx, &wg, exchange, crypto.AuthConfig{}, opts...)
+	require.NoError(t, err)
+	cancel()
+	wg.Wait()
+	assert.Equal(t, expected, followerCfg)
+}
@@ -0,0 +1,67 @@
+package factory
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/recording"
+	"github.com/thecodedproject/crypto/exchangesdk/simulator"
+)
+
+func newReplayMarketFollower(
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	exchange crypto.Exchange,
+	_ crypto.AuthConfig,
+	opts options,
+) (<-chan exchangesdk.OrderBook, <-chan exchangesdk.OrderBookTrade, error) {
+
+	if opts.replayPath == "" {
+		return nil, nil, errors.New("Cannot create replay market follower; no replay file set")
+	}
+
+	return followSimulatedMarket(
+		ctx,
+		wg,
+		opts.simulated.get(exchange, func() *simulator.Exchange {
+			return simulator.New(exchange, opts.simulatorOpts...)
+		}),
+		func(
+			ctx context.Context,
+			wg *sync.WaitGroup,
+		) (<-chan exchangesdk.OrderBook, <-chan exchangesdk.OrderBookTrade, error) {
+			return recording.NewMarketFollower(
+				ctx,
+				wg,
+				opts.replayPath,
+				opts.replaySpeed,
+				opts.recordingOpts...,
+			)
+		},
+	)
+}
+
+// RecordMarketFollower records the events of a market follower (e.g. as
+// returned by NewMarketFollower) to the file at path, as
+// recording.RecordMarketFollower, with the clock and logger set by opts
+func RecordMarketFollower(
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	path string,
+	obf <-chan exchangesdk.OrderBook,
+	tradeStream <-chan exchangesdk.OrderBookTrade,
+	opts ...Option,
+) (<-chan exchangesdk.OrderBook, <-chan exchangesdk.OrderBookTrade, error) {
+
+	return recording.RecordMarketFollower(
+		ctx,
+		wg,
+		path,
+		obf,
+		tradeStream,
+		resolveOptions(opts).recordingOpts...,
+	)
+}
@@ -0,0 +1,194 @@
+package factory_test
+
+import (
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/factory"
+	"github.com/thecodedproject/crypto/exchangesdk/recording"
+)
+
+func writeRecording(t *testing.T, books []exchangesdk.OrderBook) string {
+
+	path := filepath.Join(t.TempDir(), "recording.gz")
+	rec, err := recording.OpenRecorder(path)
+	require.NoError(t, err)
+
+	for i, ob := range books {
+		require.NoError(t, rec.RecordOrderBook(time.Unix(int64(i+1), 0), ob))
+	}
+	require.NoError(t, rec.Close())
+	return path
+}
+
+func TestReplayProviderFillsOrdersAgainstReplayedBook(t *testing.T) {
+
+	simulated := factory.WithSimulatedExchanges(factory.NewSimulatedExchanges())
+
+	path := writeRecording(t, []exchangesdk.OrderBook{
+		{
+			Bids: []exchangesdk.OrderBookOrder{{Price: 99, Volume: 1}},
+			Asks: []exchangesdk.OrderBookOrder{{Price: 101, Volume: 1}},
+		},
+		{
+			Bids: []exchangesdk.OrderBookOrder{{Price: 98, Volume: 1}},
+			Asks: []exchangesdk.OrderBookOrder{{Price: 100, Volume: 1}},
+		},
+	})
+
+	exchange := crypto.Exchange{
+		Provider: crypto.ApiProviderReplay,
+		Pair:     crypto.PairBTCEUR,
+	}
+
+	c, err := factory.NewClient(exchange, "", "", simulated)
+	require.NoError(t, err)
+	assert.Equal(t, exchange, c.Exchange())
+
+	ctx := context.Background()
+	orderId, err := c.PostLimitOrder(ctx, exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeBid,
+		Price:  decimal.NewFromFloat(100),
+		Volume: decimal.NewFromFloat(0.5),
+	})
+	require.NoError(t, err)
+
+	status, err := c.GetOrderStatus(ctx, orderId)
+	require.NoError(t, err)
+	assert.Equal(t, exchangesdk.OrderStateInOrderBook, status.State)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	obf, _, err := factory.NewMarketFollower(
+		ctx,
+		&wg,
+		exchange,
+		crypto.AuthConfig{},
+		factory.WithReplayFile(path),
+		simulated,
+	)
+	require.NoError(t, err)
+
+	<-obf
+	<-obf
+	status, err = c.GetOrderStatus(ctx, orderId)
+	require.NoError(t, err)
+	assert.Equal(t, exchangesdk.OrderStateFilled, status.State)
+	assert.True(t, decimal.NewFromFloat(0.5).Equal(status.FillAmountBase))
+	assert.True(t, decimal.NewFromFloat(50).Equal(status.FillAmountCounter))
+
+	_, more := <-obf
+	assert.False(t, more)
+	wg.Wait()
+}
+
+func TestReplayProviderWithoutReplayFileReturnsError(t *testing.T) {
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	_, _, err := factory.NewMarketFollower(
+		context.Background(),
+		&wg,
+		crypto.Exchange{
+			Provider: crypto.ApiProviderReplay,
+			Pair:     crypto.PairBTCEUR,
+		},
+		crypto.AuthConfig{},
+	)
+	require.Error(t, err)
+}
+
+// errorLogger records the messages logged at error level
+type errorLogger struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (l *errorLogger) Debug(string, ...interface{}) {}
+func (l *errorLogger) Info(string, ...interface{})  {}
+func (l *errorLogger) Warn(string, ...interface{})  {}
+
+func (l *errorLogger) Error(msg string, args ...interface{}) {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.msgs = append(l.msgs, msg)
+}
+
+func TestReplayProviderLogsToLoggerOfFactory(t *testing.T) {
+
+	// An order book delta before any snapshot cannot be replayed
+	path := filepath.Join(t.TempDir(), "recording.gz")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	gz := gzip.NewWriter(f)
+	_, err = gz.Write([]byte(`{"k":"d","r":1,"ts":1}` + "\n"))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	require.NoError(t, f.Close())
+
+	logger := &errorLogger{}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	obf, _, err := factory.NewMarketFollower(
+		context.Background(),
+		&wg,
+		crypto.Exchange{
+			Provider: crypto.ApiProviderReplay,
+			Pair:     crypto.PairBTCEUR,
+		},
+		crypto.AuthConfig{},
+		factory.WithReplayFile(path),
+		factory.WithSimulatedExchanges(factory.NewSimulatedExchanges()),
+		factory.WithLogger(logger),
+	)
+	require.NoError(t, err)
+
+	_, more := <-obf
+	assert.False(t, more)
+	wg.Wait()
+
+	assert.Equal(t, []string{"Replay of recording failed"}, logger.msgs)
+}
+
+func TestRecordMarketFollowerLogsToLoggerOfFactory(t *testing.T) {
+
+	// Writes to /dev/full fail, so the recording cannot be closed
+	const path = "/dev/full"
+	if _, err := os.Stat(path); err != nil {
+		t.Skip(path, "is not available")
+	}
+
+	obfIn := make(chan exchangesdk.OrderBook)
+	tradeStreamIn := make(chan exchangesdk.OrderBookTrade)
+
+	logger := &errorLogger{}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	_, _, err := factory.RecordMarketFollower(
+		context.Background(),
+		&wg,
+		path,
+		obfIn,
+		tradeStreamIn,
+		factory.WithLogger(logger),
+	)
+	require.NoError(t, err)
+
+	close(obfIn)
+	close(tradeStreamIn)
+	wg.Wait()
+
+	assert.Equal(t, []string{"Recorder cannot close recording"}, logger.msgs)
+}
@@ -0,0 +1,105 @@
+package factory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/dummyclient"
+	"github.com/thecodedproject/crypto/exchangesdk/simulator"
+)
+
+// SimulatedExchanges holds the simulated exchanges of the simulated providers
+// (the dummy exchanges and replay); one per crypto.Exchange, which is shared
+// by the clients and market followers created with the same
+// SimulatedExchanges, so that orders placed with a client are filled against
+// the market data emitted by a follower (see WithSimulatedExchanges)
+type SimulatedExchanges struct {
+	mu sync.Mutex
+	m  map[crypto.Exchange]*simulator.Exchange
+}
+
+func NewSimulatedExchanges() *SimulatedExchanges {
+
+	return &SimulatedExchanges{
+		m: make(map[crypto.Exchange]*simulator.Exchange),
+	}
+}
+
+// defaultSimulatedExchanges is used by the factory unless
+// WithSimulatedExchanges is given
+var defaultSimulatedExchanges = NewSimulatedExchanges()
+
+// get returns the simulated exchange of exchange, creating it with
+// newExchange if there is none
+func (s *SimulatedExchanges) get(
+	exchange crypto.Exchange,
+	newExchange func() *simulator.Exchange,
+) *simulator.Exchange {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.m[exchange]
+	if !ok {
+		e = newExchange()
+		s.m[exchange] = e
+	}
+	return e
+}
+
+func dummyExchange(exchange crypto.Exchange, o options) *simulator.Exchange {
+
+	return o.simulated.get(exchange, func() *simulator.Exchange {
+
+		reported := exchange
+		if exchange.Provider == crypto.ApiProviderDummyExchangeBinanceMarket {
+			// When using a dummy exchange with a binance market follower, make
+			// sdk client appear to be for binance in order to pass validation
+			// checks for stats pipes
+			reported.Provider = crypto.ApiProviderBinance
+		}
+
+		// dummyclient.NewClient never returns an error
+		e, _ := dummyclient.NewClient("", "", reported, o.simulatorOpts...)
+		return e
+	})
+}
+
+// followSimulatedMarket starts a market follower with follow and feeds its
+// output into e (see simulator.FollowMarket).
+// wg.Done is called once both the follower and the feed have finished.
+func followSimulatedMarket(
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	e *simulator.Exchange,
+	follow func(
+		context.Context,
+		*sync.WaitGroup,
+	) (<-chan exchangesdk.OrderBook, <-chan exchangesdk.OrderBookTrade, error),
+) (<-chan exchangesdk.OrderBook, <-chan exchangesdk.OrderBookTrade, error) {
+
+	var followerWg sync.WaitGroup
+	followerWg.Add(1)
+	obf, tradeStream, err := follow(ctx, &followerWg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	followerWg.Add(1)
+	obfOut, tradeStreamOut := simulator.FollowMarket(
+		ctx,
+		&followerWg,
+		e,
+		obf,
+		tradeStream,
+	)
+
+	go func() {
+		followerWg.Wait()
+		wg.Done()
+	}()
+
+	return obfOut, tradeStreamOut, nil
+}
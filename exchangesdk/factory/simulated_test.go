@@ -0,0 +1,141 @@
+package factory_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/factory"
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+func TestDummyExchangeFillsOrdersAgainstDummyMarketFollower(t *testing.T) {
+
+	simulated := factory.WithSimulatedExchanges(factory.NewSimulatedExchanges())
+
+	exchange := crypto.Exchange{
+		Provider: crypto.ApiProviderDummyExchange,
+		Pair:     crypto.PairBTCEUR,
+	}
+
+	c, err := factory.NewClient(exchange, "", "", simulated)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	orderId, err := c.PostLimitOrder(ctx, exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeBid,
+		Price:  decimal.NewFromFloat(250),
+		Volume: decimal.NewFromFloat(0.5),
+	})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	obf, _, err := factory.NewMarketFollower(ctx, &wg, exchange, crypto.AuthConfig{}, simulated)
+	require.NoError(t, err)
+
+	<-obf
+	status, err := c.GetOrderStatus(ctx, orderId)
+	require.NoError(t, err)
+	assert.Equal(t, exchangesdk.OrderStateFilled, status.State)
+	assert.True(t, decimal.NewFromFloat(125).Equal(status.FillAmountCounter))
+
+	cancel()
+	wg.Wait()
+}
+
+func TestDummyExchangeBinanceMarketClientReportsBinanceProvider(t *testing.T) {
+
+	c, err := factory.NewClient(
+		crypto.Exchange{
+			Provider: crypto.ApiProviderDummyExchangeBinanceMarket,
+			Pair:     crypto.PairBTCEUR,
+		},
+		"",
+		"",
+		factory.WithSimulatedExchanges(factory.NewSimulatedExchanges()),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, crypto.Exchange{
+		Provider: crypto.ApiProviderBinance,
+		Pair:     crypto.PairBTCEUR,
+	}, c.Exchange())
+}
+
+func TestDummyMarketFollowerIsPacedByClock(t *testing.T) {
+
+	start := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := utiltime.NewSimulatedClock(start)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	obf, trades, err := factory.NewMarketFollower(
+		ctx,
+		&wg,
+		crypto.Exchange{
+			Provider: crypto.ApiProviderDummyExchange,
+			Pair:     crypto.PairBTCEUR,
+		},
+		crypto.AuthConfig{},
+		factory.WithClock(clock),
+		factory.WithSimulatedExchanges(factory.NewSimulatedExchanges()),
+	)
+	require.NoError(t, err)
+
+	for i := 1; i <= 2; i++ {
+		// Wait for the follower to wait on the clock before advancing it
+		require.Eventually(t, func() bool {
+			return clock.Waiters() == 1
+		}, time.Second, time.Millisecond)
+		clock.Advance(time.Second)
+
+		expected := start.Add(time.Duration(i) * time.Second)
+		ob := <-obf
+		assert.Equal(t, expected, ob.Timestamp)
+		trade := <-trades
+		assert.Equal(t, expected, trade.Timestamp)
+	}
+
+	cancel()
+	wg.Wait()
+}
+
+func TestSimulatedExchangesAreNotShared(t *testing.T) {
+
+	exchange := crypto.Exchange{
+		Provider: crypto.ApiProviderDummyExchange,
+		Pair:     crypto.PairBTCEUR,
+	}
+
+	simulated := factory.WithSimulatedExchanges(factory.NewSimulatedExchanges())
+	c, err := factory.NewClient(exchange, "", "", simulated)
+	require.NoError(t, err)
+	orderId, err := c.PostLimitOrder(context.Background(), exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeBid,
+		Price:  decimal.NewFromFloat(250),
+		Volume: decimal.NewFromFloat(0.5),
+	})
+	require.NoError(t, err)
+
+	same, err := factory.NewClient(exchange, "", "", simulated)
+	require.NoError(t, err)
+	_, err = same.GetOrderStatus(context.Background(), orderId)
+	assert.NoError(t, err)
+
+	other, err := factory.NewClient(
+		exchange,
+		"",
+		"",
+		factory.WithSimulatedExchanges(factory.NewSimulatedExchanges()),
+	)
+	require.NoError(t, err)
+	_, err = other.GetOrderStatus(context.Background(), orderId)
+	assert.Error(t, err)
+}
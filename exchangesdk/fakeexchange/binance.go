@@ -0,0 +1,855 @@
+package fakeexchange
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/simulator"
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+// Binance is an in-process fake of the Binance spot REST and websocket APIs,
+// for testing the binance client and market follower end to end (request
+// signing, URL building and websocket handling included).
+//
+// The market for each symbol (e.g. "BTCEUR") is scripted with SetOrderBook
+// and AddTrade, which are published on the depth and trade streams; orders
+// placed via the REST API are matched against it by a simulator.Exchange,
+// with their updates published on the user data stream.
+type Binance struct {
+	apiKey    string
+	apiSecret string
+	server    *httptest.Server
+	ctx       context.Context
+	cancel    context.CancelFunc
+
+	mu      sync.Mutex
+	markets map[string]*binanceMarket
+	// subs maps each websocket connection to the streams it subscribed to
+	subs map[*subscriber]*binanceSubscription
+
+	nextListenKey int64
+	listenKeys    map[string]bool
+	// userSubs maps each user data stream connection to its listen key
+	userSubs map[*subscriber]string
+}
+
+type binanceMarket struct {
+	sim          *simulator.Exchange
+	book         exchangesdk.OrderBook
+	lastUpdateId int64
+}
+
+type binanceSubscription struct {
+	streams map[string]bool
+	// synced is set once an order book snapshot has been served for a symbol
+	// the connection follows, after which it will apply every depth update
+	synced bool
+}
+
+type binanceError struct {
+	Code int64  `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// NewBinance starts a fake Binance server which accepts requests signed with
+// apiKey and apiSecret. Close must be called to stop it.
+func NewBinance(apiKey, apiSecret string) *Binance {
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	b := &Binance{
+		apiKey:     apiKey,
+		apiSecret:  apiSecret,
+		ctx:        ctx,
+		cancel:     cancel,
+		markets:    make(map[string]*binanceMarket),
+		subs:       make(map[*subscriber]*binanceSubscription),
+		listenKeys: make(map[string]bool),
+		userSubs:   make(map[*subscriber]string),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/ticker/price", b.handleTickerPrice)
+	mux.HandleFunc("/api/v3/depth", b.handleDepth)
+	mux.HandleFunc("/api/v3/order", b.handleOrder)
+	mux.HandleFunc("/api/v3/order/cancelReplace", b.handleCancelReplace)
+	mux.HandleFunc("/api/v3/userDataStream", b.handleUserDataStream)
+	mux.HandleFunc("/stream", b.handleStream)
+	mux.HandleFunc("/ws/", b.handleUserStream)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeJson(w, http.StatusNotFound, binanceError{Code: -1000, Msg: "Unknown path"})
+	})
+
+	b.server = httptest.NewServer(mux)
+	return b
+}
+
+// URL is the base URL of the REST API (see binance.WithBaseUrl)
+func (b *Binance) URL() string {
+
+	return b.server.URL
+}
+
+// WsURL is the base URL of the websocket streams (see binance.WithWsUrl)
+func (b *Binance) WsURL() string {
+
+	return wsUrl(b.server.URL)
+}
+
+func (b *Binance) Close() {
+
+	b.cancel()
+
+	b.mu.Lock()
+	for s := range b.subs {
+		s.close()
+	}
+	for s := range b.userSubs {
+		s.close()
+	}
+	b.mu.Unlock()
+
+	b.server.CloseClientConnections()
+	b.server.Close()
+}
+
+// MarketFollowers returns the number of connected market followers which
+// have fetched an order book snapshot, and so will emit any further changes
+// to the order book
+func (b *Binance) MarketFollowers() int {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := 0
+	for _, sub := range b.subs {
+		if sub.synced {
+			n++
+		}
+	}
+	return n
+}
+
+// UserStreams returns the number of connected user data streams
+func (b *Binance) UserStreams() int {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.userSubs)
+}
+
+// DropUserStreams disconnects all of the user data streams (e.g. to test
+// reconnecting)
+func (b *Binance) DropUserStreams() {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for s := range b.userSubs {
+		s.close()
+		delete(b.userSubs, s)
+	}
+}
+
+// SetOrderBook replaces the order book for symbol, publishing the changed
+// price levels on the depth stream
+func (b *Binance) SetOrderBook(symbol string, ob exchangesdk.OrderBook) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	m := b.market(symbol)
+	bids := diffBinanceLevels(m.book.Bids, ob.Bids)
+	asks := diffBinanceLevels(m.book.Asks, ob.Asks)
+
+	m.book = ob
+	m.lastUpdateId++
+	m.sim.UpdateOrderBook(ob)
+
+	b.publish(strings.ToLower(symbol)+"@depth", struct {
+		Event         string     `json:"e"`
+		EventTime     int64      `json:"E"`
+		Symbol        string     `json:"s"`
+		FirstUpdateId int64      `json:"U"`
+		LastUpdateId  int64      `json:"u"`
+		Bids          [][]string `json:"b"`
+		Asks          [][]string `json:"a"`
+	}{
+		Event:         "depthUpdate",
+		EventTime:     eventTime(ob.Timestamp),
+		Symbol:        symbol,
+		FirstUpdateId: m.lastUpdateId,
+		LastUpdateId:  m.lastUpdateId,
+		Bids:          bids,
+		Asks:          asks,
+	})
+}
+
+// AddTrade publishes a market trade for symbol on the trade stream, filling
+// any orders which it trades through
+func (b *Binance) AddTrade(symbol string, t exchangesdk.OrderBookTrade) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.market(symbol).sim.AddTrade(t)
+
+	b.publish(strings.ToLower(symbol)+"@trade", struct {
+		Event        string `json:"e"`
+		EventTime    int64  `json:"E"`
+		Symbol       string `json:"s"`
+		Price        string `json:"p"`
+		Volume       string `json:"q"`
+		BuyerIsMaker bool   `json:"m"`
+	}{
+		Event:        "trade",
+		EventTime:    eventTime(t.Timestamp),
+		Symbol:       symbol,
+		Price:        formatFloat(t.Price),
+		Volume:       formatFloat(t.Volume),
+		BuyerIsMaker: t.MakerSide == exchangesdk.OrderBookSideBid,
+	})
+}
+
+func (b *Binance) market(symbol string) *binanceMarket {
+
+	m, ok := b.markets[symbol]
+	if !ok {
+		m = &binanceMarket{
+			sim: simulator.New(crypto.Exchange{Provider: crypto.ApiProviderBinance}),
+		}
+		b.markets[symbol] = m
+
+		updates, err := m.sim.OrderUpdates(b.ctx)
+		if err != nil {
+			panic(err)
+		}
+		go b.publishExecutionReports(symbol, updates)
+	}
+	return m
+}
+
+// publishExecutionReports publishes the updates to orders on symbol to the
+// user data streams
+func (b *Binance) publishExecutionReports(
+	symbol string,
+	updates <-chan exchangesdk.OrderUpdate,
+) {
+
+	for u := range updates {
+		b.mu.Lock()
+		status, err := b.markets[symbol].sim.GetOrderStatus(b.ctx, u.OrderId)
+		if err != nil {
+			panic(err)
+		}
+
+		report, ok := newExecutionReport(symbol, status.Type, u)
+		if ok {
+			msg, err := json.Marshal(report)
+			if err != nil {
+				panic(err)
+			}
+			for s := range b.userSubs {
+				s.send(msg)
+			}
+		}
+		b.mu.Unlock()
+	}
+}
+
+type binanceExecutionReport struct {
+	Event             string `json:"e"`
+	EventTime         int64  `json:"E"`
+	Symbol            string `json:"s"`
+	ClientOrderId     string `json:"c"`
+	Side              string `json:"S"`
+	OrigClientOrderId string `json:"C"`
+	ExecutionType     string `json:"x"`
+	Status            string `json:"X"`
+	LastQty           string `json:"l"`
+	CumQty            string `json:"z"`
+	LastPrice         string `json:"L"`
+	Commission        string `json:"n"`
+	CommissionAsset   string `json:"N"`
+	TransactionTime   int64  `json:"T"`
+	IsWorking         bool   `json:"w"`
+	CumQuoteQty       string `json:"Z"`
+}
+
+// newExecutionReport converts u to an execution report, returning false for
+// updates which Binance does not report separately (i.e. an order becoming
+// filled, which is reported by the trade which fills it)
+func newExecutionReport(
+	symbol string,
+	orderType exchangesdk.OrderType,
+	u exchangesdk.OrderUpdate,
+) (binanceExecutionReport, bool) {
+
+	status, isWorking := binanceOrderStatus(exchangesdk.OrderStatus{
+		State:          u.State,
+		FillAmountBase: u.FillAmountBase,
+	})
+
+	r := binanceExecutionReport{
+		Event:           "executionReport",
+		EventTime:       eventTime(u.Timestamp),
+		Symbol:          symbol,
+		ClientOrderId:   u.OrderId,
+		Side:            "BUY",
+		Status:          status,
+		LastQty:         "0",
+		CumQty:          u.FillAmountBase.String(),
+		LastPrice:       "0",
+		Commission:      "0",
+		TransactionTime: eventTime(u.Timestamp),
+		IsWorking:       isWorking,
+		CumQuoteQty:     u.FillAmountCounter.String(),
+	}
+	if orderType == exchangesdk.OrderTypeAsk {
+		r.Side = "SELL"
+	}
+
+	if u.Type == exchangesdk.OrderUpdateTypeFill {
+		r.ExecutionType = "TRADE"
+		r.LastQty = u.FillVolume.String()
+		r.LastPrice = u.FillPrice.String()
+		// All symbols supported by the binance client have a three letter
+		// base asset
+		if u.BaseFee.IsPositive() {
+			r.Commission = u.BaseFee.String()
+			r.CommissionAsset = symbol[:3]
+		} else if u.CounterFee.IsPositive() {
+			r.Commission = u.CounterFee.String()
+			r.CommissionAsset = symbol[3:]
+		}
+		return r, true
+	}
+
+	switch u.State {
+	case exchangesdk.OrderStateAwaitingTrigger, exchangesdk.OrderStateInOrderBook:
+		r.ExecutionType = "NEW"
+	case exchangesdk.OrderStateCancelled:
+		// Cancels are reported with the client order id of the cancel request
+		r.ExecutionType = "CANCELED"
+		r.ClientOrderId = "cancel-" + u.OrderId
+		r.OrigClientOrderId = u.OrderId
+	default:
+		return binanceExecutionReport{}, false
+	}
+	return r, true
+}
+
+func (b *Binance) publish(stream string, data interface{}) {
+
+	msg, err := json.Marshal(struct {
+		Stream string      `json:"stream"`
+		Data   interface{} `json:"data"`
+	}{
+		Stream: stream,
+		Data:   data,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	for s, sub := range b.subs {
+		if sub.streams[stream] {
+			s.send(msg)
+		}
+	}
+}
+
+func (b *Binance) handleTickerPrice(w http.ResponseWriter, r *http.Request) {
+
+	symbol := r.URL.Query().Get("symbol")
+
+	b.mu.Lock()
+	price, err := b.market(symbol).sim.LatestPrice(r.Context())
+	b.mu.Unlock()
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, binanceError{Code: -1121, Msg: err.Error()})
+		return
+	}
+
+	writeJson(w, http.StatusOK, struct {
+		Symbol string `json:"symbol"`
+		Price  string `json:"price"`
+	}{
+		Symbol: symbol,
+		Price:  price.String(),
+	})
+}
+
+func (b *Binance) handleDepth(w http.ResponseWriter, r *http.Request) {
+
+	symbol := r.URL.Query().Get("symbol")
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	m := b.market(symbol)
+	for _, sub := range b.subs {
+		if sub.streams[strings.ToLower(symbol)+"@depth"] {
+			sub.synced = true
+		}
+	}
+
+	writeJson(w, http.StatusOK, struct {
+		LastUpdateId int64      `json:"lastUpdateId"`
+		Bids         [][]string `json:"bids"`
+		Asks         [][]string `json:"asks"`
+	}{
+		LastUpdateId: m.lastUpdateId,
+		Bids:         diffBinanceLevels(nil, m.book.Bids),
+		Asks:         diffBinanceLevels(nil, m.book.Asks),
+	})
+}
+
+func (b *Binance) handleOrder(w http.ResponseWriter, r *http.Request) {
+
+	if !b.authenticated(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sim := b.market(query.Get("symbol")).sim
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodPost:
+		id, err := postBinanceOrder(ctx, sim, query.Get)
+		if err != nil {
+			writeJson(w, http.StatusBadRequest, binanceError{Code: -1013, Msg: err.Error()})
+			return
+		}
+		writeJson(w, http.StatusOK, struct {
+			Symbol        string `json:"symbol"`
+			ClientOrderId string `json:"clientOrderId"`
+		}{
+			Symbol:        query.Get("symbol"),
+			ClientOrderId: id,
+		})
+	case http.MethodGet:
+		b.writeOrderStatus(w, ctx, sim, query.Get("origClientOrderId"))
+	case http.MethodDelete:
+		err := sim.CancelOrder(ctx, query.Get("origClientOrderId"))
+		if err != nil {
+			writeJson(w, http.StatusBadRequest, binanceError{Code: -2011, Msg: "Unknown order sent."})
+			return
+		}
+		writeJson(w, http.StatusOK, struct {
+			ClientOrderId string `json:"origClientOrderId"`
+			Status        string `json:"status"`
+		}{
+			ClientOrderId: query.Get("origClientOrderId"),
+			Status:        "CANCELED",
+		})
+	default:
+		writeJson(w, http.StatusMethodNotAllowed, binanceError{Code: -1000, Msg: "Unsupported method"})
+	}
+}
+
+// handleCancelReplace cancels an order and posts its replacement, in
+// STOP_ON_FAILURE mode (the replacement is only posted if the cancel
+// succeeds)
+func (b *Binance) handleCancelReplace(w http.ResponseWriter, r *http.Request) {
+
+	if !b.authenticated(w, r) {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		writeJson(w, http.StatusMethodNotAllowed, binanceError{Code: -1000, Msg: "Unsupported method"})
+		return
+	}
+
+	query := r.URL.Query()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sim := b.market(query.Get("symbol")).sim
+	ctx := r.Context()
+
+	type cancelReplaceData struct {
+		CancelResult     string      `json:"cancelResult"`
+		NewOrderResult   string      `json:"newOrderResult"`
+		CancelResponse   interface{} `json:"cancelResponse"`
+		NewOrderResponse interface{} `json:"newOrderResponse"`
+	}
+	type cancelReplaceError struct {
+		binanceError
+		Data cancelReplaceData `json:"data"`
+	}
+
+	origId := query.Get("cancelOrigClientOrderId")
+	err := sim.CancelOrder(ctx, origId)
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, cancelReplaceError{
+			binanceError: binanceError{Code: -2022, Msg: "Order cancel-replace failed."},
+			Data: cancelReplaceData{
+				CancelResult:   "FAILURE",
+				NewOrderResult: "NOT_ATTEMPTED",
+				CancelResponse: binanceError{Code: -2011, Msg: "Unknown order sent."},
+			},
+		})
+		return
+	}
+
+	status, err := sim.GetOrderStatus(ctx, origId)
+	if err != nil {
+		writeJson(w, http.StatusInternalServerError, binanceError{Code: -1000, Msg: err.Error()})
+		return
+	}
+
+	side := "BUY"
+	if status.Type == exchangesdk.OrderTypeAsk {
+		side = "SELL"
+	}
+	orderStatus, _ := binanceOrderStatus(status)
+	cancelResponse := struct {
+		Symbol              string `json:"symbol"`
+		OrigClientOrderId   string `json:"origClientOrderId"`
+		Status              string `json:"status"`
+		Side                string `json:"side"`
+		ExecutedQty         string `json:"executedQty"`
+		CummulativeQuoteQty string `json:"cummulativeQuoteQty"`
+	}{
+		Symbol:              query.Get("symbol"),
+		OrigClientOrderId:   origId,
+		Status:              orderStatus,
+		Side:                side,
+		ExecutedQty:         status.FillAmountBase.String(),
+		CummulativeQuoteQty: status.FillAmountCounter.String(),
+	}
+
+	id, err := postBinanceOrder(ctx, sim, query.Get)
+	if err != nil {
+		writeJson(w, http.StatusConflict, cancelReplaceError{
+			binanceError: binanceError{Code: -2021, Msg: "Order cancel-replace partially failed."},
+			Data: cancelReplaceData{
+				CancelResult:     "SUCCESS",
+				NewOrderResult:   "FAILURE",
+				CancelResponse:   cancelResponse,
+				NewOrderResponse: binanceError{Code: -1013, Msg: err.Error()},
+			},
+		})
+		return
+	}
+
+	writeJson(w, http.StatusOK, cancelReplaceData{
+		CancelResult:   "SUCCESS",
+		NewOrderResult: "SUCCESS",
+		CancelResponse: cancelResponse,
+		NewOrderResponse: struct {
+			Symbol        string `json:"symbol"`
+			ClientOrderId string `json:"clientOrderId"`
+		}{
+			Symbol:        query.Get("symbol"),
+			ClientOrderId: id,
+		},
+	})
+}
+
+// authenticated checks the api key and HMAC signature of r, writing an error
+// response if they are not valid
+func (b *Binance) authenticated(w http.ResponseWriter, r *http.Request) bool {
+
+	if r.Header.Get("X-MBX-APIKEY") != b.apiKey {
+		writeJson(w, http.StatusUnauthorized, binanceError{Code: -2014, Msg: "API-key format invalid."})
+		return false
+	}
+
+	query := r.URL.Query()
+	signature := query.Get("signature")
+	query.Del("signature")
+
+	mac := hmac.New(sha256.New, []byte(b.apiSecret))
+	mac.Write([]byte(query.Encode()))
+	if !hmac.Equal([]byte(signature), []byte(hex.EncodeToString(mac.Sum(nil)))) {
+		writeJson(w, http.StatusUnauthorized, binanceError{Code: -1022, Msg: "Signature for this request is not valid."})
+		return false
+	}
+
+	if query.Get("timestamp") == "" {
+		writeJson(w, http.StatusBadRequest, binanceError{Code: -1102, Msg: "Mandatory parameter 'timestamp' was not sent."})
+		return false
+	}
+
+	return true
+}
+
+func postBinanceOrder(
+	ctx context.Context,
+	sim *simulator.Exchange,
+	param func(string) string,
+) (string, error) {
+
+	side := exchangesdk.OrderBookSideBid
+	if param("side") == "SELL" {
+		side = exchangesdk.OrderBookSideAsk
+	}
+
+	price, err := decimal.NewFromString(param("price"))
+	if err != nil {
+		return "", err
+	}
+	volume, err := decimal.NewFromString(param("quantity"))
+	if err != nil {
+		return "", err
+	}
+
+	if param("type") == "STOP_LOSS_LIMIT" {
+		stopPrice, err := decimal.NewFromString(param("stopPrice"))
+		if err != nil {
+			return "", err
+		}
+		return sim.PostStopLimitOrder(ctx, exchangesdk.StopLimitOrder{
+			Side:       side,
+			StopPrice:  stopPrice,
+			LimitPrice: price,
+			Volume:     volume,
+		})
+	}
+
+	orderType := exchangesdk.OrderTypeBid
+	if side == exchangesdk.OrderBookSideAsk {
+		orderType = exchangesdk.OrderTypeAsk
+	}
+	return sim.PostLimitOrder(ctx, exchangesdk.Order{
+		Type:   orderType,
+		Price:  price,
+		Volume: volume,
+	})
+}
+
+func (b *Binance) writeOrderStatus(
+	w http.ResponseWriter,
+	ctx context.Context,
+	sim *simulator.Exchange,
+	id string,
+) {
+
+	status, err := sim.GetOrderStatus(ctx, id)
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, binanceError{Code: -2013, Msg: "Order does not exist."})
+		return
+	}
+
+	res := struct {
+		ClientOrderId       string `json:"clientOrderId"`
+		Status              string `json:"status"`
+		Side                string `json:"side"`
+		ExecutedQty         string `json:"executedQty"`
+		CummulativeQuoteQty string `json:"cummulativeQuoteQty"`
+		IsWorking           bool   `json:"isWorking"`
+	}{
+		ClientOrderId:       id,
+		Side:                "BUY",
+		ExecutedQty:         status.FillAmountBase.String(),
+		CummulativeQuoteQty: status.FillAmountCounter.String(),
+	}
+	if status.Type == exchangesdk.OrderTypeAsk {
+		res.Side = "SELL"
+	}
+	res.Status, res.IsWorking = binanceOrderStatus(status)
+
+	writeJson(w, http.StatusOK, res)
+}
+
+// binanceOrderStatus returns the Binance order status and isWorking flag of
+// an order
+func binanceOrderStatus(status exchangesdk.OrderStatus) (string, bool) {
+
+	switch status.State {
+	case exchangesdk.OrderStateAwaitingTrigger:
+		return "NEW", false
+	case exchangesdk.OrderStateInOrderBook:
+		if status.FillAmountBase.IsPositive() {
+			return "PARTIALLY_FILLED", true
+		}
+		return "NEW", true
+	case exchangesdk.OrderStateFilled:
+		return "FILLED", true
+	case exchangesdk.OrderStateCancelled:
+		return "CANCELED", false
+	default:
+		return "", false
+	}
+}
+
+func (b *Binance) handleUserDataStream(w http.ResponseWriter, r *http.Request) {
+
+	if r.Header.Get("X-MBX-APIKEY") != b.apiKey {
+		writeJson(w, http.StatusUnauthorized, binanceError{Code: -2014, Msg: "API-key format invalid."})
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if r.Method == http.MethodPost {
+		b.nextListenKey++
+		listenKey := fmt.Sprintf("listen-key-%d", b.nextListenKey)
+		b.listenKeys[listenKey] = true
+		writeJson(w, http.StatusOK, struct {
+			ListenKey string `json:"listenKey"`
+		}{
+			ListenKey: listenKey,
+		})
+		return
+	}
+
+	listenKey := r.URL.Query().Get("listenKey")
+	if !b.listenKeys[listenKey] {
+		writeJson(w, http.StatusBadRequest, binanceError{Code: -1125, Msg: "This listenKey does not exist."})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+	case http.MethodDelete:
+		delete(b.listenKeys, listenKey)
+		for s, key := range b.userSubs {
+			if key == listenKey {
+				s.close()
+				delete(b.userSubs, s)
+			}
+		}
+	default:
+		writeJson(w, http.StatusMethodNotAllowed, binanceError{Code: -1000, Msg: "Unsupported method"})
+		return
+	}
+	writeJson(w, http.StatusOK, struct{}{})
+}
+
+func (b *Binance) handleUserStream(w http.ResponseWriter, r *http.Request) {
+
+	listenKey := strings.TrimPrefix(r.URL.Path, "/ws/")
+
+	b.mu.Lock()
+	valid := b.listenKeys[listenKey]
+	b.mu.Unlock()
+	if !valid {
+		writeJson(w, http.StatusBadRequest, binanceError{Code: -1125, Msg: "This listenKey does not exist."})
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	s := newSubscriber(conn)
+
+	b.mu.Lock()
+	b.userSubs[s] = listenKey
+	b.mu.Unlock()
+
+	s.waitForClose()
+
+	b.mu.Lock()
+	delete(b.userSubs, s)
+	b.mu.Unlock()
+}
+
+func (b *Binance) handleStream(w http.ResponseWriter, r *http.Request) {
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	sub := &binanceSubscription{
+		streams: make(map[string]bool),
+	}
+	for _, stream := range strings.Split(r.URL.Query().Get("streams"), "/") {
+		sub.streams[stream] = true
+	}
+
+	s := newSubscriber(conn)
+
+	b.mu.Lock()
+	b.subs[s] = sub
+	b.mu.Unlock()
+
+	s.waitForClose()
+
+	b.mu.Lock()
+	delete(b.subs, s)
+	b.mu.Unlock()
+}
+
+// diffBinanceLevels returns the price levels (as [price, volume] strings)
+// which have changed between prev and next, with removed levels having zero
+// volume. Orders at the same price are combined into a single level.
+func diffBinanceLevels(prev, next []exchangesdk.OrderBookOrder) [][]string {
+
+	prevLevels := levelVolumes(prev)
+	nextLevels := levelVolumes(next)
+
+	var prices []float64
+	for price, volume := range nextLevels {
+		if prevVolume, ok := prevLevels[price]; !ok || prevVolume != volume {
+			prices = append(prices, price)
+		}
+	}
+	for price := range prevLevels {
+		if _, ok := nextLevels[price]; !ok {
+			prices = append(prices, price)
+		}
+	}
+	sort.Float64s(prices)
+
+	levels := make([][]string, 0, len(prices))
+	for _, price := range prices {
+		levels = append(levels, []string{
+			formatFloat(price),
+			formatFloat(nextLevels[price]),
+		})
+	}
+	return levels
+}
+
+func levelVolumes(orders []exchangesdk.OrderBookOrder) map[float64]float64 {
+
+	levels := make(map[float64]float64)
+	for _, o := range orders {
+		levels[o.Price] += o.Volume
+	}
+	return levels
+}
+
+func eventTime(t time.Time) int64 {
+
+	if t.IsZero() {
+		return unixMilli(utiltime.Now())
+	}
+	return unixMilli(t)
+}
+
+func writeJson(w http.ResponseWriter, status int, v interface{}) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
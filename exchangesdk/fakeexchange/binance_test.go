@@ -0,0 +1,353 @@
+package fakeexchange_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/binance"
+	"github.com/thecodedproject/crypto/exchangesdk/fakeexchange"
+)
+
+func D(f float64) decimal.Decimal {
+
+	return decimal.NewFromFloat(f)
+}
+
+func book(bid, ask float64) exchangesdk.OrderBook {
+
+	return exchangesdk.OrderBook{
+		Bids: []exchangesdk.OrderBookOrder{{Price: bid, Volume: 1}},
+		Asks: []exchangesdk.OrderBookOrder{{Price: ask, Volume: 1}},
+	}
+}
+
+func TestBinanceClientPlacesAndFillsOrders(t *testing.T) {
+
+	fake := fakeexchange.NewBinance("key", "secret")
+	defer fake.Close()
+	fake.SetOrderBook("BTCEUR", book(99, 101))
+
+	c, err := binance.NewClient(
+		"key",
+		"secret",
+		crypto.PairBTCEUR,
+		binance.WithBaseUrl(fake.URL()),
+	)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	price, err := c.LatestPrice(ctx)
+	require.NoError(t, err)
+	assert.True(t, D(100).Equal(price))
+
+	bidId, err := c.PostLimitOrder(ctx, exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeBid,
+		Price:  D(100),
+		Volume: D(0.5),
+	})
+	require.NoError(t, err)
+
+	askId, err := c.PostLimitOrder(ctx, exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeAsk,
+		Price:  D(105),
+		Volume: D(0.5),
+	})
+	require.NoError(t, err)
+
+	status, err := c.GetOrderStatus(ctx, bidId)
+	require.NoError(t, err)
+	assert.Equal(t, exchangesdk.OrderStateInOrderBook, status.State)
+	assert.Equal(t, exchangesdk.OrderTypeBid, status.Type)
+
+	fake.SetOrderBook("BTCEUR", book(98, 99.5))
+
+	status, err = c.GetOrderStatus(ctx, bidId)
+	require.NoError(t, err)
+	assert.Equal(t, exchangesdk.OrderStateFilled, status.State)
+	assert.True(t, D(0.5).Equal(status.FillAmountBase))
+	assert.True(t, D(50).Equal(status.FillAmountCounter))
+
+	require.NoError(t, c.CancelOrder(ctx, askId))
+	status, err = c.GetOrderStatus(ctx, askId)
+	require.NoError(t, err)
+	assert.Equal(t, exchangesdk.OrderStateCancelled, status.State)
+	assert.Equal(t, exchangesdk.OrderTypeAsk, status.Type)
+
+	assert.Error(t, c.CancelOrder(ctx, askId))
+}
+
+func TestBinanceClientStopLimitOrderAwaitsTrigger(t *testing.T) {
+
+	fake := fakeexchange.NewBinance("key", "secret")
+	defer fake.Close()
+	fake.SetOrderBook("BTCEUR", book(99, 101))
+
+	c, err := binance.NewClient(
+		"key",
+		"secret",
+		crypto.PairBTCEUR,
+		binance.WithBaseUrl(fake.URL()),
+	)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	id, err := c.PostStopLimitOrder(ctx, exchangesdk.StopLimitOrder{
+		Side:       exchangesdk.OrderBookSideAsk,
+		StopPrice:  D(95),
+		LimitPrice: D(94),
+		Volume:     D(1),
+	})
+	require.NoError(t, err)
+
+	status, err := c.GetOrderStatus(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, exchangesdk.OrderStateAwaitingTrigger, status.State)
+
+	fake.AddTrade("BTCEUR", exchangesdk.OrderBookTrade{Price: 95, Volume: 1})
+
+	status, err = c.GetOrderStatus(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, exchangesdk.OrderStateFilled, status.State)
+}
+
+func TestBinanceClientReplaceOrder(t *testing.T) {
+
+	fake := fakeexchange.NewBinance("key", "secret")
+	defer fake.Close()
+	fake.SetOrderBook("BTCEUR", book(99, 101))
+
+	c, err := binance.NewClient(
+		"key",
+		"secret",
+		crypto.PairBTCEUR,
+		binance.WithBaseUrl(fake.URL()),
+	)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	id, err := c.PostLimitOrder(ctx, exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeBid,
+		Price:  D(98),
+		Volume: D(1),
+	})
+	require.NoError(t, err)
+
+	res, err := exchangesdk.ReplaceOrder(ctx, c, id, exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeBid,
+		Price:  D(99),
+		Volume: D(1),
+	})
+	require.NoError(t, err)
+	assert.True(t, res.Cancelled)
+	assert.Equal(t, exchangesdk.OrderStateCancelled, res.Original.State)
+	require.NotEmpty(t, res.NewOrderId)
+
+	status, err := c.GetOrderStatus(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, exchangesdk.OrderStateCancelled, status.State)
+
+	status, err = c.GetOrderStatus(ctx, res.NewOrderId)
+	require.NoError(t, err)
+	assert.Equal(t, exchangesdk.OrderStateInOrderBook, status.State)
+
+	// The original is no longer open, so replacing it again fails without
+	// posting a new order
+	res, err = exchangesdk.ReplaceOrder(ctx, c, id, exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeBid,
+		Price:  D(97),
+		Volume: D(1),
+	})
+	require.Error(t, err)
+	assert.Equal(t, exchangesdk.ReplaceResult{}, res)
+}
+
+func TestBinanceClientWithWrongCredentialsReturnsError(t *testing.T) {
+
+	fake := fakeexchange.NewBinance("key", "secret")
+	defer fake.Close()
+
+	testCases := []struct {
+		Name   string
+		Key    string
+		Secret string
+	}{
+		{Name: "Wrong key", Key: "other", Secret: "secret"},
+		{Name: "Wrong secret", Key: "key", Secret: "other"},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+			c, err := binance.NewClient(
+				test.Key,
+				test.Secret,
+				crypto.PairBTCEUR,
+				binance.WithBaseUrl(fake.URL()),
+			)
+			require.NoError(t, err)
+
+			_, err = c.PostLimitOrder(context.Background(), exchangesdk.Order{
+				Type:   exchangesdk.OrderTypeBid,
+				Price:  D(100),
+				Volume: D(1),
+			})
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestBinanceMarketFollowerFollowsFakeMarket(t *testing.T) {
+
+	fake := fakeexchange.NewBinance("key", "secret")
+	defer fake.Close()
+	fake.SetOrderBook("BTCEUR", book(99, 101))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	obf, tradeStream, err := binance.NewMarketFollower(
+		ctx,
+		&wg,
+		crypto.PairBTCEUR,
+		binance.WithBaseUrl(fake.URL()),
+		binance.WithWsUrl(fake.WsURL()),
+	)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return fake.MarketFollowers() == 1
+	}, time.Second, time.Millisecond)
+
+	fake.SetOrderBook("BTCEUR", exchangesdk.OrderBook{
+		Timestamp: time.Unix(1000, 0),
+		Bids: []exchangesdk.OrderBookOrder{
+			{Price: 99, Volume: 2},
+			{Price: 98, Volume: 1},
+		},
+		Asks: []exchangesdk.OrderBookOrder{{Price: 102, Volume: 3}},
+	})
+
+	ob := <-obf
+	assert.True(t, time.Unix(1000, 0).Equal(ob.Timestamp))
+	assert.Equal(t, []exchangesdk.OrderBookOrder{
+		{Price: 99, Volume: 2},
+		{Price: 98, Volume: 1},
+	}, ob.Bids)
+	assert.Equal(t, []exchangesdk.OrderBookOrder{{Price: 102, Volume: 3}}, ob.Asks)
+
+	fake.AddTrade("BTCEUR", exchangesdk.OrderBookTrade{
+		MakerSide: exchangesdk.OrderBookSideBid,
+		Price:     99,
+		Volume:    0.5,
+		Timestamp: time.Unix(1001, 0),
+	})
+
+	trade := <-tradeStream
+	assert.Equal(t, exchangesdk.OrderBookSideBid, trade.MakerSide)
+	assert.Equal(t, 99.0, trade.Price)
+	assert.Equal(t, 0.5, trade.Volume)
+	assert.True(t, time.Unix(1001, 0).Equal(trade.Timestamp))
+}
+
+func nextUpdate(
+	t *testing.T,
+	updates <-chan exchangesdk.OrderUpdate,
+) exchangesdk.OrderUpdate {
+
+	select {
+	case u, ok := <-updates:
+		require.True(t, ok, "updates channel closed")
+		return u
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "timed out waiting for order update")
+		return exchangesdk.OrderUpdate{}
+	}
+}
+
+func TestBinanceClientOrderUpdates(t *testing.T) {
+
+	fake := fakeexchange.NewBinance("key", "secret")
+	defer fake.Close()
+	fake.SetOrderBook("BTCEUR", book(99, 101))
+
+	c, err := binance.NewClient(
+		"key",
+		"secret",
+		crypto.PairBTCEUR,
+		binance.WithBaseUrl(fake.URL()),
+		binance.WithWsUrl(fake.WsURL()),
+	)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates, err := c.OrderUpdates(ctx)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return fake.UserStreams() == 1
+	}, time.Second, time.Millisecond)
+
+	bidId, err := c.PostLimitOrder(ctx, exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeBid,
+		Price:  D(100),
+		Volume: D(0.5),
+	})
+	require.NoError(t, err)
+
+	u := nextUpdate(t, updates)
+	assert.Equal(t, exchangesdk.OrderUpdateTypeStateChange, u.Type)
+	assert.Equal(t, bidId, u.OrderId)
+	assert.Equal(t, exchangesdk.OrderStateInOrderBook, u.State)
+
+	fake.SetOrderBook("BTCEUR", book(98, 99.5))
+
+	u = nextUpdate(t, updates)
+	assert.Equal(t, exchangesdk.OrderUpdateTypeFill, u.Type)
+	assert.Equal(t, bidId, u.OrderId)
+	assert.Equal(t, exchangesdk.OrderStateFilled, u.State)
+	assert.True(t, D(0.5).Equal(u.FillVolume))
+	assert.True(t, D(100).Equal(u.FillPrice))
+	assert.True(t, D(50).Equal(u.FillAmountCounter))
+
+	u = nextUpdate(t, updates)
+	assert.Equal(t, exchangesdk.OrderUpdateTypeStateChange, u.Type)
+	assert.Equal(t, bidId, u.OrderId)
+	assert.Equal(t, exchangesdk.OrderStateFilled, u.State)
+
+	fake.DropUserStreams()
+	require.Eventually(t, func() bool {
+		return fake.UserStreams() == 1
+	}, 5*time.Second, time.Millisecond)
+
+	askId, err := c.PostLimitOrder(ctx, exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeAsk,
+		Price:  D(105),
+		Volume: D(0.5),
+	})
+	require.NoError(t, err)
+	require.NoError(t, c.CancelOrder(ctx, askId))
+
+	u = nextUpdate(t, updates)
+	assert.Equal(t, askId, u.OrderId)
+	assert.Equal(t, exchangesdk.OrderStateInOrderBook, u.State)
+
+	u = nextUpdate(t, updates)
+	assert.Equal(t, exchangesdk.OrderUpdateTypeStateChange, u.Type)
+	assert.Equal(t, askId, u.OrderId)
+	assert.Equal(t, exchangesdk.OrderStateCancelled, u.State)
+
+	cancel()
+	for range updates {
+	}
+}
@@ -0,0 +1,878 @@
+package fakeexchange
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/simulator"
+)
+
+const (
+	lunoTradesPageSize = 100
+)
+
+// Luno is an in-process fake of the Luno REST and websocket APIs, for testing
+// the luno client and market follower end to end.
+//
+// The market for each pair (e.g. "XBTEUR") is scripted with SetOrderBook and
+// AddTrade, which are published on the pair's stream as order creates,
+// deletes and trades; orders placed via the REST API are matched against it
+// by a simulator.Exchange, with their updates published on the user stream.
+type Luno struct {
+	apiKey    string
+	apiSecret string
+	server    *httptest.Server
+	ctx       context.Context
+	cancel    context.CancelFunc
+
+	mu       sync.Mutex
+	markets  map[string]*lunoMarket
+	userSubs map[*subscriber]struct{}
+}
+
+type lunoMarket struct {
+	sim *simulator.Exchange
+
+	sequence    int64
+	timestamp   int64
+	nextOrderId int64
+	bids        []lunoOrder
+	asks        []lunoOrder
+	lastTrade   float64
+
+	subs map[*subscriber]struct{}
+}
+
+type lunoOrder struct {
+	Id     string `json:"id"`
+	Price  string `json:"price"`
+	Volume string `json:"volume"`
+
+	price  float64
+	volume float64
+}
+
+type lunoError struct {
+	Message string `json:"error"`
+	Code    string `json:"error_code"`
+}
+
+// NewLuno starts a fake Luno server which accepts requests authenticated with
+// apiKey and apiSecret. Close must be called to stop it.
+func NewLuno(apiKey, apiSecret string) *Luno {
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	l := &Luno{
+		apiKey:    apiKey,
+		apiSecret: apiSecret,
+		ctx:       ctx,
+		cancel:    cancel,
+		markets:   make(map[string]*lunoMarket),
+		userSubs:  make(map[*subscriber]struct{}),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/1/ticker", l.handleTicker)
+	mux.HandleFunc("/api/1/postorder", l.withAuth(l.handlePostOrder))
+	mux.HandleFunc("/api/1/stoporder", l.withAuth(l.handleStopOrder))
+	mux.HandleFunc("/api/1/orders/", l.withAuth(l.handleGetOrder))
+	mux.HandleFunc("/api/1/listorders", l.withAuth(l.handleListOrders))
+	mux.HandleFunc("/api/1/listtrades", l.withAuth(l.handleListTrades))
+	mux.HandleFunc("/api/1/stream/", l.handleStream)
+	mux.HandleFunc("/api/1/userstream", l.handleUserStream)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeJson(w, http.StatusNotFound, lunoError{Message: "Not found", Code: "ErrNotFound"})
+	})
+
+	l.server = httptest.NewServer(mux)
+	return l
+}
+
+// URL is the base URL of the REST API (see luno.WithBaseUrl)
+func (l *Luno) URL() string {
+
+	return l.server.URL
+}
+
+// WsURL is the base URL of the websocket streams (see luno.WithWsUrl)
+func (l *Luno) WsURL() string {
+
+	return wsUrl(l.server.URL)
+}
+
+func (l *Luno) Close() {
+
+	l.cancel()
+
+	l.mu.Lock()
+	for _, m := range l.markets {
+		for s := range m.subs {
+			s.close()
+		}
+	}
+	for s := range l.userSubs {
+		s.close()
+	}
+	l.mu.Unlock()
+
+	l.server.CloseClientConnections()
+	l.server.Close()
+}
+
+// MarketFollowers returns the number of connected market followers (which
+// have been sent an order book snapshot)
+func (l *Luno) MarketFollowers() int {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n := 0
+	for _, m := range l.markets {
+		n += len(m.subs)
+	}
+	return n
+}
+
+// UserStreams returns the number of connected user streams
+func (l *Luno) UserStreams() int {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.userSubs)
+}
+
+// DropUserStreams disconnects all of the user streams (e.g. to test
+// reconnecting)
+func (l *Luno) DropUserStreams() {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for s := range l.userSubs {
+		s.close()
+		delete(l.userSubs, s)
+	}
+}
+
+// SetOrderBook replaces the order book for pair, publishing the difference
+// from the previous order book as order deletes and creates
+func (l *Luno) SetOrderBook(pair string, ob exchangesdk.OrderBook) {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	m := l.market(pair)
+	m.timestamp = eventTime(ob.Timestamp)
+	m.sim.UpdateOrderBook(ob)
+
+	m.bids = l.replaceOrders(m, "BID", m.bids, ob.Bids)
+	m.asks = l.replaceOrders(m, "ASK", m.asks, ob.Asks)
+}
+
+// replaceOrders publishes the deletes and creates to turn current into next,
+// and returns the new orders
+func (l *Luno) replaceOrders(
+	m *lunoMarket,
+	orderType string,
+	current []lunoOrder,
+	next []exchangesdk.OrderBookOrder,
+) []lunoOrder {
+
+	unmatched := make([]exchangesdk.OrderBookOrder, len(next))
+	copy(unmatched, next)
+
+	var kept []lunoOrder
+	for _, o := range current {
+		found := false
+		for i, n := range unmatched {
+			if n.Price == o.price && n.Volume == o.volume {
+				unmatched = append(unmatched[:i], unmatched[i+1:]...)
+				found = true
+				break
+			}
+		}
+		if found {
+			kept = append(kept, o)
+			continue
+		}
+		l.publish(m, lunoUpdate{
+			DeleteUpdate: &lunoDeleteUpdate{OrderId: o.Id},
+		})
+	}
+
+	for _, n := range unmatched {
+		m.nextOrderId++
+		o := newLunoOrder(fmt.Sprintf("book-%d", m.nextOrderId), n.Price, n.Volume)
+		kept = append(kept, o)
+		l.publish(m, lunoUpdate{
+			CreateUpdate: &lunoCreateUpdate{
+				OrderId: o.Id,
+				Type:    orderType,
+				Price:   o.Price,
+				Volume:  o.Volume,
+			},
+		})
+	}
+
+	return kept
+}
+
+// AddTrade publishes a market trade for pair against the orders on the maker
+// side of the book at the trade price (which must have enough volume),
+// filling any of our orders which it trades through
+func (l *Luno) AddTrade(pair string, t exchangesdk.OrderBookTrade) error {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	m := l.market(pair)
+
+	orders := &m.bids
+	if t.MakerSide == exchangesdk.OrderBookSideAsk {
+		orders = &m.asks
+	}
+
+	available := 0.0
+	for _, o := range *orders {
+		if o.price == t.Price {
+			available += o.volume
+		}
+	}
+	if available < t.Volume {
+		return fmt.Errorf(
+			"cannot add trade of %f at %f; only %f available",
+			t.Volume,
+			t.Price,
+			available,
+		)
+	}
+
+	var tradeUpdates []lunoTradeUpdate
+	remaining := t.Volume
+	var kept []lunoOrder
+	for _, o := range *orders {
+		if o.price != t.Price || remaining <= 0 {
+			kept = append(kept, o)
+			continue
+		}
+
+		base := o.volume
+		if remaining < base {
+			base = remaining
+		}
+		remaining -= base
+		tradeUpdates = append(tradeUpdates, lunoTradeUpdate{
+			Base:         formatFloat(base),
+			Counter:      formatFloat(base * t.Price),
+			MakerOrderId: o.Id,
+		})
+
+		if o.volume > base {
+			kept = append(kept, newLunoOrder(o.Id, o.price, o.volume-base))
+		}
+	}
+	*orders = kept
+
+	if !t.Timestamp.IsZero() {
+		m.timestamp = unixMilli(t.Timestamp)
+	}
+	m.lastTrade = t.Price
+	m.sim.AddTrade(t)
+
+	l.publish(m, lunoUpdate{
+		TradeUpdates: tradeUpdates,
+	})
+	return nil
+}
+
+func newLunoOrder(id string, price, volume float64) lunoOrder {
+
+	return lunoOrder{
+		Id:     id,
+		Price:  formatFloat(price),
+		Volume: formatFloat(volume),
+		price:  price,
+		volume: volume,
+	}
+}
+
+func (l *Luno) market(pair string) *lunoMarket {
+
+	m, ok := l.markets[pair]
+	if !ok {
+		m = &lunoMarket{
+			sim:       simulator.New(crypto.Exchange{Provider: crypto.ApiProviderLuno}),
+			timestamp: eventTime(time.Time{}),
+			subs:      make(map[*subscriber]struct{}),
+		}
+		l.markets[pair] = m
+
+		updates, err := m.sim.OrderUpdates(l.ctx)
+		if err != nil {
+			panic(err)
+		}
+		go l.publishUserUpdates(pair, updates)
+	}
+	return m
+}
+
+type lunoUserUpdate struct {
+	Type              string                 `json:"type"`
+	Timestamp         int64                  `json:"timestamp"`
+	OrderStatusUpdate *lunoOrderStatusUpdate `json:"order_status_update,omitempty"`
+	OrderFillUpdate   *lunoOrderFillUpdate   `json:"order_fill_update,omitempty"`
+}
+
+type lunoOrderStatusUpdate struct {
+	OrderId  string `json:"order_id"`
+	MarketId string `json:"market_id"`
+	Status   string `json:"status"`
+}
+
+type lunoOrderFillUpdate struct {
+	OrderId         string `json:"order_id"`
+	MarketId        string `json:"market_id"`
+	BaseFill        string `json:"base_fill"`
+	CounterFill     string `json:"counter_fill"`
+	BaseDelta       string `json:"base_delta"`
+	CounterDelta    string `json:"counter_delta"`
+	BaseFeeDelta    string `json:"base_fee_delta"`
+	CounterFeeDelta string `json:"counter_fee_delta"`
+}
+
+// publishUserUpdates publishes the updates to orders on pair to the user
+// streams
+func (l *Luno) publishUserUpdates(
+	pair string,
+	updates <-chan exchangesdk.OrderUpdate,
+) {
+
+	for u := range updates {
+		msg := lunoUserUpdate{
+			Timestamp: eventTime(u.Timestamp),
+		}
+		orderId := pair + "-" + u.OrderId
+
+		if u.Type == exchangesdk.OrderUpdateTypeFill {
+			msg.Type = "order_fill"
+			msg.OrderFillUpdate = &lunoOrderFillUpdate{
+				OrderId:         orderId,
+				MarketId:        pair,
+				BaseFill:        u.FillAmountBase.String(),
+				CounterFill:     u.FillAmountCounter.String(),
+				BaseDelta:       u.FillVolume.String(),
+				CounterDelta:    u.FillVolume.Mul(u.FillPrice).String(),
+				BaseFeeDelta:    u.BaseFee.String(),
+				CounterFeeDelta: u.CounterFee.String(),
+			}
+		} else {
+			msg.Type = "order_status"
+			msg.OrderStatusUpdate = &lunoOrderStatusUpdate{
+				OrderId:  orderId,
+				MarketId: pair,
+				Status:   lunoOrderState(u.State),
+			}
+		}
+
+		b, err := json.Marshal(msg)
+		if err != nil {
+			panic(err)
+		}
+
+		l.mu.Lock()
+		for s := range l.userSubs {
+			s.send(b)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// lunoOrderState returns the Luno order state of an order; Luno reports
+// cancelled orders as complete
+func lunoOrderState(state exchangesdk.OrderState) string {
+
+	switch state {
+	case exchangesdk.OrderStateAwaitingTrigger:
+		return "AWAITING"
+	case exchangesdk.OrderStateInOrderBook:
+		return "PENDING"
+	default:
+		return "COMPLETE"
+	}
+}
+
+type lunoUpdate struct {
+	Sequence     string            `json:"sequence"`
+	TradeUpdates []lunoTradeUpdate `json:"trade_updates"`
+	CreateUpdate *lunoCreateUpdate `json:"create_update"`
+	DeleteUpdate *lunoDeleteUpdate `json:"delete_update"`
+	StatusUpdate *struct{}         `json:"status_update"`
+	Timestamp    int64             `json:"timestamp"`
+}
+
+type lunoTradeUpdate struct {
+	Base         string `json:"base"`
+	Counter      string `json:"counter"`
+	MakerOrderId string `json:"maker_order_id"`
+	TakerOrderId string `json:"taker_order_id"`
+}
+
+type lunoCreateUpdate struct {
+	OrderId string `json:"order_id"`
+	Type    string `json:"type"`
+	Price   string `json:"price"`
+	Volume  string `json:"volume"`
+}
+
+type lunoDeleteUpdate struct {
+	OrderId string `json:"order_id"`
+}
+
+func (l *Luno) publish(m *lunoMarket, u lunoUpdate) {
+
+	m.sequence++
+	u.Sequence = strconv.FormatInt(m.sequence, 10)
+	u.Timestamp = m.timestamp
+
+	msg, err := json.Marshal(u)
+	if err != nil {
+		panic(err)
+	}
+
+	for s := range m.subs {
+		s.send(msg)
+	}
+}
+
+func (l *Luno) handleStream(w http.ResponseWriter, r *http.Request) {
+
+	pair := strings.TrimPrefix(r.URL.Path, "/api/1/stream/")
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	creds := struct {
+		Key    string `json:"api_key_id"`
+		Secret string `json:"api_key_secret"`
+	}{}
+	err = conn.ReadJSON(&creds)
+	if err != nil || creds.Key != l.apiKey || creds.Secret != l.apiSecret {
+		conn.Close()
+		return
+	}
+
+	s := newSubscriber(conn)
+
+	l.mu.Lock()
+	m := l.market(pair)
+	snapshot, err := json.Marshal(struct {
+		Sequence  string      `json:"sequence"`
+		Bids      []lunoOrder `json:"bids"`
+		Asks      []lunoOrder `json:"asks"`
+		Timestamp int64       `json:"timestamp"`
+	}{
+		Sequence:  strconv.FormatInt(m.sequence, 10),
+		Bids:      append([]lunoOrder{}, m.bids...),
+		Asks:      append([]lunoOrder{}, m.asks...),
+		Timestamp: m.timestamp,
+	})
+	if err != nil {
+		panic(err)
+	}
+	s.send(snapshot)
+	m.subs[s] = struct{}{}
+	l.mu.Unlock()
+
+	s.waitForClose()
+
+	l.mu.Lock()
+	delete(m.subs, s)
+	l.mu.Unlock()
+}
+
+func (l *Luno) handleUserStream(w http.ResponseWriter, r *http.Request) {
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	creds := struct {
+		Key    string `json:"api_key_id"`
+		Secret string `json:"api_key_secret"`
+	}{}
+	err = conn.ReadJSON(&creds)
+	if err != nil || creds.Key != l.apiKey || creds.Secret != l.apiSecret {
+		conn.Close()
+		return
+	}
+
+	s := newSubscriber(conn)
+
+	l.mu.Lock()
+	l.userSubs[s] = struct{}{}
+	l.mu.Unlock()
+
+	s.waitForClose()
+
+	l.mu.Lock()
+	delete(l.userSubs, s)
+	l.mu.Unlock()
+}
+
+func (l *Luno) withAuth(handler http.HandlerFunc) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, secret, ok := r.BasicAuth()
+		if !ok || key != l.apiKey || secret != l.apiSecret {
+			writeJson(w, http.StatusUnauthorized, lunoError{
+				Message: "API key not found",
+				Code:    "ErrAPIKeyNotFound",
+			})
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func (l *Luno) handleTicker(w http.ResponseWriter, r *http.Request) {
+
+	pair := r.URL.Query().Get("pair")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	m := l.market(pair)
+	if len(m.bids) == 0 || len(m.asks) == 0 {
+		writeJson(w, http.StatusNotFound, lunoError{Message: "No market", Code: "ErrMarketUnavailable"})
+		return
+	}
+
+	writeJson(w, http.StatusOK, struct {
+		Pair      string `json:"pair"`
+		Bid       string `json:"bid"`
+		Ask       string `json:"ask"`
+		LastTrade string `json:"last_trade"`
+		Status    string `json:"status"`
+		Timestamp int64  `json:"timestamp"`
+	}{
+		Pair:      pair,
+		Bid:       formatFloat(bestPrice(m.bids, true)),
+		Ask:       formatFloat(bestPrice(m.asks, false)),
+		LastTrade: formatFloat(m.lastTrade),
+		Status:    "ACTIVE",
+		Timestamp: m.timestamp,
+	})
+}
+
+func bestPrice(orders []lunoOrder, highest bool) float64 {
+
+	best := orders[0].price
+	for _, o := range orders[1:] {
+		if (highest && o.price > best) || (!highest && o.price < best) {
+			best = o.price
+		}
+	}
+	return best
+}
+
+func (l *Luno) handlePostOrder(w http.ResponseWriter, r *http.Request) {
+
+	err := r.ParseForm()
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, lunoError{Message: err.Error(), Code: "ErrInvalidArguments"})
+		return
+	}
+	pair := r.PostForm.Get("pair")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	m := l.market(pair)
+	id, err := l.postOrder(r.Context(), m, r.PostForm.Get)
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, lunoError{Message: err.Error(), Code: "ErrInvalidArguments"})
+		return
+	}
+
+	writeJson(w, http.StatusOK, struct {
+		OrderId string `json:"order_id"`
+	}{
+		OrderId: pair + "-" + id,
+	})
+}
+
+func (l *Luno) postOrder(
+	ctx context.Context,
+	m *lunoMarket,
+	param func(string) string,
+) (string, error) {
+
+	var side exchangesdk.OrderBookSide
+	switch param("type") {
+	case "BID", "BUY":
+		side = exchangesdk.OrderBookSideBid
+	case "ASK", "SELL":
+		side = exchangesdk.OrderBookSideAsk
+	default:
+		return "", fmt.Errorf("unknown order type `%s`", param("type"))
+	}
+
+	price, err := decimal.NewFromString(param("price"))
+	if err != nil {
+		return "", err
+	}
+	volume, err := decimal.NewFromString(param("volume"))
+	if err != nil {
+		return "", err
+	}
+
+	stopPrice := decimal.Zero
+	if param("stop_price") != "" {
+		stopPrice, err = decimal.NewFromString(param("stop_price"))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if stopPrice.IsPositive() {
+		return m.sim.PostStopLimitOrder(ctx, exchangesdk.StopLimitOrder{
+			Side:       side,
+			StopPrice:  stopPrice,
+			LimitPrice: price,
+			Volume:     volume,
+		})
+	}
+
+	priceF, _ := price.Float64()
+	if param("post_only") == "true" {
+		if side == exchangesdk.OrderBookSideBid && len(m.asks) > 0 && priceF >= bestPrice(m.asks, false) ||
+			side == exchangesdk.OrderBookSideAsk && len(m.bids) > 0 && priceF <= bestPrice(m.bids, true) {
+			return "", fmt.Errorf("Post-only order would trade")
+		}
+	}
+
+	orderType := exchangesdk.OrderTypeBid
+	if side == exchangesdk.OrderBookSideAsk {
+		orderType = exchangesdk.OrderTypeAsk
+	}
+	return m.sim.PostLimitOrder(ctx, exchangesdk.Order{
+		Type:   orderType,
+		Price:  price,
+		Volume: volume,
+	})
+}
+
+// splitOrderId returns the market and simulator order id of an order id
+// returned by the fake
+func (l *Luno) splitOrderId(orderId string) (*lunoMarket, string, bool) {
+
+	parts := strings.SplitN(orderId, "-", 2)
+	if len(parts) != 2 {
+		return nil, "", false
+	}
+	m, ok := l.markets[parts[0]]
+	return m, parts[1], ok
+}
+
+func (l *Luno) handleStopOrder(w http.ResponseWriter, r *http.Request) {
+
+	err := r.ParseForm()
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, lunoError{Message: err.Error(), Code: "ErrInvalidArguments"})
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	m, id, ok := l.splitOrderId(r.PostForm.Get("order_id"))
+	if ok {
+		err = m.sim.CancelOrder(r.Context(), id)
+	}
+	if !ok || err != nil {
+		writeJson(w, http.StatusNotFound, lunoError{Message: "Order not found", Code: "ErrOrderNotFound"})
+		return
+	}
+
+	writeJson(w, http.StatusOK, struct {
+		Success bool `json:"success"`
+	}{
+		Success: true,
+	})
+}
+
+func (l *Luno) handleGetOrder(w http.ResponseWriter, r *http.Request) {
+
+	orderId := strings.TrimPrefix(r.URL.Path, "/api/1/orders/")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	m, id, ok := l.splitOrderId(orderId)
+	var status exchangesdk.OrderStatus
+	var err error
+	if ok {
+		status, err = m.sim.GetOrderStatus(r.Context(), id)
+	}
+	if !ok || err != nil {
+		writeJson(w, http.StatusNotFound, lunoError{Message: "Order not found", Code: "ErrOrderNotFound"})
+		return
+	}
+
+	writeJson(w, http.StatusOK, struct {
+		OrderId string `json:"order_id"`
+		State   string `json:"state"`
+		Type    string `json:"type"`
+		Base    string `json:"base"`
+		Counter string `json:"counter"`
+	}{
+		OrderId: orderId,
+		State:   lunoOrderState(status.State),
+		Type:    string(status.Type),
+		Base:    status.FillAmountBase.String(),
+		Counter: status.FillAmountCounter.String(),
+	})
+}
+
+// handleListOrders lists the open orders of a pair, filtered by state if one
+// is given
+func (l *Luno) handleListOrders(w http.ResponseWriter, r *http.Request) {
+
+	query := r.URL.Query()
+	pair := query.Get("pair")
+	state := query.Get("state")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	m := l.market(pair)
+	simOrders, err := m.sim.OpenOrders(r.Context())
+	if err != nil {
+		writeJson(w, http.StatusInternalServerError, lunoError{Message: err.Error(), Code: "ErrInternal"})
+		return
+	}
+
+	type lunoListedOrder struct {
+		OrderId     string `json:"order_id"`
+		Pair        string `json:"pair"`
+		State       string `json:"state"`
+		Type        string `json:"type"`
+		LimitPrice  string `json:"limit_price"`
+		LimitVolume string `json:"limit_volume"`
+		Base        string `json:"base"`
+	}
+
+	orders := []lunoListedOrder{}
+	for _, o := range simOrders {
+		orderState := lunoOrderState(o.State)
+		if state != "" && state != orderState {
+			continue
+		}
+
+		orderType := "BID"
+		if o.Side == exchangesdk.OrderBookSideAsk {
+			orderType = "ASK"
+		}
+		orders = append(orders, lunoListedOrder{
+			OrderId:     pair + "-" + o.Id,
+			Pair:        pair,
+			State:       orderState,
+			Type:        orderType,
+			LimitPrice:  o.LimitPrice.String(),
+			LimitVolume: o.Volume.String(),
+			Base:        o.FillAmountBase.String(),
+		})
+	}
+
+	writeJson(w, http.StatusOK, struct {
+		Orders []lunoListedOrder `json:"orders"`
+	}{
+		Orders: orders,
+	})
+}
+
+func (l *Luno) handleListTrades(w http.ResponseWriter, r *http.Request) {
+
+	query := r.URL.Query()
+	pair := query.Get("pair")
+	afterSeq, _ := strconv.ParseInt(query.Get("after_seq"), 10, 64)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	m := l.market(pair)
+
+	type lunoTrade struct {
+		OrderId    string `json:"order_id"`
+		Pair       string `json:"pair"`
+		Sequence   int64  `json:"sequence"`
+		Timestamp  int64  `json:"timestamp"`
+		Type       string `json:"type"`
+		IsBuy      bool   `json:"is_buy"`
+		Price      string `json:"price"`
+		Volume     string `json:"volume"`
+		Base       string `json:"base"`
+		Counter    string `json:"counter"`
+		FeeBase    string `json:"fee_base"`
+		FeeCounter string `json:"fee_counter"`
+	}
+
+	var simTrades []exchangesdk.Trade
+	for page := int64(1); ; page++ {
+		t, err := m.sim.GetTrades(r.Context(), page)
+		if err != nil {
+			writeJson(w, http.StatusInternalServerError, lunoError{Message: err.Error(), Code: "ErrInternal"})
+			return
+		}
+		if len(t) == 0 {
+			break
+		}
+		simTrades = append(simTrades, t...)
+	}
+
+	trades := []lunoTrade{}
+	for i, t := range simTrades {
+		seq := int64(i) + 1
+		if seq <= afterSeq {
+			continue
+		}
+		if len(trades) == lunoTradesPageSize {
+			break
+		}
+		trades = append(trades, lunoTrade{
+			OrderId:    pair + "-" + t.OrderId,
+			Pair:       pair,
+			Sequence:   seq,
+			Timestamp:  unixMilli(t.Timestamp),
+			Type:       string(t.Type),
+			IsBuy:      t.Type == exchangesdk.OrderTypeBid,
+			Price:      t.Price.String(),
+			Volume:     t.Volume.String(),
+			Base:       t.Volume.String(),
+			Counter:    t.Volume.Mul(t.Price).String(),
+			FeeBase:    t.BaseFee.String(),
+			FeeCounter: t.CounterFee.String(),
+		})
+	}
+
+	writeJson(w, http.StatusOK, struct {
+		Trades []lunoTrade `json:"trades"`
+	}{
+		Trades: trades,
+	})
+}
@@ -0,0 +1,350 @@
+package fakeexchange_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/fakeexchange"
+	"github.com/thecodedproject/crypto/exchangesdk/luno"
+)
+
+func TestLunoClientPlacesAndFillsOrders(t *testing.T) {
+
+	fake := fakeexchange.NewLuno("key", "secret")
+	defer fake.Close()
+	fake.SetOrderBook("XBTEUR", book(99, 101))
+
+	c, err := luno.NewClient(
+		"key",
+		"secret",
+		crypto.PairBTCEUR,
+		luno.WithBaseUrl(fake.URL()),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, crypto.Exchange{
+		Provider: crypto.ApiProviderLuno,
+		Pair:     crypto.PairBTCEUR,
+	}, c.Exchange())
+
+	ctx := context.Background()
+
+	price, err := c.LatestPrice(ctx)
+	require.NoError(t, err)
+	assert.True(t, D(100).Equal(price))
+
+	_, err = c.PostLimitOrder(ctx, exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeBid,
+		Price:  D(101),
+		Volume: D(0.5),
+	})
+	require.Error(t, err, "post only order which would trade")
+
+	bidId, err := c.PostLimitOrder(ctx, exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeBid,
+		Price:  D(100),
+		Volume: D(0.5),
+	})
+	require.NoError(t, err)
+
+	stopId, err := c.PostStopLimitOrder(ctx, exchangesdk.StopLimitOrder{
+		Side:       exchangesdk.OrderBookSideAsk,
+		StopPrice:  D(90),
+		LimitPrice: D(89),
+		Volume:     D(1),
+	})
+	require.NoError(t, err)
+
+	status, err := c.GetOrderStatus(ctx, bidId)
+	require.NoError(t, err)
+	assert.Equal(t, exchangesdk.OrderStateInOrderBook, status.State)
+	assert.Equal(t, exchangesdk.OrderTypeBid, status.Type)
+
+	status, err = c.GetOrderStatus(ctx, stopId)
+	require.NoError(t, err)
+	assert.Equal(t, exchangesdk.OrderStateAwaitingTrigger, status.State)
+
+	fake.SetOrderBook("XBTEUR", book(98, 99.5))
+
+	status, err = c.GetOrderStatus(ctx, bidId)
+	require.NoError(t, err)
+	assert.Equal(t, exchangesdk.OrderStateFilled, status.State)
+	assert.True(t, D(0.5).Equal(status.FillAmountBase))
+
+	trades, err := c.GetTrades(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(trades))
+	assert.Equal(t, bidId, trades[0].OrderId)
+	assert.Equal(t, exchangesdk.OrderTypeBid, trades[0].Type)
+	assert.True(t, D(100).Equal(trades[0].Price))
+	assert.True(t, D(0.5).Equal(trades[0].Volume))
+
+	require.NoError(t, c.CancelOrder(ctx, stopId))
+}
+
+func TestLunoClientListsOpenOrders(t *testing.T) {
+
+	fake := fakeexchange.NewLuno("key", "secret")
+	defer fake.Close()
+	fake.SetOrderBook("XBTEUR", book(99, 101))
+
+	c, err := luno.NewClient(
+		"key",
+		"secret",
+		crypto.PairBTCEUR,
+		luno.WithBaseUrl(fake.URL()),
+	)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	bidId, err := c.PostLimitOrder(ctx, exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeBid,
+		Price:  D(98),
+		Volume: D(0.5),
+	})
+	require.NoError(t, err)
+
+	askId, err := c.PostLimitOrder(ctx, exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeAsk,
+		Price:  D(102),
+		Volume: D(1),
+	})
+	require.NoError(t, err)
+
+	cancelledId, err := c.PostLimitOrder(ctx, exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeAsk,
+		Price:  D(103),
+		Volume: D(1),
+	})
+	require.NoError(t, err)
+	require.NoError(t, c.CancelOrder(ctx, cancelledId))
+
+	orders, err := c.OpenOrders(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(orders))
+
+	assert.Equal(t, bidId, orders[0].Id)
+	assert.Equal(t, exchangesdk.OrderBookSideBid, orders[0].Side)
+	assert.Equal(t, exchangesdk.OrderStateInOrderBook, orders[0].State)
+	assert.True(t, D(98).Equal(orders[0].LimitPrice))
+	assert.True(t, D(0.5).Equal(orders[0].Volume))
+	assert.True(t, orders[0].FillAmountBase.IsZero())
+
+	assert.Equal(t, askId, orders[1].Id)
+	assert.Equal(t, exchangesdk.OrderBookSideAsk, orders[1].Side)
+	assert.True(t, D(102).Equal(orders[1].LimitPrice))
+}
+
+func TestLunoClientReplaceOrderCancelsBeforePosting(t *testing.T) {
+
+	fake := fakeexchange.NewLuno("key", "secret")
+	defer fake.Close()
+	fake.SetOrderBook("XBTEUR", book(99, 101))
+
+	c, err := luno.NewClient(
+		"key",
+		"secret",
+		crypto.PairBTCEUR,
+		luno.WithBaseUrl(fake.URL()),
+	)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	id, err := c.PostLimitOrder(ctx, exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeAsk,
+		Price:  D(102),
+		Volume: D(1),
+	})
+	require.NoError(t, err)
+
+	res, err := exchangesdk.ReplaceOrder(ctx, c, id, exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeAsk,
+		Price:  D(101.5),
+		Volume: D(1),
+	})
+	require.NoError(t, err)
+	assert.True(t, res.Cancelled)
+	// Luno reports cancelled orders as complete
+	assert.Equal(t, exchangesdk.OrderStateFilled, res.Original.State)
+	assert.True(t, res.Original.FillAmountBase.IsZero())
+
+	status, err := c.GetOrderStatus(ctx, res.NewOrderId)
+	require.NoError(t, err)
+	assert.Equal(t, exchangesdk.OrderStateInOrderBook, status.State)
+}
+
+func TestLunoClientWithWrongCredentialsReturnsError(t *testing.T) {
+
+	fake := fakeexchange.NewLuno("key", "secret")
+	defer fake.Close()
+
+	c, err := luno.NewClient(
+		"key",
+		"other",
+		crypto.PairBTCEUR,
+		luno.WithBaseUrl(fake.URL()),
+	)
+	require.NoError(t, err)
+
+	_, err = c.PostLimitOrder(context.Background(), exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeBid,
+		Price:  D(100),
+		Volume: D(1),
+	})
+	require.Error(t, err)
+}
+
+func TestLunoMarketFollowerFollowsFakeMarket(t *testing.T) {
+
+	fake := fakeexchange.NewLuno("key", "secret")
+	defer fake.Close()
+	fake.SetOrderBook("XBTEUR", exchangesdk.OrderBook{
+		Timestamp: time.Unix(1000, 0),
+		Bids: []exchangesdk.OrderBookOrder{
+			{Price: 99, Volume: 1},
+			{Price: 99, Volume: 2},
+		},
+		Asks: []exchangesdk.OrderBookOrder{{Price: 101, Volume: 1}},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	obf, tradeStream, err := luno.NewOrderBookFollowerAndTradeStream(
+		ctx,
+		&wg,
+		crypto.PairBTCEUR,
+		"key",
+		"secret",
+		luno.WithWsUrl(fake.WsURL()),
+	)
+	require.NoError(t, err)
+
+	ob := <-obf
+	assert.True(t, time.Unix(1000, 0).Equal(ob.Timestamp))
+	assert.Equal(t, 2, len(ob.Bids))
+	assert.Equal(t, 99.0, ob.Bids[0].Price)
+	assert.Equal(t, []exchangesdk.OrderBookOrder{{Price: 101, Volume: 1}}, ob.Asks)
+
+	require.NoError(t, fake.AddTrade("XBTEUR", exchangesdk.OrderBookTrade{
+		MakerSide: exchangesdk.OrderBookSideBid,
+		Price:     99,
+		Volume:    1.5,
+		Timestamp: time.Unix(1001, 0),
+	}))
+
+	for i := 0; i < 2; i++ {
+		trade := <-tradeStream
+		assert.Equal(t, exchangesdk.OrderBookSideBid, trade.MakerSide)
+		assert.Equal(t, 99.0, trade.Price)
+		assert.True(t, time.Unix(1001, 0).Equal(trade.Timestamp))
+	}
+
+	ob = <-obf
+	assert.Equal(t, []exchangesdk.OrderBookOrder{{Price: 99, Volume: 1.5}}, ob.Bids)
+
+	fake.SetOrderBook("XBTEUR", book(98, 100))
+
+	// One update for each deleted and created order
+	for i := 0; i < 4; i++ {
+		ob = <-obf
+	}
+	assert.Equal(t, []exchangesdk.OrderBookOrder{{Price: 98, Volume: 1}}, ob.Bids)
+	assert.Equal(t, []exchangesdk.OrderBookOrder{{Price: 100, Volume: 1}}, ob.Asks)
+}
+
+func TestLunoAddTradeWithoutMakerVolumeReturnsError(t *testing.T) {
+
+	fake := fakeexchange.NewLuno("key", "secret")
+	defer fake.Close()
+	fake.SetOrderBook("XBTEUR", book(99, 101))
+
+	err := fake.AddTrade("XBTEUR", exchangesdk.OrderBookTrade{
+		MakerSide: exchangesdk.OrderBookSideAsk,
+		Price:     101,
+		Volume:    2,
+	})
+	require.Error(t, err)
+}
+
+func TestLunoClientOrderUpdates(t *testing.T) {
+
+	fake := fakeexchange.NewLuno("key", "secret")
+	defer fake.Close()
+	fake.SetOrderBook("XBTEUR", book(99, 101))
+
+	c, err := luno.NewClient(
+		"key",
+		"secret",
+		crypto.PairBTCEUR,
+		luno.WithBaseUrl(fake.URL()),
+		luno.WithWsUrl(fake.WsURL()),
+	)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates, err := c.OrderUpdates(ctx)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return fake.UserStreams() == 1
+	}, time.Second, time.Millisecond)
+
+	bidId, err := c.PostLimitOrder(ctx, exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeBid,
+		Price:  D(100),
+		Volume: D(0.5),
+	})
+	require.NoError(t, err)
+
+	u := nextUpdate(t, updates)
+	assert.Equal(t, exchangesdk.OrderUpdateTypeStateChange, u.Type)
+	assert.Equal(t, bidId, u.OrderId)
+	assert.Equal(t, exchangesdk.OrderStateInOrderBook, u.State)
+
+	fake.SetOrderBook("XBTEUR", book(98, 99.5))
+
+	u = nextUpdate(t, updates)
+	assert.Equal(t, exchangesdk.OrderUpdateTypeFill, u.Type)
+	assert.Equal(t, bidId, u.OrderId)
+	assert.True(t, D(0.5).Equal(u.FillVolume))
+	assert.True(t, D(100).Equal(u.FillPrice))
+
+	u = nextUpdate(t, updates)
+	assert.Equal(t, exchangesdk.OrderUpdateTypeStateChange, u.Type)
+	assert.Equal(t, bidId, u.OrderId)
+	assert.Equal(t, exchangesdk.OrderStateFilled, u.State)
+	assert.True(t, D(0.5).Equal(u.FillAmountBase))
+	assert.True(t, D(50).Equal(u.FillAmountCounter))
+
+	fake.DropUserStreams()
+	require.Eventually(t, func() bool {
+		return fake.UserStreams() == 1
+	}, 5*time.Second, time.Millisecond)
+
+	stopId, err := c.PostStopLimitOrder(ctx, exchangesdk.StopLimitOrder{
+		Side:       exchangesdk.OrderBookSideAsk,
+		StopPrice:  D(90),
+		LimitPrice: D(89),
+		Volume:     D(1),
+	})
+	require.NoError(t, err)
+
+	u = nextUpdate(t, updates)
+	assert.Equal(t, stopId, u.OrderId)
+	assert.Equal(t, exchangesdk.OrderStateAwaitingTrigger, u.State)
+
+	cancel()
+	for range updates {
+	}
+}
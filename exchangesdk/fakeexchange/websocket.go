@@ -0,0 +1,101 @@
+package fakeexchange
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	subscriberBufferSize = 1024
+)
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// subscriber is a websocket connection which is sent published messages in
+// order by its own writer goroutine, so that publishing never blocks on a
+// slow client
+type subscriber struct {
+	conn     *websocket.Conn
+	messages chan []byte
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+func newSubscriber(conn *websocket.Conn) *subscriber {
+
+	s := &subscriber{
+		conn:     conn,
+		messages: make(chan []byte, subscriberBufferSize),
+		done:     make(chan struct{}),
+	}
+
+	go func() {
+		defer conn.Close()
+		for {
+			select {
+			case msg := <-s.messages:
+				err := conn.WriteMessage(websocket.TextMessage, msg)
+				if err != nil {
+					return
+				}
+			case <-s.done:
+				return
+			}
+		}
+	}()
+
+	return s
+}
+
+// send queues msg for sending, dropping the connection if the client has
+// fallen too far behind
+func (s *subscriber) send(msg []byte) {
+
+	select {
+	case s.messages <- msg:
+	default:
+		s.close()
+	}
+}
+
+func (s *subscriber) close() {
+
+	s.doneOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+// waitForClose reads (and discards) messages from the client until the
+// connection is closed
+func (s *subscriber) waitForClose() {
+
+	for {
+		_, _, err := s.conn.ReadMessage()
+		if err != nil {
+			s.close()
+			return
+		}
+	}
+}
+
+func wsUrl(httpUrl string) string {
+
+	return "ws" + strings.TrimPrefix(httpUrl, "http")
+}
+
+func formatFloat(f float64) string {
+
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func unixMilli(t time.Time) int64 {
+
+	return t.UnixNano() / int64(time.Millisecond)
+}
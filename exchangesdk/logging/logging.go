@@ -0,0 +1,124 @@
+// Package logging defines the logger which the exchange clients, market
+// followers and order managers report errors and events to.
+package logging
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Keys of the structured fields of log entries
+const (
+	KeyProvider = "provider"
+	KeyPair     = "pair"
+	KeySequence = "sequence"
+	KeyOrderId  = "order_id"
+	KeyPath     = "path"
+	KeyError    = "error"
+)
+
+// Logger is a structured logger. Its methods take a message followed by
+// alternating keys and values, e.g.
+//
+//	logger.Error("order book follower failed", logging.KeyPair, pair, logging.KeyError, err)
+//
+// It is satisfied by *slog.Logger, so that one can be used directly.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// Std is a Logger which writes to the standard logger of the log package
+var Std Logger = New(nil)
+
+// Nop is a Logger which discards all entries
+var Nop Logger = nop{}
+
+// New returns a Logger which writes entries to l as a line of the level,
+// message and key=value fields; if l is nil, entries are written to the
+// standard logger of the log package
+func New(l *log.Logger) Logger {
+
+	return stdLogger{l: l}
+}
+
+type stdLogger struct {
+	l *log.Logger
+}
+
+func (s stdLogger) Debug(msg string, args ...interface{}) {
+
+	s.write("DEBUG", msg, args)
+}
+
+func (s stdLogger) Info(msg string, args ...interface{}) {
+
+	s.write("INFO", msg, args)
+}
+
+func (s stdLogger) Warn(msg string, args ...interface{}) {
+
+	s.write("WARN", msg, args)
+}
+
+func (s stdLogger) Error(msg string, args ...interface{}) {
+
+	s.write("ERROR", msg, args)
+}
+
+func (s stdLogger) write(level string, msg string, args []interface{}) {
+
+	line := level + " " + msg + formatFields(args)
+	if s.l == nil {
+		log.Println(line)
+		return
+	}
+	s.l.Println(line)
+}
+
+// formatFields formats args as ` key=value` pairs; as with slog, a value
+// without a key is given the key `!BADKEY`
+func formatFields(args []interface{}) string {
+
+	var b strings.Builder
+	for len(args) > 0 {
+		key, ok := args[0].(string)
+		if !ok || len(args) == 1 {
+			fmt.Fprintf(&b, " !BADKEY=%s", formatValue(args[0]))
+			args = args[1:]
+			continue
+		}
+		fmt.Fprintf(&b, " %s=%s", key, formatValue(args[1]))
+		args = args[2:]
+	}
+	return b.String()
+}
+
+// formatValue formats v, quoting it if it would otherwise be ambiguous
+func formatValue(v interface{}) string {
+
+	var s string
+	switch v := v.(type) {
+	case error:
+		s = v.Error()
+	case fmt.Stringer:
+		s = v.String()
+	default:
+		s = fmt.Sprint(v)
+	}
+
+	if s == "" || strings.ContainsAny(s, " =\"\n") {
+		return fmt.Sprintf("%q", s)
+	}
+	return s
+}
+
+type nop struct{}
+
+func (nop) Debug(string, ...interface{}) {}
+func (nop) Info(string, ...interface{})  {}
+func (nop) Warn(string, ...interface{})  {}
+func (nop) Error(string, ...interface{}) {}
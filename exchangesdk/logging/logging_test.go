@@ -0,0 +1,35 @@
+package logging_test
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk/logging"
+)
+
+func TestNewWritesLevelMessageAndFields(t *testing.T) {
+
+	var b bytes.Buffer
+	logger := logging.New(log.New(&b, "", 0))
+
+	logger.Error(
+		"Follower failed",
+		logging.KeyProvider, crypto.ApiProviderBinance,
+		logging.KeyPair, crypto.PairBTCEUR,
+		logging.KeySequence, int64(42),
+		logging.KeyError, errors.New("missed some updates"),
+	)
+	logger.Info("Order placed", logging.KeyOrderId, "abc", "note", "")
+	logger.Warn("Odd fields", "key", "value", 12)
+
+	assert.Equal(t,
+		"ERROR Follower failed provider=binance pair=btceur sequence=42 error=\"missed some updates\"\n"+
+			"INFO Order placed order_id=abc note=\"\"\n"+
+			"WARN Odd fields key=value !BADKEY=12\n",
+		b.String(),
+	)
+}
@@ -0,0 +1,174 @@
+package luno
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/requestutil"
+)
+
+const (
+	maxCandlesPerRequest = 1000
+)
+
+var _ exchangesdk.HistoricalCandlesClient = (*client)(nil)
+
+type lunoCandle struct {
+	Timestamp int64   `json:"timestamp"`
+	Open      float64 `json:"open,string"`
+	High      float64 `json:"high,string"`
+	Low       float64 `json:"low,string"`
+	Close     float64 `json:"close,string"`
+	Volume    float64 `json:"volume,string"`
+}
+
+func (l *client) HistoricalCandles(
+	ctx context.Context,
+	interval time.Duration,
+	from time.Time,
+	to time.Time,
+) ([]exchangesdk.Candle, error) {
+
+	err := validateCandleInterval(interval)
+	if err != nil {
+		return nil, err
+	}
+
+	candles := make([]exchangesdk.Candle, 0)
+	since := from
+	for since.Before(to) {
+
+		page, err := l.getCandles(ctx, interval, since)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, candle := range page {
+			if candle.Timestamp.Before(from) || !candle.Timestamp.Before(to) {
+				continue
+			}
+			candles = append(candles, candle)
+		}
+
+		if len(page) < maxCandlesPerRequest {
+			break
+		}
+
+		since = page[len(page)-1].Timestamp.Add(interval)
+	}
+
+	return candles, nil
+}
+
+func (l *client) getCandles(
+	ctx context.Context,
+	interval time.Duration,
+	since time.Time,
+) ([]exchangesdk.Candle, error) {
+
+	path, err := requestutil.FullPath(l.baseUrl, "/api/exchange/1/candles")
+	if err != nil {
+		return nil, err
+	}
+	values := url.Values{}
+	values.Add("pair", l.tradingPair)
+	values.Add("since", strconv.FormatInt(since.UnixNano()/1e6, 10))
+	values.Add("duration", strconv.FormatInt(int64(interval/time.Second), 10))
+	path.RawQuery = values.Encode()
+
+	req, err := http.NewRequest("GET", path.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.SetBasicAuth(l.apiKey, l.apiSecret)
+
+	body, err := getBody(l.httpClient.Do(req))
+	if err != nil {
+		return nil, err
+	}
+
+	res := struct {
+		Candles []lunoCandle `json:"candles"`
+	}{}
+
+	err = json.Unmarshal(body, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	candles := make([]exchangesdk.Candle, 0, len(res.Candles))
+	for _, c := range res.Candles {
+		candles = append(candles, exchangesdk.Candle{
+			Timestamp: time.Unix(0, c.Timestamp*int64(time.Millisecond)),
+			Open:      c.Open,
+			High:      c.High,
+			Low:       c.Low,
+			Close:     c.Close,
+			Volume:    c.Volume,
+		})
+	}
+
+	return candles, nil
+}
+
+func validateCandleInterval(interval time.Duration) error {
+
+	switch interval {
+	case time.Minute,
+		5 * time.Minute,
+		15 * time.Minute,
+		30 * time.Minute,
+		time.Hour,
+		3 * time.Hour,
+		4 * time.Hour,
+		8 * time.Hour,
+		24 * time.Hour,
+		3 * 24 * time.Hour,
+		7 * 24 * time.Hour:
+		return nil
+	default:
+		return fmt.Errorf("candle interval %s is not supported by Luno", interval)
+	}
+}
+
+func getBody(res *http.Response, err error) ([]byte, error) {
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+
+		errStruct := struct {
+			ErrCode string `json:"error_code"`
+			ErrMsg  string `json:"error"`
+		}{}
+
+		err := json.Unmarshal(body, &errStruct)
+		if err != nil || errStruct.ErrMsg == "" {
+			return nil, requestutil.HttpStatusError(res)
+		}
+		return nil, requestutil.HttpStatusError(
+			res,
+			errStruct.ErrCode,
+			": ",
+			errStruct.ErrMsg,
+		)
+	}
+
+	return body, nil
+}
@@ -0,0 +1,162 @@
+package luno_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thecodedproject/crypto/exchangesdk/luno"
+	"github.com/thecodedproject/crypto/exchangesdk/requestutil"
+)
+
+func candlesJson(sinceMs int64, durationMs int64, n int) string {
+
+	candles := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		candles = append(candles, fmt.Sprintf(
+			"{\"timestamp\": %d, \"open\": \"%d.1\", \"close\": \"%d.4\", \"high\": \"%d.2\", \"low\": \"%d.3\", \"volume\": \"%d.5\"}",
+			sinceMs+int64(i)*durationMs,
+			i, i, i, i, i,
+		))
+	}
+	return "{\"candles\": [" + strings.Join(candles, ",") + "]}"
+}
+
+func TestHistoricalCandlesWithUnsupportedIntervalReturnsError(t *testing.T) {
+
+	c := luno.NewHttpClientForTesting(t, "k", "s", "XBTEUR", func(req *http.Request) *http.Response {
+
+		t.Fatal("unexpected request")
+		return nil
+	})
+
+	_, err := c.HistoricalCandles(
+		context.Background(),
+		2*time.Minute,
+		time.Unix(0, 0),
+		time.Unix(1000, 0),
+	)
+	require.Error(t, err)
+}
+
+func TestHistoricalCandlesSinglePage(t *testing.T) {
+
+	from := time.Unix(1600000000, 0)
+	to := from.Add(2 * time.Hour)
+
+	handlerCalls := 0
+	c := luno.NewHttpClientForTesting(t, "k", "s", "XBTEUR", func(req *http.Request) *http.Response {
+
+		handlerCalls++
+		assert.Equal(t, "GET", req.Method)
+		assert.Contains(t, req.URL.String(), "https://api.luno.com/api/exchange/1/candles")
+
+		user, pass, ok := req.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "k", user)
+		assert.Equal(t, "s", pass)
+
+		values := req.URL.Query()
+		assert.Equal(t, "XBTEUR", values.Get("pair"))
+		assert.Equal(t, "1600000000000", values.Get("since"))
+		assert.Equal(t, "3600", values.Get("duration"))
+
+		// Luno returns candles up to the present; those after `to` must be dropped
+		return &http.Response{
+			StatusCode: 200,
+			Body: requestutil.ResBodyFromJsonf(
+				t,
+				"%s",
+				candlesJson(1600000000000, 3600000, 3),
+			),
+		}
+	})
+
+	candles, err := c.HistoricalCandles(context.Background(), time.Hour, from, to)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, handlerCalls)
+	require.Equal(t, 2, len(candles))
+	assert.True(t, from.Equal(candles[0].Timestamp))
+	assert.Equal(t, 0.1, candles[0].Open)
+	assert.Equal(t, 0.2, candles[0].High)
+	assert.Equal(t, 0.3, candles[0].Low)
+	assert.Equal(t, 0.4, candles[0].Close)
+	assert.Equal(t, 0.5, candles[0].Volume)
+	assert.True(t, from.Add(time.Hour).Equal(candles[1].Timestamp))
+	assert.Equal(t, 1.4, candles[1].Close)
+}
+
+func TestHistoricalCandlesPaginatesOverMultipleRequests(t *testing.T) {
+
+	sinceMs := int64(1600000000000)
+	durationMs := int64(60000)
+	from := time.Unix(0, sinceMs*int64(time.Millisecond))
+	to := from.Add(2500 * time.Minute)
+
+	var sinces []string
+	c := luno.NewHttpClientForTesting(t, "k", "s", "XBTEUR", func(req *http.Request) *http.Response {
+
+		since := req.URL.Query().Get("since")
+		sinces = append(sinces, since)
+
+		sinceMs, err := strconv.ParseInt(since, 10, 64)
+		require.NoError(t, err)
+
+		return &http.Response{
+			StatusCode: 200,
+			Body: requestutil.ResBodyFromJsonf(
+				t,
+				"%s",
+				candlesJson(sinceMs, durationMs, 1000),
+			),
+		}
+	})
+
+	candles, err := c.HistoricalCandles(context.Background(), time.Minute, from, to)
+	require.NoError(t, err)
+
+	assert.Equal(
+		t,
+		[]string{
+			strconv.FormatInt(sinceMs, 10),
+			strconv.FormatInt(sinceMs+1000*durationMs, 10),
+			strconv.FormatInt(sinceMs+2000*durationMs, 10),
+		},
+		sinces,
+	)
+	require.Equal(t, 2500, len(candles))
+	for i, candle := range candles {
+		assert.True(t, from.Add(time.Duration(i)*time.Minute).Equal(candle.Timestamp))
+	}
+}
+
+func TestHistoricalCandlesWhenLunoReturnsErrorReturnsError(t *testing.T) {
+
+	c := luno.NewHttpClientForTesting(t, "k", "s", "XBTEUR", func(req *http.Request) *http.Response {
+
+		return &http.Response{
+			StatusCode: 401,
+			Status:     "401 Unauthorized",
+			Body: requestutil.ResBodyFromJsonf(
+				t,
+				"{\"error_code\": \"ErrApiKeyRevoked\", \"error\": \"API key revoked\"}",
+			),
+		}
+	})
+
+	_, err := c.HistoricalCandles(
+		context.Background(),
+		time.Hour,
+		time.Unix(0, 0),
+		time.Unix(10000, 0),
+	)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "API key revoked")
+}
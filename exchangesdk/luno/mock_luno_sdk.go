@@ -32,6 +32,11 @@ func (m *MockLunoSdk) GetOrder(ctx context.Context, req *luno_sdk.GetOrderReques
 	return args.Get(0).(*luno_sdk.GetOrderResponse), args.Error(1)
 }
 
+func (m *MockLunoSdk) ListOrders(ctx context.Context, req *luno_sdk.ListOrdersRequest) (*luno_sdk.ListOrdersResponse, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).(*luno_sdk.ListOrdersResponse), args.Error(1)
+}
+
 func (m *MockLunoSdk) ListUserTrades(ctx context.Context, req *luno_sdk.ListUserTradesRequest) (*luno_sdk.ListUserTradesResponse, error) {
 	args := m.Called(ctx, req)
 	return args.Get(0).(*luno_sdk.ListUserTradesResponse), args.Error(1)
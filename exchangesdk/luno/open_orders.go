@@ -0,0 +1,54 @@
+package luno
+
+import (
+	"context"
+
+	luno_sdk "github.com/luno/luno-go"
+	"github.com/thecodedproject/crypto/exchangesdk"
+)
+
+var _ exchangesdk.OpenOrdersClient = (*client)(nil)
+
+// OpenOrders returns the orders on the client's pair which Luno reports as
+// pending; Luno lists at most the 100 most recently placed of them
+func (l *client) OpenOrders(ctx context.Context) ([]exchangesdk.OpenOrder, error) {
+
+	res, err := l.lunoSdk.ListOrders(ctx, &luno_sdk.ListOrdersRequest{
+		Pair:  l.tradingPair,
+		State: luno_sdk.OrderStatePending,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	open := make([]exchangesdk.OpenOrder, 0, len(res.Orders))
+	for _, o := range res.Orders {
+		side := exchangesdk.OrderBookSideBid
+		if o.Type == luno_sdk.OrderTypeAsk || o.Type == luno_sdk.OrderTypeSell {
+			side = exchangesdk.OrderBookSideAsk
+		}
+
+		limitPrice, err := lunoToShopSpringDecimal(o.LimitPrice)
+		if err != nil {
+			return nil, err
+		}
+		volume, err := lunoToShopSpringDecimal(o.LimitVolume)
+		if err != nil {
+			return nil, err
+		}
+		fillAmountBase, err := lunoToShopSpringDecimal(o.Base)
+		if err != nil {
+			return nil, err
+		}
+
+		open = append(open, exchangesdk.OpenOrder{
+			Id:             o.OrderId,
+			Side:           side,
+			State:          orderState(o.State),
+			LimitPrice:     limitPrice,
+			Volume:         volume,
+			FillAmountBase: fillAmountBase,
+		})
+	}
+	return open, nil
+}
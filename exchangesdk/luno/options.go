@@ -0,0 +1,169 @@
+package luno
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/logging"
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+const (
+	defaultBaseUrl = "https://api.luno.com"
+	defaultWsUrl   = "wss://ws.luno.com"
+
+	// defaultTimeout matches the timeout of the Luno SDK's own HTTP client,
+	// and applies to the default client and websocket handshakes unless
+	// WithTimeout is used
+	defaultTimeout = 10 * time.Second
+)
+
+type options struct {
+	baseUrl    string
+	wsUrl      string
+	httpClient *http.Client
+	timeout    time.Duration
+	timeoutSet bool
+	transport  func(http.RoundTripper) http.RoundTripper
+	observer   exchangesdk.FollowerObserver
+	logger     logging.Logger
+	clock      utiltime.Clock
+}
+
+// Option configures the endpoints used by the Luno client and market
+// follower
+type Option func(*options)
+
+func resolveOptions(opts []Option) options {
+
+	o := options{
+		baseUrl: defaultBaseUrl,
+		wsUrl:   defaultWsUrl,
+		logger:  logging.Std,
+		clock:   utiltime.Real,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithBaseUrl sets the base URL of the REST API (e.g. a local fake exchange)
+func WithBaseUrl(baseUrl string) Option {
+
+	return func(o *options) {
+		o.baseUrl = baseUrl
+	}
+}
+
+// WithWsUrl sets the base URL of the websocket streams
+func WithWsUrl(wsUrl string) Option {
+
+	return func(o *options) {
+		o.wsUrl = wsUrl
+	}
+}
+
+// WithHttpClient sets the HTTP client used for REST requests, whose timeout
+// is kept unless WithTimeout is used; by default a copy of http.DefaultClient
+// with a timeout of 10s is used
+func WithHttpClient(httpClient *http.Client) Option {
+
+	return func(o *options) {
+		o.httpClient = httpClient
+	}
+}
+
+// WithTimeout sets the timeout of REST requests and of websocket handshakes;
+// a timeout of zero means no timeout
+func WithTimeout(timeout time.Duration) Option {
+
+	return func(o *options) {
+		o.timeout = timeout
+		o.timeoutSet = true
+	}
+}
+
+// WithTransport wraps the transport of the HTTP client used for REST requests
+// (e.g. to instrument them); the configured client is left unchanged
+func WithTransport(wrap func(http.RoundTripper) http.RoundTripper) Option {
+
+	return func(o *options) {
+		o.transport = wrap
+	}
+}
+
+// WithFollowerObserver sets the observer which is notified of sequence gaps within the
+// market follower
+func WithFollowerObserver(observer exchangesdk.FollowerObserver) Option {
+
+	return func(o *options) {
+		o.observer = observer
+	}
+}
+
+// WithLogger sets the logger which errors of the market follower and of the
+// client's order updates stream are reported to; by default logging.Std is
+// used
+func WithLogger(logger logging.Logger) Option {
+
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithClock sets the clock which times the reconnection of the client's order
+// updates stream; by default the real clock is used.
+// The market follower has no timers of its own, and timestamps its order
+// books and trades with the times given by the exchange.
+func WithClock(clock utiltime.Clock) Option {
+
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// client returns the HTTP client for REST requests, applying the timeout to
+// a copy so that the configured client is left unchanged
+func (o options) client() *http.Client {
+
+	var c http.Client
+	if o.httpClient != nil {
+		c = *o.httpClient
+	} else {
+		c = *http.DefaultClient
+		c.Timeout = defaultTimeout
+	}
+	if o.timeoutSet {
+		c.Timeout = o.timeout
+	}
+	if o.transport != nil {
+		c.Transport = o.transport(transportOrDefault(c.Transport))
+	}
+	return &c
+}
+
+func (o options) dialer() *websocket.Dialer {
+
+	d := *websocket.DefaultDialer
+	timeout := defaultTimeout
+	if o.timeoutSet {
+		timeout = o.timeout
+	}
+	if timeout > 0 {
+		d.HandshakeTimeout = timeout
+	}
+	return &d
+}
+
+// transportOrDefault returns t, or the transport which http.Client uses when
+// its transport is nil
+func transportOrDefault(t http.RoundTripper) http.RoundTripper {
+
+	if t == nil {
+		return http.DefaultTransport
+	}
+	return t
+}
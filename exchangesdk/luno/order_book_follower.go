@@ -3,19 +3,24 @@ package luno
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"math"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/gorilla/websocket"
 	"github.com/thecodedproject/crypto"
 	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/logging"
 )
 
+// ErrOutOfSequence is returned by HandleUpdate when an update does not follow
+// on from the last update applied to the order book
+var ErrOutOfSequence = errors.New("Out of sequence OrderBookUpdate")
+
 type exchangeConfig struct {
-	WsUrl                 string
+	StreamPath            string
 	MarketVolumePrecision float64
 }
 
@@ -77,6 +82,7 @@ func NewOrderBookFollowerAndTradeStream(
 	pair crypto.Pair,
 	apiKey string,
 	apiSecret string,
+	opts ...Option,
 ) (<-chan exchangesdk.OrderBook, <-chan exchangesdk.OrderBookTrade, error) {
 
 	exConf, err := getExchangeConfig(pair)
@@ -87,9 +93,11 @@ func NewOrderBookFollowerAndTradeStream(
 	return followForever(
 		ctx,
 		wg,
+		pair,
 		exConf,
 		apiKey,
 		apiSecret,
+		resolveOptions(opts),
 	)
 }
 
@@ -98,27 +106,27 @@ func getExchangeConfig(pair crypto.Pair) (exchangeConfig, error) {
 	switch pair {
 	case crypto.PairBTCEUR:
 		return exchangeConfig{
-			WsUrl:                 "wss://ws.luno.com/api/1/stream/XBTEUR",
+			StreamPath:            "/api/1/stream/XBTEUR",
 			MarketVolumePrecision: 1e-4,
 		}, nil
 	case crypto.PairBTCGBP:
 		return exchangeConfig{
-			WsUrl:                 "wss://ws.luno.com/api/1/stream/XBTGBP",
+			StreamPath:            "/api/1/stream/XBTGBP",
 			MarketVolumePrecision: 1e-4,
 		}, nil
 	case crypto.PairLTCBTC:
 		return exchangeConfig{
-			WsUrl:                 "wss://ws.luno.com/api/1/stream/LTCXBT",
+			StreamPath:            "/api/1/stream/LTCXBT",
 			MarketVolumePrecision: 1e-2,
 		}, nil
 	case crypto.PairETHBTC:
 		return exchangeConfig{
-			WsUrl:                 "wss://ws.luno.com/api/1/stream/ETHXBT",
+			StreamPath:            "/api/1/stream/ETHXBT",
 			MarketVolumePrecision: 1e-2,
 		}, nil
 	case crypto.PairBCHBTC:
 		return exchangeConfig{
-			WsUrl:                 "wss://ws.luno.com/api/1/stream/BCHXBT",
+			StreamPath:            "/api/1/stream/BCHXBT",
 			MarketVolumePrecision: 1e-2,
 		}, nil
 	default:
@@ -129,22 +137,59 @@ func getExchangeConfig(pair crypto.Pair) (exchangeConfig, error) {
 func followForever(
 	ctx context.Context,
 	wg *sync.WaitGroup,
+	pair crypto.Pair,
 	exConf exchangeConfig,
 	apiKey string,
 	apiSecret string,
+	opts options,
 ) (<-chan exchangesdk.OrderBook, <-chan exchangesdk.OrderBookTrade, error) {
 
 	obf := make(chan exchangesdk.OrderBook, 1)
 	tradeStream := make(chan exchangesdk.OrderBookTrade, 1)
 	var ob InternalOrderBook
 
+	logError := func(msg string, err error, args ...interface{}) {
+		fields := []interface{}{
+			logging.KeyProvider, crypto.ApiProviderLuno,
+			logging.KeyPair, pair,
+		}
+		fields = append(fields, args...)
+		fields = append(fields, logging.KeyError, err)
+		opts.logger.Error(msg, fields...)
+	}
+
+	// fail reports an error which the follower cannot recover from, and
+	// closes its streams
+	fail := func(msg string, err error, args ...interface{}) {
+		logError(msg, err, args...)
+		close(obf)
+		close(tradeStream)
+	}
+
 	go func() {
 
-		ws, _, err := websocket.DefaultDialer.Dial(exConf.WsUrl, nil)
+		defer wg.Done()
+
+		ws, _, err := opts.dialer().Dial(
+			strings.TrimRight(opts.wsUrl, "/")+exConf.StreamPath,
+			nil,
+		)
 		if err != nil {
-			log.Fatal(err)
+			fail("Luno order book follower cannot connect", err)
+			return
 		}
-		defer ws.Close()
+
+		// The connection is closed once the follower is cancelled, to
+		// interrupt a blocked read, or once it has stopped
+		stopped := make(chan struct{})
+		defer close(stopped)
+		go func() {
+			select {
+			case <-ctx.Done():
+			case <-stopped:
+			}
+			ws.Close()
+		}()
 
 		creds := struct {
 			Key    string `json:"api_key_id"`
@@ -155,26 +200,38 @@ func followForever(
 		}
 
 		if err := ws.WriteJSON(creds); err != nil {
-			log.Fatal(err)
+			fail("Luno order book follower cannot authenticate", err)
+			return
 		}
 
 		_, msg, err := ws.ReadMessage()
 		if err != nil {
-			log.Fatal("ReadMessage error:", err)
+			fail("Luno order book follower cannot read snapshot", err)
+			return
 		}
 
 		snapshot := OrderBookSnapshot{}
 		if err := json.Unmarshal(msg, &snapshot); err != nil {
-			log.Fatal(err)
+			fail("Luno order book follower cannot decode snapshot", err, "message", string(msg))
+			return
 		}
 		handleSnapshot(&ob, snapshot)
-		obf <- *toSortedOrderBook(&ob)
+		select {
+		case obf <- *toSortedOrderBook(&ob):
+		case <-ctx.Done():
+			return
+		}
 
 		for {
 
 			_, msg, err := ws.ReadMessage()
 			if err != nil {
-				log.Fatal("ReadMessage error:", err)
+				if ctx.Err() != nil {
+					// The connection was closed after the follower was cancelled
+					return
+				}
+				fail("Luno order book follower cannot read message", err)
+				return
 			}
 
 			if string(msg) == "\"\"" {
@@ -184,34 +241,50 @@ func followForever(
 
 			update := OrderBookUpdate{}
 			if err := json.Unmarshal(msg, &update); err != nil {
-				log.Fatal(err, ": ", string(msg))
+				fail("Luno order book follower cannot decode message", err, "message", string(msg))
+				return
 			}
 
 			for _, tradeUpdate := range update.TradeUpdates {
 				t, err := convertToSdkTrade(&ob, tradeUpdate, update.Timestamp)
 				if err != nil {
-					log.Println("TradeStream error:", err)
-					close(tradeStream)
-					wg.Done()
+					fail(
+						"Luno order book follower cannot convert trade",
+						err,
+						logging.KeySequence, update.Sequence,
+					)
+					return
+				}
+
+				select {
+				case tradeStream <- t:
+				case <-ctx.Done():
 					return
 				}
-				tradeStream <- t
 			}
 
 			obUpdated, err := HandleUpdate(&ob, update, exConf.MarketVolumePrecision)
+			if errors.Is(err, ErrOutOfSequence) && opts.observer != nil {
+				opts.observer.SequenceGap()
+			}
 			if err != nil {
-				log.Println("OrderBookFollower error:", err)
-				close(obf)
-				wg.Done()
+				fail(
+					"Luno order book follower cannot apply order book update",
+					err,
+					logging.KeySequence, update.Sequence,
+				)
 				return
 			}
 			if obUpdated {
-				obf <- *toSortedOrderBook(&ob)
+				select {
+				case obf <- *toSortedOrderBook(&ob):
+				case <-ctx.Done():
+					return
+				}
 			}
 
 			select {
 			case <-ctx.Done():
-				wg.Done()
 				return
 			default:
 				continue
@@ -275,7 +348,7 @@ func HandleUpdate(ob *InternalOrderBook, u OrderBookUpdate, volPrecision float64
 	}
 
 	if u.Sequence != ob.LastSequenceId+1 {
-		return updated, fmt.Errorf("Out of sequence OrderBookUpdate")
+		return updated, ErrOutOfSequence
 	}
 
 	for _, t := range u.TradeUpdates {
@@ -410,9 +483,14 @@ func convertToSdkTrade(
 		return exchangesdk.OrderBookTrade{}, fmt.Errorf("received trade with unknown trade side `%+v`", t)
 	}
 
+	if t.Base <= 0 {
+		return exchangesdk.OrderBookTrade{}, fmt.Errorf("received trade with non-positive base `%+v`", t)
+	}
+
+	// Counter is the total counter amount traded, not the price
 	return exchangesdk.OrderBookTrade{
 		MakerSide: makerSide,
-		Price:     t.Counter,
+		Price:     t.Counter / t.Base,
 		Volume:    t.Base,
 		Timestamp: time.Unix(0, timestamp*int64(time.Millisecond)),
 	}, nil
@@ -1,10 +1,16 @@
 package luno_test
 
 import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk/logging"
 	"github.com/thecodedproject/crypto/exchangesdk/luno"
 )
 
@@ -166,3 +172,66 @@ func TestHandleUpdate(t *testing.T) {
 		})
 	}
 }
+
+type logEntry struct {
+	msg  string
+	args []interface{}
+}
+
+// recordingLogger records the entries logged at error level
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+func (l *recordingLogger) Debug(string, ...interface{}) {}
+func (l *recordingLogger) Info(string, ...interface{})  {}
+func (l *recordingLogger) Warn(string, ...interface{})  {}
+
+func (l *recordingLogger) Error(msg string, args ...interface{}) {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = append(l.entries, logEntry{msg: msg, args: args})
+}
+
+func TestFollowerWhichCannotConnectLogsErrorAndClosesStreams(t *testing.T) {
+
+	// A server which has been closed, so that connecting to it fails
+	server := httptest.NewServer(nil)
+	wsUrl := "ws" + strings.TrimPrefix(server.URL, "http")
+	server.Close()
+
+	logger := &recordingLogger{}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	obf, tradeStream, err := luno.NewOrderBookFollowerAndTradeStream(
+		context.Background(),
+		&wg,
+		crypto.PairBTCEUR,
+		"key",
+		"secret",
+		luno.WithWsUrl(wsUrl),
+		luno.WithLogger(logger),
+	)
+	require.NoError(t, err)
+
+	_, more := <-obf
+	assert.False(t, more)
+	_, more = <-tradeStream
+	assert.False(t, more)
+	wg.Wait()
+
+	require.Len(t, logger.entries, 1)
+	entry := logger.entries[0]
+	assert.Equal(t, "Luno order book follower cannot connect", entry.msg)
+	require.Len(t, entry.args, 6)
+	assert.Equal(t, []interface{}{
+		logging.KeyProvider, crypto.ApiProviderLuno,
+		logging.KeyPair, crypto.PairBTCEUR,
+		logging.KeyError,
+	}, entry.args[:5])
+	assert.Error(t, entry.args[5].(error))
+}
@@ -0,0 +1,211 @@
+package luno
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	luno_sdk "github.com/luno/luno-go"
+	"github.com/shopspring/decimal"
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/logging"
+)
+
+const (
+	userStreamPath           = "/api/1/userstream"
+	userStreamReconnectDelay = time.Second
+)
+
+var _ exchangesdk.OrderUpdatesClient = (*client)(nil)
+
+type userStreamUpdate struct {
+	Type      string `json:"type"`
+	Timestamp int64  `json:"timestamp"`
+
+	OrderStatusUpdate *struct {
+		OrderId  string `json:"order_id"`
+		MarketId string `json:"market_id"`
+		Status   string `json:"status"`
+	} `json:"order_status_update"`
+
+	OrderFillUpdate *struct {
+		OrderId         string          `json:"order_id"`
+		MarketId        string          `json:"market_id"`
+		BaseFill        decimal.Decimal `json:"base_fill"`
+		CounterFill     decimal.Decimal `json:"counter_fill"`
+		BaseDelta       decimal.Decimal `json:"base_delta"`
+		CounterDelta    decimal.Decimal `json:"counter_delta"`
+		BaseFeeDelta    decimal.Decimal `json:"base_fee_delta"`
+		CounterFeeDelta decimal.Decimal `json:"counter_fee_delta"`
+	} `json:"order_fill_update"`
+}
+
+type fillAmounts struct {
+	base    decimal.Decimal
+	counter decimal.Decimal
+}
+
+// OrderUpdates streams updates to the client's orders from the Luno user
+// stream, reconnecting if the stream is dropped.
+// As with GetOrderStatus, Luno reports cancelled orders as complete, and so
+// they are streamed as OrderStateFilled.
+func (l *client) OrderUpdates(
+	ctx context.Context,
+) (<-chan exchangesdk.OrderUpdate, error) {
+
+	updates := make(chan exchangesdk.OrderUpdate)
+	go func() {
+
+		defer close(updates)
+
+		// Status updates do not include the amounts filled, so these are
+		// kept from the fill updates
+		fills := make(map[string]fillAmounts)
+
+		for {
+			err := l.followUserStream(ctx, fills, updates)
+			if ctx.Err() != nil {
+				return
+			}
+			l.logger.Error(
+				"Luno user stream failed",
+				logging.KeyProvider, crypto.ApiProviderLuno,
+				logging.KeyPair, l.pair,
+				logging.KeyError, err,
+			)
+
+			select {
+			case <-l.clock.After(userStreamReconnectDelay):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return updates, nil
+}
+
+// followUserStream sends the order updates from the user stream until the
+// stream fails or ctx is cancelled
+func (l *client) followUserStream(
+	ctx context.Context,
+	fills map[string]fillAmounts,
+	updates chan<- exchangesdk.OrderUpdate,
+) error {
+
+	ws, _, err := l.dialer.Dial(
+		strings.TrimRight(l.wsUrl, "/")+userStreamPath,
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-streamCtx.Done()
+		ws.Close()
+	}()
+
+	creds := struct {
+		Key    string `json:"api_key_id"`
+		Secret string `json:"api_key_secret"`
+	}{
+		Key:    l.apiKey,
+		Secret: l.apiSecret,
+	}
+
+	err = ws.WriteJSON(creds)
+	if err != nil {
+		return err
+	}
+
+	for {
+		_, msg, err := ws.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		if string(msg) == "\"\"" {
+			// Keep alive message
+			continue
+		}
+
+		var update userStreamUpdate
+		err = json.Unmarshal(msg, &update)
+		if err != nil {
+			return err
+		}
+
+		for _, u := range l.orderUpdates(update, fills) {
+			select {
+			case updates <- u:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+func (l *client) orderUpdates(
+	update userStreamUpdate,
+	fills map[string]fillAmounts,
+) []exchangesdk.OrderUpdate {
+
+	timestamp := time.Unix(0, update.Timestamp*int64(time.Millisecond))
+
+	switch {
+	case update.OrderFillUpdate != nil:
+		f := update.OrderFillUpdate
+		if f.MarketId != l.tradingPair || !f.BaseDelta.IsPositive() {
+			return nil
+		}
+
+		fills[f.OrderId] = fillAmounts{
+			base:    f.BaseFill,
+			counter: f.CounterFill,
+		}
+
+		return []exchangesdk.OrderUpdate{{
+			Type:      exchangesdk.OrderUpdateTypeFill,
+			OrderId:   f.OrderId,
+			Timestamp: timestamp,
+			// Luno sends a separate status update once the order is complete
+			State:             exchangesdk.OrderStateInOrderBook,
+			FillAmountBase:    f.BaseFill,
+			FillAmountCounter: f.CounterFill,
+			FillPrice:         f.CounterDelta.Div(f.BaseDelta),
+			FillVolume:        f.BaseDelta,
+			BaseFee:           f.BaseFeeDelta,
+			CounterFee:        f.CounterFeeDelta,
+		}}
+
+	case update.OrderStatusUpdate != nil:
+		s := update.OrderStatusUpdate
+		if s.MarketId != l.tradingPair {
+			return nil
+		}
+
+		state := orderState(luno_sdk.OrderState(s.Status))
+		filled := fills[s.OrderId]
+		if state == exchangesdk.OrderStateFilled || state == exchangesdk.OrderStateCancelled {
+			delete(fills, s.OrderId)
+		}
+
+		return []exchangesdk.OrderUpdate{{
+			Type:              exchangesdk.OrderUpdateTypeStateChange,
+			OrderId:           s.OrderId,
+			Timestamp:         timestamp,
+			State:             state,
+			FillAmountBase:    filled.base,
+			FillAmountCounter: filled.counter,
+		}}
+
+	default:
+		return nil
+	}
+}
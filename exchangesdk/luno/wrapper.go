@@ -4,15 +4,20 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"testing"
 	"time"
 
+	"github.com/gorilla/websocket"
 	luno_sdk "github.com/luno/luno-go"
 	lunodecimal "github.com/luno/luno-go/decimal"
 	"github.com/shopspring/decimal"
 	"github.com/thecodedproject/crypto"
 	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/logging"
+	"github.com/thecodedproject/crypto/exchangesdk/requestutil"
 	"github.com/thecodedproject/crypto/util"
+	utiltime "github.com/thecodedproject/crypto/util/time"
 )
 
 // LunoSdk is the interface presented by the Luno Go SDK.
@@ -22,6 +27,7 @@ type LunoSdk interface {
 	PostLimitOrder(ctx context.Context, req *luno_sdk.PostLimitOrderRequest) (*luno_sdk.PostLimitOrderResponse, error)
 	StopOrder(ctx context.Context, req *luno_sdk.StopOrderRequest) (*luno_sdk.StopOrderResponse, error)
 	GetOrder(ctx context.Context, req *luno_sdk.GetOrderRequest) (*luno_sdk.GetOrderResponse, error)
+	ListOrders(ctx context.Context, req *luno_sdk.ListOrdersRequest) (*luno_sdk.ListOrdersResponse, error)
 	ListUserTrades(ctx context.Context, req *luno_sdk.ListUserTradesRequest) (*luno_sdk.ListUserTradesResponse, error)
 }
 
@@ -32,15 +38,24 @@ type tradesAndLastSeq struct {
 
 type client struct {
 	lunoSdk      LunoSdk
+	baseUrl      string
+	wsUrl        string
+	apiKey       string
+	apiSecret    string
+	httpClient   *http.Client
+	dialer       *websocket.Dialer
 	pair         crypto.Pair
 	tradingPair  string
 	tradesByPage map[int64]tradesAndLastSeq
+	logger       logging.Logger
+	clock        utiltime.Clock
 }
 
 func NewClient(
 	id string,
 	secret string,
 	pair crypto.Pair,
+	opts ...Option,
 ) (*client, error) {
 
 	tradingPair, err := getLunoTradingPair(pair)
@@ -48,13 +63,27 @@ func NewClient(
 		return nil, err
 	}
 
+	o := resolveOptions(opts)
+	httpClient := o.client()
+
 	c := luno_sdk.NewClient()
 	c.SetAuth(id, secret)
+	c.SetBaseURL(o.baseUrl)
+	c.SetHTTPClient(httpClient)
 
 	return &client{
 		lunoSdk:      c,
+		baseUrl:      o.baseUrl,
+		wsUrl:        o.wsUrl,
+		apiKey:       id,
+		apiSecret:    secret,
+		httpClient:   httpClient,
+		dialer:       o.dialer(),
+		pair:         pair,
 		tradingPair:  tradingPair,
 		tradesByPage: make(map[int64]tradesAndLastSeq),
+		logger:       o.logger,
+		clock:        o.clock,
 	}, nil
 }
 
@@ -62,8 +91,40 @@ func NewClientForTesting(_ *testing.T, lunoSdk LunoSdk) *client {
 
 	return &client{
 		lunoSdk:      lunoSdk,
+		baseUrl:      defaultBaseUrl,
+		wsUrl:        defaultWsUrl,
+		httpClient:   http.DefaultClient,
+		dialer:       websocket.DefaultDialer,
 		tradingPair:  "TestPair",
 		tradesByPage: make(map[int64]tradesAndLastSeq),
+		logger:       logging.Std,
+		clock:        utiltime.Real,
+	}
+}
+
+// NewHttpClientForTesting returns a client which makes any requests not
+// supported by the Luno SDK via handler
+func NewHttpClientForTesting(
+	_ *testing.T,
+	apiKey string,
+	apiSecret string,
+	tradingPair string,
+	handler func(req *http.Request) *http.Response,
+) *client {
+
+	return &client{
+		baseUrl:   defaultBaseUrl,
+		wsUrl:     defaultWsUrl,
+		apiKey:    apiKey,
+		apiSecret: apiSecret,
+		httpClient: &http.Client{
+			Transport: requestutil.RoundTripFunc(handler),
+		},
+		dialer:       websocket.DefaultDialer,
+		tradingPair:  tradingPair,
+		tradesByPage: make(map[int64]tradesAndLastSeq),
+		logger:       logging.Std,
+		clock:        utiltime.Real,
 	}
 }
 
@@ -233,22 +294,29 @@ func (l *client) GetOrderStatus(ctx context.Context, orderId string) (exchangesd
 		return exchangesdk.OrderStatus{}, err
 	}
 
-	state := exchangesdk.OrderStateUnknown
-	if res.State == "PENDING" {
-		state = exchangesdk.OrderStateInOrderBook
-	} else if res.State == "COMPLETED" {
-		state = exchangesdk.OrderStateFilled
-	} else if res.State == "CANCELLED" {
-		state = exchangesdk.OrderStateCancelled
-	}
-
 	return exchangesdk.OrderStatus{
-		State:          state,
+		State:          orderState(res.State),
 		Type:           exchangesdk.OrderType(res.Type),
 		FillAmountBase: fillAmountBase,
 	}, nil
 }
 
+func orderState(state luno_sdk.OrderState) exchangesdk.OrderState {
+
+	switch state {
+	case "AWAITING":
+		return exchangesdk.OrderStateAwaitingTrigger
+	case luno_sdk.OrderStatePending:
+		return exchangesdk.OrderStateInOrderBook
+	case luno_sdk.OrderStateComplete, "COMPLETED":
+		return exchangesdk.OrderStateFilled
+	case "CANCELLED":
+		return exchangesdk.OrderStateCancelled
+	default:
+		return exchangesdk.OrderStateUnknown
+	}
+}
+
 func (l *client) GetTrades(ctx context.Context, page int64) ([]exchangesdk.Trade, error) {
 
 	if page < 1 {
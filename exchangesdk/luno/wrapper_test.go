@@ -2,15 +2,19 @@ package luno_test
 
 import (
 	"context"
+	"net/http"
 	"strconv"
 	"testing"
+	"time"
 
 	luno_sdk "github.com/luno/luno-go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"github.com/thecodedproject/crypto"
 	"github.com/thecodedproject/crypto/exchangesdk"
 	"github.com/thecodedproject/crypto/exchangesdk/luno"
+	"github.com/thecodedproject/crypto/exchangesdk/requestutil"
 )
 
 func makeSomeLunoTrades(n int64, offset int64) []luno_sdk.Trade {
@@ -333,3 +337,54 @@ func TestGetTradesForHundredthPageWhenOnlyFirstPageHasTradesOnlyRequestsFirstPag
 
 	assert.Equal(t, 0, len(trades))
 }
+
+// blockingHttpClient returns an HTTP client whose requests block until they
+// are cancelled
+func blockingHttpClient(timeout time.Duration) *http.Client {
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: requestutil.RoundTripFunc(func(req *http.Request) *http.Response {
+			<-req.Context().Done()
+			return nil
+		}),
+	}
+}
+
+func TestNewClientKeepsTimeoutOfHttpClient(t *testing.T) {
+
+	httpClient := blockingHttpClient(50 * time.Millisecond)
+
+	c, err := luno.NewClient(
+		"id",
+		"secret",
+		crypto.PairBTCEUR,
+		luno.WithHttpClient(httpClient),
+	)
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = c.LatestPrice(context.Background())
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < time.Second, time.Since(start).String())
+}
+
+func TestNewClientWithTimeoutAppliesTimeoutToCopyOfHttpClient(t *testing.T) {
+
+	httpClient := blockingHttpClient(0)
+
+	c, err := luno.NewClient(
+		"id",
+		"secret",
+		crypto.PairBTCEUR,
+		luno.WithHttpClient(httpClient),
+		luno.WithTimeout(50*time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = c.LatestPrice(context.Background())
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < time.Second, time.Since(start).String())
+	assert.Equal(t, time.Duration(0), httpClient.Timeout)
+}
@@ -0,0 +1,401 @@
+// Package metrics instruments the exchange clients and market followers, and
+// exports the metrics in the Prometheus text exposition format.
+//
+// Instrumentation is opt-in; see factory.WithMetrics.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+// Streams of a market follower
+const (
+	StreamOrderBook = "order_book"
+	StreamTrades    = "trades"
+)
+
+// Classes of the outcome of a REST request
+const (
+	ClassOk          = "ok"
+	ClassClientError = "client_error"
+	ClassRateLimited = "rate_limited"
+	ClassServerError = "server_error"
+	ClassTimeout     = "timeout"
+	ClassCancelled   = "cancelled"
+	ClassNetwork     = "network"
+)
+
+var (
+	latencyBuckets = []float64{
+		0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10,
+	}
+)
+
+type options struct {
+	clock utiltime.Clock
+}
+
+// Option configures Metrics
+type Option func(*options)
+
+func resolveOptions(opts []Option) options {
+
+	o := options{
+		clock: utiltime.Real,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithClock sets the clock which latencies are measured with; by default the
+// real clock is used
+func WithClock(clock utiltime.Clock) Option {
+
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// Metrics are the metrics of the market followers and REST clients of one
+// or more exchanges
+type Metrics struct {
+	registry *Registry
+	clock    utiltime.Clock
+
+	followerMessages     *CounterVec
+	followerBookLatency  *HistogramVec
+	followerReconnects   *CounterVec
+	followerSequenceGaps *CounterVec
+	followerBlocked      *CounterVec
+	followerQueued       *GaugeVec
+
+	restRequests *CounterVec
+	restDuration *HistogramVec
+
+	marketBestBid *GaugeVec
+	marketBestAsk *GaugeVec
+	marketSpread  *GaugeVec
+	marketMid     *GaugeVec
+}
+
+func New(opts ...Option) *Metrics {
+
+	o := resolveOptions(opts)
+	r := NewRegistry()
+
+	return &Metrics{
+		registry: r,
+		clock:    o.clock,
+
+		followerMessages: r.NewCounter(
+			"crypto_follower_messages_total",
+			"Messages received by market followers; use rate() for messages per second.",
+			"provider", "pair", "stream",
+		),
+		followerBookLatency: r.NewHistogram(
+			"crypto_follower_book_latency_seconds",
+			"Time from the exchange timestamp of an order book to its receipt.",
+			latencyBuckets,
+			"provider", "pair",
+		),
+		followerReconnects: r.NewCounter(
+			"crypto_follower_reconnects_total",
+			"Connections to the exchange replaced by market followers.",
+			"provider", "pair",
+		),
+		followerSequenceGaps: r.NewCounter(
+			"crypto_follower_sequence_gaps_total",
+			"Missed order book updates detected by market followers.",
+			"provider", "pair",
+		),
+		followerBlocked: r.NewCounter(
+			"crypto_follower_blocked_seconds_total",
+			"Time spent waiting for the consumer of a market follower to receive messages.",
+			"provider", "pair", "stream",
+		),
+		followerQueued: r.NewGauge(
+			"crypto_follower_queued_messages",
+			"Messages waiting in the channel of a market follower when one was received.",
+			"provider", "pair", "stream",
+		),
+
+		restRequests: r.NewCounter(
+			"crypto_rest_requests_total",
+			"REST requests made to exchanges, by class of outcome.",
+			"provider", "method", "class",
+		),
+		restDuration: r.NewHistogram(
+			"crypto_rest_request_duration_seconds",
+			"Duration of REST requests made to exchanges.",
+			latencyBuckets,
+			"provider", "method",
+		),
+
+		marketBestBid: r.NewGauge(
+			"crypto_market_best_bid",
+			"Best bid price of the latest order book.",
+			"provider", "pair",
+		),
+		marketBestAsk: r.NewGauge(
+			"crypto_market_best_ask",
+			"Best ask price of the latest order book.",
+			"provider", "pair",
+		),
+		marketSpread: r.NewGauge(
+			"crypto_market_spread",
+			"Difference between the best ask and best bid of the latest order book.",
+			"provider", "pair",
+		),
+		marketMid: r.NewGauge(
+			"crypto_market_mid",
+			"Mid price of the latest order book.",
+			"provider", "pair",
+		),
+	}
+}
+
+// Registry returns the registry of the metrics (e.g. to add further metrics
+// to those exported)
+func (m *Metrics) Registry() *Registry {
+
+	return m.registry
+}
+
+// ServeHTTP serves the metrics in the Prometheus text exposition format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+
+	m.registry.ServeHTTP(w, req)
+}
+
+// ListenAndServe serves the metrics on `/metrics` at addr until ctx is
+// cancelled; wg.Done is called once the server has shut down
+func (m *Metrics) ListenAndServe(
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	addr string,
+) error {
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	server := &http.Server{Handler: mux}
+
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+		server.Close()
+	}()
+	go server.Serve(l)
+
+	return nil
+}
+
+// FollowMarket passes the output of a market follower of exchange through,
+// recording the messages, book latency, backpressure and market prices.
+// wg.Done is called once both input streams are closed or ctx is cancelled.
+func (m *Metrics) FollowMarket(
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	exchange crypto.Exchange,
+	obf <-chan exchangesdk.OrderBook,
+	tradeStream <-chan exchangesdk.OrderBookTrade,
+) (<-chan exchangesdk.OrderBook, <-chan exchangesdk.OrderBookTrade) {
+
+	obfOut := make(chan exchangesdk.OrderBook)
+	tradeStreamOut := make(chan exchangesdk.OrderBookTrade)
+	provider, pair := exchange.Provider.String(), exchange.Pair.String()
+
+	go func() {
+
+		defer wg.Done()
+
+		for obf != nil || tradeStream != nil {
+			select {
+			case ob, more := <-obf:
+				if !more {
+					close(obfOut)
+					obf = nil
+					continue
+				}
+				m.followerQueued.Set(float64(len(obf)), provider, pair, StreamOrderBook)
+				m.observeOrderBook(ob, provider, pair)
+
+				select {
+				case obfOut <- ob:
+					continue
+				default:
+				}
+				blockedFrom := m.clock.Now()
+				select {
+				case obfOut <- ob:
+					m.followerBlocked.Add(m.clock.Now().Sub(blockedFrom).Seconds(), provider, pair, StreamOrderBook)
+				case <-ctx.Done():
+					return
+				}
+			case trade, more := <-tradeStream:
+				if !more {
+					close(tradeStreamOut)
+					tradeStream = nil
+					continue
+				}
+				m.followerQueued.Set(float64(len(tradeStream)), provider, pair, StreamTrades)
+				m.followerMessages.Inc(provider, pair, StreamTrades)
+
+				select {
+				case tradeStreamOut <- trade:
+					continue
+				default:
+				}
+				blockedFrom := m.clock.Now()
+				select {
+				case tradeStreamOut <- trade:
+					m.followerBlocked.Add(m.clock.Now().Sub(blockedFrom).Seconds(), provider, pair, StreamTrades)
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return obfOut, tradeStreamOut
+}
+
+func (m *Metrics) observeOrderBook(ob exchangesdk.OrderBook, provider, pair string) {
+
+	m.followerMessages.Inc(provider, pair, StreamOrderBook)
+	if !ob.Timestamp.IsZero() {
+		m.followerBookLatency.Observe(m.clock.Now().Sub(ob.Timestamp).Seconds(), provider, pair)
+	}
+
+	if len(ob.Bids) > 0 {
+		m.marketBestBid.Set(ob.Bids[0].Price, provider, pair)
+	}
+	if len(ob.Asks) > 0 {
+		m.marketBestAsk.Set(ob.Asks[0].Price, provider, pair)
+	}
+	if len(ob.Bids) > 0 && len(ob.Asks) > 0 {
+		m.marketSpread.Set(ob.Asks[0].Price-ob.Bids[0].Price, provider, pair)
+		m.marketMid.Set((ob.Asks[0].Price+ob.Bids[0].Price)/2, provider, pair)
+	}
+}
+
+// FollowerObserver returns an observer which records the reconnects and
+// sequence gaps of the market follower of exchange
+func (m *Metrics) FollowerObserver(exchange crypto.Exchange) exchangesdk.FollowerObserver {
+
+	return followerObserver{
+		m:        m,
+		provider: exchange.Provider.String(),
+		pair:     exchange.Pair.String(),
+	}
+}
+
+type followerObserver struct {
+	m        *Metrics
+	provider string
+	pair     string
+}
+
+func (o followerObserver) Reconnected() {
+
+	o.m.followerReconnects.Inc(o.provider, o.pair)
+}
+
+func (o followerObserver) SequenceGap() {
+
+	o.m.followerSequenceGaps.Inc(o.provider, o.pair)
+}
+
+// Transport returns a wrapper of HTTP transports which records the count,
+// duration and class of outcome of the REST requests made to provider (see
+// binance.WithTransport and luno.WithTransport)
+func (m *Metrics) Transport(provider crypto.ApiProvider) func(http.RoundTripper) http.RoundTripper {
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return &roundTripper{
+			m:        m,
+			provider: provider.String(),
+			next:     next,
+		}
+	}
+}
+
+type roundTripper struct {
+	m        *Metrics
+	provider string
+	next     http.RoundTripper
+}
+
+func (t *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	start := t.m.clock.Now()
+	res, err := t.next.RoundTrip(req)
+	duration := t.m.clock.Now().Sub(start)
+
+	method := req.Method + " " + normalisePath(req.URL.Path)
+	t.m.restDuration.Observe(duration.Seconds(), t.provider, method)
+	t.m.restRequests.Inc(t.provider, method, classify(req, res, err))
+	return res, err
+}
+
+// classify returns the class of the outcome of a request
+func classify(req *http.Request, res *http.Response, err error) string {
+
+	if err != nil {
+		var netErr net.Error
+		switch {
+		case errors.Is(err, context.Canceled) || errors.Is(req.Context().Err(), context.Canceled):
+			return ClassCancelled
+		case errors.Is(err, context.DeadlineExceeded),
+			errors.As(err, &netErr) && netErr.Timeout():
+			return ClassTimeout
+		default:
+			return ClassNetwork
+		}
+	}
+
+	switch {
+	case res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusTeapot:
+		// Binance responds with 418 once an IP has been banned for exceeding
+		// its rate limits
+		return ClassRateLimited
+	case res.StatusCode >= 500:
+		return ClassServerError
+	case res.StatusCode >= 400:
+		return ClassClientError
+	default:
+		return ClassOk
+	}
+}
+
+// normalisePath replaces the segments of path which look like ids (i.e. long
+// segments containing digits, such as order ids) with `:id`, so that the
+// requests of a method share a label
+func normalisePath(path string) string {
+
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if len(s) > 8 && strings.IndexFunc(s, unicode.IsDigit) >= 0 {
+			segments[i] = ":id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
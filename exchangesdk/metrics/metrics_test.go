@@ -0,0 +1,147 @@
+package metrics_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/metrics"
+	"github.com/thecodedproject/crypto/exchangesdk/requestutil"
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+var (
+	start    = time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	exchange = crypto.Exchange{Provider: crypto.ApiProviderBinance, Pair: crypto.PairBTCEUR}
+)
+
+func text(t *testing.T, m *metrics.Metrics) string {
+
+	var b bytes.Buffer
+	require.NoError(t, m.Registry().WriteText(&b))
+	return b.String()
+}
+
+func TestFollowMarketRecordsMessagesLatencyAndPrices(t *testing.T) {
+
+	clock := utiltime.NewSimulatedClock(start)
+	m := metrics.New(metrics.WithClock(clock))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	obf := make(chan exchangesdk.OrderBook, 1)
+	tradeStream := make(chan exchangesdk.OrderBookTrade, 1)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	obfOut, tradeStreamOut := m.FollowMarket(ctx, &wg, exchange, obf, tradeStream)
+
+	obf <- exchangesdk.OrderBook{
+		Timestamp: start.Add(-200 * time.Millisecond),
+		Bids:      []exchangesdk.OrderBookOrder{{Price: 99, Volume: 1}},
+		Asks:      []exchangesdk.OrderBookOrder{{Price: 101, Volume: 1}},
+	}
+	ob := <-obfOut
+	assert.Equal(t, 99.0, ob.Bids[0].Price)
+
+	tradeStream <- exchangesdk.OrderBookTrade{Price: 100, Volume: 1}
+	<-tradeStreamOut
+
+	close(obf)
+	close(tradeStream)
+	_, more := <-obfOut
+	assert.False(t, more)
+	_, more = <-tradeStreamOut
+	assert.False(t, more)
+	wg.Wait()
+
+	out := text(t, m)
+	assert.Contains(t, out, `crypto_follower_messages_total{provider="binance",pair="btceur",stream="order_book"} 1`)
+	assert.Contains(t, out, `crypto_follower_messages_total{provider="binance",pair="btceur",stream="trades"} 1`)
+	assert.Contains(t, out, `crypto_follower_book_latency_seconds_bucket{provider="binance",pair="btceur",le="0.1"} 0`)
+	assert.Contains(t, out, `crypto_follower_book_latency_seconds_bucket{provider="binance",pair="btceur",le="0.25"} 1`)
+	assert.Contains(t, out, `crypto_market_best_bid{provider="binance",pair="btceur"} 99`)
+	assert.Contains(t, out, `crypto_market_best_ask{provider="binance",pair="btceur"} 101`)
+	assert.Contains(t, out, `crypto_market_spread{provider="binance",pair="btceur"} 2`)
+	assert.Contains(t, out, `crypto_market_mid{provider="binance",pair="btceur"} 100`)
+}
+
+func TestFollowerObserverCountsReconnectsAndSequenceGaps(t *testing.T) {
+
+	m := metrics.New()
+	o := m.FollowerObserver(exchange)
+
+	o.Reconnected()
+	o.SequenceGap()
+	o.SequenceGap()
+
+	out := text(t, m)
+	assert.Contains(t, out, `crypto_follower_reconnects_total{provider="binance",pair="btceur"} 1`)
+	assert.Contains(t, out, `crypto_follower_sequence_gaps_total{provider="binance",pair="btceur"} 2`)
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	return f(req)
+}
+
+func TestTransportRecordsRequestsByMethodAndClass(t *testing.T) {
+
+	clock := utiltime.NewSimulatedClock(start)
+	m := metrics.New(metrics.WithClock(clock))
+
+	status := map[string]int{
+		"/api/v3/order":                       200,
+		"/api/v3/openOrders":                  429,
+		"/api/exchange/2/orders/BXMC2CJ7HNB8": 404,
+		"/api/v3/depth":                       503,
+	}
+	client := &http.Client{
+		Transport: m.Transport(crypto.ApiProviderBinance)(roundTripFunc(
+			func(req *http.Request) (*http.Response, error) {
+				clock.Advance(30 * time.Millisecond)
+				if req.URL.Path == "/api/v3/account" {
+					return nil, errors.New("connection refused")
+				}
+				return &http.Response{
+					StatusCode: status[req.URL.Path],
+					Body:       requestutil.ResBodyFromJsonf(t, "{}"),
+				}, nil
+			},
+		)),
+	}
+
+	for _, path := range []string{
+		"/api/v3/order",
+		"/api/v3/order",
+		"/api/v3/openOrders",
+		"/api/exchange/2/orders/BXMC2CJ7HNB8",
+		"/api/v3/depth",
+		"/api/v3/account",
+	} {
+		res, err := client.Get("https://api.binance.com" + path)
+		if err == nil {
+			res.Body.Close()
+		}
+	}
+
+	out := text(t, m)
+	assert.Contains(t, out, `crypto_rest_requests_total{provider="binance",method="GET /api/v3/order",class="ok"} 2`)
+	assert.Contains(t, out, `crypto_rest_requests_total{provider="binance",method="GET /api/v3/openOrders",class="rate_limited"} 1`)
+	assert.Contains(t, out, `crypto_rest_requests_total{provider="binance",method="GET /api/exchange/2/orders/:id",class="client_error"} 1`)
+	assert.Contains(t, out, `crypto_rest_requests_total{provider="binance",method="GET /api/v3/depth",class="server_error"} 1`)
+	assert.Contains(t, out, `crypto_rest_requests_total{provider="binance",method="GET /api/v3/account",class="network"} 1`)
+	assert.Contains(t, out, `crypto_rest_request_duration_seconds_bucket{provider="binance",method="GET /api/v3/order",le="0.025"} 0`)
+	assert.Contains(t, out, `crypto_rest_request_duration_seconds_bucket{provider="binance",method="GET /api/v3/order",le="0.05"} 2`)
+}
@@ -0,0 +1,290 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	kindCounter   = "counter"
+	kindGauge     = "gauge"
+	kindHistogram = "histogram"
+)
+
+// Registry holds metrics and writes them in the Prometheus text exposition
+// format; it is an http.Handler which serves them (e.g. on `/metrics`)
+type Registry struct {
+	mu       sync.Mutex
+	families []*family
+	byName   map[string]*family
+}
+
+// family is a named metric and all of its labelled series
+type family struct {
+	name       string
+	help       string
+	kind       string
+	labelNames []string
+	// buckets are the upper bounds of a histogram's buckets, in increasing
+	// order and excluding +Inf
+	buckets []float64
+	series  map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	// value is the value of a counter or gauge
+	value float64
+	// counts are the number of observations in each of a histogram's buckets
+	// (not cumulative), followed by those above the last bucket
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func NewRegistry() *Registry {
+
+	return &Registry{
+		byName: make(map[string]*family),
+	}
+}
+
+// CounterVec is a counter with labels
+type CounterVec struct {
+	r *Registry
+	f *family
+}
+
+// GaugeVec is a gauge with labels
+type GaugeVec struct {
+	r *Registry
+	f *family
+}
+
+// HistogramVec is a histogram with labels
+type HistogramVec struct {
+	r *Registry
+	f *family
+}
+
+// NewCounter registers a counter; it panics if name is already registered
+func (r *Registry) NewCounter(name, help string, labelNames ...string) *CounterVec {
+
+	return &CounterVec{r: r, f: r.register(name, help, kindCounter, labelNames, nil)}
+}
+
+// NewGauge registers a gauge; it panics if name is already registered
+func (r *Registry) NewGauge(name, help string, labelNames ...string) *GaugeVec {
+
+	return &GaugeVec{r: r, f: r.register(name, help, kindGauge, labelNames, nil)}
+}
+
+// NewHistogram registers a histogram with the given bucket upper bounds; it
+// panics if name is already registered
+func (r *Registry) NewHistogram(
+	name string,
+	help string,
+	buckets []float64,
+	labelNames ...string,
+) *HistogramVec {
+
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	if len(b) > 0 && math.IsInf(b[len(b)-1], 1) {
+		b = b[:len(b)-1]
+	}
+	return &HistogramVec{r: r, f: r.register(name, help, kindHistogram, labelNames, b)}
+}
+
+func (r *Registry) register(
+	name string,
+	help string,
+	kind string,
+	labelNames []string,
+	buckets []float64,
+) *family {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.byName[name]; ok {
+		panic(fmt.Sprintf("metrics: %s is already registered", name))
+	}
+	f := &family{
+		name:       name,
+		help:       help,
+		kind:       kind,
+		labelNames: labelNames,
+		buckets:    buckets,
+		series:     make(map[string]*series),
+	}
+	r.families = append(r.families, f)
+	r.byName[name] = f
+	return f
+}
+
+// Add adds delta, which must not be negative, to the counter with the given
+// label values
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+
+	if delta < 0 {
+		panic(fmt.Sprintf("metrics: counter %s decreased", c.f.name))
+	}
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+
+	c.f.get(labelValues).value += delta
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+
+	c.Add(1, labelValues...)
+}
+
+// Set sets the gauge with the given label values
+func (g *GaugeVec) Set(v float64, labelValues ...string) {
+
+	g.r.mu.Lock()
+	defer g.r.mu.Unlock()
+
+	g.f.get(labelValues).value = v
+}
+
+// Observe adds an observation to the histogram with the given label values
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+
+	h.r.mu.Lock()
+	defer h.r.mu.Unlock()
+
+	s := h.f.get(labelValues)
+	i := sort.SearchFloat64s(h.f.buckets, v)
+	s.counts[i]++
+	s.sum += v
+	s.count++
+}
+
+// get returns the series with the given label values, creating it if
+// required; the registry must be locked
+func (f *family) get(labelValues []string) *series {
+
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf(
+			"metrics: %s has %d labels; got %d values",
+			f.name,
+			len(f.labelNames),
+			len(labelValues),
+		))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{
+			labelValues: append([]string(nil), labelValues...),
+		}
+		if f.kind == kindHistogram {
+			s.counts = make([]uint64, len(f.buckets)+1)
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// WriteText writes all of the metrics in the Prometheus text exposition
+// format, with the series of each metric ordered by their label values
+func (r *Registry) WriteText(w io.Writer) error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, f := range r.families {
+		if len(f.series) == 0 {
+			continue
+		}
+		fmt.Fprintf(bw, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.name, f.kind)
+
+		keys := make([]string, 0, len(f.series))
+		for k := range f.series {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			f.write(bw, f.series[k])
+		}
+	}
+	return bw.Flush()
+}
+
+func (f *family) write(w io.Writer, s *series) {
+
+	if f.kind != kindHistogram {
+		fmt.Fprintf(w, "%s%s %s\n", f.name, f.labels(s, ""), formatFloat(s.value))
+		return
+	}
+
+	var cumulative uint64
+	for i, upper := range f.buckets {
+		cumulative += s.counts[i]
+		le := formatFloat(upper)
+		fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, f.labels(s, le), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, f.labels(s, "+Inf"), s.count)
+	fmt.Fprintf(w, "%s_sum%s %s\n", f.name, f.labels(s, ""), formatFloat(s.sum))
+	fmt.Fprintf(w, "%s_count%s %d\n", f.name, f.labels(s, ""), s.count)
+}
+
+// labels returns the label set of s, including the `le` label of a
+// histogram bucket if le is not empty
+func (f *family) labels(s *series, le string) string {
+
+	pairs := make([]string, 0, len(f.labelNames)+1)
+	for i, name := range f.labelNames {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", name, escapeLabelValue(s.labelValues[i])))
+	}
+	if le != "" {
+		pairs = append(pairs, fmt.Sprintf("le=\"%s\"", le))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+
+	return labelValueEscaper.Replace(s)
+}
+
+// ServeHTTP writes the metrics in the text exposition format
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	// An error writing the response can only be reported by the response
+	// being truncated, as it has already been started
+	r.WriteText(w)
+}
@@ -0,0 +1,83 @@
+package metrics_test
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thecodedproject/crypto/exchangesdk/metrics"
+)
+
+func TestWriteTextUsesExpositionFormat(t *testing.T) {
+
+	r := metrics.NewRegistry()
+	counter := r.NewCounter("requests_total", "Requests made.", "method")
+	gauge := r.NewGauge("temperature", "Line one\nline two.")
+	histogram := r.NewHistogram("latency_seconds", "Latency.", []float64{1, 0.1}, "host")
+	r.NewCounter("unused_total", "Never incremented.")
+
+	counter.Inc("GET")
+	counter.Add(2, "POST")
+	counter.Inc(`say "hi"`)
+	gauge.Set(-1.5)
+	histogram.Observe(0.05, "a")
+	histogram.Observe(0.1, "a")
+	histogram.Observe(0.5, "a")
+	histogram.Observe(5, "a")
+
+	var b bytes.Buffer
+	require.NoError(t, r.WriteText(&b))
+
+	expected := `# HELP requests_total Requests made.
+# TYPE requests_total counter
+requests_total{method="GET"} 1
+requests_total{method="POST"} 2
+requests_total{method="say \"hi\""} 1
+# HELP temperature Line one\nline two.
+# TYPE temperature gauge
+temperature -1.5
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{host="a",le="0.1"} 2
+latency_seconds_bucket{host="a",le="1"} 3
+latency_seconds_bucket{host="a",le="+Inf"} 4
+latency_seconds_sum{host="a"} 5.65
+latency_seconds_count{host="a"} 4
+`
+	assert.Equal(t, expected, b.String())
+}
+
+func TestRegisterDuplicateNamePanics(t *testing.T) {
+
+	r := metrics.NewRegistry()
+	r.NewCounter("requests_total", "Requests made.")
+
+	assert.Panics(t, func() {
+		r.NewGauge("requests_total", "Requests made.")
+	})
+}
+
+func TestWrongNumberOfLabelValuesPanics(t *testing.T) {
+
+	r := metrics.NewRegistry()
+	counter := r.NewCounter("requests_total", "Requests made.", "method", "class")
+
+	assert.Panics(t, func() {
+		counter.Inc("GET")
+	})
+}
+
+func TestServeHTTPWritesMetrics(t *testing.T) {
+
+	r := metrics.NewRegistry()
+	r.NewGauge("temperature", "Temperature.").Set(20)
+
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, 200, res.Code)
+	assert.Contains(t, res.Header().Get("Content-Type"), "text/plain; version=0.0.4")
+	assert.Contains(t, res.Body.String(), "temperature 20\n")
+}
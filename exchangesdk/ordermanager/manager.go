@@ -0,0 +1,708 @@
+// Package ordermanager follows the lifecycle of orders placed on any
+// exchangesdk.Client, from placement through AwaitingTrigger, InOrderBook
+// (and partial fills) to Filled or Cancelled, so that strategy code does not
+// have to track each order's state by hand.
+package ordermanager
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/logging"
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+const (
+	defaultPollInterval      = 5 * time.Second
+	defaultReconcileInterval = time.Minute
+	defaultRetention         = time.Hour
+
+	// maxUnmatchedUpdates is the number of streamed updates for unknown
+	// orders which are kept, in case they are for an order which is still
+	// being placed
+	maxUnmatchedUpdates = 100
+)
+
+type options struct {
+	store             Store
+	pollInterval      time.Duration
+	reconcileInterval time.Duration
+	retention         time.Duration
+	callbacks         []func(Transition)
+	logger            logging.Logger
+	clock             utiltime.Clock
+}
+
+type Option func(*options)
+
+// WithStore sets the store in which orders are persisted; by default orders
+// are kept in memory only
+func WithStore(s Store) Option {
+
+	return func(o *options) {
+		o.store = s
+	}
+}
+
+// WithPollInterval sets how often GetOrderStatus is polled for open orders
+// when the client does not stream order updates
+func WithPollInterval(d time.Duration) Option {
+
+	return func(o *options) {
+		o.pollInterval = d
+	}
+}
+
+// WithReconcileInterval sets how often open orders are reconciled with
+// GetOrderStatus when the client streams order updates, to catch any updates
+// missed while the stream was reconnecting
+func WithReconcileInterval(d time.Duration) Option {
+
+	return func(o *options) {
+		o.reconcileInterval = d
+	}
+}
+
+// WithRetention sets how long filled and cancelled orders are kept (e.g. to
+// be returned by Order) before they are removed from the manager and its
+// store; by default they are kept for an hour.
+// The entry of a bracket order is kept until its exit has been placed.
+func WithRetention(d time.Duration) Option {
+
+	return func(o *options) {
+		o.retention = d
+	}
+}
+
+// WithLogger sets the logger which errors while following orders are
+// reported to; by default logging.Std is used
+func WithLogger(l logging.Logger) Option {
+
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+// WithClock sets the clock which times polling and reconciliation, timestamps
+// orders and decides when finished orders are no longer retained (e.g. a
+// simulated clock, so that the manager can be driven by historical data); by
+// default the real clock is used
+func WithClock(clock utiltime.Clock) Option {
+
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// OnTransition registers a callback which is called with each change to an
+// order. Callbacks are called in order for each order, and may call the
+// Manager.
+// Orders placed by the manager itself (e.g. the exit of a bracket order) are
+// notified as any other.
+func OnTransition(f func(Transition)) Option {
+
+	return func(o *options) {
+		o.callbacks = append(o.callbacks, f)
+	}
+}
+
+// Manager places orders on a client and follows them until they are filled
+// or cancelled.
+// OCO and bracket orders are placed natively on clients which implement
+// exchangesdk.OCOClient, and emulated by the manager otherwise.
+// Orders are followed by Run, which subscribes to the client's order updates
+// if it implements exchangesdk.OrderUpdatesClient, and polls GetOrderStatus
+// otherwise.
+type Manager struct {
+	client exchangesdk.Client
+	opts   options
+
+	notifyMu  sync.Mutex
+	pending   []Transition
+	notifying bool
+
+	// emulateOCO is set if the client does not place OCO orders natively
+	emulateOCO bool
+
+	mu        sync.Mutex
+	orders    map[string]Order
+	unmatched []exchangesdk.OrderUpdate
+	// cancelling is the set of OCO legs being cancelled by the manager
+	cancelling map[string]bool
+	// finished are the orders which have been filled or cancelled, in the
+	// order in which they finished (or last changed since), from which they
+	// are evicted once no longer retained; finishedAt is the time of the
+	// latest entry of each
+	finished   []finishedOrder
+	finishedAt map[string]time.Time
+	// changes are the changes to orders which are yet to be written to the
+	// store, in the order in which they were made
+	changes []storeChange
+
+	// storeMu serialises writes to the store, which are made without holding
+	// mu so that the store's I/O does not block the manager
+	storeMu sync.Mutex
+}
+
+type finishedOrder struct {
+	id string
+	at time.Time
+}
+
+type storeChange struct {
+	order Order
+	// deleteId is set if the order with that id is to be deleted instead
+	deleteId string
+}
+
+// New returns a Manager for orders placed on client, loading any orders
+// persisted in its store and reconciling those which are open with the
+// exchange
+func New(
+	ctx context.Context,
+	client exchangesdk.Client,
+	opts ...Option,
+) (*Manager, error) {
+
+	o := options{
+		store:             NewMemoryStore(),
+		pollInterval:      defaultPollInterval,
+		reconcileInterval: defaultReconcileInterval,
+		retention:         defaultRetention,
+		logger:            logging.Std,
+		clock:             utiltime.Real,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	orders, err := o.store.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	_, nativeOCO := client.(exchangesdk.OCOClient)
+
+	m := &Manager{
+		client:     client,
+		opts:       o,
+		emulateOCO: !nativeOCO,
+		orders:     make(map[string]Order),
+		cancelling: make(map[string]bool),
+		finishedAt: make(map[string]time.Time),
+	}
+	for _, order := range orders {
+		m.orders[order.Id] = order
+		if !order.IsOpen() {
+			m.finished = append(m.finished, finishedOrder{id: order.Id, at: order.Updated})
+			m.finishedAt[order.Id] = order.Updated
+		}
+	}
+	sort.Slice(m.finished, func(i, j int) bool {
+		return m.finished[i].at.Before(m.finished[j].at)
+	})
+
+	err = m.Reconcile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Finish any OCO cancels or bracket exits which were interrupted by
+	// the restart
+	for _, order := range orders {
+		current, ok := m.Order(order.Id)
+		if ok {
+			m.react(ctx, Transition{Previous: current, Order: current})
+		}
+	}
+
+	m.mu.Lock()
+	m.evictLocked(m.opts.clock.Now())
+	m.mu.Unlock()
+
+	err = m.persist()
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// PostLimitOrder places a limit order and starts following it
+func (m *Manager) PostLimitOrder(
+	ctx context.Context,
+	order exchangesdk.Order,
+) (string, error) {
+
+	id, err := m.client.PostLimitOrder(ctx, order)
+	if err != nil {
+		return "", err
+	}
+
+	return id, m.follow(ctx, limitOrder(id, order))
+}
+
+// PostStopLimitOrder places a stop limit order and starts following it
+func (m *Manager) PostStopLimitOrder(
+	ctx context.Context,
+	order exchangesdk.StopLimitOrder,
+) (string, error) {
+
+	id, err := m.client.PostStopLimitOrder(ctx, order)
+	if err != nil {
+		return "", err
+	}
+
+	return id, m.follow(ctx, Order{
+		Id:         id,
+		Side:       order.Side,
+		LimitPrice: order.LimitPrice,
+		StopPrice:  order.StopPrice,
+		Volume:     order.Volume,
+		State:      exchangesdk.OrderStateAwaitingTrigger,
+	})
+}
+
+// CancelOrder cancels an order; the order is reported as cancelled once the
+// exchange confirms it (unless it was filled first)
+func (m *Manager) CancelOrder(ctx context.Context, orderId string) error {
+
+	err := m.checkFollowed(orderId)
+	if err != nil {
+		return err
+	}
+
+	return m.client.CancelOrder(ctx, orderId)
+}
+
+// ReplaceOrder replaces an order with a new limit order, as
+// exchangesdk.ReplaceOrder, and starts following the new order.
+// The original order is updated with its final status once it is out of the
+// order book (i.e. cancelled, or filled before the cancel).
+func (m *Manager) ReplaceOrder(
+	ctx context.Context,
+	orderId string,
+	order exchangesdk.Order,
+	opts ...exchangesdk.ReplaceOption,
+) (exchangesdk.ReplaceResult, error) {
+
+	err := m.checkFollowed(orderId)
+	if err != nil {
+		return exchangesdk.ReplaceResult{}, err
+	}
+
+	// The cancel is confirmed by the manager's clock, unless opts say otherwise
+	opts = append([]exchangesdk.ReplaceOption{exchangesdk.WithClock(m.opts.clock)}, opts...)
+	res, replaceErr := exchangesdk.ReplaceOrder(ctx, m.client, orderId, order, opts...)
+
+	if res.Cancelled || res.Original.State == exchangesdk.OrderStateFilled {
+		err = m.update(ctx, orderId, func(o Order) (Order, bool) {
+			return o.apply(
+				res.Original.State,
+				res.Original.FillAmountBase,
+				res.Original.FillAmountCounter,
+				m.opts.clock.Now(),
+			)
+		})
+		if err != nil && replaceErr == nil {
+			replaceErr = err
+		}
+	}
+
+	if res.NewOrderId != "" {
+		err = m.follow(ctx, limitOrder(res.NewOrderId, order))
+		if err != nil && replaceErr == nil {
+			replaceErr = err
+		}
+	}
+
+	return res, replaceErr
+}
+
+func (m *Manager) checkFollowed(orderId string) error {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.orders[orderId]
+	if !ok {
+		return fmt.Errorf("order `%s` is not followed by the order manager", orderId)
+	}
+	return nil
+}
+
+// Order returns the current state of an order placed through the manager
+func (m *Manager) Order(orderId string) (Order, bool) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	o, ok := m.orders[orderId]
+	return o, ok
+}
+
+// OpenOrders returns the orders which are not yet filled or cancelled, oldest
+// first
+func (m *Manager) OpenOrders() []Order {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var open []Order
+	for _, o := range m.orders {
+		if o.IsOpen() {
+			open = append(open, o)
+		}
+	}
+	sortOrders(open)
+	return open
+}
+
+// Reconcile updates each open order with its status from the exchange,
+// returning the first error from GetOrderStatus or the store
+func (m *Manager) Reconcile(ctx context.Context) error {
+
+	var firstErr error
+	for _, o := range m.OpenOrders() {
+		status, err := m.client.GetOrderStatus(ctx, o.Id)
+		if err == nil {
+			err = m.update(ctx, o.Id, func(o Order) (Order, bool) {
+				return o.apply(
+					status.State,
+					status.FillAmountBase,
+					status.FillAmountCounter,
+					m.opts.clock.Now(),
+				)
+			})
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Run follows the open orders until ctx is cancelled, returning ctx.Err().
+// Errors while following orders are logged, and following continues.
+func (m *Manager) Run(ctx context.Context) error {
+
+	if c, ok := m.client.(exchangesdk.OrderUpdatesClient); ok {
+		updates, err := c.OrderUpdates(ctx)
+		if err == nil {
+			return m.followUpdates(ctx, updates)
+		}
+		m.logError("Order manager cannot stream order updates; polling instead", err)
+	}
+
+	return m.poll(ctx)
+}
+
+func (m *Manager) followUpdates(
+	ctx context.Context,
+	updates <-chan exchangesdk.OrderUpdate,
+) error {
+
+	reconcile := m.opts.clock.NewTicker(m.opts.reconcileInterval)
+	defer reconcile.Stop()
+
+	for {
+		select {
+		case u, ok := <-updates:
+			if !ok {
+				// The stream is only closed once ctx is cancelled
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			err := m.applyUpdate(ctx, u)
+			if err != nil {
+				m.logError("Order manager cannot apply order update", err, logging.KeyOrderId, u.OrderId)
+			}
+		case <-reconcile.C():
+			err := m.Reconcile(ctx)
+			if err != nil && ctx.Err() == nil {
+				m.logError("Order manager cannot reconcile orders", err)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// logError logs err with the exchange of the manager's client and any further
+// fields of args
+func (m *Manager) logError(msg string, err error, args ...interface{}) {
+
+	exchange := m.client.Exchange()
+	fields := []interface{}{
+		logging.KeyProvider, exchange.Provider,
+		logging.KeyPair, exchange.Pair,
+	}
+	fields = append(fields, args...)
+	fields = append(fields, logging.KeyError, err)
+	m.opts.logger.Error(msg, fields...)
+}
+
+func (m *Manager) poll(ctx context.Context) error {
+
+	ticker := m.opts.clock.NewTicker(m.opts.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			err := m.Reconcile(ctx)
+			if err != nil && ctx.Err() == nil {
+				m.logError("Order manager cannot reconcile orders", err)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// follow starts following a newly placed order, applying any updates which
+// were streamed for it before it was placed
+func (m *Manager) follow(ctx context.Context, o Order) error {
+
+	now := m.opts.clock.Now()
+	o.Placed = now
+	o.Updated = now
+
+	m.mu.Lock()
+
+	m.orders[o.Id] = o
+	transitions := []Transition{{Order: o}}
+
+	var unmatched []exchangesdk.OrderUpdate
+	for _, u := range m.unmatched {
+		if u.OrderId != o.Id {
+			unmatched = append(unmatched, u)
+			continue
+		}
+		if t, ok := m.applyLocked(u); ok {
+			transitions = append(transitions, t)
+		}
+	}
+	m.unmatched = unmatched
+	m.changedLocked(m.orders[o.Id])
+	m.mu.Unlock()
+
+	err := m.persist()
+
+	m.notify(ctx, transitions)
+	return err
+}
+
+// applyUpdate applies a streamed update, keeping it for later if it is for
+// an order which is not (yet) followed
+func (m *Manager) applyUpdate(
+	ctx context.Context,
+	u exchangesdk.OrderUpdate,
+) error {
+
+	m.mu.Lock()
+
+	if _, ok := m.orders[u.OrderId]; !ok {
+		m.unmatched = append(m.unmatched, u)
+		if len(m.unmatched) > maxUnmatchedUpdates {
+			m.unmatched = m.unmatched[1:]
+		}
+		m.mu.Unlock()
+		return nil
+	}
+
+	t, ok := m.applyLocked(u)
+	if !ok {
+		m.mu.Unlock()
+		return nil
+	}
+	m.changedLocked(t.Order)
+	m.mu.Unlock()
+
+	err := m.persist()
+
+	m.notify(ctx, []Transition{t})
+	return err
+}
+
+func (m *Manager) applyLocked(u exchangesdk.OrderUpdate) (Transition, bool) {
+
+	prev := m.orders[u.OrderId]
+	timestamp := u.Timestamp
+	if timestamp.IsZero() {
+		timestamp = m.opts.clock.Now()
+	}
+
+	next, changed := prev.apply(
+		u.State,
+		u.FillAmountBase,
+		u.FillAmountCounter,
+		timestamp,
+	)
+	if !changed {
+		return Transition{}, false
+	}
+
+	m.orders[u.OrderId] = next
+	return Transition{Previous: prev, Order: next}, true
+}
+
+// update applies f to a followed order, persisting and notifying any change
+func (m *Manager) update(
+	ctx context.Context,
+	orderId string,
+	f func(Order) (Order, bool),
+) error {
+
+	m.mu.Lock()
+
+	prev, ok := m.orders[orderId]
+	if !ok {
+		m.mu.Unlock()
+		return nil
+	}
+
+	next, changed := f(prev)
+	if !changed {
+		m.mu.Unlock()
+		return nil
+	}
+
+	m.orders[orderId] = next
+	m.changedLocked(next)
+	m.mu.Unlock()
+
+	err := m.persist()
+
+	m.notify(ctx, []Transition{{Previous: prev, Order: next}})
+	return err
+}
+
+// changedLocked queues a change to o to be written to the store, and evicts
+// any finished orders which are no longer retained; mu must be held
+func (m *Manager) changedLocked(o Order) {
+
+	now := m.opts.clock.Now()
+	m.changes = append(m.changes, storeChange{order: o})
+	if !o.IsOpen() {
+		m.finished = append(m.finished, finishedOrder{id: o.Id, at: now})
+		m.finishedAt[o.Id] = now
+	}
+	m.evictLocked(now)
+}
+
+// evictLocked removes the orders which finished longer than the retention
+// before now from the manager, and queues their deletion from the store; mu
+// must be held
+func (m *Manager) evictLocked(now time.Time) {
+
+	for len(m.finished) > 0 && !m.finished[0].at.Add(m.opts.retention).After(now) {
+		f := m.finished[0]
+		m.finished = m.finished[1:]
+
+		if !m.finishedAt[f.id].Equal(f.at) {
+			// The order has changed since, so is retained until a later
+			// entry expires
+			continue
+		}
+		delete(m.finishedAt, f.id)
+
+		o, ok := m.orders[f.id]
+		if !ok || o.awaitingBracketExit() {
+			// The entry of a bracket order is queued again once its exit
+			// has been recorded
+			continue
+		}
+		delete(m.orders, f.id)
+		delete(m.cancelling, f.id)
+		m.changes = append(m.changes, storeChange{deleteId: f.id})
+	}
+}
+
+// persist writes the queued changes to the store in the order in which they
+// were made, returning the first error
+func (m *Manager) persist() error {
+
+	m.storeMu.Lock()
+	defer m.storeMu.Unlock()
+
+	m.mu.Lock()
+	changes := m.changes
+	m.changes = nil
+	m.mu.Unlock()
+
+	var firstErr error
+	for _, c := range changes {
+		var err error
+		if c.deleteId != "" {
+			err = m.opts.store.Delete(c.deleteId)
+		} else {
+			err = m.opts.store.Put(c.order)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// notify queues transitions for the callbacks. The first caller delivers the
+// queue (including transitions queued by the callbacks themselves, or by
+// other goroutines meanwhile), so that callbacks are never called
+// concurrently and may call the Manager.
+func (m *Manager) notify(ctx context.Context, transitions []Transition) {
+
+	m.notifyMu.Lock()
+	m.pending = append(m.pending, transitions...)
+	if m.notifying {
+		m.notifyMu.Unlock()
+		return
+	}
+	m.notifying = true
+
+	for len(m.pending) > 0 {
+		t := m.pending[0]
+		m.pending = m.pending[1:]
+		m.notifyMu.Unlock()
+
+		m.react(ctx, t)
+		for _, f := range m.opts.callbacks {
+			f(t)
+		}
+
+		m.notifyMu.Lock()
+	}
+
+	m.notifying = false
+	m.notifyMu.Unlock()
+}
+
+func limitOrder(id string, order exchangesdk.Order) Order {
+
+	side := exchangesdk.OrderBookSideBid
+	if order.Type == exchangesdk.OrderTypeAsk {
+		side = exchangesdk.OrderBookSideAsk
+	}
+
+	return Order{
+		Id:         id,
+		Side:       side,
+		LimitPrice: order.Price,
+		Volume:     order.Volume,
+		State:      exchangesdk.OrderStateInOrderBook,
+	}
+}
+
+func sortOrders(orders []Order) {
+
+	sort.Slice(orders, func(i, j int) bool {
+		if !orders[i].Placed.Equal(orders[j].Placed) {
+			return orders[i].Placed.Before(orders[j].Placed)
+		}
+		return orders[i].Id < orders[j].Id
+	})
+}
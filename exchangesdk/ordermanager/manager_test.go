@@ -0,0 +1,580 @@
+package ordermanager_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/mockery"
+	"github.com/thecodedproject/crypto/exchangesdk/ordermanager"
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+type transitionRecorder struct {
+	mu          sync.Mutex
+	transitions []ordermanager.Transition
+}
+
+func (r *transitionRecorder) record(t ordermanager.Transition) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.transitions = append(r.transitions, t)
+}
+
+func (r *transitionRecorder) states() []exchangesdk.OrderState {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var states []exchangesdk.OrderState
+	for _, t := range r.transitions {
+		states = append(states, t.Order.State)
+	}
+	return states
+}
+
+func status(
+	state exchangesdk.OrderState,
+	fillBase string,
+	fillCounter string,
+) exchangesdk.OrderStatus {
+
+	return exchangesdk.OrderStatus{
+		State:             state,
+		FillAmountBase:    decimal.RequireFromString(fillBase),
+		FillAmountCounter: decimal.RequireFromString(fillCounter),
+	}
+}
+
+func TestLimitOrderLifecycle(t *testing.T) {
+
+	now := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	defer utiltime.SetTimeNowForTesting(t, now)()
+
+	ctx := context.Background()
+	order := exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeBid,
+		Price:  decimal.NewFromInt(100),
+		Volume: decimal.NewFromInt(2),
+	}
+
+	client := new(mockery.Client).TSetup(t)
+	client.On("PostLimitOrder", mock.Anything, order).Return("order1", nil).Once()
+
+	var r transitionRecorder
+	m, err := ordermanager.New(ctx, client, ordermanager.OnTransition(r.record))
+	require.NoError(t, err)
+
+	id, err := m.PostLimitOrder(ctx, order)
+	require.NoError(t, err)
+	assert.Equal(t, "order1", id)
+
+	client.On("GetOrderStatus", mock.Anything, "order1").
+		Return(status(exchangesdk.OrderStateInOrderBook, "0", "0"), nil).Once()
+	require.NoError(t, m.Reconcile(ctx))
+
+	client.On("GetOrderStatus", mock.Anything, "order1").
+		Return(status(exchangesdk.OrderStateInOrderBook, "0.5", "50"), nil).Once()
+	require.NoError(t, m.Reconcile(ctx))
+
+	o, ok := m.Order("order1")
+	require.True(t, ok)
+	assert.True(t, o.PartiallyFilled())
+
+	client.On("GetOrderStatus", mock.Anything, "order1").
+		Return(status(exchangesdk.OrderStateFilled, "2", "200"), nil).Once()
+	require.NoError(t, m.Reconcile(ctx))
+
+	// Filled orders are no longer reconciled
+	require.NoError(t, m.Reconcile(ctx))
+
+	assert.Equal(t, []exchangesdk.OrderState{
+		exchangesdk.OrderStateInOrderBook,
+		exchangesdk.OrderStateInOrderBook,
+		exchangesdk.OrderStateFilled,
+	}, r.states())
+
+	assert.Equal(t, ordermanager.Order{
+		Id:                "order1",
+		Side:              exchangesdk.OrderBookSideBid,
+		LimitPrice:        decimal.NewFromInt(100),
+		Volume:            decimal.NewFromInt(2),
+		State:             exchangesdk.OrderStateFilled,
+		FillAmountBase:    decimal.RequireFromString("2"),
+		FillAmountCounter: decimal.RequireFromString("200"),
+		Placed:            now,
+		Updated:           now,
+	}, r.transitions[2].Order)
+	assert.True(t, r.transitions[2].Previous.PartiallyFilled())
+	assert.Empty(t, m.OpenOrders())
+}
+
+func TestStopLimitOrderTriggeredAndCancelled(t *testing.T) {
+
+	ctx := context.Background()
+	order := exchangesdk.StopLimitOrder{
+		Side:       exchangesdk.OrderBookSideAsk,
+		StopPrice:  decimal.NewFromInt(95),
+		LimitPrice: decimal.NewFromInt(94),
+		Volume:     decimal.NewFromInt(1),
+	}
+
+	client := new(mockery.Client).TSetup(t)
+	client.On("PostStopLimitOrder", mock.Anything, order).Return("stop1", nil).Once()
+	client.On("CancelOrder", mock.Anything, "stop1").Return(nil).Once()
+
+	var r transitionRecorder
+	m, err := ordermanager.New(ctx, client, ordermanager.OnTransition(r.record))
+	require.NoError(t, err)
+
+	_, err = m.PostStopLimitOrder(ctx, order)
+	require.NoError(t, err)
+
+	client.On("GetOrderStatus", mock.Anything, "stop1").
+		Return(status(exchangesdk.OrderStateInOrderBook, "0", "0"), nil).Once()
+	require.NoError(t, m.Reconcile(ctx))
+
+	require.NoError(t, m.CancelOrder(ctx, "stop1"))
+
+	client.On("GetOrderStatus", mock.Anything, "stop1").
+		Return(status(exchangesdk.OrderStateCancelled, "0", "0"), nil).Once()
+	require.NoError(t, m.Reconcile(ctx))
+
+	assert.Equal(t, []exchangesdk.OrderState{
+		exchangesdk.OrderStateAwaitingTrigger,
+		exchangesdk.OrderStateInOrderBook,
+		exchangesdk.OrderStateCancelled,
+	}, r.states())
+}
+
+func TestCancelUnknownOrderReturnsError(t *testing.T) {
+
+	client := new(mockery.Client).TSetup(t)
+	m, err := ordermanager.New(context.Background(), client)
+	require.NoError(t, err)
+
+	err = m.CancelOrder(context.Background(), "unknown")
+	assert.Error(t, err)
+}
+
+func TestReplaceOrderFollowsReplacement(t *testing.T) {
+
+	ctx := context.Background()
+	original := exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeAsk,
+		Price:  decimal.NewFromInt(101),
+		Volume: decimal.NewFromInt(1),
+	}
+	replacement := exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeAsk,
+		Price:  decimal.NewFromInt(102),
+		Volume: decimal.NewFromInt(1),
+	}
+
+	client := new(mockery.Client).TSetup(t)
+	client.On("PostLimitOrder", mock.Anything, original).Return("order1", nil).Once()
+	client.On("CancelOrder", mock.Anything, "order1").Return(nil).Once()
+	client.On("GetOrderStatus", mock.Anything, "order1").
+		Return(status(exchangesdk.OrderStateCancelled, "0.25", "25.25"), nil).Once()
+	client.On("PostLimitOrder", mock.Anything, replacement).Return("order2", nil).Once()
+
+	var r transitionRecorder
+	m, err := ordermanager.New(ctx, client, ordermanager.OnTransition(r.record))
+	require.NoError(t, err)
+
+	_, err = m.PostLimitOrder(ctx, original)
+	require.NoError(t, err)
+
+	res, err := m.ReplaceOrder(ctx, "order1", replacement)
+	require.NoError(t, err)
+	assert.True(t, res.Cancelled)
+	assert.Equal(t, "order2", res.NewOrderId)
+
+	o, ok := m.Order("order1")
+	require.True(t, ok)
+	assert.Equal(t, exchangesdk.OrderStateCancelled, o.State)
+	assert.True(t, decimal.RequireFromString("0.25").Equal(o.FillAmountBase))
+
+	open := m.OpenOrders()
+	require.Len(t, open, 1)
+	assert.Equal(t, "order2", open[0].Id)
+	assert.Equal(t, exchangesdk.OrderBookSideAsk, open[0].Side)
+	assert.True(t, replacement.Price.Equal(open[0].LimitPrice))
+
+	assert.Equal(t, []exchangesdk.OrderState{
+		exchangesdk.OrderStateInOrderBook,
+		exchangesdk.OrderStateCancelled,
+		exchangesdk.OrderStateInOrderBook,
+	}, r.states())
+
+	_, err = m.ReplaceOrder(ctx, "unknown", replacement)
+	assert.Error(t, err)
+}
+
+func TestReplaceOrderFilledDuringCancelIsFilledAndNotReplaced(t *testing.T) {
+
+	ctx := context.Background()
+	client := new(mockery.Client).TSetup(t)
+	client.On("PostLimitOrder", mock.Anything, mock.Anything).Return("order1", nil).Once()
+	client.On("CancelOrder", mock.Anything, "order1").Return(nil).Once()
+	client.On("GetOrderStatus", mock.Anything, "order1").
+		Return(status(exchangesdk.OrderStateFilled, "1", "101"), nil).Once()
+
+	m, err := ordermanager.New(ctx, client)
+	require.NoError(t, err)
+
+	_, err = m.PostLimitOrder(ctx, exchangesdk.Order{})
+	require.NoError(t, err)
+
+	res, err := m.ReplaceOrder(ctx, "order1", exchangesdk.Order{})
+	require.NoError(t, err)
+	assert.False(t, res.Cancelled)
+	assert.Empty(t, res.NewOrderId)
+
+	o, ok := m.Order("order1")
+	require.True(t, ok)
+	assert.Equal(t, exchangesdk.OrderStateFilled, o.State)
+	assert.Empty(t, m.OpenOrders())
+}
+
+func TestStaleStatusIsIgnored(t *testing.T) {
+
+	ctx := context.Background()
+	client := new(mockery.Client).TSetup(t)
+	client.On("PostLimitOrder", mock.Anything, mock.Anything).Return("order1", nil).Once()
+
+	var r transitionRecorder
+	m, err := ordermanager.New(ctx, client, ordermanager.OnTransition(r.record))
+	require.NoError(t, err)
+
+	_, err = m.PostLimitOrder(ctx, exchangesdk.Order{})
+	require.NoError(t, err)
+
+	client.On("GetOrderStatus", mock.Anything, "order1").
+		Return(status(exchangesdk.OrderStateInOrderBook, "1", "100"), nil).Once()
+	require.NoError(t, m.Reconcile(ctx))
+
+	client.On("GetOrderStatus", mock.Anything, "order1").
+		Return(status(exchangesdk.OrderStateAwaitingTrigger, "0.5", "50"), nil).Once()
+	require.NoError(t, m.Reconcile(ctx))
+
+	o, ok := m.Order("order1")
+	require.True(t, ok)
+	assert.Equal(t, exchangesdk.OrderStateInOrderBook, o.State)
+	assert.True(t, decimal.NewFromInt(1).Equal(o.FillAmountBase))
+	assert.Len(t, r.states(), 2)
+}
+
+func TestNewReconcilesOrdersPersistedBeforeRestart(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "ordermanager")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	store := ordermanager.NewFileStore(filepath.Join(dir, "orders.json"))
+
+	client := new(mockery.Client).TSetup(t)
+	client.On("PostLimitOrder", mock.Anything, mock.Anything).Return("order1", nil).Once()
+	client.On("PostLimitOrder", mock.Anything, mock.Anything).Return("order2", nil).Once()
+
+	m, err := ordermanager.New(ctx, client, ordermanager.WithStore(store))
+	require.NoError(t, err)
+	_, err = m.PostLimitOrder(ctx, exchangesdk.Order{})
+	require.NoError(t, err)
+	_, err = m.PostLimitOrder(ctx, exchangesdk.Order{})
+	require.NoError(t, err)
+
+	// Restart while order1 is filled
+	client.On("GetOrderStatus", mock.Anything, "order1").
+		Return(status(exchangesdk.OrderStateFilled, "1", "100"), nil).Once()
+	client.On("GetOrderStatus", mock.Anything, "order2").
+		Return(status(exchangesdk.OrderStateInOrderBook, "0", "0"), nil).Once()
+
+	var r transitionRecorder
+	m, err = ordermanager.New(
+		ctx,
+		client,
+		ordermanager.WithStore(store),
+		ordermanager.OnTransition(r.record),
+	)
+	require.NoError(t, err)
+
+	require.Len(t, r.transitions, 1)
+	assert.Equal(t, "order1", r.transitions[0].Order.Id)
+	assert.Equal(t, exchangesdk.OrderStateInOrderBook, r.transitions[0].Previous.State)
+	assert.Equal(t, exchangesdk.OrderStateFilled, r.transitions[0].Order.State)
+
+	open := m.OpenOrders()
+	require.Len(t, open, 1)
+	assert.Equal(t, "order2", open[0].Id)
+}
+
+func TestFinishedOrdersAreRemovedOnceNotRetained(t *testing.T) {
+
+	ctx := context.Background()
+	store := ordermanager.NewMemoryStore()
+
+	client := new(mockery.Client).TSetup(t)
+	client.On("PostLimitOrder", mock.Anything, mock.Anything).Return("order1", nil).Once()
+	client.On("PostLimitOrder", mock.Anything, mock.Anything).Return("order2", nil).Once()
+
+	m, err := ordermanager.New(
+		ctx,
+		client,
+		ordermanager.WithStore(store),
+		ordermanager.WithRetention(0),
+	)
+	require.NoError(t, err)
+	_, err = m.PostLimitOrder(ctx, exchangesdk.Order{})
+	require.NoError(t, err)
+	_, err = m.PostLimitOrder(ctx, exchangesdk.Order{})
+	require.NoError(t, err)
+
+	client.On("GetOrderStatus", mock.Anything, "order1").
+		Return(status(exchangesdk.OrderStateFilled, "1", "100"), nil).Once()
+	client.On("GetOrderStatus", mock.Anything, "order2").
+		Return(status(exchangesdk.OrderStateInOrderBook, "0", "0"), nil).Once()
+	require.NoError(t, m.Reconcile(ctx))
+
+	_, ok := m.Order("order1")
+	assert.False(t, ok)
+	_, ok = m.Order("order2")
+	assert.True(t, ok)
+
+	orders, err := store.Load()
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	assert.Equal(t, "order2", orders[0].Id)
+}
+
+func TestNewReturnsErrorWhenReconcileFails(t *testing.T) {
+
+	ctx := context.Background()
+	store := ordermanager.NewMemoryStore()
+	require.NoError(t, store.Put(ordermanager.Order{
+		Id:    "order1",
+		State: exchangesdk.OrderStateInOrderBook,
+	}))
+
+	client := new(mockery.Client).TSetup(t)
+	client.On("GetOrderStatus", mock.Anything, "order1").
+		Return(exchangesdk.OrderStatus{}, assert.AnError).Once()
+
+	_, err := ordermanager.New(ctx, client, ordermanager.WithStore(store))
+	assert.Equal(t, assert.AnError, err)
+}
+
+func TestCallbacksMayPlaceOrders(t *testing.T) {
+
+	ctx := context.Background()
+	client := new(mockery.Client).TSetup(t)
+	client.On("PostLimitOrder", mock.Anything, mock.Anything).Return("order1", nil).Once()
+	client.On("PostLimitOrder", mock.Anything, mock.Anything).Return("order2", nil).Once()
+
+	var m *ordermanager.Manager
+	var r transitionRecorder
+	callback := func(tr ordermanager.Transition) {
+		r.record(tr)
+		if tr.Order.Id == "order1" && tr.Order.State == exchangesdk.OrderStateFilled {
+			_, err := m.PostLimitOrder(ctx, exchangesdk.Order{})
+			require.NoError(t, err)
+		}
+	}
+
+	m, err := ordermanager.New(ctx, client, ordermanager.OnTransition(callback))
+	require.NoError(t, err)
+
+	_, err = m.PostLimitOrder(ctx, exchangesdk.Order{})
+	require.NoError(t, err)
+
+	client.On("GetOrderStatus", mock.Anything, "order1").
+		Return(status(exchangesdk.OrderStateFilled, "1", "100"), nil).Once()
+	require.NoError(t, m.Reconcile(ctx))
+
+	_, ok := m.Order("order2")
+	assert.True(t, ok)
+	assert.Equal(t, []exchangesdk.OrderState{
+		exchangesdk.OrderStateInOrderBook,
+		exchangesdk.OrderStateFilled,
+		exchangesdk.OrderStateInOrderBook,
+	}, r.states())
+}
+
+type streamingClient struct {
+	*mockery.Client
+	updates chan exchangesdk.OrderUpdate
+}
+
+func (c streamingClient) OrderUpdates(
+	ctx context.Context,
+) (<-chan exchangesdk.OrderUpdate, error) {
+
+	return c.updates, nil
+}
+
+func TestRunFollowsStreamedUpdates(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := streamingClient{
+		Client:  new(mockery.Client).TSetup(t),
+		updates: make(chan exchangesdk.OrderUpdate),
+	}
+	client.On("PostLimitOrder", mock.Anything, mock.Anything).Return("order1", nil).Once()
+
+	var r transitionRecorder
+	m, err := ordermanager.New(
+		ctx,
+		client,
+		ordermanager.WithReconcileInterval(time.Hour),
+		ordermanager.OnTransition(r.record),
+	)
+	require.NoError(t, err)
+
+	done := make(chan error)
+	go func() {
+		done <- m.Run(ctx)
+	}()
+
+	// An update streamed before the order is followed is applied once it is
+	client.updates <- exchangesdk.OrderUpdate{
+		Type:              exchangesdk.OrderUpdateTypeFill,
+		OrderId:           "order1",
+		State:             exchangesdk.OrderStateInOrderBook,
+		FillAmountBase:    decimal.RequireFromString("0.5"),
+		FillAmountCounter: decimal.RequireFromString("50"),
+	}
+	// Updates for orders placed elsewhere are ignored
+	client.updates <- exchangesdk.OrderUpdate{
+		Type:    exchangesdk.OrderUpdateTypeStateChange,
+		OrderId: "other",
+		State:   exchangesdk.OrderStateCancelled,
+	}
+
+	_, err = m.PostLimitOrder(ctx, exchangesdk.Order{})
+	require.NoError(t, err)
+
+	client.updates <- exchangesdk.OrderUpdate{
+		Type:              exchangesdk.OrderUpdateTypeStateChange,
+		OrderId:           "order1",
+		State:             exchangesdk.OrderStateFilled,
+		FillAmountBase:    decimal.RequireFromString("1"),
+		FillAmountCounter: decimal.RequireFromString("100"),
+	}
+
+	require.Eventually(t, func() bool {
+		return len(r.states()) == 3
+	}, time.Second, time.Millisecond)
+
+	assert.Equal(t, []exchangesdk.OrderState{
+		exchangesdk.OrderStateInOrderBook,
+		exchangesdk.OrderStateInOrderBook,
+		exchangesdk.OrderStateFilled,
+	}, r.states())
+	_, ok := m.Order("other")
+	assert.False(t, ok)
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+}
+
+func TestRunPollsClientsWithoutOrderUpdates(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := new(mockery.Client).TSetup(t)
+	client.On("PostLimitOrder", mock.Anything, mock.Anything).Return("order1", nil).Once()
+	client.On("GetOrderStatus", mock.Anything, "order1").
+		Return(status(exchangesdk.OrderStateInOrderBook, "0", "0"), nil).Once()
+	client.On("GetOrderStatus", mock.Anything, "order1").
+		Return(status(exchangesdk.OrderStateFilled, "1", "100"), nil).Once()
+
+	var r transitionRecorder
+	m, err := ordermanager.New(
+		ctx,
+		client,
+		ordermanager.WithPollInterval(time.Millisecond),
+		ordermanager.OnTransition(r.record),
+	)
+	require.NoError(t, err)
+
+	_, err = m.PostLimitOrder(ctx, exchangesdk.Order{})
+	require.NoError(t, err)
+
+	done := make(chan error)
+	go func() {
+		done <- m.Run(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		return len(m.OpenOrders()) == 0
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+	assert.Equal(t, []exchangesdk.OrderState{
+		exchangesdk.OrderStateInOrderBook,
+		exchangesdk.OrderStateFilled,
+	}, r.states())
+}
+
+func TestRunPollsByClock(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	start := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := utiltime.NewSimulatedClock(start)
+
+	client := new(mockery.Client).TSetup(t)
+	client.On("PostLimitOrder", mock.Anything, mock.Anything).Return("order1", nil).Once()
+	client.On("GetOrderStatus", mock.Anything, "order1").
+		Return(status(exchangesdk.OrderStateFilled, "1", "100"), nil).Once()
+
+	m, err := ordermanager.New(
+		ctx,
+		client,
+		ordermanager.WithPollInterval(time.Minute),
+		ordermanager.WithClock(clock),
+	)
+	require.NoError(t, err)
+
+	id, err := m.PostLimitOrder(ctx, exchangesdk.Order{})
+	require.NoError(t, err)
+
+	done := make(chan error)
+	go func() {
+		done <- m.Run(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		return clock.Waiters() == 1
+	}, time.Second, time.Millisecond)
+	client.AssertNotCalled(t, "GetOrderStatus", mock.Anything, "order1")
+
+	clock.Advance(time.Minute)
+	require.Eventually(t, func() bool {
+		return len(m.OpenOrders()) == 0
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+
+	o, ok := m.Order(id)
+	require.True(t, ok)
+	assert.Equal(t, start, o.Placed)
+	assert.Equal(t, start.Add(time.Minute), o.Updated)
+}
@@ -0,0 +1,231 @@
+package ordermanager
+
+import (
+	"context"
+
+	"github.com/shopspring/decimal"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/logging"
+)
+
+// BracketOrder is an entry limit order with a take profit and a stop loss,
+// which are placed as an OCO order on the opposite side once it fills
+type BracketOrder struct {
+	Entry exchangesdk.Order
+
+	TakeProfitPrice decimal.Decimal
+	StopPrice       decimal.Decimal
+	StopLimitPrice  decimal.Decimal
+}
+
+// PostOCOOrder places an OCO order and starts following its legs.
+//
+// Where the client does not place OCO orders natively, the legs are placed
+// as a limit order and a stop limit order, and the manager cancels each leg
+// once the other is filled, triggered or cancelled. Emulated legs are only
+// cancelled while the manager is following orders (see Run), and both legs
+// may be (partly) filled if the market moves through both before the cancel.
+func (m *Manager) PostOCOOrder(
+	ctx context.Context,
+	o exchangesdk.OCOOrder,
+) (exchangesdk.OCOOrderIds, error) {
+
+	err := o.Validate()
+	if err != nil {
+		return exchangesdk.OCOOrderIds{}, err
+	}
+
+	var ids exchangesdk.OCOOrderIds
+	if c, ok := m.client.(exchangesdk.OCOClient); ok {
+		ids, err = c.PostOCOOrder(ctx, o)
+		if err != nil {
+			return exchangesdk.OCOOrderIds{}, err
+		}
+	} else {
+		ids, err = m.postEmulatedOCOOrder(ctx, o)
+		if err != nil {
+			return exchangesdk.OCOOrderIds{}, err
+		}
+	}
+
+	limitType := exchangesdk.OrderTypeBid
+	if o.Side == exchangesdk.OrderBookSideAsk {
+		limitType = exchangesdk.OrderTypeAsk
+	}
+
+	limitLeg := limitOrder(ids.LimitOrderId, exchangesdk.Order{
+		Type:   limitType,
+		Price:  o.LimitPrice,
+		Volume: o.Volume,
+	})
+	limitLeg.OCOLegId = ids.StopOrderId
+
+	stopLeg := Order{
+		Id:         ids.StopOrderId,
+		Side:       o.Side,
+		LimitPrice: o.StopLimitPrice,
+		StopPrice:  o.StopPrice,
+		Volume:     o.Volume,
+		State:      exchangesdk.OrderStateAwaitingTrigger,
+		OCOLegId:   ids.LimitOrderId,
+	}
+
+	err = m.follow(ctx, limitLeg)
+	if err != nil {
+		return ids, err
+	}
+	return ids, m.follow(ctx, stopLeg)
+}
+
+func (m *Manager) postEmulatedOCOOrder(
+	ctx context.Context,
+	o exchangesdk.OCOOrder,
+) (exchangesdk.OCOOrderIds, error) {
+
+	limitType := exchangesdk.OrderTypeBid
+	if o.Side == exchangesdk.OrderBookSideAsk {
+		limitType = exchangesdk.OrderTypeAsk
+	}
+
+	limitId, err := m.client.PostLimitOrder(ctx, exchangesdk.Order{
+		Type:   limitType,
+		Price:  o.LimitPrice,
+		Volume: o.Volume,
+	})
+	if err != nil {
+		return exchangesdk.OCOOrderIds{}, err
+	}
+
+	stopId, err := m.client.PostStopLimitOrder(ctx, exchangesdk.StopLimitOrder{
+		Side:       o.Side,
+		StopPrice:  o.StopPrice,
+		LimitPrice: o.StopLimitPrice,
+		Volume:     o.Volume,
+	})
+	if err != nil {
+		cancelErr := m.client.CancelOrder(ctx, limitId)
+		if cancelErr != nil {
+			m.logError("Order manager cannot cancel OCO limit leg after its stop leg failed", cancelErr, logging.KeyOrderId, limitId)
+		}
+		return exchangesdk.OCOOrderIds{}, err
+	}
+
+	return exchangesdk.OCOOrderIds{
+		LimitOrderId: limitId,
+		StopOrderId:  stopId,
+	}, nil
+}
+
+// PostBracketOrder places the entry of a bracket order and starts following
+// it; the exit is placed once the entry fills (see Bracket)
+func (m *Manager) PostBracketOrder(
+	ctx context.Context,
+	o BracketOrder,
+) (string, error) {
+
+	exit := exchangesdk.OCOOrder{
+		Side:           exitSide(o.Entry.Type),
+		Volume:         o.Entry.Volume,
+		LimitPrice:     o.TakeProfitPrice,
+		StopPrice:      o.StopPrice,
+		StopLimitPrice: o.StopLimitPrice,
+	}
+	err := exit.Validate()
+	if err != nil {
+		return "", err
+	}
+
+	id, err := m.client.PostLimitOrder(ctx, o.Entry)
+	if err != nil {
+		return "", err
+	}
+
+	entry := limitOrder(id, o.Entry)
+	entry.Bracket = &Bracket{
+		TakeProfitPrice: o.TakeProfitPrice,
+		StopPrice:       o.StopPrice,
+		StopLimitPrice:  o.StopLimitPrice,
+	}
+
+	return id, m.follow(ctx, entry)
+}
+
+// react cancels the other leg of an emulated OCO order and places the exit of
+// a bracket order once t requires it
+func (m *Manager) react(ctx context.Context, t Transition) {
+
+	o := t.Order
+
+	if m.emulateOCO && o.OCOLegId != "" && !o.untouched() {
+		m.cancelOCOLeg(ctx, o.OCOLegId)
+	}
+
+	if o.awaitingBracketExit() {
+		m.placeBracketExit(ctx, o)
+	}
+}
+
+func (m *Manager) cancelOCOLeg(ctx context.Context, orderId string) {
+
+	m.mu.Lock()
+	leg, ok := m.orders[orderId]
+	if !ok || !leg.IsOpen() || m.cancelling[orderId] {
+		m.mu.Unlock()
+		return
+	}
+	m.cancelling[orderId] = true
+	m.mu.Unlock()
+
+	err := m.client.CancelOrder(ctx, orderId)
+	if err != nil {
+		m.logError("Order manager cannot cancel OCO leg", err, logging.KeyOrderId, orderId)
+
+		// Allow the cancel to be retried on the next transition
+		m.mu.Lock()
+		delete(m.cancelling, orderId)
+		m.mu.Unlock()
+	}
+}
+
+func (m *Manager) placeBracketExit(ctx context.Context, entry Order) {
+
+	entryType := exchangesdk.OrderTypeBid
+	if entry.Side == exchangesdk.OrderBookSideAsk {
+		entryType = exchangesdk.OrderTypeAsk
+	}
+
+	ids, err := m.PostOCOOrder(ctx, exchangesdk.OCOOrder{
+		Side:           exitSide(entryType),
+		Volume:         entry.FillAmountBase,
+		LimitPrice:     entry.Bracket.TakeProfitPrice,
+		StopPrice:      entry.Bracket.StopPrice,
+		StopLimitPrice: entry.Bracket.StopLimitPrice,
+	})
+	if ids == (exchangesdk.OCOOrderIds{}) {
+		m.logError("Order manager cannot place exit of bracket order", err, logging.KeyOrderId, entry.Id)
+		return
+	}
+	if err != nil {
+		m.logError("Order manager placed exit of bracket order with errors", err, logging.KeyOrderId, entry.Id)
+	}
+
+	err = m.update(ctx, entry.Id, func(o Order) (Order, bool) {
+		bracket := *o.Bracket
+		bracket.Exit = &ids
+		o.Bracket = &bracket
+		return o, true
+	})
+	if err != nil {
+		m.logError("Order manager cannot record exit of bracket order", err, logging.KeyOrderId, entry.Id)
+	}
+}
+
+// exitSide returns the side of the order which exits a position entered by
+// an order of type entry
+func exitSide(entry exchangesdk.OrderType) exchangesdk.OrderBookSide {
+
+	if entry == exchangesdk.OrderTypeAsk {
+		return exchangesdk.OrderBookSideBid
+	}
+	return exchangesdk.OrderBookSideAsk
+}
@@ -0,0 +1,274 @@
+package ordermanager_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/dummyclient"
+	"github.com/thecodedproject/crypto/exchangesdk/mockery"
+	"github.com/thecodedproject/crypto/exchangesdk/ordermanager"
+)
+
+func D(f float64) decimal.Decimal {
+
+	return decimal.NewFromFloat(f)
+}
+
+func book(bid, ask float64) exchangesdk.OrderBook {
+
+	return exchangesdk.OrderBook{
+		Bids: []exchangesdk.OrderBookOrder{{Price: bid, Volume: 10}},
+		Asks: []exchangesdk.OrderBookOrder{{Price: ask, Volume: 10}},
+	}
+}
+
+func askOCO() exchangesdk.OCOOrder {
+
+	return exchangesdk.OCOOrder{
+		Side:           exchangesdk.OrderBookSideAsk,
+		Volume:         D(1),
+		LimitPrice:     D(110),
+		StopPrice:      D(95),
+		StopLimitPrice: D(94),
+	}
+}
+
+func TestEmulatedOCOCancelsStopWhenLimitFills(t *testing.T) {
+
+	ctx := context.Background()
+
+	client := new(mockery.Client).TSetup(t)
+	client.On("PostLimitOrder", mock.Anything, exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeAsk,
+		Price:  D(110),
+		Volume: D(1),
+	}).Return("limit1", nil).Once()
+	client.On("PostStopLimitOrder", mock.Anything, exchangesdk.StopLimitOrder{
+		Side:       exchangesdk.OrderBookSideAsk,
+		StopPrice:  D(95),
+		LimitPrice: D(94),
+		Volume:     D(1),
+	}).Return("stop1", nil).Once()
+
+	m, err := ordermanager.New(ctx, client)
+	require.NoError(t, err)
+
+	ids, err := m.PostOCOOrder(ctx, askOCO())
+	require.NoError(t, err)
+	assert.Equal(t, exchangesdk.OCOOrderIds{
+		LimitOrderId: "limit1",
+		StopOrderId:  "stop1",
+	}, ids)
+
+	// A partial fill of the limit leg cancels the stop leg (once)
+	client.On("GetOrderStatus", mock.Anything, "limit1").
+		Return(status(exchangesdk.OrderStateInOrderBook, "0.5", "55"), nil).Once()
+	client.On("GetOrderStatus", mock.Anything, "stop1").
+		Return(status(exchangesdk.OrderStateAwaitingTrigger, "0", "0"), nil).Once()
+	client.On("CancelOrder", mock.Anything, "stop1").Return(nil).Once()
+	require.NoError(t, m.Reconcile(ctx))
+
+	client.On("GetOrderStatus", mock.Anything, "limit1").
+		Return(status(exchangesdk.OrderStateFilled, "1", "110"), nil).Once()
+	client.On("GetOrderStatus", mock.Anything, "stop1").
+		Return(status(exchangesdk.OrderStateCancelled, "0", "0"), nil).Once()
+	require.NoError(t, m.Reconcile(ctx))
+
+	stop, ok := m.Order("stop1")
+	require.True(t, ok)
+	assert.Equal(t, exchangesdk.OrderStateCancelled, stop.State)
+	assert.Equal(t, "limit1", stop.OCOLegId)
+	assert.Empty(t, m.OpenOrders())
+}
+
+func TestEmulatedOCOCancelsLimitWhenStopLegFails(t *testing.T) {
+
+	ctx := context.Background()
+
+	client := new(mockery.Client).TSetup(t)
+	client.On("PostLimitOrder", mock.Anything, mock.Anything).Return("limit1", nil).Once()
+	client.On("PostStopLimitOrder", mock.Anything, mock.Anything).Return("", assert.AnError).Once()
+	client.On("CancelOrder", mock.Anything, "limit1").Return(nil).Once()
+
+	m, err := ordermanager.New(ctx, client)
+	require.NoError(t, err)
+
+	_, err = m.PostOCOOrder(ctx, askOCO())
+	assert.Equal(t, assert.AnError, err)
+	assert.Empty(t, m.OpenOrders())
+}
+
+func TestPostOCOOrderWithInvalidPricesReturnsError(t *testing.T) {
+
+	client := new(mockery.Client).TSetup(t)
+	m, err := ordermanager.New(context.Background(), client)
+	require.NoError(t, err)
+
+	o := askOCO()
+	o.Side = exchangesdk.OrderBookSideBid
+	_, err = m.PostOCOOrder(context.Background(), o)
+	assert.Error(t, err)
+}
+
+type ocoClient struct {
+	*mockery.Client
+}
+
+func (c ocoClient) PostOCOOrder(
+	ctx context.Context,
+	o exchangesdk.OCOOrder,
+) (exchangesdk.OCOOrderIds, error) {
+
+	args := c.Called(ctx, o)
+	return args.Get(0).(exchangesdk.OCOOrderIds), args.Error(1)
+}
+
+func TestNativeOCOIsNotCancelledByManager(t *testing.T) {
+
+	ctx := context.Background()
+	ids := exchangesdk.OCOOrderIds{
+		LimitOrderId: "limit1",
+		StopOrderId:  "stop1",
+	}
+
+	client := ocoClient{new(mockery.Client).TSetup(t)}
+	client.On("PostOCOOrder", mock.Anything, askOCO()).Return(ids, nil).Once()
+
+	m, err := ordermanager.New(ctx, client)
+	require.NoError(t, err)
+
+	res, err := m.PostOCOOrder(ctx, askOCO())
+	require.NoError(t, err)
+	assert.Equal(t, ids, res)
+
+	stop, ok := m.Order("stop1")
+	require.True(t, ok)
+	assert.Equal(t, exchangesdk.OrderStateAwaitingTrigger, stop.State)
+	assert.True(t, D(95).Equal(stop.StopPrice))
+	assert.True(t, D(94).Equal(stop.LimitPrice))
+
+	// The exchange cancels the limit leg itself
+	client.On("GetOrderStatus", mock.Anything, "limit1").
+		Return(status(exchangesdk.OrderStateInOrderBook, "0", "0"), nil).Once()
+	client.On("GetOrderStatus", mock.Anything, "stop1").
+		Return(status(exchangesdk.OrderStateFilled, "1", "94"), nil).Once()
+	require.NoError(t, m.Reconcile(ctx))
+}
+
+func TestBracketOrderPlacesExitOnceEntryFills(t *testing.T) {
+
+	ctx := context.Background()
+
+	sim, err := dummyclient.NewClient("", "", crypto.Exchange{})
+	require.NoError(t, err)
+	sim.UpdateOrderBook(book(99, 101))
+
+	var r transitionRecorder
+	m, err := ordermanager.New(ctx, sim, ordermanager.OnTransition(r.record))
+	require.NoError(t, err)
+
+	entryId, err := m.PostBracketOrder(ctx, ordermanager.BracketOrder{
+		Entry: exchangesdk.Order{
+			Type:   exchangesdk.OrderTypeBid,
+			Price:  D(100),
+			Volume: D(1),
+		},
+		TakeProfitPrice: D(110),
+		StopPrice:       D(95),
+		StopLimitPrice:  D(94),
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, m.Reconcile(ctx))
+	require.Len(t, m.OpenOrders(), 1)
+
+	sim.UpdateOrderBook(book(98, 99.5))
+	require.NoError(t, m.Reconcile(ctx))
+
+	entry, ok := m.Order(entryId)
+	require.True(t, ok)
+	assert.Equal(t, exchangesdk.OrderStateFilled, entry.State)
+	require.NotNil(t, entry.Bracket.Exit)
+
+	exit := *entry.Bracket.Exit
+	takeProfit, ok := m.Order(exit.LimitOrderId)
+	require.True(t, ok)
+	assert.Equal(t, exchangesdk.OrderBookSideAsk, takeProfit.Side)
+	assert.Equal(t, exchangesdk.OrderStateInOrderBook, takeProfit.State)
+	assert.True(t, D(110).Equal(takeProfit.LimitPrice))
+	assert.True(t, D(1).Equal(takeProfit.Volume))
+
+	stop, ok := m.Order(exit.StopOrderId)
+	require.True(t, ok)
+	assert.Equal(t, exchangesdk.OrderStateAwaitingTrigger, stop.State)
+
+	// The stop triggers, so the take profit is cancelled
+	sim.AddTrade(exchangesdk.OrderBookTrade{Price: 95, Volume: 1})
+	require.NoError(t, m.Reconcile(ctx))
+	require.NoError(t, m.Reconcile(ctx))
+
+	stop, _ = m.Order(exit.StopOrderId)
+	assert.Equal(t, exchangesdk.OrderStateFilled, stop.State)
+	takeProfit, _ = m.Order(exit.LimitOrderId)
+	assert.Equal(t, exchangesdk.OrderStateCancelled, takeProfit.State)
+	assert.Empty(t, m.OpenOrders())
+
+	var ids []string
+	for _, tr := range r.transitions {
+		if tr.Previous.Id == "" {
+			ids = append(ids, tr.Order.Id)
+		}
+	}
+	assert.Equal(t, []string{entryId, exit.LimitOrderId, exit.StopOrderId}, ids)
+}
+
+func TestBracketOrderExitIsPlacedAfterRestart(t *testing.T) {
+
+	ctx := context.Background()
+	store := ordermanager.NewMemoryStore()
+	require.NoError(t, store.Put(ordermanager.Order{
+		Id:                "entry1",
+		Side:              exchangesdk.OrderBookSideAsk,
+		LimitPrice:        D(100),
+		Volume:            D(2),
+		State:             exchangesdk.OrderStateCancelled,
+		FillAmountBase:    D(0.5),
+		FillAmountCounter: D(50),
+		Bracket: &ordermanager.Bracket{
+			TakeProfitPrice: D(90),
+			StopPrice:       D(105),
+			StopLimitPrice:  D(106),
+		},
+	}))
+
+	client := new(mockery.Client).TSetup(t)
+	client.On("PostLimitOrder", mock.Anything, exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeBid,
+		Price:  D(90),
+		Volume: D(0.5),
+	}).Return("limit1", nil).Once()
+	client.On("PostStopLimitOrder", mock.Anything, exchangesdk.StopLimitOrder{
+		Side:       exchangesdk.OrderBookSideBid,
+		StopPrice:  D(105),
+		LimitPrice: D(106),
+		Volume:     D(0.5),
+	}).Return("stop1", nil).Once()
+
+	m, err := ordermanager.New(ctx, client, ordermanager.WithStore(store))
+	require.NoError(t, err)
+
+	entry, ok := m.Order("entry1")
+	require.True(t, ok)
+	require.NotNil(t, entry.Bracket.Exit)
+	assert.Equal(t, exchangesdk.OCOOrderIds{
+		LimitOrderId: "limit1",
+		StopOrderId:  "stop1",
+	}, *entry.Bracket.Exit)
+	assert.Len(t, m.OpenOrders(), 2)
+}
@@ -0,0 +1,142 @@
+package ordermanager
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/thecodedproject/crypto/exchangesdk"
+)
+
+// Order is the state of an order placed through a Manager, as persisted by
+// its Store
+type Order struct {
+	Id         string                    `json:"id"`
+	Side       exchangesdk.OrderBookSide `json:"side"`
+	LimitPrice decimal.Decimal           `json:"limit_price"`
+	// StopPrice is zero for limit orders
+	StopPrice decimal.Decimal `json:"stop_price"`
+	Volume    decimal.Decimal `json:"volume"`
+
+	State             exchangesdk.OrderState `json:"state"`
+	FillAmountBase    decimal.Decimal        `json:"fill_amount_base"`
+	FillAmountCounter decimal.Decimal        `json:"fill_amount_counter"`
+
+	Placed  time.Time `json:"placed"`
+	Updated time.Time `json:"updated"`
+
+	// OCOLegId is the id of the other leg of an OCO order
+	OCOLegId string `json:"oco_leg_id,omitempty"`
+	// Bracket is set on the entry order of a bracket order
+	Bracket *Bracket `json:"bracket,omitempty"`
+}
+
+// Bracket is the exit of a bracket order, which is placed as an OCO order
+// for the volume filled once the entry order is filled (or cancelled after
+// being partly filled)
+type Bracket struct {
+	TakeProfitPrice decimal.Decimal `json:"take_profit_price"`
+	StopPrice       decimal.Decimal `json:"stop_price"`
+	StopLimitPrice  decimal.Decimal `json:"stop_limit_price"`
+
+	// Exit is set once the exit has been placed
+	Exit *exchangesdk.OCOOrderIds `json:"exit,omitempty"`
+}
+
+// Transition is a change to an order; either of its state or of the amount
+// filled
+type Transition struct {
+	Previous Order
+	Order    Order
+}
+
+// IsOpen returns true until the order is filled or cancelled
+func (o Order) IsOpen() bool {
+
+	return !isTerminal(o.State)
+}
+
+// awaitingBracketExit returns true if o is the entry of a bracket order which
+// has finished with some of it filled, but whose exit is yet to be placed
+func (o Order) awaitingBracketExit() bool {
+
+	return o.Bracket != nil && o.Bracket.Exit == nil &&
+		!o.IsOpen() && o.FillAmountBase.IsPositive()
+}
+
+// PartiallyFilled returns true if the order is in the order book and has
+// been partly filled
+func (o Order) PartiallyFilled() bool {
+
+	return o.State == exchangesdk.OrderStateInOrderBook &&
+		o.FillAmountBase.IsPositive()
+}
+
+// untouched returns true while an order is open and has been neither
+// triggered nor filled
+func (o Order) untouched() bool {
+
+	switch o.State {
+	case exchangesdk.OrderStateAwaitingTrigger:
+		return true
+	case exchangesdk.OrderStateInOrderBook:
+		return o.StopPrice.IsZero() && !o.FillAmountBase.IsPositive()
+	default:
+		return false
+	}
+}
+
+func isTerminal(s exchangesdk.OrderState) bool {
+
+	return s == exchangesdk.OrderStateFilled || s == exchangesdk.OrderStateCancelled
+}
+
+// stateRank orders the states an order moves through; an order never moves
+// to a state of a lower rank
+func stateRank(s exchangesdk.OrderState) int {
+
+	switch s {
+	case exchangesdk.OrderStateAwaitingTrigger:
+		return 1
+	case exchangesdk.OrderStateInOrderBook:
+		return 2
+	case exchangesdk.OrderStateFilled, exchangesdk.OrderStateCancelled:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// apply returns o updated with the state and fill amounts reported by the
+// exchange, and whether it changed.
+//
+// Orders move from AwaitingTrigger to InOrderBook to Filled or Cancelled
+// (skipping states as required), and their fill amounts only increase;
+// reports which would move an order backwards (e.g. a stale poll racing a
+// streamed update), or change a filled or cancelled order, are ignored.
+func (o Order) apply(
+	state exchangesdk.OrderState,
+	fillBase decimal.Decimal,
+	fillCounter decimal.Decimal,
+	now time.Time,
+) (Order, bool) {
+
+	if isTerminal(o.State) {
+		return o, false
+	}
+
+	changed := false
+	if stateRank(state) > stateRank(o.State) {
+		o.State = state
+		changed = true
+	}
+	if fillBase.GreaterThan(o.FillAmountBase) {
+		o.FillAmountBase = fillBase
+		o.FillAmountCounter = fillCounter
+		changed = true
+	}
+
+	if changed {
+		o.Updated = now
+	}
+	return o, changed
+}
@@ -0,0 +1,283 @@
+package ordermanager
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// minCompactRecords is the number of records a file store's journal must
+// have before it is compacted
+const minCompactRecords = 1000
+
+// Store persists the orders followed by a Manager, so that it can reconcile
+// them with the exchange after a restart.
+// Each change to an order is written with Put, and orders which are no
+// longer retained by the manager are removed with Delete.
+type Store interface {
+	Load() ([]Order, error)
+	Put(o Order) error
+	Delete(orderId string) error
+}
+
+type memoryStore struct {
+	mu     sync.Mutex
+	orders map[string]Order
+}
+
+// NewMemoryStore returns a Store which keeps orders in memory only
+func NewMemoryStore() Store {
+
+	return &memoryStore{
+		orders: make(map[string]Order),
+	}
+}
+
+func (s *memoryStore) Load() ([]Order, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return sortedOrders(s.orders), nil
+}
+
+func (s *memoryStore) Put(o Order) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.orders[o.Id] = o
+	return nil
+}
+
+func (s *memoryStore) Delete(orderId string) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.orders, orderId)
+	return nil
+}
+
+type fileStore struct {
+	path string
+
+	mu sync.Mutex
+	// orders are the orders in the file, from which it is compacted
+	orders map[string]Order
+	// records is the number of records in the file
+	records int
+}
+
+// journalRecord is a line of a file store; either the new state of an order
+// or the id of an order which was deleted
+type journalRecord struct {
+	Order  *Order `json:"order,omitempty"`
+	Delete string `json:"delete,omitempty"`
+}
+
+// NewFileStore returns a Store which keeps orders in the file at path, as a
+// journal of JSON records which is appended to as orders change, and
+// compacted on Load and once most of its records are superseded.
+// Loading a file which does not exist returns no orders.
+func NewFileStore(path string) Store {
+
+	return &fileStore{
+		path:   path,
+		orders: make(map[string]Order),
+	}
+}
+
+func (s *fileStore) Load() ([]Order, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := readJournal(b)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load orders from %s: %w", s.path, err)
+	}
+	s.orders = orders
+
+	err = s.compactLocked()
+	if err != nil {
+		return nil, err
+	}
+	return sortedOrders(s.orders), nil
+}
+
+func (s *fileStore) Put(o Order) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.appendLocked(journalRecord{Order: &o})
+	if err != nil {
+		return err
+	}
+	s.orders[o.Id] = o
+	return s.maybeCompactLocked()
+}
+
+func (s *fileStore) Delete(orderId string) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orders[orderId]; !ok {
+		return nil
+	}
+
+	err := s.appendLocked(journalRecord{Delete: orderId})
+	if err != nil {
+		return err
+	}
+	delete(s.orders, orderId)
+	return s.maybeCompactLocked()
+}
+
+func (s *fileStore) appendLocked(r journalRecord) error {
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(append(b, '\n'))
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	s.records++
+	return nil
+}
+
+// maybeCompactLocked compacts the journal once most of its records are
+// superseded
+func (s *fileStore) maybeCompactLocked() error {
+
+	if s.records < minCompactRecords || s.records < 2*len(s.orders) {
+		return nil
+	}
+	return s.compactLocked()
+}
+
+// compactLocked replaces the journal with a record of each order, writing a
+// temporary file which then replaces the store's file, so that the file is
+// never left partly written
+func (s *fileStore) compactLocked() error {
+
+	var buf bytes.Buffer
+	for _, o := range sortedOrders(s.orders) {
+		o := o
+		b, err := json.Marshal(journalRecord{Order: &o})
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+		buf.WriteByte('\n')
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(buf.Bytes())
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(f.Name(), s.path)
+	if err != nil {
+		return err
+	}
+	s.records = len(s.orders)
+	return nil
+}
+
+// readJournal returns the orders of a journal; a final record which was only
+// partly written (e.g. by a crash) is ignored.
+// Files written as a JSON array of orders, as before the store was a
+// journal, are also read.
+func readJournal(b []byte) (map[string]Order, error) {
+
+	orders := make(map[string]Order)
+
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] == '[' {
+		var list []Order
+		err := json.Unmarshal(trimmed, &list)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range list {
+			orders[o.Id] = o
+		}
+		return orders, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	scanner.Buffer(nil, len(b)+1)
+	complete := len(b) == 0 || b[len(b)-1] == '\n'
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var r journalRecord
+		err := json.Unmarshal(scanner.Bytes(), &r)
+		if err != nil {
+			if !complete && bytes.HasSuffix(b, scanner.Bytes()) {
+				break
+			}
+			return nil, fmt.Errorf("record %d: %w", line, err)
+		}
+
+		switch {
+		case r.Order != nil:
+			orders[r.Order.Id] = *r.Order
+		case r.Delete != "":
+			delete(orders, r.Delete)
+		}
+	}
+	return orders, scanner.Err()
+}
+
+func sortedOrders(m map[string]Order) []Order {
+
+	orders := make([]Order, 0, len(m))
+	for _, o := range m {
+		orders = append(orders, o)
+	}
+	sortOrders(orders)
+	return orders
+}
@@ -0,0 +1,151 @@
+package ordermanager_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/ordermanager"
+)
+
+func TestFileStore(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "ordermanager")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "orders.json")
+	store := ordermanager.NewFileStore(path)
+
+	orders, err := store.Load()
+	require.NoError(t, err)
+	assert.Empty(t, orders)
+
+	expected := []ordermanager.Order{
+		{
+			Id:         "order1",
+			Side:       exchangesdk.OrderBookSideAsk,
+			LimitPrice: decimal.RequireFromString("94.5"),
+			StopPrice:  decimal.RequireFromString("95"),
+			Volume:     decimal.RequireFromString("1.25"),
+			State:      exchangesdk.OrderStateAwaitingTrigger,
+			Placed:     time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC),
+			Updated:    time.Date(2021, 3, 4, 5, 6, 8, 0, time.UTC),
+		},
+		{
+			Id:                "order2",
+			Side:              exchangesdk.OrderBookSideBid,
+			State:             exchangesdk.OrderStateFilled,
+			FillAmountBase:    decimal.RequireFromString("2"),
+			FillAmountCounter: decimal.RequireFromString("200"),
+		},
+	}
+	deleted := ordermanager.Order{Id: "order3", State: exchangesdk.OrderStateCancelled}
+	require.NoError(t, store.Put(deleted))
+	for _, o := range expected {
+		require.NoError(t, store.Put(o))
+	}
+	require.NoError(t, store.Delete(deleted.Id))
+
+	orders, err = ordermanager.NewFileStore(path).Load()
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	// Orders are loaded oldest first
+	orders[0], orders[1] = orders[1], orders[0]
+	for i := range expected {
+		assert.Equal(t, expected[i].Id, orders[i].Id)
+		assert.Equal(t, expected[i].Side, orders[i].Side)
+		assert.Equal(t, expected[i].State, orders[i].State)
+		assert.True(t, expected[i].LimitPrice.Equal(orders[i].LimitPrice))
+		assert.True(t, expected[i].StopPrice.Equal(orders[i].StopPrice))
+		assert.True(t, expected[i].Volume.Equal(orders[i].Volume))
+		assert.True(t, expected[i].FillAmountBase.Equal(orders[i].FillAmountBase))
+		assert.True(t, expected[i].FillAmountCounter.Equal(orders[i].FillAmountCounter))
+		assert.True(t, expected[i].Placed.Equal(orders[i].Placed))
+		assert.True(t, expected[i].Updated.Equal(orders[i].Updated))
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestFileStoreIsCompacted(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "ordermanager")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "orders.json")
+	store := ordermanager.NewFileStore(path)
+
+	for i := 0; i < 3000; i++ {
+		o := ordermanager.Order{
+			Id:    fmt.Sprintf("order%d", i),
+			State: exchangesdk.OrderStateFilled,
+		}
+		require.NoError(t, store.Put(o))
+		require.NoError(t, store.Delete(o.Id))
+	}
+	require.NoError(t, store.Put(ordermanager.Order{Id: "open"}))
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, len(strings.Split(string(b), "\n")) < 1000)
+
+	orders, err := ordermanager.NewFileStore(path).Load()
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	assert.Equal(t, "open", orders[0].Id)
+}
+
+func TestFileStoreIgnoresPartlyWrittenRecord(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "ordermanager")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "orders.json")
+	err = ioutil.WriteFile(
+		path,
+		[]byte(`{"order":{"id":"order1","state":"in_order_book"}}`+"\n"+`{"order":{"id":"ord`),
+		0644,
+	)
+	require.NoError(t, err)
+
+	orders, err := ordermanager.NewFileStore(path).Load()
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	assert.Equal(t, "order1", orders[0].Id)
+}
+
+func TestFileStoreLoadsListOfOrders(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "ordermanager")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "orders.json")
+	err = ioutil.WriteFile(path, []byte(`[{"id":"order1"},{"id":"order2"}]`), 0644)
+	require.NoError(t, err)
+
+	store := ordermanager.NewFileStore(path)
+	orders, err := store.Load()
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+
+	// Changes are appended to the file once it has been converted
+	require.NoError(t, store.Delete("order1"))
+	orders, err = ordermanager.NewFileStore(path).Load()
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	assert.Equal(t, "order2", orders[0].Id)
+}
@@ -0,0 +1,194 @@
+package recording
+
+import (
+	"sort"
+	"time"
+
+	"github.com/thecodedproject/crypto/exchangesdk"
+)
+
+// A recording is a gzip compressed stream of JSON records, one per line.
+//
+// Each recording session starts a new gzip member, so that sessions can be
+// appended to an existing file (gzip readers handle concatenated members
+// transparently).
+// Within a session, the first order book is written as a full snapshot and
+// subsequent books are written as deltas against the previous book, with a
+// new snapshot written every snapshotPeriod books.
+
+const (
+	recordTypeSnapshot = "s"
+	recordTypeDelta    = "d"
+	recordTypeTrade    = "t"
+
+	snapshotPeriod = 1000
+)
+
+// Event is a single item emitted by a market follower along with the time
+// at which it was received.
+// Exactly one of OrderBook and Trade is set.
+type Event struct {
+	Received  time.Time
+	OrderBook *exchangesdk.OrderBook
+	Trade     *exchangesdk.OrderBookTrade
+}
+
+type record struct {
+	Type string `json:"k"`
+
+	// Received is the receive time in unix nanoseconds
+	Received int64 `json:"r"`
+
+	// Timestamp is the exchange time of the book or trade in unix nanoseconds
+	Timestamp int64 `json:"ts"`
+
+	// Bids and Asks are [price, volume] levels; in a delta record a volume of
+	// zero means the level was removed
+	Bids [][2]float64 `json:"b,omitempty"`
+	Asks [][2]float64 `json:"a,omitempty"`
+
+	MakerSide exchangesdk.OrderBookSide `json:"m,omitempty"`
+	Price     float64                   `json:"p,omitempty"`
+	Volume    float64                   `json:"v,omitempty"`
+}
+
+func toUnixNano(t time.Time) int64 {
+
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromUnixNano(ns int64) time.Time {
+
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+func toLevels(orders []exchangesdk.OrderBookOrder) [][2]float64 {
+
+	levels := make([][2]float64, 0, len(orders))
+	for _, o := range orders {
+		levels = append(levels, [2]float64{o.Price, o.Volume})
+	}
+	return levels
+}
+
+func fromLevels(levels [][2]float64) []exchangesdk.OrderBookOrder {
+
+	orders := make([]exchangesdk.OrderBookOrder, 0, len(levels))
+	for _, l := range levels {
+		orders = append(orders, exchangesdk.OrderBookOrder{
+			Price:  l[0],
+			Volume: l[1],
+		})
+	}
+	return orders
+}
+
+// groupByPrice returns the volumes at each price, in the order in which
+// they appear in orders.
+// Prices are grouped (rather than keyed directly) as some exchanges (e.g. Luno)
+// report individual orders, so the same price can appear more than once.
+func groupByPrice(orders []exchangesdk.OrderBookOrder) map[float64][]float64 {
+
+	groups := make(map[float64][]float64, len(orders))
+	for _, o := range orders {
+		groups[o.Price] = append(groups[o.Price], o.Volume)
+	}
+	return groups
+}
+
+func volumesEqual(a, b []float64) bool {
+
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// diffLevels returns the levels which must be applied to prev to produce next.
+// For every price which has changed, the delta contains all of the levels at
+// that price in next, or a single level with zero volume if the price has been
+// removed.
+func diffLevels(prev, next []exchangesdk.OrderBookOrder) [][2]float64 {
+
+	prevGroups := groupByPrice(prev)
+	nextGroups := groupByPrice(next)
+
+	var diff [][2]float64
+	for _, o := range next {
+		volumes, ok := nextGroups[o.Price]
+		if !ok {
+			// Already added all levels at this price
+			continue
+		}
+		if !volumesEqual(prevGroups[o.Price], volumes) {
+			for _, v := range volumes {
+				diff = append(diff, [2]float64{o.Price, v})
+			}
+		}
+		delete(nextGroups, o.Price)
+		delete(prevGroups, o.Price)
+	}
+
+	removed := make([]float64, 0, len(prevGroups))
+	for price := range prevGroups {
+		removed = append(removed, price)
+	}
+	sort.Float64s(removed)
+	for _, price := range removed {
+		diff = append(diff, [2]float64{price, 0})
+	}
+
+	return diff
+}
+
+// applyLevels applies a delta (as produced by diffLevels) to orders,
+// returning a new, unsorted, slice
+func applyLevels(orders []exchangesdk.OrderBookOrder, delta [][2]float64) []exchangesdk.OrderBookOrder {
+
+	groups := groupByPrice(orders)
+
+	replaced := make(map[float64]bool)
+	for _, l := range delta {
+		if !replaced[l[0]] {
+			delete(groups, l[0])
+			replaced[l[0]] = true
+		}
+		if l[1] != 0 {
+			groups[l[0]] = append(groups[l[0]], l[1])
+		}
+	}
+
+	updated := make([]exchangesdk.OrderBookOrder, 0, len(orders)+len(delta))
+	for price, volumes := range groups {
+		for _, volume := range volumes {
+			updated = append(updated, exchangesdk.OrderBookOrder{
+				Price:  price,
+				Volume: volume,
+			})
+		}
+	}
+	return updated
+}
+
+func copyOrderBook(ob exchangesdk.OrderBook) exchangesdk.OrderBook {
+
+	c := exchangesdk.OrderBook{
+		Timestamp: ob.Timestamp,
+		Bids:      make([]exchangesdk.OrderBookOrder, len(ob.Bids)),
+		Asks:      make([]exchangesdk.OrderBookOrder, len(ob.Asks)),
+	}
+	copy(c.Bids, ob.Bids)
+	copy(c.Asks, ob.Asks)
+	return c
+}
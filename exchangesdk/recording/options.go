@@ -0,0 +1,44 @@
+package recording
+
+import (
+	"github.com/thecodedproject/crypto/exchangesdk/logging"
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+type options struct {
+	clock  utiltime.Clock
+	logger logging.Logger
+}
+
+// Option configures the recording and replaying of market followers
+type Option func(*options)
+
+func resolveOptions(opts []Option) options {
+
+	o := options{
+		clock:  utiltime.Real,
+		logger: logging.Std,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithClock sets the clock which paces replays and flushes recordings, and
+// at which recorded events are received; by default the real clock is used
+func WithClock(clock utiltime.Clock) Option {
+
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// WithLogger sets the logger which errors while recording or replaying a
+// market follower are reported to; by default logging.Std is used
+func WithLogger(logger logging.Logger) Option {
+
+	return func(o *options) {
+		o.logger = logger
+	}
+}
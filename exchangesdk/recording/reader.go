@@ -0,0 +1,156 @@
+package recording
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/thecodedproject/crypto/exchangesdk"
+)
+
+// Reader reads the events from a recording, reconstructing full order books
+// from the recorded deltas
+type Reader struct {
+	scanner  *bufio.Scanner
+	lastBook *exchangesdk.OrderBook
+}
+
+func NewReader(r io.Reader) (*Reader, error) {
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+
+	return &Reader{
+		scanner: scanner,
+	}, nil
+}
+
+// Next returns the next event in the recording, or io.EOF when there are no
+// more events.
+// A recording which was not closed cleanly (e.g. the recorder crashed) is
+// read up to the last complete record.
+func (r *Reader) Next() (Event, error) {
+
+	for {
+		if !r.scanner.Scan() {
+			err := r.scanner.Err()
+			if err == nil || err == io.ErrUnexpectedEOF {
+				return Event{}, io.EOF
+			}
+			return Event{}, err
+		}
+
+		var rec record
+		err := json.Unmarshal(r.scanner.Bytes(), &rec)
+		if err != nil {
+			// A partially written final record; treat as the end of the recording
+			if isLastLine(r.scanner) {
+				return Event{}, io.EOF
+			}
+			return Event{}, err
+		}
+
+		return r.toEvent(rec)
+	}
+}
+
+func isLastLine(scanner *bufio.Scanner) bool {
+
+	return !scanner.Scan()
+}
+
+func (r *Reader) toEvent(rec record) (Event, error) {
+
+	received := fromUnixNano(rec.Received)
+
+	switch rec.Type {
+	case recordTypeSnapshot:
+		ob := exchangesdk.OrderBook{
+			Timestamp: fromUnixNano(rec.Timestamp),
+			Bids:      fromLevels(rec.Bids),
+			Asks:      fromLevels(rec.Asks),
+		}
+		r.lastBook = &ob
+		return Event{
+			Received:  received,
+			OrderBook: copyOrderBookPtr(ob),
+		}, nil
+
+	case recordTypeDelta:
+		if r.lastBook == nil {
+			return Event{}, fmt.Errorf("recording contains an order book delta before any snapshot")
+		}
+		ob := exchangesdk.OrderBook{
+			Timestamp: fromUnixNano(rec.Timestamp),
+			Bids:      applyLevels(r.lastBook.Bids, rec.Bids),
+			Asks:      applyLevels(r.lastBook.Asks, rec.Asks),
+		}
+		sortBook(&ob)
+		r.lastBook = &ob
+		return Event{
+			Received:  received,
+			OrderBook: copyOrderBookPtr(ob),
+		}, nil
+
+	case recordTypeTrade:
+		return Event{
+			Received: received,
+			Trade: &exchangesdk.OrderBookTrade{
+				MakerSide: rec.MakerSide,
+				Price:     rec.Price,
+				Volume:    rec.Volume,
+				Timestamp: fromUnixNano(rec.Timestamp),
+			},
+		}, nil
+
+	default:
+		return Event{}, fmt.Errorf("unknown record type `%s` in recording", rec.Type)
+	}
+}
+
+// sortBook sorts the bids (descending) and asks (ascending) of ob, preserving
+// the relative order of orders at the same price
+func sortBook(ob *exchangesdk.OrderBook) {
+
+	sort.SliceStable(ob.Bids, func(i, j int) bool {
+		return ob.Bids[i].Price > ob.Bids[j].Price
+	})
+	sort.SliceStable(ob.Asks, func(i, j int) bool {
+		return ob.Asks[i].Price < ob.Asks[j].Price
+	})
+}
+
+func copyOrderBookPtr(ob exchangesdk.OrderBook) *exchangesdk.OrderBook {
+
+	c := copyOrderBook(ob)
+	return &c
+}
+
+// ReadAll reads all of the events from a recording
+func ReadAll(r io.Reader) ([]Event, error) {
+
+	reader, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	for {
+		e, err := reader.Next()
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+}
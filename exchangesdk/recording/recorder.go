@@ -0,0 +1,217 @@
+package recording
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/logging"
+)
+
+const (
+	flushPeriod = 5 * time.Second
+)
+
+// Recorder writes market follower events to a compressed recording
+type Recorder struct {
+	closer io.Closer
+	gz     *gzip.Writer
+	buf    *bufio.Writer
+	enc    *json.Encoder
+
+	lastBook           *exchangesdk.OrderBook
+	booksSinceSnapshot int
+}
+
+// NewRecorder returns a recorder which writes a new recording session to w.
+// Close must be called to flush the session.
+func NewRecorder(w io.Writer) *Recorder {
+
+	gz := gzip.NewWriter(w)
+	buf := bufio.NewWriter(gz)
+	return &Recorder{
+		gz:  gz,
+		buf: buf,
+		enc: json.NewEncoder(buf),
+	}
+}
+
+// OpenRecorder opens (or creates) the recording at path and returns a
+// recorder which appends a new session to it
+func OpenRecorder(path string) (*Recorder, error) {
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	r := NewRecorder(f)
+	r.closer = f
+	return r, nil
+}
+
+func (r *Recorder) RecordOrderBook(received time.Time, ob exchangesdk.OrderBook) error {
+
+	rec := record{
+		Received:  toUnixNano(received),
+		Timestamp: toUnixNano(ob.Timestamp),
+	}
+
+	if r.lastBook == nil || r.booksSinceSnapshot >= snapshotPeriod {
+		rec.Type = recordTypeSnapshot
+		rec.Bids = toLevels(ob.Bids)
+		rec.Asks = toLevels(ob.Asks)
+		r.booksSinceSnapshot = 0
+	} else {
+		rec.Type = recordTypeDelta
+		rec.Bids = diffLevels(r.lastBook.Bids, ob.Bids)
+		rec.Asks = diffLevels(r.lastBook.Asks, ob.Asks)
+		r.booksSinceSnapshot++
+	}
+
+	err := r.enc.Encode(&rec)
+	if err != nil {
+		return err
+	}
+
+	lastBook := copyOrderBook(ob)
+	r.lastBook = &lastBook
+	return nil
+}
+
+func (r *Recorder) RecordTrade(received time.Time, t exchangesdk.OrderBookTrade) error {
+
+	return r.enc.Encode(&record{
+		Type:      recordTypeTrade,
+		Received:  toUnixNano(received),
+		Timestamp: toUnixNano(t.Timestamp),
+		MakerSide: t.MakerSide,
+		Price:     t.Price,
+		Volume:    t.Volume,
+	})
+}
+
+// Flush writes any buffered records to the underlying writer, such that they
+// can be read back even if the session is not closed cleanly
+func (r *Recorder) Flush() error {
+
+	err := r.buf.Flush()
+	if err != nil {
+		return err
+	}
+	return r.gz.Flush()
+}
+
+// Close flushes and ends the recording session, closing the underlying file
+// if the recorder was created with OpenRecorder
+func (r *Recorder) Close() error {
+
+	err := r.buf.Flush()
+	if err != nil {
+		return err
+	}
+
+	err = r.gz.Close()
+	if err != nil {
+		return err
+	}
+
+	if r.closer != nil {
+		return r.closer.Close()
+	}
+	return nil
+}
+
+// RecordMarketFollower records everything emitted by a market follower
+// (e.g. as returned by factory.NewMarketFollower) to the file at path, while
+// passing the events through to the returned channels unchanged.
+//
+// wg.Done is called once the recording has been closed, either when ctx is
+// cancelled or when both of the input channels have been closed.
+func RecordMarketFollower(
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	path string,
+	obf <-chan exchangesdk.OrderBook,
+	tradeStream <-chan exchangesdk.OrderBookTrade,
+	opts ...Option,
+) (<-chan exchangesdk.OrderBook, <-chan exchangesdk.OrderBookTrade, error) {
+
+	o := resolveOptions(opts)
+
+	rec, err := OpenRecorder(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	obfOut := make(chan exchangesdk.OrderBook, 1)
+	tradeStreamOut := make(chan exchangesdk.OrderBookTrade, 1)
+
+	logError := func(msg string, err error) {
+		o.logger.Error(msg, logging.KeyPath, path, logging.KeyError, err)
+	}
+
+	go func() {
+
+		defer wg.Done()
+		defer func() {
+			err := rec.Close()
+			if err != nil {
+				logError("Recorder cannot close recording", err)
+			}
+		}()
+
+		flushTicker := o.clock.NewTicker(flushPeriod)
+		defer flushTicker.Stop()
+
+		for obf != nil || tradeStream != nil {
+			select {
+			case <-flushTicker.C():
+				err := rec.Flush()
+				if err != nil {
+					logError("Recorder cannot flush recording", err)
+				}
+			case ob, more := <-obf:
+				if !more {
+					close(obfOut)
+					obf = nil
+					continue
+				}
+				err := rec.RecordOrderBook(o.clock.Now(), ob)
+				if err != nil {
+					logError("Recorder cannot record order book", err)
+				}
+				select {
+				case obfOut <- ob:
+				case <-ctx.Done():
+					return
+				}
+			case trade, more := <-tradeStream:
+				if !more {
+					close(tradeStreamOut)
+					tradeStream = nil
+					continue
+				}
+				err := rec.RecordTrade(o.clock.Now(), trade)
+				if err != nil {
+					logError("Recorder cannot record trade", err)
+				}
+				select {
+				case tradeStreamOut <- trade:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return obfOut, tradeStreamOut, nil
+}
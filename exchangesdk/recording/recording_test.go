@@ -0,0 +1,424 @@
+package recording_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/logging"
+	"github.com/thecodedproject/crypto/exchangesdk/recording"
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+func book(ts int64, bids, asks [][2]float64) exchangesdk.OrderBook {
+
+	ob := exchangesdk.OrderBook{
+		Timestamp: time.Unix(ts, 0),
+		Bids:      []exchangesdk.OrderBookOrder{},
+		Asks:      []exchangesdk.OrderBookOrder{},
+	}
+	for _, b := range bids {
+		ob.Bids = append(ob.Bids, exchangesdk.OrderBookOrder{Price: b[0], Volume: b[1]})
+	}
+	for _, a := range asks {
+		ob.Asks = append(ob.Asks, exchangesdk.OrderBookOrder{Price: a[0], Volume: a[1]})
+	}
+	return ob
+}
+
+func someEvents() []recording.Event {
+
+	books := []exchangesdk.OrderBook{
+		book(1, [][2]float64{{10, 1}, {9, 2}}, [][2]float64{{11, 1}, {12, 3}}),
+		// Update a level, add a level and remove a level
+		book(2, [][2]float64{{10, 1.5}, {9, 2}, {8, 1}}, [][2]float64{{12, 3}}),
+		// Several orders at the same price (as reported by Luno)
+		book(3, [][2]float64{{10, 1.5}, {10, 0.5}, {9, 2}}, [][2]float64{{12, 3}, {12, 1}}),
+		// Remove one of the orders at a shared price
+		book(4, [][2]float64{{10, 0.5}, {9, 2}}, [][2]float64{{12, 3}, {12, 1}}),
+		book(5, [][2]float64{}, [][2]float64{}),
+	}
+
+	var events []recording.Event
+	for i := range books {
+		events = append(events, recording.Event{
+			Received:  time.Unix(100+int64(i), 0),
+			OrderBook: &books[i],
+		})
+		events = append(events, recording.Event{
+			Received: time.Unix(100+int64(i), 500),
+			Trade: &exchangesdk.OrderBookTrade{
+				MakerSide: exchangesdk.OrderBookSideBid,
+				Price:     float64(i),
+				Volume:    0.1,
+				Timestamp: time.Unix(int64(i)+1, 0),
+			},
+		})
+	}
+	return events
+}
+
+func record(t *testing.T, rec *recording.Recorder, events []recording.Event) {
+
+	for _, e := range events {
+		if e.OrderBook != nil {
+			require.NoError(t, rec.RecordOrderBook(e.Received, *e.OrderBook))
+		}
+		if e.Trade != nil {
+			require.NoError(t, rec.RecordTrade(e.Received, *e.Trade))
+		}
+	}
+}
+
+func assertEventsEqual(t *testing.T, expected, actual []recording.Event) {
+
+	require.Equal(t, len(expected), len(actual))
+	for i := range expected {
+		assert.True(t, expected[i].Received.Equal(actual[i].Received), "event %d received", i)
+		if expected[i].OrderBook != nil {
+			require.NotNil(t, actual[i].OrderBook, "event %d", i)
+			assert.True(t, expected[i].OrderBook.Timestamp.Equal(actual[i].OrderBook.Timestamp))
+			assert.Equal(t, expected[i].OrderBook.Bids, actual[i].OrderBook.Bids, "event %d bids", i)
+			assert.Equal(t, expected[i].OrderBook.Asks, actual[i].OrderBook.Asks, "event %d asks", i)
+		} else {
+			assert.Nil(t, actual[i].OrderBook, "event %d", i)
+		}
+		if expected[i].Trade != nil {
+			require.NotNil(t, actual[i].Trade, "event %d", i)
+			assert.True(t, expected[i].Trade.Timestamp.Equal(actual[i].Trade.Timestamp))
+			assert.Equal(t, expected[i].Trade.MakerSide, actual[i].Trade.MakerSide)
+			assert.Equal(t, expected[i].Trade.Price, actual[i].Trade.Price)
+			assert.Equal(t, expected[i].Trade.Volume, actual[i].Trade.Volume)
+		} else {
+			assert.Nil(t, actual[i].Trade, "event %d", i)
+		}
+	}
+}
+
+func TestRecordAndReadRoundTrips(t *testing.T) {
+
+	events := someEvents()
+
+	var buf bytes.Buffer
+	rec := recording.NewRecorder(&buf)
+	record(t, rec, events)
+	require.NoError(t, rec.Close())
+
+	actual, err := recording.ReadAll(&buf)
+	require.NoError(t, err)
+
+	assertEventsEqual(t, events, actual)
+}
+
+func TestRecordingSessionsCanBeAppended(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "recording.gz")
+	events := someEvents()
+
+	rec, err := recording.OpenRecorder(path)
+	require.NoError(t, err)
+	record(t, rec, events[:5])
+	require.NoError(t, rec.Close())
+
+	rec, err = recording.OpenRecorder(path)
+	require.NoError(t, err)
+	record(t, rec, events[5:])
+	require.NoError(t, rec.Close())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	actual, err := recording.ReadAll(f)
+	require.NoError(t, err)
+
+	assertEventsEqual(t, events, actual)
+}
+
+func TestReadFlushedButUnclosedRecordingReturnsFlushedEvents(t *testing.T) {
+
+	events := someEvents()
+
+	var buf bytes.Buffer
+	rec := recording.NewRecorder(&buf)
+	record(t, rec, events[:4])
+	require.NoError(t, rec.Flush())
+
+	actual, err := recording.ReadAll(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+
+	assertEventsEqual(t, events[:4], actual)
+}
+
+func TestReplayAsFastAsPossibleEmitsEventsInOrder(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "recording.gz")
+	events := someEvents()
+
+	rec, err := recording.OpenRecorder(path)
+	require.NoError(t, err)
+	record(t, rec, events)
+	require.NoError(t, rec.Close())
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	obf, tradeStream, err := recording.NewMarketFollower(
+		ctx,
+		&wg,
+		path,
+		recording.ReplaySpeedAsFastAsPossible,
+	)
+	require.NoError(t, err)
+
+	var actual []recording.Event
+	for obf != nil || tradeStream != nil {
+		select {
+		case ob, more := <-obf:
+			if !more {
+				obf = nil
+				continue
+			}
+			actual = append(actual, recording.Event{OrderBook: &ob})
+		case trade, more := <-tradeStream:
+			if !more {
+				tradeStream = nil
+				continue
+			}
+			actual = append(actual, recording.Event{Trade: &trade})
+		}
+	}
+	wg.Wait()
+
+	for i := range events {
+		events[i].Received = time.Time{}
+	}
+	assertEventsEqual(t, events, actual)
+}
+
+func TestReplayAtRecordedSpeedWaitsBetweenEvents(t *testing.T) {
+
+	var buf bytes.Buffer
+	rec := recording.NewRecorder(&buf)
+	start := time.Unix(1000, 0)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, rec.RecordTrade(
+			start.Add(time.Duration(i)*50*time.Millisecond),
+			exchangesdk.OrderBookTrade{Price: float64(i)},
+		))
+	}
+	require.NoError(t, rec.Close())
+
+	reader, err := recording.NewReader(&buf)
+	require.NoError(t, err)
+
+	obf := make(chan exchangesdk.OrderBook)
+	tradeStream := make(chan exchangesdk.OrderBookTrade, 3)
+
+	replayStart := time.Now()
+	err = recording.Replay(
+		context.Background(),
+		reader,
+		recording.ReplaySpeedRecorded,
+		obf,
+		tradeStream,
+	)
+	require.NoError(t, err)
+
+	assert.True(t, time.Since(replayStart) >= 100*time.Millisecond)
+	assert.Equal(t, 3, len(tradeStream))
+}
+
+func TestReplayAtRecordedSpeedIsPacedByClock(t *testing.T) {
+
+	var buf bytes.Buffer
+	rec := recording.NewRecorder(&buf)
+	start := time.Unix(1000, 0)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, rec.RecordTrade(
+			start.Add(time.Duration(i)*time.Minute),
+			exchangesdk.OrderBookTrade{Price: float64(i)},
+		))
+	}
+	require.NoError(t, rec.Close())
+
+	reader, err := recording.NewReader(&buf)
+	require.NoError(t, err)
+
+	clock := utiltime.NewSimulatedClock(time.Unix(0, 0))
+	obf := make(chan exchangesdk.OrderBook)
+	tradeStream := make(chan exchangesdk.OrderBookTrade, 3)
+
+	done := make(chan error)
+	go func() {
+		done <- recording.Replay(
+			context.Background(),
+			reader,
+			recording.ReplaySpeedRecorded,
+			obf,
+			tradeStream,
+			recording.WithClock(clock),
+		)
+	}()
+
+	for i := 0; i < 2; i++ {
+		require.Eventually(t, func() bool {
+			return clock.Waiters() == 1
+		}, time.Second, time.Millisecond)
+		assert.Equal(t, i+1, len(tradeStream))
+		clock.Advance(time.Minute)
+	}
+
+	require.NoError(t, <-done)
+	assert.Equal(t, 3, len(tradeStream))
+}
+
+func TestRecordMarketFollowerPassesThroughAndRecordsEvents(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "recording.gz")
+	events := someEvents()
+
+	obfIn := make(chan exchangesdk.OrderBook)
+	tradeStreamIn := make(chan exchangesdk.OrderBookTrade)
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	obf, tradeStream, err := recording.RecordMarketFollower(
+		ctx,
+		&wg,
+		path,
+		obfIn,
+		tradeStreamIn,
+	)
+	require.NoError(t, err)
+
+	for _, e := range events {
+		if e.OrderBook != nil {
+			obfIn <- *e.OrderBook
+			ob := <-obf
+			assert.Equal(t, *e.OrderBook, ob)
+		}
+		if e.Trade != nil {
+			tradeStreamIn <- *e.Trade
+			trade := <-tradeStream
+			assert.Equal(t, *e.Trade, trade)
+		}
+	}
+	close(obfIn)
+	close(tradeStreamIn)
+	wg.Wait()
+
+	contents, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	actual, err := recording.ReadAll(bytes.NewReader(contents))
+	require.NoError(t, err)
+	require.Equal(t, len(events), len(actual))
+	for i := range events {
+		assert.Equal(t, events[i].OrderBook == nil, actual[i].OrderBook == nil)
+		assert.Equal(t, events[i].Trade == nil, actual[i].Trade == nil)
+	}
+}
+
+// recordingLogger records the entries logged at error level
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+type logEntry struct {
+	msg  string
+	args []interface{}
+}
+
+func (l *recordingLogger) Debug(string, ...interface{}) {}
+func (l *recordingLogger) Info(string, ...interface{})  {}
+func (l *recordingLogger) Warn(string, ...interface{})  {}
+
+func (l *recordingLogger) Error(msg string, args ...interface{}) {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = append(l.entries, logEntry{msg: msg, args: args})
+}
+
+func TestReplayErrorIsLoggedToLogger(t *testing.T) {
+
+	// An order book delta before any snapshot cannot be replayed
+	path := filepath.Join(t.TempDir(), "recording.gz")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	gz := gzip.NewWriter(f)
+	_, err = gz.Write([]byte(`{"k":"d","r":1,"ts":1}` + "\n"))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	require.NoError(t, f.Close())
+
+	logger := &recordingLogger{}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	obf, tradeStream, err := recording.NewMarketFollower(
+		context.Background(),
+		&wg,
+		path,
+		recording.ReplaySpeedAsFastAsPossible,
+		recording.WithLogger(logger),
+	)
+	require.NoError(t, err)
+
+	_, more := <-obf
+	assert.False(t, more)
+	_, more = <-tradeStream
+	assert.False(t, more)
+	wg.Wait()
+
+	require.Equal(t, 1, len(logger.entries))
+	assert.Equal(t, "Replay of recording failed", logger.entries[0].msg)
+	assert.Equal(t, []interface{}{logging.KeyPath, path}, logger.entries[0].args[:2])
+}
+
+func TestRecorderErrorIsLoggedToLogger(t *testing.T) {
+
+	// Writes to /dev/full fail, so the recording cannot be closed
+	const path = "/dev/full"
+	if _, err := os.Stat(path); err != nil {
+		t.Skip(path, "is not available")
+	}
+
+	obfIn := make(chan exchangesdk.OrderBook)
+	tradeStreamIn := make(chan exchangesdk.OrderBookTrade)
+
+	logger := &recordingLogger{}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	obf, _, err := recording.RecordMarketFollower(
+		context.Background(),
+		&wg,
+		path,
+		obfIn,
+		tradeStreamIn,
+		recording.WithLogger(logger),
+	)
+	require.NoError(t, err)
+
+	obfIn <- book(1, [][2]float64{{99, 1}}, [][2]float64{{101, 1}})
+	<-obf
+	close(obfIn)
+	close(tradeStreamIn)
+	wg.Wait()
+
+	require.Equal(t, 1, len(logger.entries))
+	assert.Equal(t, "Recorder cannot close recording", logger.entries[0].msg)
+	assert.Equal(t, []interface{}{logging.KeyPath, path}, logger.entries[0].args[:2])
+}
@@ -0,0 +1,138 @@
+package recording
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/logging"
+)
+
+const (
+	// ReplaySpeedAsFastAsPossible replays events without any delay between them
+	ReplaySpeedAsFastAsPossible = 0.0
+
+	// ReplaySpeedRecorded replays events with the same delays between them as
+	// when they were recorded
+	ReplaySpeedRecorded = 1.0
+)
+
+// NewMarketFollower replays the recording at path, exposing the same
+// channels as factory.NewMarketFollower.
+//
+// speed scales the delay between events relative to the recording (e.g. a
+// speed of 2 replays at twice the recorded rate); ReplaySpeedAsFastAsPossible
+// replays without delay.
+// Events are emitted in recorded order on unbuffered channels, so order
+// books and trades are never received out of order.
+// Both channels are closed once the recording has been replayed.
+// As with the other market followers, wg.Done is called when the replay
+// finishes or ctx is cancelled.
+func NewMarketFollower(
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	path string,
+	speed float64,
+	opts ...Option,
+) (<-chan exchangesdk.OrderBook, <-chan exchangesdk.OrderBookTrade, error) {
+
+	o := resolveOptions(opts)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reader, err := NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	obf := make(chan exchangesdk.OrderBook)
+	tradeStream := make(chan exchangesdk.OrderBookTrade)
+
+	go func() {
+
+		defer wg.Done()
+		defer f.Close()
+
+		err := Replay(ctx, reader, speed, obf, tradeStream, opts...)
+		if err != nil && err != context.Canceled {
+			o.logger.Error(
+				"Replay of recording failed",
+				logging.KeyPath, path,
+				logging.KeyError, err,
+			)
+		}
+		close(obf)
+		close(tradeStream)
+	}()
+
+	return obf, tradeStream, nil
+}
+
+// Replay sends each event from reader to obf or tradeStream, returning
+// once all events have been sent or ctx is cancelled
+func Replay(
+	ctx context.Context,
+	reader *Reader,
+	speed float64,
+	obf chan<- exchangesdk.OrderBook,
+	tradeStream chan<- exchangesdk.OrderBookTrade,
+	opts ...Option,
+) error {
+
+	clock := resolveOptions(opts).clock
+
+	var firstReceived time.Time
+	var replayStart time.Time
+
+	for {
+		e, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if speed > 0 {
+			if firstReceived.IsZero() {
+				firstReceived = e.Received
+				replayStart = clock.Now()
+			}
+
+			offset := time.Duration(float64(e.Received.Sub(firstReceived)) / speed)
+			wait := replayStart.Add(offset).Sub(clock.Now())
+			if wait > 0 {
+				timer := clock.NewTimer(wait)
+				select {
+				case <-timer.C():
+				case <-ctx.Done():
+					timer.Stop()
+					return ctx.Err()
+				}
+			}
+		}
+
+		if e.OrderBook != nil {
+			select {
+			case obf <- *e.OrderBook:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		if e.Trade != nil {
+			select {
+			case tradeStream <- *e.Trade:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package exchangesdk
+
+import (
+	"context"
+	"time"
+
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+// outOfBookPollInterval is how often the status of a cancelled order is
+// polled while confirming that it has left the order book
+const outOfBookPollInterval = 100 * time.Millisecond
+
+type replaceOptions struct {
+	unfilledOnly bool
+	clock        utiltime.Clock
+}
+
+// ReplaceOption configures ReplaceOrder and AwaitOutOfBook
+type ReplaceOption func(*replaceOptions)
+
+func resolveReplaceOptions(opts []ReplaceOption) replaceOptions {
+
+	o := replaceOptions{
+		clock: utiltime.Real,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// ReplaceUnfilledOnly only posts the replacement if the original order was
+// cancelled without any of it being filled (e.g. where the replacement is for
+// the whole of the original's volume).
+// As the fill of the original is only known once it has been cancelled, the
+// order is always replaced by cancelling and then posting, even if the client
+// implements ReplaceOrderClient.
+func ReplaceUnfilledOnly() ReplaceOption {
+
+	return func(o *replaceOptions) {
+		o.unfilledOnly = true
+	}
+}
+
+// WithClock sets the clock which times the polls of the status of a
+// cancelled order; e.g. a simulated clock, so that a backtest's cancel is
+// confirmed as its simulated time passes. The default is the real time.
+func WithClock(clock utiltime.Clock) ReplaceOption {
+
+	return func(o *replaceOptions) {
+		o.clock = clock
+	}
+}
+
+// ReplaceOrder cancels orderId and posts order in its place, using the
+// client's ReplaceOrder if it implements ReplaceOrderClient.
+//
+// Otherwise the order is cancelled and then its status polled until it is no
+// longer in the order book (or ctx is cancelled), so that the original and
+// its replacement are never both open; only then is order posted.
+// If the original was filled before it could be cancelled, then order is not
+// posted and the result is not Cancelled (but has the original's final
+// status), so that the filled volume is not placed again; where an exchange
+// reports cancelled orders as filled, this includes any partially filled
+// original.
+//
+// The result reports which legs succeeded, including when an error is
+// returned: if the cancel fails (e.g. the order was already filled) then
+// order is not posted, and if the post fails then the original order remains
+// cancelled.
+func ReplaceOrder(
+	ctx context.Context,
+	c Client,
+	orderId string,
+	order Order,
+	opts ...ReplaceOption,
+) (ReplaceResult, error) {
+
+	o := resolveReplaceOptions(opts)
+
+	if r, ok := c.(ReplaceOrderClient); ok && !o.unfilledOnly {
+		return r.ReplaceOrder(ctx, orderId, order)
+	}
+
+	err := c.CancelOrder(ctx, orderId)
+	if err != nil {
+		return ReplaceResult{}, err
+	}
+
+	original, err := AwaitOutOfBook(ctx, c, orderId, opts...)
+	if err != nil {
+		return ReplaceResult{}, err
+	}
+
+	// Some exchanges (e.g. Luno) report cancelled orders as filled, so the
+	// original is only taken to have been filled if some of it was
+	if original.State == OrderStateFilled && !original.FillAmountBase.IsZero() {
+		return ReplaceResult{Original: original}, nil
+	}
+
+	res := ReplaceResult{
+		Cancelled: true,
+		Original:  original,
+	}
+
+	if o.unfilledOnly && !original.FillAmountBase.IsZero() {
+		return res, nil
+	}
+
+	res.NewOrderId, err = c.PostLimitOrder(ctx, order)
+	if err != nil {
+		return res, err
+	}
+
+	return res, nil
+}
+
+// AwaitOutOfBook polls the status of orderId until it is filled or cancelled
+// (e.g. to confirm a cancel, after which the order may still be filled until
+// the exchange has removed it from the order book), returning its final
+// status.
+// The polls are timed by the clock given with WithClock; the other options
+// have no effect.
+func AwaitOutOfBook(
+	ctx context.Context,
+	c Client,
+	orderId string,
+	opts ...ReplaceOption,
+) (OrderStatus, error) {
+
+	o := resolveReplaceOptions(opts)
+
+	for {
+		status, err := c.GetOrderStatus(ctx, orderId)
+		if err != nil {
+			return OrderStatus{}, err
+		}
+
+		if status.State == OrderStateFilled || status.State == OrderStateCancelled {
+			return status, nil
+		}
+
+		select {
+		case <-o.clock.After(outOfBookPollInterval):
+		case <-ctx.Done():
+			return OrderStatus{}, ctx.Err()
+		}
+	}
+}
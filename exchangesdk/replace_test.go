@@ -0,0 +1,295 @@
+package exchangesdk_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/backtest"
+	"github.com/thecodedproject/crypto/exchangesdk/mockery"
+	"github.com/thecodedproject/crypto/exchangesdk/recording"
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+type replaceOrderClient struct {
+	*mockery.Client
+}
+
+func (c replaceOrderClient) ReplaceOrder(
+	ctx context.Context,
+	orderId string,
+	order exchangesdk.Order,
+) (exchangesdk.ReplaceResult, error) {
+
+	return exchangesdk.ReplaceResult{Cancelled: true, NewOrderId: "native"}, nil
+}
+
+func TestReplaceOrderUsesReplaceOrderClient(t *testing.T) {
+
+	client := replaceOrderClient{new(mockery.Client).TSetup(t)}
+
+	res, err := exchangesdk.ReplaceOrder(context.Background(), client, "order1", exchangesdk.Order{})
+	require.NoError(t, err)
+	assert.Equal(t, "native", res.NewOrderId)
+}
+
+func TestReplaceOrderCancelsConfirmsAndPosts(t *testing.T) {
+
+	order := exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeBid,
+		Price:  decimal.NewFromInt(100),
+		Volume: decimal.NewFromInt(1),
+	}
+	cancelled := exchangesdk.OrderStatus{
+		State:          exchangesdk.OrderStateCancelled,
+		FillAmountBase: decimal.RequireFromString("0.2"),
+	}
+
+	var calls []string
+	record := func(call string) func(mock.Arguments) {
+		return func(mock.Arguments) {
+			calls = append(calls, call)
+		}
+	}
+
+	client := new(mockery.Client).TSetup(t)
+	client.On("CancelOrder", mock.Anything, "order1").
+		Return(nil).Once().Run(record("cancel"))
+	// The cancel is not yet confirmed on the first poll
+	client.On("GetOrderStatus", mock.Anything, "order1").
+		Return(exchangesdk.OrderStatus{State: exchangesdk.OrderStateInOrderBook}, nil).
+		Once().Run(record("in book"))
+	client.On("GetOrderStatus", mock.Anything, "order1").
+		Return(cancelled, nil).Once().Run(record("cancelled"))
+	client.On("PostLimitOrder", mock.Anything, order).
+		Return("order2", nil).Once().Run(record("post"))
+
+	res, err := exchangesdk.ReplaceOrder(context.Background(), client, "order1", order)
+	require.NoError(t, err)
+	assert.Equal(t, exchangesdk.ReplaceResult{
+		Cancelled:  true,
+		Original:   cancelled,
+		NewOrderId: "order2",
+	}, res)
+	assert.Equal(t, []string{"cancel", "in book", "cancelled", "post"}, calls)
+}
+
+func TestReplaceOrderWhenCancelFailsDoesNotPost(t *testing.T) {
+
+	client := new(mockery.Client).TSetup(t)
+	client.On("CancelOrder", mock.Anything, "order1").Return(assert.AnError).Once()
+
+	res, err := exchangesdk.ReplaceOrder(context.Background(), client, "order1", exchangesdk.Order{})
+	assert.Equal(t, assert.AnError, err)
+	assert.Equal(t, exchangesdk.ReplaceResult{}, res)
+}
+
+func TestReplaceOrderWhenPostFailsReportsCancel(t *testing.T) {
+
+	cancelled := exchangesdk.OrderStatus{State: exchangesdk.OrderStateCancelled}
+
+	client := new(mockery.Client).TSetup(t)
+	client.On("CancelOrder", mock.Anything, "order1").Return(nil).Once()
+	client.On("GetOrderStatus", mock.Anything, "order1").Return(cancelled, nil).Once()
+	client.On("PostLimitOrder", mock.Anything, mock.Anything).Return("", assert.AnError).Once()
+
+	res, err := exchangesdk.ReplaceOrder(context.Background(), client, "order1", exchangesdk.Order{})
+	assert.Equal(t, assert.AnError, err)
+	assert.Equal(t, exchangesdk.ReplaceResult{
+		Cancelled: true,
+		Original:  cancelled,
+	}, res)
+}
+
+func TestReplaceOrderWhenFilledDuringCancelDoesNotPost(t *testing.T) {
+
+	filled := exchangesdk.OrderStatus{
+		State:          exchangesdk.OrderStateFilled,
+		FillAmountBase: decimal.NewFromInt(1),
+	}
+
+	// The cancel is accepted, but the order is filled before the exchange
+	// removes it from the order book
+	client := new(mockery.Client).TSetup(t)
+	client.On("CancelOrder", mock.Anything, "order1").Return(nil).Once()
+	client.On("GetOrderStatus", mock.Anything, "order1").
+		Return(exchangesdk.OrderStatus{State: exchangesdk.OrderStateInOrderBook}, nil).
+		Once()
+	client.On("GetOrderStatus", mock.Anything, "order1").Return(filled, nil).Once()
+
+	res, err := exchangesdk.ReplaceOrder(context.Background(), client, "order1", exchangesdk.Order{})
+	require.NoError(t, err)
+	assert.Equal(t, exchangesdk.ReplaceResult{Original: filled}, res)
+	client.AssertNotCalled(t, "PostLimitOrder", mock.Anything, mock.Anything)
+}
+
+func TestReplaceOrderUnfilledOnlyWhenPartiallyFilledDoesNotPost(t *testing.T) {
+
+	cancelled := exchangesdk.OrderStatus{
+		State:          exchangesdk.OrderStateCancelled,
+		FillAmountBase: decimal.RequireFromString("0.2"),
+	}
+
+	// The client's native replace is not used, as it would post the
+	// replacement regardless of the original's fill
+	client := replaceOrderClient{new(mockery.Client).TSetup(t)}
+	client.On("CancelOrder", mock.Anything, "order1").Return(nil).Once()
+	client.On("GetOrderStatus", mock.Anything, "order1").Return(cancelled, nil).Once()
+
+	res, err := exchangesdk.ReplaceOrder(
+		context.Background(),
+		client,
+		"order1",
+		exchangesdk.Order{},
+		exchangesdk.ReplaceUnfilledOnly(),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, exchangesdk.ReplaceResult{
+		Cancelled: true,
+		Original:  cancelled,
+	}, res)
+	client.AssertNotCalled(t, "PostLimitOrder", mock.Anything, mock.Anything)
+}
+
+func TestReplaceOrderUnfilledOnlyWhenUnfilledPosts(t *testing.T) {
+
+	cancelled := exchangesdk.OrderStatus{State: exchangesdk.OrderStateCancelled}
+
+	client := new(mockery.Client).TSetup(t)
+	client.On("CancelOrder", mock.Anything, "order1").Return(nil).Once()
+	client.On("GetOrderStatus", mock.Anything, "order1").Return(cancelled, nil).Once()
+	client.On("PostLimitOrder", mock.Anything, mock.Anything).Return("order2", nil).Once()
+
+	res, err := exchangesdk.ReplaceOrder(
+		context.Background(),
+		client,
+		"order1",
+		exchangesdk.Order{},
+		exchangesdk.ReplaceUnfilledOnly(),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "order2", res.NewOrderId)
+}
+
+type bookEvents []recording.Event
+
+func (e *bookEvents) Next() (recording.Event, error) {
+
+	if len(*e) == 0 {
+		return recording.Event{}, io.EOF
+	}
+	next := (*e)[0]
+	*e = (*e)[1:]
+	return next, nil
+}
+
+type onOrderBook func(context.Context, exchangesdk.Client, exchangesdk.OrderBook) error
+
+func (f onOrderBook) OnOrderBook(
+	ctx context.Context,
+	c exchangesdk.Client,
+	ob exchangesdk.OrderBook,
+) error {
+
+	return f(ctx, c, ob)
+}
+
+func (f onOrderBook) OnTrade(context.Context, exchangesdk.Client, exchangesdk.OrderBookTrade) error {
+
+	return nil
+}
+
+func TestAwaitOutOfBookIsTimedByClockOfBacktest(t *testing.T) {
+
+	var es bookEvents
+	for sec := int64(1); sec <= 6; sec++ {
+		es = append(es, recording.Event{
+			Received: time.Unix(sec, 0),
+			OrderBook: &exchangesdk.OrderBook{
+				Timestamp: time.Unix(sec, 0),
+				Bids:      []exchangesdk.OrderBookOrder{{Price: 99, Volume: 10}},
+				Asks:      []exchangesdk.OrderBookOrder{{Price: 101, Volume: 10}},
+			},
+		})
+	}
+
+	clock := utiltime.NewSimulatedClock(time.Unix(0, 0))
+	var orderId string
+	var status exchangesdk.OrderStatus
+	var awaitErr error
+	var confirmedAt time.Time
+	done := make(chan struct{})
+
+	// waitForPoll waits until the cancel has been confirmed, or the next poll
+	// is waiting on the clock, so that it is made once the clock is moved to
+	// the next event
+	waitForPoll := func() error {
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			select {
+			case <-done:
+				return nil
+			default:
+			}
+			if clock.Waiters() > 0 {
+				return nil
+			}
+			time.Sleep(time.Millisecond)
+		}
+		return errors.New("cancel is not confirmed by the clock")
+	}
+
+	s := onOrderBook(func(ctx context.Context, c exchangesdk.Client, ob exchangesdk.OrderBook) error {
+		switch ob.Timestamp.Unix() {
+		case 1:
+			var err error
+			orderId, err = c.PostLimitOrder(ctx, exchangesdk.Order{
+				Type:   exchangesdk.OrderTypeBid,
+				Price:  decimal.NewFromInt(90),
+				Volume: decimal.NewFromInt(1),
+			})
+			return err
+		case 2:
+			err := c.CancelOrder(ctx, orderId)
+			if err != nil {
+				return err
+			}
+			go func() {
+				defer close(done)
+				status, awaitErr = exchangesdk.AwaitOutOfBook(
+					ctx,
+					c,
+					orderId,
+					exchangesdk.WithClock(clock),
+				)
+				confirmedAt = clock.Now()
+			}()
+		}
+		return waitForPoll()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := backtest.Run(
+		ctx,
+		&es,
+		s,
+		backtest.WithClock(clock),
+		backtest.WithLatency(1500*time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	<-done
+	require.NoError(t, awaitErr)
+	assert.Equal(t, exchangesdk.OrderStateCancelled, status.State)
+	// The cancel reaches the simulated exchange at the first event after its
+	// latency
+	assert.True(t, !confirmedAt.Before(time.Unix(4, 0)), confirmedAt)
+}
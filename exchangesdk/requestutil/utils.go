@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
 	"testing"
@@ -13,23 +12,24 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func FullPath(baseUrl string, paths ...string) *url.URL {
+// FullPath returns baseUrl with each of paths resolved against it in turn
+func FullPath(baseUrl string, paths ...string) (*url.URL, error) {
 
 	base, err := url.Parse(baseUrl)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	for _, p := range paths {
 		pUrl, err := url.Parse(p)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 
 		base = base.ResolveReference(pUrl)
 	}
 
-	return base
+	return base, nil
 }
 
 func HttpStatusError(res *http.Response, i ...interface{}) error {
@@ -0,0 +1,331 @@
+// Package router splits an order for a pair across several exchanges (venues)
+// which trade that pair, for the best all-in price.
+//
+// The latest order book of each venue is walked (as in
+// market_stats.VolumePrice), with each level's price adjusted by the venue's
+// TakerFee, and the order is filled from the best adjusted levels across all
+// venues. The resulting child orders are placed as limit orders at the worst
+// price taken on each venue, so that they take the planned depth.
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/shopspring/decimal"
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+)
+
+// ErrNotEnoughDepth is returned when the combined order books of all venues
+// cannot fill the requested volume
+var ErrNotEnoughDepth = errors.New("not enough depth across venues to route order")
+
+// Venue is an exchange on which orders can be routed
+type Venue struct {
+	Client exchangesdk.Client
+
+	// OrderBooks streams the venue's order book (e.g. from
+	// factory.NewMarketFollower); it is followed by Router.Follow
+	OrderBooks <-chan exchangesdk.OrderBook
+}
+
+// Allocation is the part of a routed order which is placed on one venue
+type Allocation struct {
+	Client   exchangesdk.Client
+	Exchange crypto.Exchange
+
+	Volume decimal.Decimal
+	// LimitPrice is the worst price taken from the venue's order book,
+	// rounded to the venue's counter precision away from the book (up for a
+	// bid, down for an ask) so that the planned depth is still taken
+	LimitPrice decimal.Decimal
+	// AveragePrice is the expected average fill price, excluding fees
+	AveragePrice decimal.Decimal
+	// Fee is the expected taker fee, in counter
+	Fee decimal.Decimal
+
+	// OrderId is the id of the placed child order (set by Route only)
+	OrderId string
+}
+
+// Plan is an order split across venues
+type Plan struct {
+	Side        exchangesdk.OrderBookSide
+	Volume      decimal.Decimal
+	Allocations []Allocation
+
+	// Unallocated is the part of Volume which is not allocated, as it is
+	// finer than the base precision of the venues
+	Unallocated decimal.Decimal
+}
+
+// AllInPrice returns the expected average price per unit of base of all
+// allocations, including fees (i.e. the cost of a bid, or the proceeds of an
+// ask, per unit)
+func (p Plan) AllInPrice() decimal.Decimal {
+
+	var volume, counter decimal.Decimal
+	for _, a := range p.Allocations {
+		volume = volume.Add(a.Volume)
+		notional := a.AveragePrice.Mul(a.Volume)
+		if p.Side == exchangesdk.OrderBookSideBid {
+			counter = counter.Add(notional).Add(a.Fee)
+		} else {
+			counter = counter.Add(notional).Sub(a.Fee)
+		}
+	}
+
+	if volume.IsZero() {
+		return decimal.Decimal{}
+	}
+	return counter.Div(volume)
+}
+
+// Router routes orders across a set of venues
+type Router struct {
+	venues []Venue
+
+	mu    sync.Mutex
+	books []*exchangesdk.OrderBook
+}
+
+func New(venues ...Venue) *Router {
+
+	return &Router{
+		venues: venues,
+		books:  make([]*exchangesdk.OrderBook, len(venues)),
+	}
+}
+
+// Follow keeps the latest order book of each venue which has an OrderBooks
+// stream, until the stream is closed or ctx is cancelled
+func (r *Router) Follow(ctx context.Context, wg *sync.WaitGroup) {
+
+	for i, v := range r.venues {
+		if v.OrderBooks == nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, books <-chan exchangesdk.OrderBook) {
+			defer wg.Done()
+
+			for {
+				select {
+				case ob, ok := <-books:
+					if !ok {
+						return
+					}
+					r.UpdateOrderBook(i, ob)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(i, v.OrderBooks)
+	}
+}
+
+// UpdateOrderBook sets the latest order book of the venue at index venue
+func (r *Router) UpdateOrderBook(venue int, ob exchangesdk.OrderBook) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.books[venue] = &ob
+}
+
+// volumeTolerance is the remaining volume, when walking the order books,
+// which is treated as zero (to allow for float rounding errors)
+const volumeTolerance = 1e-9
+
+// level is a price level of one venue's order book
+type level struct {
+	venue          int
+	price          float64
+	effectivePrice float64
+	volume         float64
+}
+
+// Plan splits an order of volume on side (bid to buy, ask to sell) across
+// the venues' latest order books, for the best all-in price. Venues without
+// an order book yet are not used.
+// Allocations are truncated to their venue's base precision, and the volume
+// which this leaves over is allocated to the venue with the best price (see
+// Plan.Unallocated for any which is finer than its precision).
+func (r *Router) Plan(
+	side exchangesdk.OrderBookSide,
+	volume decimal.Decimal,
+) (Plan, error) {
+
+	if side != exchangesdk.OrderBookSideBid && side != exchangesdk.OrderBookSideAsk {
+		return Plan{}, errors.New("routed order side must be bid or ask")
+	}
+	if !volume.IsPositive() {
+		return Plan{}, errors.New("routed order volume must be positive")
+	}
+
+	levels := r.levels(side)
+
+	remaining, _ := volume.Float64()
+	taken := make([]float64, len(r.venues))
+	notional := make([]float64, len(r.venues))
+	worst := make([]float64, len(r.venues))
+	for _, l := range levels {
+		if remaining <= volumeTolerance {
+			break
+		}
+
+		v := l.volume
+		if v > remaining {
+			v = remaining
+		}
+		taken[l.venue] += v
+		notional[l.venue] += v * l.price
+		worst[l.venue] = l.price
+		remaining -= v
+	}
+
+	if remaining > volumeTolerance {
+		return Plan{}, ErrNotEnoughDepth
+	}
+
+	allocated := make([]decimal.Decimal, len(r.venues))
+	var total decimal.Decimal
+	for i, v := range r.venues {
+		allocated[i] = decimal.NewFromFloat(taken[i]).Truncate(v.Client.BasePrecision())
+		total = total.Add(allocated[i])
+	}
+
+	unallocated := decimal.Max(volume.Sub(total), decimal.Zero)
+	best := levels[0].venue
+	extra := unallocated.Truncate(r.venues[best].Client.BasePrecision())
+	allocated[best] = allocated[best].Add(extra)
+
+	plan := Plan{
+		Side:        side,
+		Volume:      volume,
+		Unallocated: unallocated.Sub(extra),
+	}
+	for i, v := range r.venues {
+		client := v.Client
+		if !allocated[i].IsPositive() {
+			continue
+		}
+
+		average := decimal.NewFromFloat(notional[i] / taken[i])
+		plan.Allocations = append(plan.Allocations, Allocation{
+			Client:       client,
+			Exchange:     client.Exchange(),
+			Volume:       allocated[i],
+			LimitPrice:   limitPrice(side, worst[i], client.CounterPrecision()),
+			AveragePrice: average,
+			Fee:          average.Mul(allocated[i]).Mul(client.TakerFee()),
+		})
+	}
+
+	return plan, nil
+}
+
+// limitPrice returns price rounded to precision away from the order book;
+// up for a bid and down for an ask
+func limitPrice(
+	side exchangesdk.OrderBookSide,
+	price float64,
+	precision int32,
+) decimal.Decimal {
+
+	p := decimal.NewFromFloat(price).Shift(precision)
+	if side == exchangesdk.OrderBookSideBid {
+		p = p.Ceil()
+	} else {
+		p = p.Floor()
+	}
+	return p.Shift(-precision)
+}
+
+// Route plans an order (see Plan) and places a limit order for each
+// allocation on its venue.
+// If placing an order fails then the remaining allocations are still placed,
+// and the plan is returned (with the OrderId of each placed allocation)
+// along with the first error.
+func (r *Router) Route(
+	ctx context.Context,
+	side exchangesdk.OrderBookSide,
+	volume decimal.Decimal,
+) (Plan, error) {
+
+	plan, err := r.Plan(side, volume)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	orderType := exchangesdk.OrderTypeBid
+	if side == exchangesdk.OrderBookSideAsk {
+		orderType = exchangesdk.OrderTypeAsk
+	}
+
+	var firstErr error
+	for i, a := range plan.Allocations {
+		id, err := a.Client.PostLimitOrder(ctx, exchangesdk.Order{
+			Type:   orderType,
+			Price:  a.LimitPrice,
+			Volume: a.Volume,
+		})
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %w", a.Exchange.Provider, err)
+			}
+			continue
+		}
+		plan.Allocations[i].OrderId = id
+	}
+
+	return plan, firstErr
+}
+
+// levels returns the levels of the venues' order books which an order on
+// side would take, best (by fee adjusted price) first
+func (r *Router) levels(side exchangesdk.OrderBookSide) []level {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var levels []level
+	for i, ob := range r.books {
+		if ob == nil {
+			continue
+		}
+
+		fee, _ := r.venues[i].Client.TakerFee().Float64()
+		orders := ob.Asks
+		adjust := 1 + fee
+		if side == exchangesdk.OrderBookSideAsk {
+			orders = ob.Bids
+			adjust = 1 - fee
+		}
+
+		for _, o := range orders {
+			if o.Volume <= 0 {
+				continue
+			}
+			levels = append(levels, level{
+				venue:          i,
+				price:          o.Price,
+				effectivePrice: o.Price * adjust,
+				volume:         o.Volume,
+			})
+		}
+	}
+
+	sort.SliceStable(levels, func(i, j int) bool {
+		if side == exchangesdk.OrderBookSideBid {
+			return levels[i].effectivePrice < levels[j].effectivePrice
+		}
+		return levels[i].effectivePrice > levels[j].effectivePrice
+	})
+	return levels
+}
@@ -0,0 +1,350 @@
+package router_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/mockery"
+	"github.com/thecodedproject/crypto/exchangesdk/router"
+	"github.com/thecodedproject/crypto/exchangesdk/simulator"
+)
+
+func D(f float64) decimal.Decimal {
+
+	return decimal.NewFromFloat(f)
+}
+
+var (
+	luno = crypto.Exchange{
+		Provider: crypto.ApiProviderLuno,
+		Pair:     crypto.PairBTCEUR,
+	}
+	binance = crypto.Exchange{
+		Provider: crypto.ApiProviderBinance,
+		Pair:     crypto.PairBTCEUR,
+	}
+)
+
+// lunoBook is cheaper at the top of book, but Binance is cheaper after fees
+// once the top level is taken
+var (
+	lunoBook = exchangesdk.OrderBook{
+		Bids: []exchangesdk.OrderBookOrder{{Price: 99, Volume: 1}, {Price: 98.5, Volume: 1}},
+		Asks: []exchangesdk.OrderBookOrder{{Price: 100, Volume: 1}, {Price: 100.5, Volume: 1}},
+	}
+	binanceBook = exchangesdk.OrderBook{
+		Bids: []exchangesdk.OrderBookOrder{{Price: 99.2, Volume: 1}},
+		Asks: []exchangesdk.OrderBookOrder{{Price: 99.9, Volume: 1}},
+	}
+)
+
+func newVenues() (*simulator.Exchange, *simulator.Exchange) {
+
+	lunoSim := simulator.New(luno)
+	binanceSim := simulator.New(
+		binance,
+		simulator.WithFees(decimal.Zero, D(0.002)),
+	)
+	return lunoSim, binanceSim
+}
+
+type expectedAllocation struct {
+	exchange     crypto.Exchange
+	volume       float64
+	limitPrice   float64
+	averagePrice float64
+	fee          float64
+}
+
+func assertPlan(
+	t *testing.T,
+	expected []expectedAllocation,
+	plan router.Plan,
+) {
+
+	require.Len(t, plan.Allocations, len(expected))
+	for i, e := range expected {
+		a := plan.Allocations[i]
+		assert.Equal(t, e.exchange, a.Exchange, i)
+		assert.True(t, D(e.volume).Equal(a.Volume), "%d volume: %s", i, a.Volume)
+		assert.True(t, D(e.limitPrice).Equal(a.LimitPrice), "%d limit price: %s", i, a.LimitPrice)
+		assert.True(t, D(e.averagePrice).Equal(a.AveragePrice), "%d average price: %s", i, a.AveragePrice)
+		assert.True(t, D(e.fee).Equal(a.Fee), "%d fee: %s", i, a.Fee)
+	}
+}
+
+func TestPlan(t *testing.T) {
+
+	testCases := []struct {
+		name        string
+		side        exchangesdk.OrderBookSide
+		volume      float64
+		noBinance   bool
+		expected    []expectedAllocation
+		expectedErr error
+	}{
+		{
+			name:   "bid within best venue after fees",
+			side:   exchangesdk.OrderBookSideBid,
+			volume: 0.5,
+			expected: []expectedAllocation{
+				{exchange: luno, volume: 0.5, limitPrice: 100, averagePrice: 100},
+			},
+		},
+		{
+			name:   "bid split across venues",
+			side:   exchangesdk.OrderBookSideBid,
+			volume: 1.5,
+			expected: []expectedAllocation{
+				{exchange: luno, volume: 1, limitPrice: 100, averagePrice: 100},
+				{exchange: binance, volume: 0.5, limitPrice: 99.9, averagePrice: 99.9, fee: 0.0999},
+			},
+		},
+		{
+			name:   "bid walks several levels of a venue",
+			side:   exchangesdk.OrderBookSideBid,
+			volume: 2.5,
+			expected: []expectedAllocation{
+				{exchange: luno, volume: 1.5, limitPrice: 100.5, averagePrice: 100.16666666666667},
+				{exchange: binance, volume: 1, limitPrice: 99.9, averagePrice: 99.9, fee: 0.1998},
+			},
+		},
+		{
+			name:   "ask split across venues",
+			side:   exchangesdk.OrderBookSideAsk,
+			volume: 1.5,
+			expected: []expectedAllocation{
+				{exchange: luno, volume: 0.5, limitPrice: 99, averagePrice: 99},
+				{exchange: binance, volume: 1, limitPrice: 99.2, averagePrice: 99.2, fee: 0.1984},
+			},
+		},
+		{
+			name:      "venue without order book is not used",
+			side:      exchangesdk.OrderBookSideBid,
+			volume:    1.5,
+			noBinance: true,
+			expected: []expectedAllocation{
+				{exchange: luno, volume: 1.5, limitPrice: 100.5, averagePrice: 100.16666666666667},
+			},
+		},
+		{
+			name:        "not enough depth",
+			side:        exchangesdk.OrderBookSideAsk,
+			volume:      3.5,
+			expectedErr: router.ErrNotEnoughDepth,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+
+			lunoSim, binanceSim := newVenues()
+			r := router.New(
+				router.Venue{Client: lunoSim},
+				router.Venue{Client: binanceSim},
+			)
+			r.UpdateOrderBook(0, lunoBook)
+			if !test.noBinance {
+				r.UpdateOrderBook(1, binanceBook)
+			}
+
+			plan, err := r.Plan(test.side, D(test.volume))
+			if test.expectedErr != nil {
+				assert.Equal(t, test.expectedErr, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, test.side, plan.Side)
+			assertPlan(t, test.expected, plan)
+		})
+	}
+}
+
+func TestPlanInvalidOrderReturnsError(t *testing.T) {
+
+	lunoSim, _ := newVenues()
+	r := router.New(router.Venue{Client: lunoSim})
+	r.UpdateOrderBook(0, lunoBook)
+
+	_, err := r.Plan(exchangesdk.OrderBookSideUnknown, D(1))
+	assert.Error(t, err)
+
+	_, err = r.Plan(exchangesdk.OrderBookSideBid, decimal.Zero)
+	assert.Error(t, err)
+}
+
+func TestPlanRoundsLimitPriceAwayFromBook(t *testing.T) {
+
+	sim := simulator.New(luno, simulator.WithPrecision(2, 6))
+	r := router.New(router.Venue{Client: sim})
+	r.UpdateOrderBook(0, exchangesdk.OrderBook{
+		Bids: []exchangesdk.OrderBookOrder{{Price: 99.987, Volume: 1}},
+		Asks: []exchangesdk.OrderBookOrder{{Price: 100.123, Volume: 1}},
+	})
+
+	bid, err := r.Plan(exchangesdk.OrderBookSideBid, D(1))
+	require.NoError(t, err)
+	require.Len(t, bid.Allocations, 1)
+	assert.True(t, D(100.13).Equal(bid.Allocations[0].LimitPrice), bid.Allocations[0].LimitPrice.String())
+
+	ask, err := r.Plan(exchangesdk.OrderBookSideAsk, D(1))
+	require.NoError(t, err)
+	require.Len(t, ask.Allocations, 1)
+	assert.True(t, D(99.98).Equal(ask.Allocations[0].LimitPrice), ask.Allocations[0].LimitPrice.String())
+}
+
+func TestPlanAllocatesVolumeLeftByPrecisionToBestVenue(t *testing.T) {
+
+	lunoSim := simulator.New(luno, simulator.WithPrecision(2, 2))
+	binanceSim := simulator.New(binance, simulator.WithPrecision(2, 2))
+	r := router.New(
+		router.Venue{Client: lunoSim},
+		router.Venue{Client: binanceSim},
+	)
+	r.UpdateOrderBook(0, exchangesdk.OrderBook{
+		Asks: []exchangesdk.OrderBookOrder{{Price: 100, Volume: 0.505}},
+	})
+	r.UpdateOrderBook(1, exchangesdk.OrderBook{
+		Asks: []exchangesdk.OrderBookOrder{{Price: 100.1, Volume: 1}},
+	})
+
+	// The 0.505 taken from luno and 0.495 from binance are truncated to 0.5
+	// and 0.49, and the 0.01 left is given to luno
+	plan, err := r.Plan(exchangesdk.OrderBookSideBid, D(1))
+	require.NoError(t, err)
+	assertPlan(t, []expectedAllocation{
+		{exchange: luno, volume: 0.51, limitPrice: 100, averagePrice: 100},
+		{exchange: binance, volume: 0.49, limitPrice: 100.1, averagePrice: 100.1},
+	}, plan)
+	assert.True(t, plan.Unallocated.IsZero())
+}
+
+func TestPlanReturnsVolumeFinerThanPrecisionAsUnallocated(t *testing.T) {
+
+	sim := simulator.New(luno, simulator.WithPrecision(2, 3))
+	r := router.New(router.Venue{Client: sim})
+	r.UpdateOrderBook(0, lunoBook)
+
+	plan, err := r.Plan(exchangesdk.OrderBookSideBid, D(0.5005))
+	require.NoError(t, err)
+	assertPlan(t, []expectedAllocation{
+		{exchange: luno, volume: 0.5, limitPrice: 100, averagePrice: 100},
+	}, plan)
+	assert.True(t, D(0.0005).Equal(plan.Unallocated), plan.Unallocated.String())
+}
+
+func TestAllInPriceIncludesFees(t *testing.T) {
+
+	lunoSim, binanceSim := newVenues()
+	r := router.New(
+		router.Venue{Client: lunoSim},
+		router.Venue{Client: binanceSim},
+	)
+	r.UpdateOrderBook(0, lunoBook)
+	r.UpdateOrderBook(1, binanceBook)
+
+	bid, err := r.Plan(exchangesdk.OrderBookSideBid, D(2))
+	require.NoError(t, err)
+	// (100 + 99.9 + 0.1998) / 2
+	assert.True(t, D(100.0499).Equal(bid.AllInPrice()), bid.AllInPrice().String())
+
+	ask, err := r.Plan(exchangesdk.OrderBookSideAsk, D(2))
+	require.NoError(t, err)
+	// (99 + 99.2 - 0.1984) / 2
+	assert.True(t, D(99.0008).Equal(ask.AllInPrice()), ask.AllInPrice().String())
+}
+
+func TestRoutePlacesChildOrders(t *testing.T) {
+
+	ctx := context.Background()
+	lunoSim, binanceSim := newVenues()
+	lunoSim.UpdateOrderBook(lunoBook)
+	binanceSim.UpdateOrderBook(binanceBook)
+
+	r := router.New(
+		router.Venue{Client: lunoSim},
+		router.Venue{Client: binanceSim},
+	)
+	r.UpdateOrderBook(0, lunoBook)
+	r.UpdateOrderBook(1, binanceBook)
+
+	plan, err := r.Route(ctx, exchangesdk.OrderBookSideBid, D(1.5))
+	require.NoError(t, err)
+	require.Len(t, plan.Allocations, 2)
+
+	for _, a := range plan.Allocations {
+		require.NotEmpty(t, a.OrderId)
+
+		status, err := a.Client.GetOrderStatus(ctx, a.OrderId)
+		require.NoError(t, err)
+		assert.Equal(t, exchangesdk.OrderStateFilled, status.State)
+		assert.True(t, a.AveragePrice.Mul(a.Volume).Equal(status.FillAmountCounter))
+	}
+}
+
+func TestRoutePlacesRemainingOrdersAfterError(t *testing.T) {
+
+	ctx := context.Background()
+	lunoSim, _ := newVenues()
+	lunoSim.UpdateOrderBook(lunoBook)
+
+	failing := new(mockery.Client).TSetup(t)
+	failing.On("Exchange").Return(binance)
+	failing.On("TakerFee").Return(decimal.Zero)
+	failing.On("BasePrecision").Return(int32(6))
+	failing.On("CounterPrecision").Return(int32(2))
+	failing.On("PostLimitOrder", mock.Anything, mock.Anything).
+		Return("", errors.New("rejected"))
+
+	r := router.New(
+		router.Venue{Client: failing},
+		router.Venue{Client: lunoSim},
+	)
+	r.UpdateOrderBook(0, binanceBook)
+	r.UpdateOrderBook(1, lunoBook)
+
+	plan, err := r.Route(ctx, exchangesdk.OrderBookSideBid, D(1.5))
+	assert.EqualError(t, err, "binance: rejected")
+
+	require.Len(t, plan.Allocations, 2)
+	assert.Empty(t, plan.Allocations[0].OrderId)
+	assert.NotEmpty(t, plan.Allocations[1].OrderId)
+}
+
+func TestFollowUsesLatestOrderBooks(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+
+	lunoSim, binanceSim := newVenues()
+	lunoBooks := make(chan exchangesdk.OrderBook)
+	binanceBooks := make(chan exchangesdk.OrderBook)
+
+	r := router.New(
+		router.Venue{Client: lunoSim, OrderBooks: lunoBooks},
+		router.Venue{Client: binanceSim, OrderBooks: binanceBooks},
+	)
+	r.Follow(ctx, &wg)
+
+	// A send only completes once the previous book has been received, so
+	// sending each book twice ensures that the first has been applied
+	for i := 0; i < 2; i++ {
+		lunoBooks <- lunoBook
+		binanceBooks <- binanceBook
+	}
+
+	plan, err := r.Plan(exchangesdk.OrderBookSideBid, D(1.5))
+	require.NoError(t, err)
+	require.Len(t, plan.Allocations, 2)
+}
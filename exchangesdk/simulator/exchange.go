@@ -0,0 +1,622 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+const (
+	tradesPageSize = 100
+)
+
+// Exchange is a simulated exchange which matches orders against a market that
+// is supplied via UpdateOrderBook and AddTrade (e.g. from a market follower
+// or a replayed recording).
+//
+// Orders which cross the market when placed (or triggered) are filled as a
+// taker by walking the opposite side of the order book up to their limit
+// price; any remainder rests in the book.
+// Resting orders are filled as a maker, with price-time priority:
+//  - a resting order joins the back of the queue at its price level, so market
+//    trades at that price must first consume the volume which was ahead of it
+//  - a market trade through a resting order's price, or an order book which
+//    has moved through it, fills the order in full.
+// Stop limit orders are triggered when a market trade reaches their stop price.
+type Exchange struct {
+	mu sync.Mutex
+
+	exchange         crypto.Exchange
+	makerFee         decimal.Decimal
+	takerFee         decimal.Decimal
+	counterPrecision int32
+	basePrecision    int32
+
+	nextOrderId int64
+	orders      map[string]*order
+	trades      []exchangesdk.Trade
+	// open is the orders which are in the order book or awaiting their
+	// trigger; only these are matched against the market
+	open map[string]*order
+
+	book exchangesdk.OrderBook
+	// consumed is the volume at each price of the current book which has been
+	// taken by our taker orders, so that it cannot be taken twice
+	consumed       map[float64]float64
+	lastTradePrice float64
+	marketTime     time.Time
+	// clock tells the time until the market has supplied one
+	clock utiltime.Clock
+
+	updateSubs map[*updateSubscriber]bool
+}
+
+type order struct {
+	id  string
+	seq int64
+
+	side       exchangesdk.OrderBookSide
+	limitPrice decimal.Decimal
+	stopPrice  decimal.Decimal
+	volume     decimal.Decimal
+
+	// queueAhead is the market volume ahead of this order at its price level
+	queueAhead float64
+
+	state             exchangesdk.OrderState
+	fillAmountBase    decimal.Decimal
+	fillAmountCounter decimal.Decimal
+}
+
+var (
+	_ exchangesdk.Client           = (*Exchange)(nil)
+	_ exchangesdk.OpenOrdersClient = (*Exchange)(nil)
+)
+
+type Option func(*Exchange)
+
+// WithFees sets the maker and taker fees (as ratios) charged on fills.
+// Fees are charged in the asset received; i.e. in base for bids and in
+// counter for asks.
+func WithFees(maker, taker decimal.Decimal) Option {
+
+	return func(e *Exchange) {
+		e.makerFee = maker
+		e.takerFee = taker
+	}
+}
+
+func WithPrecision(counter, base int32) Option {
+
+	return func(e *Exchange) {
+		e.counterPrecision = counter
+		e.basePrecision = base
+	}
+}
+
+// WithClock sets the clock which timestamps fills and order updates until
+// the market has supplied a time (via an order book or trade timestamp); by
+// default the system clock is used
+func WithClock(clock utiltime.Clock) Option {
+
+	return func(e *Exchange) {
+		e.clock = clock
+	}
+}
+
+func New(exchange crypto.Exchange, opts ...Option) *Exchange {
+
+	e := &Exchange{
+		exchange:         exchange,
+		counterPrecision: 2,
+		basePrecision:    6,
+		clock:            utiltime.Real,
+		orders:           make(map[string]*order),
+		open:             make(map[string]*order),
+		consumed:         make(map[float64]float64),
+		updateSubs:       make(map[*updateSubscriber]bool),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// UpdateOrderBook sets the current market order book, filling any resting
+// orders which it has moved through
+func (e *Exchange) UpdateOrderBook(ob exchangesdk.OrderBook) {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.book = ob
+	e.consumed = make(map[float64]float64)
+	e.setMarketTime(ob.Timestamp)
+
+	for _, o := range e.ordersByPriority() {
+		if o.state != exchangesdk.OrderStateInOrderBook {
+			continue
+		}
+
+		if e.bookCrosses(o) {
+			e.fill(o, o.limitPrice, e.remaining(o), true)
+			continue
+		}
+
+		// Volume ahead can only be removed (by cancels or trades), not added
+		levelVolume := sameSideVolumeAt(&e.book, o.side, o.limitPrice)
+		if levelVolume < o.queueAhead {
+			o.queueAhead = levelVolume
+		}
+	}
+}
+
+// AddTrade records a market trade, triggering any stop orders at the trade
+// price and filling any resting orders which it reaches
+func (e *Exchange) AddTrade(t exchangesdk.OrderBookTrade) {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.lastTradePrice = t.Price
+	e.setMarketTime(t.Timestamp)
+
+	tradePrice := decimal.NewFromFloat(t.Price)
+	orders := e.ordersByPriority()
+
+	for _, o := range orders {
+		if o.state == exchangesdk.OrderStateAwaitingTrigger && stopTriggered(o, tradePrice) {
+			o.state = exchangesdk.OrderStateInOrderBook
+			e.publishStateChange(o)
+			o.queueAhead = sameSideVolumeAt(&e.book, o.side, o.limitPrice)
+			e.takeFromBook(o)
+		}
+	}
+
+	// Volume of the trade left to fill orders at the trade price, and the
+	// market volume which it has consumed at that price on each side
+	tradeVolume := map[exchangesdk.OrderBookSide]float64{
+		exchangesdk.OrderBookSideBid: t.Volume,
+		exchangesdk.OrderBookSideAsk: t.Volume,
+	}
+	marketConsumed := map[exchangesdk.OrderBookSide]float64{}
+
+	for _, o := range orders {
+		if o.state != exchangesdk.OrderStateInOrderBook {
+			continue
+		}
+		if t.MakerSide != exchangesdk.OrderBookSideUnknown && t.MakerSide != o.side {
+			continue
+		}
+
+		if tradedThrough(o, tradePrice) {
+			e.fill(o, o.limitPrice, e.remaining(o), true)
+			continue
+		}
+
+		if !o.limitPrice.Equal(tradePrice) {
+			continue
+		}
+
+		ahead := o.queueAhead - marketConsumed[o.side]
+		if ahead < 0 {
+			ahead = 0
+		}
+		consume := ahead
+		if tradeVolume[o.side] < consume {
+			consume = tradeVolume[o.side]
+		}
+		tradeVolume[o.side] -= consume
+		marketConsumed[o.side] += consume
+		o.queueAhead = ahead - consume
+
+		fillVolume := decimal.Min(e.remaining(o), decimal.NewFromFloat(tradeVolume[o.side]))
+		if fillVolume.IsPositive() {
+			e.fill(o, o.limitPrice, fillVolume, true)
+			f, _ := fillVolume.Float64()
+			tradeVolume[o.side] -= f
+		}
+	}
+}
+
+func (e *Exchange) setMarketTime(t time.Time) {
+
+	if !t.IsZero() {
+		e.marketTime = t
+	}
+}
+
+func (e *Exchange) now() time.Time {
+
+	if e.marketTime.IsZero() {
+		return e.clock.Now()
+	}
+	return e.marketTime
+}
+
+// ordersByPriority returns the open orders grouped by side and sorted by
+// price-time priority; best priced first, and oldest first within a price
+func (e *Exchange) ordersByPriority() []*order {
+
+	orders := make([]*order, 0, len(e.open))
+	for _, o := range e.open {
+		orders = append(orders, o)
+	}
+
+	sort.Slice(orders, func(i, j int) bool {
+		a, b := orders[i], orders[j]
+		if a.side != b.side {
+			return a.side < b.side
+		}
+		if !a.limitPrice.Equal(b.limitPrice) {
+			if a.side == exchangesdk.OrderBookSideBid {
+				return a.limitPrice.GreaterThan(b.limitPrice)
+			}
+			return a.limitPrice.LessThan(b.limitPrice)
+		}
+		return a.seq < b.seq
+	})
+
+	return orders
+}
+
+func stopTriggered(o *order, price decimal.Decimal) bool {
+
+	if o.side == exchangesdk.OrderBookSideAsk {
+		return price.LessThanOrEqual(o.stopPrice)
+	}
+	return price.GreaterThanOrEqual(o.stopPrice)
+}
+
+func tradedThrough(o *order, price decimal.Decimal) bool {
+
+	if o.side == exchangesdk.OrderBookSideBid {
+		return price.LessThan(o.limitPrice)
+	}
+	return price.GreaterThan(o.limitPrice)
+}
+
+func (e *Exchange) bookCrosses(o *order) bool {
+
+	switch o.side {
+	case exchangesdk.OrderBookSideBid:
+		return len(e.book.Asks) > 0 &&
+			decimal.NewFromFloat(e.book.Asks[0].Price).LessThanOrEqual(o.limitPrice)
+	case exchangesdk.OrderBookSideAsk:
+		return len(e.book.Bids) > 0 &&
+			decimal.NewFromFloat(e.book.Bids[0].Price).GreaterThanOrEqual(o.limitPrice)
+	default:
+		return false
+	}
+}
+
+// takeFromBook fills as much of o as possible as a taker against the
+// opposite side of the order book, up to the order's limit price
+func (e *Exchange) takeFromBook(o *order) {
+
+	levels := e.book.Asks
+	if o.side == exchangesdk.OrderBookSideAsk {
+		levels = e.book.Bids
+	}
+
+	for _, level := range levels {
+		if e.remaining(o).IsZero() {
+			return
+		}
+
+		price := decimal.NewFromFloat(level.Price)
+		if (o.side == exchangesdk.OrderBookSideBid && price.GreaterThan(o.limitPrice)) ||
+			(o.side == exchangesdk.OrderBookSideAsk && price.LessThan(o.limitPrice)) {
+			return
+		}
+
+		available := level.Volume - e.consumed[level.Price]
+		if available <= 0 {
+			continue
+		}
+
+		fillVolume := decimal.Min(e.remaining(o), decimal.NewFromFloat(available))
+		e.fill(o, price, fillVolume, false)
+
+		f, _ := fillVolume.Float64()
+		e.consumed[level.Price] += f
+	}
+}
+
+func sameSideVolumeAt(
+	ob *exchangesdk.OrderBook,
+	side exchangesdk.OrderBookSide,
+	price decimal.Decimal,
+) float64 {
+
+	levels := ob.Bids
+	if side == exchangesdk.OrderBookSideAsk {
+		levels = ob.Asks
+	}
+
+	p, _ := price.Float64()
+	var volume float64
+	for _, level := range levels {
+		if level.Price == p {
+			volume += level.Volume
+		}
+	}
+	return volume
+}
+
+func (e *Exchange) remaining(o *order) decimal.Decimal {
+
+	return o.volume.Sub(o.fillAmountBase)
+}
+
+func (e *Exchange) fill(
+	o *order,
+	price decimal.Decimal,
+	volume decimal.Decimal,
+	isMaker bool,
+) {
+
+	if !volume.IsPositive() {
+		return
+	}
+
+	o.fillAmountBase = o.fillAmountBase.Add(volume)
+	o.fillAmountCounter = o.fillAmountCounter.Add(volume.Mul(price))
+	filled := !e.remaining(o).IsPositive()
+	if filled {
+		o.state = exchangesdk.OrderStateFilled
+		delete(e.open, o.id)
+	}
+
+	feeRate := e.takerFee
+	if isMaker {
+		feeRate = e.makerFee
+	}
+
+	trade := exchangesdk.Trade{
+		OrderId:   o.id,
+		Timestamp: e.now(),
+		Price:     price,
+		Volume:    volume,
+		Type:      sideToOrderType(o.side),
+	}
+	if o.side == exchangesdk.OrderBookSideBid {
+		trade.BaseFee = volume.Mul(feeRate)
+	} else {
+		trade.CounterFee = volume.Mul(price).Mul(feeRate)
+	}
+
+	e.trades = append(e.trades, trade)
+
+	e.publishUpdate(exchangesdk.OrderUpdate{
+		Type:              exchangesdk.OrderUpdateTypeFill,
+		OrderId:           o.id,
+		Timestamp:         trade.Timestamp,
+		State:             o.state,
+		FillAmountBase:    o.fillAmountBase,
+		FillAmountCounter: o.fillAmountCounter,
+		FillPrice:         price,
+		FillVolume:        volume,
+		BaseFee:           trade.BaseFee,
+		CounterFee:        trade.CounterFee,
+	})
+	if filled {
+		e.publishStateChange(o)
+	}
+}
+
+func (e *Exchange) Exchange() crypto.Exchange {
+
+	return e.exchange
+}
+
+// LatestPrice returns the price of the last market trade, or the mid price
+// of the order book if there have been no trades
+func (e *Exchange) LatestPrice(ctx context.Context) (decimal.Decimal, error) {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.lastTradePrice != 0 {
+		return decimal.NewFromFloat(e.lastTradePrice), nil
+	}
+
+	if len(e.book.Bids) == 0 || len(e.book.Asks) == 0 {
+		return decimal.Decimal{}, fmt.Errorf("no market data to determine latest price")
+	}
+
+	return decimal.NewFromFloat((e.book.Bids[0].Price + e.book.Asks[0].Price) / 2), nil
+}
+
+func (e *Exchange) PostLimitOrder(ctx context.Context, o exchangesdk.Order) (string, error) {
+
+	side, err := orderTypeToSide(o.Type)
+	if err != nil {
+		return "", err
+	}
+
+	return e.addOrder(&order{
+		side:       side,
+		limitPrice: o.Price,
+		volume:     o.Volume,
+		state:      exchangesdk.OrderStateInOrderBook,
+	})
+}
+
+func (e *Exchange) PostStopLimitOrder(ctx context.Context, o exchangesdk.StopLimitOrder) (string, error) {
+
+	if o.Side != exchangesdk.OrderBookSideBid && o.Side != exchangesdk.OrderBookSideAsk {
+		return "", fmt.Errorf("unknown stop limit order side `%s`", o.Side)
+	}
+
+	return e.addOrder(&order{
+		side:       o.Side,
+		limitPrice: o.LimitPrice,
+		stopPrice:  o.StopPrice,
+		volume:     o.Volume,
+		state:      exchangesdk.OrderStateAwaitingTrigger,
+	})
+}
+
+func (e *Exchange) addOrder(o *order) (string, error) {
+
+	if !o.volume.IsPositive() {
+		return "", fmt.Errorf("order volume must be positive; got %s", o.volume)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.nextOrderId++
+	o.id = fmt.Sprintf("sim-%d", e.nextOrderId)
+	o.seq = e.nextOrderId
+	e.orders[o.id] = o
+	e.open[o.id] = o
+	e.publishStateChange(o)
+
+	if o.state == exchangesdk.OrderStateInOrderBook {
+		o.queueAhead = sameSideVolumeAt(&e.book, o.side, o.limitPrice)
+		e.takeFromBook(o)
+	}
+
+	return o.id, nil
+}
+
+func (e *Exchange) CancelOrder(ctx context.Context, orderId string) error {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	o, ok := e.orders[orderId]
+	if !ok {
+		return fmt.Errorf("no such order `%s`", orderId)
+	}
+
+	if o.state == exchangesdk.OrderStateFilled || o.state == exchangesdk.OrderStateCancelled {
+		return fmt.Errorf("cannot cancel order `%s` in state %s", orderId, o.state)
+	}
+
+	o.state = exchangesdk.OrderStateCancelled
+	delete(e.open, o.id)
+	e.publishStateChange(o)
+	return nil
+}
+
+func (e *Exchange) GetOrderStatus(
+	ctx context.Context,
+	orderId string,
+) (exchangesdk.OrderStatus, error) {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	o, ok := e.orders[orderId]
+	if !ok {
+		return exchangesdk.OrderStatus{}, fmt.Errorf("no such order `%s`", orderId)
+	}
+
+	return exchangesdk.OrderStatus{
+		State:             o.state,
+		Type:              sideToOrderType(o.side),
+		FillAmountBase:    o.fillAmountBase,
+		FillAmountCounter: o.fillAmountCounter,
+	}, nil
+}
+
+// OpenOrders returns the orders which are in the order book or awaiting their
+// trigger; bids then asks, each in price-time priority
+func (e *Exchange) OpenOrders(ctx context.Context) ([]exchangesdk.OpenOrder, error) {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	open := []exchangesdk.OpenOrder{}
+	for _, o := range e.ordersByPriority() {
+		if o.state != exchangesdk.OrderStateInOrderBook &&
+			o.state != exchangesdk.OrderStateAwaitingTrigger {
+			continue
+		}
+		open = append(open, exchangesdk.OpenOrder{
+			Id:             o.id,
+			Side:           o.side,
+			State:          o.state,
+			LimitPrice:     o.limitPrice,
+			Volume:         o.volume,
+			FillAmountBase: o.fillAmountBase,
+		})
+	}
+	return open, nil
+}
+
+// GetTrades returns our fills, oldest first, in pages of 100 (starting from
+// page 1)
+func (e *Exchange) GetTrades(ctx context.Context, page int64) ([]exchangesdk.Trade, error) {
+
+	if page < 1 {
+		return nil, fmt.Errorf("Cannot get page less than 1; trying to get page %d", page)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	start := (page - 1) * tradesPageSize
+	if start >= int64(len(e.trades)) {
+		return []exchangesdk.Trade{}, nil
+	}
+
+	end := start + tradesPageSize
+	if end > int64(len(e.trades)) {
+		end = int64(len(e.trades))
+	}
+
+	trades := make([]exchangesdk.Trade, end-start)
+	copy(trades, e.trades[start:end])
+	return trades, nil
+}
+
+func (e *Exchange) MakerFee() decimal.Decimal {
+
+	return e.makerFee
+}
+
+func (e *Exchange) TakerFee() decimal.Decimal {
+
+	return e.takerFee
+}
+
+func (e *Exchange) CounterPrecision() int32 {
+
+	return e.counterPrecision
+}
+
+func (e *Exchange) BasePrecision() int32 {
+
+	return e.basePrecision
+}
+
+func orderTypeToSide(t exchangesdk.OrderType) (exchangesdk.OrderBookSide, error) {
+
+	switch t {
+	case exchangesdk.OrderTypeBid:
+		return exchangesdk.OrderBookSideBid, nil
+	case exchangesdk.OrderTypeAsk:
+		return exchangesdk.OrderBookSideAsk, nil
+	default:
+		return exchangesdk.OrderBookSideUnknown, fmt.Errorf("unknown order type `%s`", t)
+	}
+}
+
+func sideToOrderType(side exchangesdk.OrderBookSide) exchangesdk.OrderType {
+
+	if side == exchangesdk.OrderBookSideAsk {
+		return exchangesdk.OrderTypeAsk
+	}
+	return exchangesdk.OrderTypeBid
+}
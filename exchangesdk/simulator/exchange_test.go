@@ -0,0 +1,443 @@
+package simulator_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/simulator"
+)
+
+func D(f float64) decimal.Decimal {
+
+	return decimal.NewFromFloat(f)
+}
+
+func bookWithTopOfBook(bid, ask float64) exchangesdk.OrderBook {
+
+	return exchangesdk.OrderBook{
+		Bids: []exchangesdk.OrderBookOrder{{Price: bid, Volume: 1}},
+		Asks: []exchangesdk.OrderBookOrder{{Price: ask, Volume: 1}},
+	}
+}
+
+func requireState(
+	t *testing.T,
+	e *simulator.Exchange,
+	orderId string,
+	expected exchangesdk.OrderState,
+) exchangesdk.OrderStatus {
+
+	status, err := e.GetOrderStatus(context.Background(), orderId)
+	require.NoError(t, err)
+	require.Equal(t, expected, status.State)
+	return status
+}
+
+func TestLimitOrderFillsWhenBookCrosses(t *testing.T) {
+
+	ctx := context.Background()
+	e := simulator.New(crypto.Exchange{})
+	e.UpdateOrderBook(bookWithTopOfBook(99, 101))
+
+	bidId, err := e.PostLimitOrder(ctx, exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeBid,
+		Price:  D(100),
+		Volume: D(2),
+	})
+	require.NoError(t, err)
+	askId, err := e.PostLimitOrder(ctx, exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeAsk,
+		Price:  D(102),
+		Volume: D(1),
+	})
+	require.NoError(t, err)
+
+	requireState(t, e, bidId, exchangesdk.OrderStateInOrderBook)
+	requireState(t, e, askId, exchangesdk.OrderStateInOrderBook)
+
+	e.UpdateOrderBook(bookWithTopOfBook(98, 100))
+	status := requireState(t, e, bidId, exchangesdk.OrderStateFilled)
+	assert.True(t, D(2).Equal(status.FillAmountBase))
+	assert.True(t, D(200).Equal(status.FillAmountCounter))
+	assert.Equal(t, exchangesdk.OrderTypeBid, status.Type)
+	requireState(t, e, askId, exchangesdk.OrderStateInOrderBook)
+
+	e.UpdateOrderBook(bookWithTopOfBook(102, 103))
+	status = requireState(t, e, askId, exchangesdk.OrderStateFilled)
+	assert.Equal(t, exchangesdk.OrderTypeAsk, status.Type)
+}
+
+func TestLimitOrderFillsWhenTradedThrough(t *testing.T) {
+
+	ctx := context.Background()
+	e := simulator.New(crypto.Exchange{})
+
+	id, err := e.PostLimitOrder(ctx, exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeAsk,
+		Price:  D(100),
+		Volume: D(1),
+	})
+	require.NoError(t, err)
+
+	e.AddTrade(exchangesdk.OrderBookTrade{Price: 100})
+	requireState(t, e, id, exchangesdk.OrderStateInOrderBook)
+
+	e.AddTrade(exchangesdk.OrderBookTrade{Price: 100.5})
+	requireState(t, e, id, exchangesdk.OrderStateFilled)
+}
+
+func TestStopLimitOrderTriggersAtStopPrice(t *testing.T) {
+
+	ctx := context.Background()
+	e := simulator.New(crypto.Exchange{})
+	e.UpdateOrderBook(bookWithTopOfBook(99, 101))
+
+	id, err := e.PostStopLimitOrder(ctx, exchangesdk.StopLimitOrder{
+		Side:       exchangesdk.OrderBookSideAsk,
+		StopPrice:  D(95),
+		LimitPrice: D(94),
+		Volume:     D(1),
+	})
+	require.NoError(t, err)
+	requireState(t, e, id, exchangesdk.OrderStateAwaitingTrigger)
+
+	e.AddTrade(exchangesdk.OrderBookTrade{Price: 96})
+	requireState(t, e, id, exchangesdk.OrderStateAwaitingTrigger)
+
+	e.AddTrade(exchangesdk.OrderBookTrade{Price: 95})
+	requireState(t, e, id, exchangesdk.OrderStateFilled)
+}
+
+func TestCancelOrder(t *testing.T) {
+
+	ctx := context.Background()
+	e := simulator.New(crypto.Exchange{})
+
+	id, err := e.PostLimitOrder(ctx, exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeBid,
+		Price:  D(100),
+		Volume: D(1),
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, e.CancelOrder(ctx, id))
+	requireState(t, e, id, exchangesdk.OrderStateCancelled)
+
+	e.UpdateOrderBook(bookWithTopOfBook(98, 99))
+	requireState(t, e, id, exchangesdk.OrderStateCancelled)
+
+	assert.Error(t, e.CancelOrder(ctx, id))
+	assert.Error(t, e.CancelOrder(ctx, "unknown"))
+}
+
+func TestOpenOrdersListsRestingAndStopOrdersInPriority(t *testing.T) {
+
+	ctx := context.Background()
+	e := simulator.New(crypto.Exchange{})
+	e.UpdateOrderBook(bookWithTopOfBook(100, 101))
+
+	post := func(orderType exchangesdk.OrderType, price float64) string {
+		id, err := e.PostLimitOrder(ctx, exchangesdk.Order{
+			Type:   orderType,
+			Price:  D(price),
+			Volume: D(0.5),
+		})
+		require.NoError(t, err)
+		return id
+	}
+
+	lowBid := post(exchangesdk.OrderTypeBid, 98)
+	highBid := post(exchangesdk.OrderTypeBid, 99)
+	ask := post(exchangesdk.OrderTypeAsk, 102)
+	cancelled := post(exchangesdk.OrderTypeAsk, 103)
+	require.NoError(t, e.CancelOrder(ctx, cancelled))
+	// Crosses the book, and so is filled rather than open
+	post(exchangesdk.OrderTypeBid, 101)
+
+	stop, err := e.PostStopLimitOrder(ctx, exchangesdk.StopLimitOrder{
+		Side:       exchangesdk.OrderBookSideAsk,
+		StopPrice:  D(95),
+		LimitPrice: D(94),
+		Volume:     D(1),
+	})
+	require.NoError(t, err)
+
+	open, err := e.OpenOrders(ctx)
+	require.NoError(t, err)
+
+	var ids []string
+	for _, o := range open {
+		ids = append(ids, o.Id)
+	}
+	assert.Equal(t, []string{highBid, lowBid, stop, ask}, ids)
+
+	assert.Equal(t, exchangesdk.OrderBookSideBid, open[0].Side)
+	assert.Equal(t, exchangesdk.OrderStateInOrderBook, open[0].State)
+	assert.True(t, D(99).Equal(open[0].LimitPrice))
+	assert.True(t, D(0.5).Equal(open[0].Volume))
+	assert.Equal(t, exchangesdk.OrderStateAwaitingTrigger, open[2].State)
+}
+
+func TestLatestPrice(t *testing.T) {
+
+	ctx := context.Background()
+	e := simulator.New(crypto.Exchange{})
+
+	_, err := e.LatestPrice(ctx)
+	require.Error(t, err)
+
+	e.UpdateOrderBook(bookWithTopOfBook(99, 101))
+	price, err := e.LatestPrice(ctx)
+	require.NoError(t, err)
+	assert.True(t, D(100).Equal(price))
+
+	e.AddTrade(exchangesdk.OrderBookTrade{Price: 100.5})
+	price, err = e.LatestPrice(ctx)
+	require.NoError(t, err)
+	assert.True(t, D(100.5).Equal(price))
+}
+
+func TestCrossingLimitOrderTakesFromBookAndRestsRemainder(t *testing.T) {
+
+	ctx := context.Background()
+	e := simulator.New(
+		crypto.Exchange{},
+		simulator.WithFees(D(0.001), D(0.002)),
+	)
+	e.UpdateOrderBook(exchangesdk.OrderBook{
+		Bids: []exchangesdk.OrderBookOrder{{Price: 99, Volume: 1}},
+		Asks: []exchangesdk.OrderBookOrder{
+			{Price: 100, Volume: 0.5},
+			{Price: 101, Volume: 1},
+			{Price: 103, Volume: 1},
+		},
+	})
+
+	id, err := e.PostLimitOrder(ctx, exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeBid,
+		Price:  D(101),
+		Volume: D(2),
+	})
+	require.NoError(t, err)
+
+	status := requireState(t, e, id, exchangesdk.OrderStateInOrderBook)
+	assert.True(t, D(1.5).Equal(status.FillAmountBase))
+	assert.True(t, D(151).Equal(status.FillAmountCounter))
+
+	trades, err := e.GetTrades(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(trades))
+	assert.Equal(t, id, trades[0].OrderId)
+	assert.True(t, D(100).Equal(trades[0].Price))
+	assert.True(t, D(0.5).Equal(trades[0].Volume))
+	assert.True(t, D(0.001).Equal(trades[0].BaseFee), "taker fee charged in base")
+	assert.True(t, trades[0].CounterFee.IsZero())
+	assert.Equal(t, exchangesdk.OrderTypeBid, trades[0].Type)
+	assert.True(t, D(101).Equal(trades[1].Price))
+	assert.True(t, D(1).Equal(trades[1].Volume))
+
+	// A second order cannot take the volume already taken from this book
+	id2, err := e.PostLimitOrder(ctx, exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeBid,
+		Price:  D(101),
+		Volume: D(1),
+	})
+	require.NoError(t, err)
+	status = requireState(t, e, id2, exchangesdk.OrderStateInOrderBook)
+	assert.True(t, status.FillAmountBase.IsZero())
+}
+
+func TestRestingOrderFillsAfterQueueAheadIsTraded(t *testing.T) {
+
+	ctx := context.Background()
+	e := simulator.New(
+		crypto.Exchange{},
+		simulator.WithFees(D(0.001), D(0.002)),
+	)
+	e.UpdateOrderBook(exchangesdk.OrderBook{
+		Bids: []exchangesdk.OrderBookOrder{{Price: 100, Volume: 2}},
+		Asks: []exchangesdk.OrderBookOrder{{Price: 101, Volume: 1}},
+	})
+
+	id, err := e.PostLimitOrder(ctx, exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeBid,
+		Price:  D(100),
+		Volume: D(1),
+	})
+	require.NoError(t, err)
+
+	sell := func(volume float64) {
+		e.AddTrade(exchangesdk.OrderBookTrade{
+			MakerSide: exchangesdk.OrderBookSideBid,
+			Price:     100,
+			Volume:    volume,
+		})
+	}
+
+	// Trades on the other side of the book do not fill bids
+	e.AddTrade(exchangesdk.OrderBookTrade{
+		MakerSide: exchangesdk.OrderBookSideAsk,
+		Price:     100,
+		Volume:    5,
+	})
+	status := requireState(t, e, id, exchangesdk.OrderStateInOrderBook)
+	assert.True(t, status.FillAmountBase.IsZero())
+
+	sell(1.5)
+	status = requireState(t, e, id, exchangesdk.OrderStateInOrderBook)
+	assert.True(t, status.FillAmountBase.IsZero())
+
+	sell(1)
+	status = requireState(t, e, id, exchangesdk.OrderStateInOrderBook)
+	assert.True(t, D(0.5).Equal(status.FillAmountBase))
+
+	sell(1)
+	status = requireState(t, e, id, exchangesdk.OrderStateFilled)
+	assert.True(t, D(1).Equal(status.FillAmountBase))
+	assert.True(t, D(100).Equal(status.FillAmountCounter))
+
+	trades, err := e.GetTrades(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(trades))
+	assert.True(t, D(0.0005).Equal(trades[0].BaseFee), "maker fee charged in base")
+	assert.True(t, D(0.0005).Equal(trades[1].BaseFee))
+}
+
+func TestQueueAheadShrinksWithBookVolume(t *testing.T) {
+
+	ctx := context.Background()
+	e := simulator.New(crypto.Exchange{})
+	e.UpdateOrderBook(exchangesdk.OrderBook{
+		Bids: []exchangesdk.OrderBookOrder{{Price: 99, Volume: 1}},
+		Asks: []exchangesdk.OrderBookOrder{{Price: 101, Volume: 5}},
+	})
+
+	id, err := e.PostLimitOrder(ctx, exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeAsk,
+		Price:  D(101),
+		Volume: D(1),
+	})
+	require.NoError(t, err)
+
+	// Orders ahead cancelled
+	e.UpdateOrderBook(exchangesdk.OrderBook{
+		Bids: []exchangesdk.OrderBookOrder{{Price: 99, Volume: 1}},
+		Asks: []exchangesdk.OrderBookOrder{{Price: 101, Volume: 1}},
+	})
+
+	e.AddTrade(exchangesdk.OrderBookTrade{
+		MakerSide: exchangesdk.OrderBookSideAsk,
+		Price:     101,
+		Volume:    1.5,
+	})
+	status := requireState(t, e, id, exchangesdk.OrderStateInOrderBook)
+	assert.True(t, D(0.5).Equal(status.FillAmountBase))
+}
+
+func TestOwnOrdersFillInPriceTimePriority(t *testing.T) {
+
+	ctx := context.Background()
+	e := simulator.New(crypto.Exchange{})
+
+	post := func(price float64) string {
+		id, err := e.PostLimitOrder(ctx, exchangesdk.Order{
+			Type:   exchangesdk.OrderTypeAsk,
+			Price:  D(price),
+			Volume: D(1),
+		})
+		require.NoError(t, err)
+		return id
+	}
+
+	earlier := post(100)
+	better := post(99)
+	later := post(100)
+
+	// Trades through 99 fill the better priced order in full, and the trade
+	// volume at 100 goes to the earlier order at that price first
+	e.AddTrade(exchangesdk.OrderBookTrade{
+		MakerSide: exchangesdk.OrderBookSideAsk,
+		Price:     100,
+		Volume:    1.5,
+	})
+
+	requireState(t, e, better, exchangesdk.OrderStateFilled)
+	requireState(t, e, earlier, exchangesdk.OrderStateFilled)
+	status := requireState(t, e, later, exchangesdk.OrderStateInOrderBook)
+	assert.True(t, D(0.5).Equal(status.FillAmountBase))
+}
+
+func TestStopLimitOrderPartiallyFillsWhenTriggered(t *testing.T) {
+
+	ctx := context.Background()
+	e := simulator.New(crypto.Exchange{})
+	e.UpdateOrderBook(exchangesdk.OrderBook{
+		Bids: []exchangesdk.OrderBookOrder{{Price: 105, Volume: 0.25}},
+		Asks: []exchangesdk.OrderBookOrder{{Price: 106, Volume: 1}},
+	})
+
+	id, err := e.PostStopLimitOrder(ctx, exchangesdk.StopLimitOrder{
+		Side:       exchangesdk.OrderBookSideBid,
+		StopPrice:  D(110),
+		LimitPrice: D(111),
+		Volume:     D(1.5),
+	})
+	require.NoError(t, err)
+	requireState(t, e, id, exchangesdk.OrderStateAwaitingTrigger)
+
+	e.AddTrade(exchangesdk.OrderBookTrade{
+		MakerSide: exchangesdk.OrderBookSideBid,
+		Price:     109,
+		Volume:    1,
+	})
+	requireState(t, e, id, exchangesdk.OrderStateAwaitingTrigger)
+
+	e.AddTrade(exchangesdk.OrderBookTrade{
+		MakerSide: exchangesdk.OrderBookSideAsk,
+		Price:     111,
+		Volume:    0.1,
+	})
+	status := requireState(t, e, id, exchangesdk.OrderStateInOrderBook)
+	assert.True(t, D(1).Equal(status.FillAmountBase))
+	assert.True(t, D(106).Equal(status.FillAmountCounter))
+}
+
+func TestGetTradesIsPaginated(t *testing.T) {
+
+	ctx := context.Background()
+	e := simulator.New(crypto.Exchange{})
+
+	_, err := e.GetTrades(ctx, 0)
+	require.Error(t, err)
+
+	e.UpdateOrderBook(exchangesdk.OrderBook{
+		Asks: []exchangesdk.OrderBookOrder{{Price: 100, Volume: 1000}},
+	})
+	for i := 0; i < 150; i++ {
+		_, err := e.PostLimitOrder(ctx, exchangesdk.Order{
+			Type:   exchangesdk.OrderTypeBid,
+			Price:  D(100),
+			Volume: D(1),
+		})
+		require.NoError(t, err)
+	}
+
+	page1, err := e.GetTrades(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 100, len(page1))
+	assert.Equal(t, "sim-1", page1[0].OrderId)
+
+	page2, err := e.GetTrades(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 50, len(page2))
+	assert.Equal(t, "sim-101", page2[0].OrderId)
+
+	page3, err := e.GetTrades(ctx, 3)
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(page3))
+}
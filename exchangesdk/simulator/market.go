@@ -0,0 +1,67 @@
+package simulator
+
+import (
+	"context"
+	"sync"
+
+	"github.com/thecodedproject/crypto/exchangesdk"
+)
+
+// FollowMarket feeds everything emitted by a market follower into e, passing
+// each event on to the returned channels only after e has been updated.
+// Consumers of the returned channels therefore never see market data which
+// has not yet been applied to e (although e may have been updated with the
+// next event by the time it is received).
+//
+// The returned channels are closed when the input channels are closed.
+// wg.Done is called once both input channels have been closed or ctx is
+// cancelled.
+func FollowMarket(
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	e *Exchange,
+	obf <-chan exchangesdk.OrderBook,
+	tradeStream <-chan exchangesdk.OrderBookTrade,
+) (<-chan exchangesdk.OrderBook, <-chan exchangesdk.OrderBookTrade) {
+
+	obfOut := make(chan exchangesdk.OrderBook)
+	tradeStreamOut := make(chan exchangesdk.OrderBookTrade)
+
+	go func() {
+
+		defer wg.Done()
+
+		for obf != nil || tradeStream != nil {
+			select {
+			case ob, more := <-obf:
+				if !more {
+					close(obfOut)
+					obf = nil
+					continue
+				}
+				e.UpdateOrderBook(ob)
+				select {
+				case obfOut <- ob:
+				case <-ctx.Done():
+					return
+				}
+			case trade, more := <-tradeStream:
+				if !more {
+					close(tradeStreamOut)
+					tradeStream = nil
+					continue
+				}
+				e.AddTrade(trade)
+				select {
+				case tradeStreamOut <- trade:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return obfOut, tradeStreamOut
+}
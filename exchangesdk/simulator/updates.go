@@ -0,0 +1,104 @@
+package simulator
+
+import (
+	"context"
+	"sync"
+
+	"github.com/thecodedproject/crypto/exchangesdk"
+)
+
+var _ exchangesdk.OrderUpdatesClient = (*Exchange)(nil)
+
+// updateSubscriber queues the order updates for a single OrderUpdates
+// stream, so that publishing them never blocks the matching engine on a slow
+// reader
+type updateSubscriber struct {
+	mu      sync.Mutex
+	queue   []exchangesdk.OrderUpdate
+	pending chan struct{}
+}
+
+func (s *updateSubscriber) push(u exchangesdk.OrderUpdate) {
+
+	s.mu.Lock()
+	s.queue = append(s.queue, u)
+	s.mu.Unlock()
+
+	select {
+	case s.pending <- struct{}{}:
+	default:
+	}
+}
+
+func (s *updateSubscriber) take() []exchangesdk.OrderUpdate {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	queue := s.queue
+	s.queue = nil
+	return queue
+}
+
+// OrderUpdates streams updates to all orders placed after it is called, until
+// ctx is cancelled
+func (e *Exchange) OrderUpdates(
+	ctx context.Context,
+) (<-chan exchangesdk.OrderUpdate, error) {
+
+	s := &updateSubscriber{
+		pending: make(chan struct{}, 1),
+	}
+
+	e.mu.Lock()
+	e.updateSubs[s] = true
+	e.mu.Unlock()
+
+	updates := make(chan exchangesdk.OrderUpdate)
+	go func() {
+
+		defer close(updates)
+		defer func() {
+			e.mu.Lock()
+			delete(e.updateSubs, s)
+			e.mu.Unlock()
+		}()
+
+		for {
+			select {
+			case <-s.pending:
+			case <-ctx.Done():
+				return
+			}
+
+			for _, u := range s.take() {
+				select {
+				case updates <- u:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return updates, nil
+}
+
+func (e *Exchange) publishUpdate(u exchangesdk.OrderUpdate) {
+
+	for s := range e.updateSubs {
+		s.push(u)
+	}
+}
+
+func (e *Exchange) publishStateChange(o *order) {
+
+	e.publishUpdate(exchangesdk.OrderUpdate{
+		Type:              exchangesdk.OrderUpdateTypeStateChange,
+		OrderId:           o.id,
+		Timestamp:         e.now(),
+		State:             o.state,
+		FillAmountBase:    o.fillAmountBase,
+		FillAmountCounter: o.fillAmountCounter,
+	})
+}
@@ -0,0 +1,107 @@
+package simulator_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/simulator"
+)
+
+func nextUpdate(
+	t *testing.T,
+	updates <-chan exchangesdk.OrderUpdate,
+) exchangesdk.OrderUpdate {
+
+	select {
+	case u, ok := <-updates:
+		require.True(t, ok, "updates channel closed")
+		return u
+	case <-time.After(time.Second):
+		require.Fail(t, "timed out waiting for order update")
+		return exchangesdk.OrderUpdate{}
+	}
+}
+
+func TestOrderUpdates(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	e := simulator.New(
+		crypto.Exchange{},
+		simulator.WithFees(D(0.01), D(0.02)),
+	)
+	e.UpdateOrderBook(bookWithTopOfBook(99, 101))
+
+	updates, err := e.OrderUpdates(ctx)
+	require.NoError(t, err)
+
+	bidId, err := e.PostLimitOrder(ctx, exchangesdk.Order{
+		Type:   exchangesdk.OrderTypeBid,
+		Price:  D(100),
+		Volume: D(2),
+	})
+	require.NoError(t, err)
+
+	stopId, err := e.PostStopLimitOrder(ctx, exchangesdk.StopLimitOrder{
+		Side:       exchangesdk.OrderBookSideAsk,
+		StopPrice:  D(95),
+		LimitPrice: D(96),
+		Volume:     D(1),
+	})
+	require.NoError(t, err)
+
+	e.AddTrade(exchangesdk.OrderBookTrade{
+		MakerSide: exchangesdk.OrderBookSideBid,
+		Price:     100,
+		Volume:    1.5,
+	})
+	e.AddTrade(exchangesdk.OrderBookTrade{
+		MakerSide: exchangesdk.OrderBookSideBid,
+		Price:     100,
+		Volume:    1,
+	})
+	e.UpdateOrderBook(bookWithTopOfBook(90, 101))
+	e.AddTrade(exchangesdk.OrderBookTrade{
+		MakerSide: exchangesdk.OrderBookSideBid,
+		Price:     95,
+		Volume:    1,
+	})
+	require.NoError(t, e.CancelOrder(ctx, stopId))
+
+	expected := []struct {
+		Type    exchangesdk.OrderUpdateType
+		OrderId string
+		State   exchangesdk.OrderState
+		Volume  float64
+	}{
+		{exchangesdk.OrderUpdateTypeStateChange, bidId, exchangesdk.OrderStateInOrderBook, 0},
+		{exchangesdk.OrderUpdateTypeStateChange, stopId, exchangesdk.OrderStateAwaitingTrigger, 0},
+		{exchangesdk.OrderUpdateTypeFill, bidId, exchangesdk.OrderStateInOrderBook, 1.5},
+		{exchangesdk.OrderUpdateTypeFill, bidId, exchangesdk.OrderStateFilled, 0.5},
+		{exchangesdk.OrderUpdateTypeStateChange, bidId, exchangesdk.OrderStateFilled, 0},
+		{exchangesdk.OrderUpdateTypeStateChange, stopId, exchangesdk.OrderStateInOrderBook, 0},
+		{exchangesdk.OrderUpdateTypeStateChange, stopId, exchangesdk.OrderStateCancelled, 0},
+	}
+
+	for i, e := range expected {
+		u := nextUpdate(t, updates)
+		assert.Equal(t, e.Type, u.Type, "update %d", i)
+		assert.Equal(t, e.OrderId, u.OrderId, "update %d", i)
+		assert.Equal(t, e.State, u.State, "update %d", i)
+		if e.Type == exchangesdk.OrderUpdateTypeFill {
+			assert.True(t, D(e.Volume).Equal(u.FillVolume), "update %d", i)
+			assert.True(t, D(100).Equal(u.FillPrice), "update %d", i)
+			assert.True(t, D(e.Volume).Mul(D(0.01)).Equal(u.BaseFee), "update %d", i)
+		}
+	}
+
+	cancel()
+	for range updates {
+	}
+}
@@ -0,0 +1,401 @@
+// Package spread tracks the spreads of a pair across several exchanges
+// (venues), by aligning the venues' order books on a common time grid and
+// keeping rolling stats of series derived from them at each grid point.
+package spread
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/util"
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+// defaultStaleAfter is the default age at which a venue's order book is
+// considered stale
+const defaultStaleAfter = 10 * time.Second
+
+// Alignment is how the venues' order books are aligned on the grid
+type Alignment int
+
+const (
+	// AlignAsOf aligns order books by their timestamps: the book of each
+	// venue at a grid point is its latest book with a timestamp at or before
+	// the grid point. Grid points are emitted once all (non-stale) venues
+	// have books after them, so historical data (e.g. a replay) drives the
+	// grid without any clock.
+	AlignAsOf Alignment = iota
+
+	// AlignLastValue samples the last book received from each venue on each
+	// tick of the clock, regardless of the books' timestamps
+	AlignLastValue
+)
+
+// Venue is an exchange whose order books are tracked
+type Venue struct {
+	Exchange crypto.Exchange
+
+	// TakerFee is the venue's taker fee as a ratio (e.g. from the venue
+	// client's TakerFee)
+	TakerFee decimal.Decimal
+
+	// OrderBooks streams the venue's order book (e.g. from
+	// factory.NewMarketFollower); it is followed by Tracker.Follow
+	OrderBooks <-chan exchangesdk.OrderBook
+}
+
+// GridPoint is the order book of each venue at a point on the grid; the
+// book of a venue is nil if it has none, or it is stale
+type GridPoint struct {
+	Time       time.Time
+	OrderBooks []*exchangesdk.OrderBook
+}
+
+type options struct {
+	clock      utiltime.Clock
+	alignment  Alignment
+	staleAfter time.Duration
+	onGrid     []func(GridPoint)
+}
+
+type Option func(*options)
+
+// WithClock sets the clock which drives the grid when using AlignLastValue,
+// and which the series' stats are relative to; by default the real clock is
+// used
+func WithClock(clock utiltime.Clock) Option {
+
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// WithAlignment sets how order books are aligned; by default AlignAsOf
+func WithAlignment(a Alignment) Option {
+
+	return func(o *options) {
+		o.alignment = a
+	}
+}
+
+// WithStaleAfter sets the age at which a venue's order book is stale, after
+// which the venue is left out of grid points until it has a new book (and,
+// with AlignAsOf, no longer holds back the grid); by default 10 seconds
+func WithStaleAfter(d time.Duration) Option {
+
+	return func(o *options) {
+		o.staleAfter = d
+	}
+}
+
+// OnGridPoint registers a callback which is called with each grid point,
+// after the series have been updated; it is called with the tracker locked,
+// and so may read the series directly but must not call View
+func OnGridPoint(f func(GridPoint)) Option {
+
+	return func(o *options) {
+		o.onGrid = append(o.onGrid, f)
+	}
+}
+
+type venueState struct {
+	takerFee float64
+
+	// latest is the latest book received; asOf is the latest book at or
+	// before the last grid point and pending the books received after it
+	latest  *exchangesdk.OrderBook
+	asOf    *exchangesdk.OrderBook
+	pending []exchangesdk.OrderBook
+}
+
+// Tracker aligns the order books of several venues on a grid, and keeps
+// rolling stats of the following series at each grid point:
+//   - Mid: the mid price of a venue
+//   - MidDifference: the mid of one venue less the mid of another
+//   - ArbitrageGap: the proceeds per unit of buying at the best ask on one
+//     venue and selling at the best bid on another, net of both taker fees;
+//     positive when the arbitrage is profitable
+type Tracker struct {
+	venues   []Venue
+	interval time.Duration
+	opts     options
+
+	mu       sync.Mutex
+	states   []venueState
+	nextGrid time.Time
+
+	mids           []util.RollingStats
+	midDifferences [][]util.RollingStats
+	arbitrageGaps  [][]util.RollingStats
+}
+
+// NewTracker returns a tracker of venues on a grid of the given interval,
+// whose series keep stats for maxCacheDuration
+func NewTracker(
+	venues []Venue,
+	interval time.Duration,
+	maxCacheDuration time.Duration,
+	opts ...Option,
+) (*Tracker, error) {
+
+	if len(venues) < 2 {
+		return nil, fmt.Errorf("spread tracker needs at least 2 venues, got %d", len(venues))
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("spread tracker interval must be positive, got %s", interval)
+	}
+
+	o := options{
+		clock:      utiltime.Real,
+		staleAfter: defaultStaleAfter,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	// Stats are queried relative to the clock, so a simulated clock should
+	// be used when the grid is driven by historical data
+	newStats := func() util.RollingStats {
+		return util.NewRollingStats(maxCacheDuration, util.WithClock(o.clock))
+	}
+
+	t := &Tracker{
+		venues:   venues,
+		interval: interval,
+		opts:     o,
+		states:   make([]venueState, len(venues)),
+	}
+	t.midDifferences = make([][]util.RollingStats, len(venues))
+	t.arbitrageGaps = make([][]util.RollingStats, len(venues))
+	for i, v := range venues {
+		t.states[i].takerFee, _ = v.TakerFee.Float64()
+		t.mids = append(t.mids, newStats())
+		t.midDifferences[i] = make([]util.RollingStats, len(venues))
+		t.arbitrageGaps[i] = make([]util.RollingStats, len(venues))
+		for j := range venues {
+			if i == j {
+				continue
+			}
+			t.midDifferences[i][j] = newStats()
+			t.arbitrageGaps[i][j] = newStats()
+		}
+	}
+	return t, nil
+}
+
+// Follow reads each venue's OrderBooks stream (and, with AlignLastValue,
+// samples the grid on each tick of the clock) until ctx is cancelled
+func (t *Tracker) Follow(ctx context.Context, wg *sync.WaitGroup) {
+
+	for i, v := range t.venues {
+		if v.OrderBooks == nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, books <-chan exchangesdk.OrderBook) {
+			defer wg.Done()
+
+			for {
+				select {
+				case ob, ok := <-books:
+					if !ok {
+						return
+					}
+					t.AddOrderBook(i, ob)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(i, v.OrderBooks)
+	}
+
+	if t.opts.alignment != AlignLastValue {
+		return
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := t.opts.clock.NewTicker(t.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C():
+				t.mu.Lock()
+				t.sampleLastValues(now)
+				t.mu.Unlock()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// AddOrderBook adds an order book of the venue at index venue; with
+// AlignAsOf, this emits any grid points which it completes.
+// Each venue's books are expected in timestamp order.
+func (t *Tracker) AddOrderBook(venue int, ob exchangesdk.OrderBook) {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := &t.states[venue]
+	s.latest = &ob
+	if t.opts.alignment == AlignAsOf {
+		s.pending = append(s.pending, ob)
+		t.emitAsOf()
+	}
+}
+
+// View calls f with the tracker locked, so that f can read the series
+// without them being updated concurrently by Follow
+func (t *Tracker) View(f func()) {
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	f()
+}
+
+// Mid returns the series of the mid price of a venue
+func (t *Tracker) Mid(venue int) util.RollingStats {
+
+	return t.mids[venue]
+}
+
+// MidDifference returns the series of the mid of venue a less the mid of
+// venue b
+func (t *Tracker) MidDifference(a, b int) util.RollingStats {
+
+	return t.midDifferences[a][b]
+}
+
+// ArbitrageGap returns the series of the net proceeds per unit of buying on
+// venue buy and selling on venue sell
+func (t *Tracker) ArbitrageGap(buy, sell int) util.RollingStats {
+
+	return t.arbitrageGaps[buy][sell]
+}
+
+// emitAsOf emits all grid points up to the watermark: the earliest latest
+// timestamp of the venues which are not stale
+func (t *Tracker) emitAsOf() {
+
+	var newest time.Time
+	for _, s := range t.states {
+		if s.latest == nil {
+			// The grid starts once all venues have a book
+			return
+		}
+		if s.latest.Timestamp.After(newest) {
+			newest = s.latest.Timestamp
+		}
+	}
+
+	var watermark time.Time
+	for _, s := range t.states {
+		ts := s.latest.Timestamp
+		if newest.Sub(ts) > t.opts.staleAfter {
+			continue
+		}
+		if watermark.IsZero() || ts.Before(watermark) {
+			watermark = ts
+		}
+	}
+
+	if t.nextGrid.IsZero() {
+		// The first grid point is the first at which all venues have a book
+		var start time.Time
+		for _, s := range t.states {
+			if s.pending[0].Timestamp.After(start) {
+				start = s.pending[0].Timestamp
+			}
+		}
+		t.nextGrid = start.Truncate(t.interval)
+		if t.nextGrid.Before(start) {
+			t.nextGrid = t.nextGrid.Add(t.interval)
+		}
+	}
+
+	for !t.nextGrid.After(watermark) {
+		g := t.nextGrid
+		for i := range t.states {
+			s := &t.states[i]
+			n := 0
+			for n < len(s.pending) && !s.pending[n].Timestamp.After(g) {
+				s.asOf = &s.pending[n]
+				n++
+			}
+			s.pending = s.pending[n:]
+		}
+
+		books := make([]*exchangesdk.OrderBook, len(t.states))
+		for i, s := range t.states {
+			books[i] = t.fresh(s.asOf, g)
+		}
+		t.emit(GridPoint{Time: g, OrderBooks: books})
+
+		t.nextGrid = g.Add(t.interval)
+	}
+}
+
+func (t *Tracker) sampleLastValues(now time.Time) {
+
+	books := make([]*exchangesdk.OrderBook, len(t.states))
+	for i, s := range t.states {
+		books[i] = t.fresh(s.latest, now)
+	}
+	t.emit(GridPoint{Time: now, OrderBooks: books})
+}
+
+// fresh returns ob, or nil if it is stale at time g
+func (t *Tracker) fresh(ob *exchangesdk.OrderBook, g time.Time) *exchangesdk.OrderBook {
+
+	if ob == nil || g.Sub(ob.Timestamp) > t.opts.staleAfter {
+		return nil
+	}
+	return ob
+}
+
+// emit updates the series with a grid point
+func (t *Tracker) emit(gp GridPoint) {
+
+	type top struct {
+		bid, ask float64
+	}
+
+	tops := make([]*top, len(gp.OrderBooks))
+	for i, ob := range gp.OrderBooks {
+		if ob == nil || len(ob.Bids) == 0 || len(ob.Asks) == 0 {
+			continue
+		}
+		tops[i] = &top{bid: ob.Bids[0].Price, ask: ob.Asks[0].Price}
+		t.mids[i].Add(gp.Time, (tops[i].bid+tops[i].ask)/2)
+	}
+
+	for i, a := range tops {
+		for j, b := range tops {
+			if i == j || a == nil || b == nil {
+				continue
+			}
+
+			t.midDifferences[i][j].Add(gp.Time, (a.bid+a.ask)/2-(b.bid+b.ask)/2)
+
+			cost := a.ask * (1 + t.states[i].takerFee)
+			proceeds := b.bid * (1 - t.states[j].takerFee)
+			t.arbitrageGaps[i][j].Add(gp.Time, proceeds-cost)
+		}
+	}
+
+	for _, f := range t.opts.onGrid {
+		f(gp)
+	}
+}
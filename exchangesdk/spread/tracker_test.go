@@ -0,0 +1,217 @@
+package spread_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/spread"
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+var start = time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func at(d time.Duration) time.Time {
+
+	return start.Add(d)
+}
+
+func book(ts time.Time, bid, ask float64) exchangesdk.OrderBook {
+
+	return exchangesdk.OrderBook{
+		Timestamp: ts,
+		Bids:      []exchangesdk.OrderBookOrder{{Price: bid, Volume: 1}},
+		Asks:      []exchangesdk.OrderBookOrder{{Price: ask, Volume: 1}},
+	}
+}
+
+func venues() []spread.Venue {
+
+	return []spread.Venue{
+		{
+			Exchange: crypto.Exchange{Provider: crypto.ApiProviderLuno, Pair: crypto.PairBTCEUR},
+		},
+		{
+			Exchange: crypto.Exchange{Provider: crypto.ApiProviderBinance, Pair: crypto.PairBTCEUR},
+			TakerFee: decimal.New(1, -3),
+		},
+	}
+}
+
+// gridRecorder records the grid points of a tracker
+type gridRecorder struct {
+	points []spread.GridPoint
+}
+
+func (r *gridRecorder) option() spread.Option {
+
+	return spread.OnGridPoint(func(gp spread.GridPoint) {
+		r.points = append(r.points, gp)
+	})
+}
+
+func (r *gridRecorder) times() []time.Time {
+
+	var times []time.Time
+	for _, gp := range r.points {
+		times = append(times, gp.Time)
+	}
+	return times
+}
+
+func TestAsOfAlignmentUsesLatestBookAtEachGridPoint(t *testing.T) {
+
+	clock := utiltime.NewSimulatedClock(start)
+	var rec gridRecorder
+
+	tracker, err := spread.NewTracker(
+		venues(),
+		time.Second,
+		time.Minute,
+		spread.WithClock(clock),
+		rec.option(),
+	)
+	require.NoError(t, err)
+
+	tracker.AddOrderBook(0, book(at(200*time.Millisecond), 99, 101))
+	tracker.AddOrderBook(1, book(at(500*time.Millisecond), 100, 100.5))
+	tracker.AddOrderBook(0, book(at(1500*time.Millisecond), 100, 102))
+	// The grid starts at the first point at which both venues have a book,
+	// and a point is only emitted once both venues have books after it
+	assert.Empty(t, rec.points)
+
+	tracker.AddOrderBook(1, book(at(2500*time.Millisecond), 101, 101.5))
+	require.Equal(t, []time.Time{at(time.Second)}, rec.times())
+	assert.Equal(t, at(200*time.Millisecond), rec.points[0].OrderBooks[0].Timestamp)
+	assert.Equal(t, at(500*time.Millisecond), rec.points[0].OrderBooks[1].Timestamp)
+
+	tracker.View(func() {
+		assert.Equal(t, 100.0, tracker.Mid(0).Latest())
+		assert.Equal(t, 100.25, tracker.Mid(1).Latest())
+		assert.Equal(t, -0.25, tracker.MidDifference(0, 1).Latest())
+		assert.Equal(t, 0.25, tracker.MidDifference(1, 0).Latest())
+		// Buy on Luno at 101 (no fee), sell on Binance at 100 less 0.1%
+		assert.InDelta(t, 99.9-101, tracker.ArbitrageGap(0, 1).Latest(), 1e-9)
+		// Buy on Binance at 100.5 plus 0.1%, sell on Luno at 99
+		assert.InDelta(t, 99-100.6005, tracker.ArbitrageGap(1, 0).Latest(), 1e-9)
+	})
+
+	tracker.AddOrderBook(0, book(at(3100*time.Millisecond), 100, 101))
+	require.Equal(t, []time.Time{at(time.Second), at(2 * time.Second)}, rec.times())
+	assert.Equal(t, at(1500*time.Millisecond), rec.points[1].OrderBooks[0].Timestamp)
+	assert.Equal(t, at(500*time.Millisecond), rec.points[1].OrderBooks[1].Timestamp)
+
+	clock.Set(at(3 * time.Second))
+	tracker.View(func() {
+		assert.Equal(t, 0.75, tracker.MidDifference(0, 1).Latest())
+
+		mean, err := tracker.MidDifference(0, 1).Mean(start)
+		require.NoError(t, err)
+		assert.Equal(t, 0.25, mean)
+	})
+}
+
+func TestAsOfAlignmentLeavesOutStaleVenues(t *testing.T) {
+
+	var rec gridRecorder
+	tracker, err := spread.NewTracker(
+		venues(),
+		time.Second,
+		time.Minute,
+		spread.WithStaleAfter(2*time.Second),
+		rec.option(),
+	)
+	require.NoError(t, err)
+
+	tracker.AddOrderBook(0, book(at(500*time.Millisecond), 99, 101))
+	tracker.AddOrderBook(1, book(at(500*time.Millisecond), 100, 102))
+	tracker.AddOrderBook(0, book(at(1200*time.Millisecond), 99, 101))
+	tracker.AddOrderBook(0, book(at(3*time.Second), 99, 101))
+	tracker.AddOrderBook(0, book(at(5*time.Second), 99, 101))
+
+	// Binance no longer holds back the grid once it is stale
+	require.Equal(t, []time.Time{
+		at(time.Second),
+		at(2 * time.Second),
+		at(3 * time.Second),
+		at(4 * time.Second),
+		at(5 * time.Second),
+	}, rec.times())
+
+	for i, gp := range rec.points {
+		assert.NotNil(t, gp.OrderBooks[0], i)
+		if gp.Time.Before(at(3 * time.Second)) {
+			assert.NotNil(t, gp.OrderBooks[1], i)
+		} else {
+			assert.Nil(t, gp.OrderBooks[1], i)
+		}
+	}
+}
+
+func TestLastValueAlignmentSamplesOnClockTicks(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+
+	clock := utiltime.NewSimulatedClock(start)
+	lunoBooks := make(chan exchangesdk.OrderBook)
+	binanceBooks := make(chan exchangesdk.OrderBook)
+	points := make(chan spread.GridPoint, 1)
+
+	vs := venues()
+	vs[0].OrderBooks = lunoBooks
+	vs[1].OrderBooks = binanceBooks
+
+	tracker, err := spread.NewTracker(
+		vs,
+		time.Second,
+		time.Minute,
+		spread.WithClock(clock),
+		spread.WithAlignment(spread.AlignLastValue),
+		spread.OnGridPoint(func(gp spread.GridPoint) {
+			points <- gp
+		}),
+	)
+	require.NoError(t, err)
+	tracker.Follow(ctx, &wg)
+
+	// The timestamps of the books are ignored, other than for staleness; a
+	// send only completes once the previous book has been received, so
+	// sending each book twice ensures that the first has been added
+	for i := 0; i < 2; i++ {
+		lunoBooks <- book(at(-time.Second), 99, 101)
+		binanceBooks <- book(at(-time.Second), 100, 104)
+	}
+
+	require.Eventually(t, func() bool {
+		return clock.Waiters() == 1
+	}, time.Second, time.Millisecond)
+	clock.Advance(time.Second)
+
+	gp := <-points
+	assert.Equal(t, at(time.Second), gp.Time)
+	require.Len(t, gp.OrderBooks, 2)
+	assert.Equal(t, 99.0, gp.OrderBooks[0].Bids[0].Price)
+	assert.Equal(t, 100.0, gp.OrderBooks[1].Bids[0].Price)
+
+	tracker.View(func() {
+		assert.Equal(t, -2.0, tracker.MidDifference(0, 1).Latest())
+	})
+}
+
+func TestNewTrackerInvalidArgsReturnsError(t *testing.T) {
+
+	_, err := spread.NewTracker(venues()[:1], time.Second, time.Minute)
+	assert.Error(t, err)
+
+	_, err = spread.NewTracker(venues(), 0, time.Minute)
+	assert.Error(t, err)
+}
@@ -0,0 +1,379 @@
+// Package trailingstop implements trailing stop orders on any
+// exchangesdk.Client, as a stop limit order whose stop price is moved (by
+// cancelling and re-posting it) as the market moves in our favour.
+package trailingstop
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/logging"
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+// Order is a trailing stop order.
+//
+// An ask stop protects a long position; its stop price trails below the
+// highest price seen. A bid stop protects a short position; its stop price
+// trails above the lowest price seen.
+type Order struct {
+	Side   exchangesdk.OrderBookSide
+	Volume decimal.Decimal
+
+	// The stop price trails the best price seen by either TrailAmount, or by
+	// TrailPercent as a ratio of the price (i.e. 2% as 0.02); exactly one
+	// must be set
+	TrailAmount  decimal.Decimal
+	TrailPercent decimal.Decimal
+
+	// LimitOffset is the distance of the limit price beyond the stop price
+	// (below it for an ask, above it for a bid)
+	LimitOffset decimal.Decimal
+}
+
+type options struct {
+	minStep decimal.Decimal
+	logger  logging.Logger
+	clock   utiltime.Clock
+}
+
+type Option func(*options)
+
+// WithMinStep sets the minimum distance which the stop price must move
+// before the stop order is re-posted, to limit the number of requests made
+// in a fast moving market. By default the order is re-posted on any move.
+func WithMinStep(step decimal.Decimal) Option {
+
+	return func(o *options) {
+		o.minStep = step
+	}
+}
+
+// WithLogger sets the logger which errors while following the market are
+// reported to; by default logging.Std is used
+func WithLogger(l logging.Logger) Option {
+
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+// WithClock sets the clock which times FollowLatestPrice and the polls
+// confirming the cancel of a moved stop (e.g. a simulated clock, so that the
+// stop can be driven by historical data); by default the real clock is used
+func WithClock(clock utiltime.Clock) Option {
+
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// TrailingStop is a placed trailing stop order. Its stop price is moved by
+// Update, which is called with each new market price by Follow or
+// FollowLatestPrice.
+type TrailingStop struct {
+	client exchangesdk.Client
+	order  Order
+	opts   options
+
+	mu        sync.Mutex
+	best      decimal.Decimal
+	stopPrice decimal.Decimal
+	orderId   string
+	triggered bool
+}
+
+// New places a trailing stop order on client, with its stop price trailing
+// the current market price
+func New(
+	ctx context.Context,
+	client exchangesdk.Client,
+	o Order,
+	price decimal.Decimal,
+	opts ...Option,
+) (*TrailingStop, error) {
+
+	if o.Side != exchangesdk.OrderBookSideBid && o.Side != exchangesdk.OrderBookSideAsk {
+		return nil, errors.New("trailing stop side must be bid or ask")
+	}
+	if o.TrailAmount.IsPositive() == o.TrailPercent.IsPositive() {
+		return nil, errors.New("trailing stop must have exactly one of a trail amount or percent")
+	}
+
+	opt := options{
+		logger: logging.Std,
+		clock:  utiltime.Real,
+	}
+	for _, f := range opts {
+		f(&opt)
+	}
+
+	s := &TrailingStop{
+		client: client,
+		order:  o,
+		opts:   opt,
+		best:   price,
+	}
+
+	s.stopPrice = s.stopFor(price)
+	id, err := s.post(ctx, s.stopPrice)
+	if err != nil {
+		return nil, err
+	}
+	s.orderId = id
+
+	return s, nil
+}
+
+// OrderId returns the id of the current stop limit order
+func (s *TrailingStop) OrderId() string {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.orderId
+}
+
+// StopPrice returns the stop price of the current stop limit order
+func (s *TrailingStop) StopPrice() decimal.Decimal {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stopPrice
+}
+
+// Triggered returns true once the stop order has been triggered, after
+// which it no longer trails the market
+func (s *TrailingStop) Triggered() bool {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.triggered
+}
+
+// Update moves the stop price if price is better than any price seen so far.
+//
+// If price has reached the stop price then the order's status is checked,
+// and the stop is marked as triggered if it has been.
+func (s *TrailingStop) Update(ctx context.Context, price decimal.Decimal) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.triggered {
+		return nil
+	}
+
+	if s.reached(price) {
+		status, err := s.client.GetOrderStatus(ctx, s.orderId)
+		if err != nil {
+			return err
+		}
+		if status.State != exchangesdk.OrderStateAwaitingTrigger {
+			s.triggered = true
+		}
+		return nil
+	}
+
+	if !s.better(price) {
+		return nil
+	}
+	s.best = price
+
+	stopPrice := s.stopFor(price)
+	if stopPrice.Sub(s.stopPrice).Abs().LessThan(s.opts.minStep) ||
+		stopPrice.Equal(s.stopPrice) {
+
+		return nil
+	}
+
+	return s.move(ctx, stopPrice)
+}
+
+// Follow updates the stop with the price of each trade until it is
+// triggered (returning nil), trades is closed (returning nil) or ctx is
+// cancelled (returning ctx.Err()).
+// Errors while updating the stop are logged, and following continues.
+func (s *TrailingStop) Follow(
+	ctx context.Context,
+	trades <-chan exchangesdk.OrderBookTrade,
+) error {
+
+	for {
+		select {
+		case t, ok := <-trades:
+			if !ok {
+				return nil
+			}
+
+			err := s.Update(ctx, decimal.NewFromFloat(t.Price))
+			if err != nil {
+				s.logError(err)
+			}
+			if s.Triggered() {
+				return nil
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// FollowLatestPrice updates the stop with the client's LatestPrice every
+// interval, until it is triggered (returning nil) or ctx is cancelled
+// (returning ctx.Err()).
+// Errors while updating the stop are logged, and following continues.
+func (s *TrailingStop) FollowLatestPrice(
+	ctx context.Context,
+	interval time.Duration,
+) error {
+
+	ticker := s.opts.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			price, err := s.client.LatestPrice(ctx)
+			if err == nil {
+				err = s.Update(ctx, price)
+			}
+			if err != nil {
+				s.logError(err)
+			}
+			if s.Triggered() {
+				return nil
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// move cancels the stop order and re-posts it at stopPrice, unless it was
+// triggered before the cancel.
+// As the cancelled order may still trigger until the exchange has removed it
+// from the order book, it is only re-posted once the cancel is confirmed, so
+// that both stops are never live together.
+func (s *TrailingStop) move(ctx context.Context, stopPrice decimal.Decimal) error {
+
+	var status exchangesdk.OrderStatus
+	cancelErr := s.client.CancelOrder(ctx, s.orderId)
+	if cancelErr != nil {
+		// The cancel fails if the order was triggered first
+		var err error
+		status, err = s.client.GetOrderStatus(ctx, s.orderId)
+		if err != nil {
+			return cancelErr
+		}
+		if status.State == exchangesdk.OrderStateAwaitingTrigger &&
+			!status.FillAmountBase.IsPositive() {
+
+			return cancelErr
+		}
+		s.triggered = true
+		return nil
+	}
+
+	status, err := exchangesdk.AwaitOutOfBook(
+		ctx,
+		s.client,
+		s.orderId,
+		exchangesdk.WithClock(s.opts.clock),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Some exchanges (e.g. Luno) report cancelled orders as filled, so any
+	// fill is used to tell whether the order was triggered
+	if status.FillAmountBase.IsPositive() {
+		s.triggered = true
+		return nil
+	}
+
+	id, err := s.post(ctx, stopPrice)
+	if err != nil {
+		return err
+	}
+
+	s.orderId = id
+	s.stopPrice = stopPrice
+	return nil
+}
+
+func (s *TrailingStop) post(ctx context.Context, stopPrice decimal.Decimal) (string, error) {
+
+	limitPrice := stopPrice.Sub(s.order.LimitOffset)
+	if s.order.Side == exchangesdk.OrderBookSideBid {
+		limitPrice = stopPrice.Add(s.order.LimitOffset)
+	}
+
+	return s.client.PostStopLimitOrder(ctx, exchangesdk.StopLimitOrder{
+		Side:       s.order.Side,
+		StopPrice:  stopPrice,
+		LimitPrice: limitPrice,
+		Volume:     s.order.Volume,
+	})
+}
+
+// stopFor returns the stop price trailing price, rounded away from price to
+// the client's counter precision
+func (s *TrailingStop) stopFor(price decimal.Decimal) decimal.Decimal {
+
+	trail := s.order.TrailAmount
+	if s.order.TrailPercent.IsPositive() {
+		trail = price.Mul(s.order.TrailPercent)
+	}
+
+	precision := s.client.CounterPrecision()
+	if s.order.Side == exchangesdk.OrderBookSideAsk {
+		return price.Sub(trail).Truncate(precision)
+	}
+
+	stop := price.Add(trail)
+	rounded := stop.Truncate(precision)
+	if rounded.LessThan(stop) {
+		rounded = rounded.Add(decimal.New(1, -precision))
+	}
+	return rounded
+}
+
+// better returns true if price is better for the stop than the best price
+// seen so far
+func (s *TrailingStop) better(price decimal.Decimal) bool {
+
+	if s.order.Side == exchangesdk.OrderBookSideAsk {
+		return price.GreaterThan(s.best)
+	}
+	return price.LessThan(s.best)
+}
+
+// reached returns true if price has reached the stop price
+func (s *TrailingStop) reached(price decimal.Decimal) bool {
+
+	if s.order.Side == exchangesdk.OrderBookSideAsk {
+		return price.LessThanOrEqual(s.stopPrice)
+	}
+	return price.GreaterThanOrEqual(s.stopPrice)
+}
+
+// logError logs an error while following the market, with the exchange and
+// the id of the current stop order
+func (s *TrailingStop) logError(err error) {
+
+	exchange := s.client.Exchange()
+	s.opts.logger.Error(
+		"Trailing stop cannot update stop order",
+		logging.KeyProvider, exchange.Provider,
+		logging.KeyPair, exchange.Pair,
+		logging.KeyOrderId, s.OrderId(),
+		logging.KeyError, err,
+	)
+}
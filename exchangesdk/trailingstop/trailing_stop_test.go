@@ -0,0 +1,429 @@
+package trailingstop_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/dummyclient"
+	"github.com/thecodedproject/crypto/exchangesdk/mockery"
+	"github.com/thecodedproject/crypto/exchangesdk/trailingstop"
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+func D(f float64) decimal.Decimal {
+
+	return decimal.NewFromFloat(f)
+}
+
+type step struct {
+	price             float64
+	expectedStop      float64
+	expectedTriggered bool
+}
+
+// followPath feeds each price of path to the simulated market and then to
+// a trailing stop, checking the stop after each
+func followPath(
+	t *testing.T,
+	o trailingstop.Order,
+	start float64,
+	path []step,
+	opts ...trailingstop.Option,
+) {
+
+	ctx := context.Background()
+
+	sim, err := dummyclient.NewClient("", "", crypto.Exchange{})
+	require.NoError(t, err)
+	sim.UpdateOrderBook(exchangesdk.OrderBook{
+		Bids: []exchangesdk.OrderBookOrder{{Price: 1, Volume: 100}},
+		Asks: []exchangesdk.OrderBookOrder{{Price: 1000, Volume: 100}},
+	})
+
+	s, err := trailingstop.New(ctx, sim, o, D(start), opts...)
+	require.NoError(t, err)
+
+	for i, step := range path {
+		sim.AddTrade(exchangesdk.OrderBookTrade{Price: step.price, Volume: 1})
+		require.NoError(t, s.Update(ctx, D(step.price)))
+
+		assert.Equal(t, step.expectedTriggered, s.Triggered(), "step %d", i)
+		assert.True(t, D(step.expectedStop).Equal(s.StopPrice()),
+			"step %d: expected stop %v, got %s", i, step.expectedStop, s.StopPrice())
+
+		status, err := sim.GetOrderStatus(ctx, s.OrderId())
+		require.NoError(t, err)
+		assert.Equal(
+			t,
+			step.expectedTriggered,
+			status.State != exchangesdk.OrderStateAwaitingTrigger,
+			"step %d", i,
+		)
+	}
+}
+
+func TestAskTrailsByAmount(t *testing.T) {
+
+	followPath(
+		t,
+		trailingstop.Order{
+			Side:        exchangesdk.OrderBookSideAsk,
+			Volume:      D(1),
+			TrailAmount: D(5),
+			LimitOffset: D(1),
+		},
+		100,
+		[]step{
+			{price: 102, expectedStop: 97},
+			{price: 101, expectedStop: 97},
+			{price: 105.5, expectedStop: 100.5},
+			{price: 98, expectedStop: 100.5, expectedTriggered: true},
+		},
+	)
+}
+
+func TestBidTrailsByPercent(t *testing.T) {
+
+	followPath(
+		t,
+		trailingstop.Order{
+			Side:         exchangesdk.OrderBookSideBid,
+			Volume:       D(2),
+			TrailPercent: D(0.01),
+			LimitOffset:  D(0.5),
+		},
+		100,
+		[]step{
+			// Stops are rounded up to the counter precision (2dp)
+			{price: 99.5, expectedStop: 100.5},
+			{price: 99.123, expectedStop: 100.12},
+			{price: 99.9, expectedStop: 100.12},
+			{price: 100.12, expectedStop: 100.12, expectedTriggered: true},
+		},
+	)
+}
+
+func TestMinStepLimitsReposts(t *testing.T) {
+
+	followPath(
+		t,
+		trailingstop.Order{
+			Side:        exchangesdk.OrderBookSideAsk,
+			Volume:      D(1),
+			TrailAmount: D(5),
+		},
+		100,
+		[]step{
+			{price: 100.5, expectedStop: 95},
+			{price: 101, expectedStop: 96},
+			{price: 101.9, expectedStop: 96},
+			{price: 102, expectedStop: 97},
+		},
+		trailingstop.WithMinStep(D(1)),
+	)
+}
+
+func TestFollowReturnsOnceTriggered(t *testing.T) {
+
+	ctx := context.Background()
+
+	sim, err := dummyclient.NewClient("", "", crypto.Exchange{})
+	require.NoError(t, err)
+	sim.UpdateOrderBook(exchangesdk.OrderBook{
+		Bids: []exchangesdk.OrderBookOrder{{Price: 99, Volume: 100}},
+		Asks: []exchangesdk.OrderBookOrder{{Price: 101, Volume: 100}},
+	})
+
+	s, err := trailingstop.New(ctx, sim, trailingstop.Order{
+		Side:        exchangesdk.OrderBookSideAsk,
+		Volume:      D(1),
+		TrailAmount: D(2),
+		LimitOffset: D(10),
+	}, D(100))
+	require.NoError(t, err)
+
+	trades := make(chan exchangesdk.OrderBookTrade)
+	done := make(chan error)
+	go func() {
+		done <- s.Follow(ctx, trades)
+	}()
+
+	// Each trade is sent twice, so that the first has been handled once the
+	// second is received
+	for _, price := range []float64{101, 103, 102} {
+		trade := exchangesdk.OrderBookTrade{Price: price, Volume: 1}
+		sim.AddTrade(trade)
+		trades <- trade
+		trades <- trade
+	}
+	assert.True(t, D(101).Equal(s.StopPrice()))
+
+	// The stop at 101 is triggered and sells into the bids
+	trade := exchangesdk.OrderBookTrade{Price: 101, Volume: 1}
+	sim.AddTrade(trade)
+	trades <- trade
+
+	require.NoError(t, <-done)
+	assert.True(t, s.Triggered())
+	assert.True(t, D(101).Equal(s.StopPrice()))
+
+	status, err := sim.GetOrderStatus(ctx, s.OrderId())
+	require.NoError(t, err)
+	assert.Equal(t, exchangesdk.OrderStateFilled, status.State)
+	assert.True(t, D(99).Equal(status.AverageFillPrice()))
+}
+
+func TestNewWithInvalidOrderReturnsError(t *testing.T) {
+
+	testCases := []struct {
+		name  string
+		order trailingstop.Order
+	}{
+		{
+			name: "No side",
+			order: trailingstop.Order{
+				Volume:      D(1),
+				TrailAmount: D(1),
+			},
+		},
+		{
+			name: "No trail",
+			order: trailingstop.Order{
+				Side:   exchangesdk.OrderBookSideAsk,
+				Volume: D(1),
+			},
+		},
+		{
+			name: "Trail amount and percent",
+			order: trailingstop.Order{
+				Side:         exchangesdk.OrderBookSideAsk,
+				Volume:       D(1),
+				TrailAmount:  D(1),
+				TrailPercent: D(0.01),
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			client := new(mockery.Client).TSetup(t)
+			_, err := trailingstop.New(context.Background(), client, test.order, D(100))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestStopTriggeredBeforeCancelIsNotReposted(t *testing.T) {
+
+	ctx := context.Background()
+
+	client := new(mockery.Client).TSetup(t)
+	client.On("CounterPrecision").Return(int32(2))
+	client.On("PostStopLimitOrder", mock.Anything, exchangesdk.StopLimitOrder{
+		Side:       exchangesdk.OrderBookSideAsk,
+		StopPrice:  D(95),
+		LimitPrice: D(95),
+		Volume:     D(1),
+	}).Return("stop1", nil).Once()
+
+	s, err := trailingstop.New(ctx, client, trailingstop.Order{
+		Side:        exchangesdk.OrderBookSideAsk,
+		Volume:      D(1),
+		TrailAmount: D(5),
+	}, D(100))
+	require.NoError(t, err)
+
+	client.On("CancelOrder", mock.Anything, "stop1").Return(assert.AnError).Once()
+	client.On("GetOrderStatus", mock.Anything, "stop1").Return(exchangesdk.OrderStatus{
+		State: exchangesdk.OrderStateInOrderBook,
+	}, nil).Once()
+
+	require.NoError(t, s.Update(ctx, D(101)))
+	assert.True(t, s.Triggered())
+	assert.True(t, D(95).Equal(s.StopPrice()))
+
+	// Once triggered, the stop no longer trails
+	require.NoError(t, s.Update(ctx, D(110)))
+}
+
+func TestFailedCancelOfUntriggeredStopReturnsError(t *testing.T) {
+
+	ctx := context.Background()
+
+	client := new(mockery.Client).TSetup(t)
+	client.On("CounterPrecision").Return(int32(2))
+	client.On("PostStopLimitOrder", mock.Anything, mock.Anything).Return("stop1", nil).Once()
+
+	s, err := trailingstop.New(ctx, client, trailingstop.Order{
+		Side:        exchangesdk.OrderBookSideAsk,
+		Volume:      D(1),
+		TrailAmount: D(5),
+	}, D(100))
+	require.NoError(t, err)
+
+	client.On("CancelOrder", mock.Anything, "stop1").Return(assert.AnError).Once()
+	client.On("GetOrderStatus", mock.Anything, "stop1").Return(exchangesdk.OrderStatus{
+		State: exchangesdk.OrderStateAwaitingTrigger,
+	}, nil).Once()
+
+	err = s.Update(ctx, D(101))
+	assert.Equal(t, assert.AnError, err)
+	assert.False(t, s.Triggered())
+	assert.Equal(t, "stop1", s.OrderId())
+}
+
+func TestStopIsRepostedOnlyOnceCancelIsConfirmed(t *testing.T) {
+
+	ctx := context.Background()
+	clock := utiltime.NewSimulatedClock(time.Unix(1000, 0))
+
+	client := new(mockery.Client).TSetup(t)
+	client.On("CounterPrecision").Return(int32(2))
+	client.On("PostStopLimitOrder", mock.Anything, mock.Anything).Return("stop1", nil).Once()
+
+	s, err := trailingstop.New(ctx, client, trailingstop.Order{
+		Side:        exchangesdk.OrderBookSideAsk,
+		Volume:      D(1),
+		TrailAmount: D(5),
+	}, D(100), trailingstop.WithClock(clock))
+	require.NoError(t, err)
+
+	// The cancel is accepted, but the old stop stays live until a later poll
+	client.On("CancelOrder", mock.Anything, "stop1").Return(nil).Once()
+	client.On("GetOrderStatus", mock.Anything, "stop1").Return(exchangesdk.OrderStatus{
+		State: exchangesdk.OrderStateAwaitingTrigger,
+	}, nil).Once()
+	client.On("GetOrderStatus", mock.Anything, "stop1").Return(exchangesdk.OrderStatus{
+		State: exchangesdk.OrderStateCancelled,
+	}, nil).Once()
+	client.On("PostStopLimitOrder", mock.Anything, exchangesdk.StopLimitOrder{
+		Side:       exchangesdk.OrderBookSideAsk,
+		StopPrice:  D(105),
+		LimitPrice: D(105),
+		Volume:     D(1),
+	}).Return("stop2", nil).Once()
+
+	done := make(chan error)
+	go func() {
+		done <- s.Update(ctx, D(110))
+	}()
+
+	require.Eventually(t, func() bool {
+		return clock.Waiters() == 1
+	}, time.Second, time.Millisecond)
+	client.AssertNumberOfCalls(t, "PostStopLimitOrder", 1)
+
+	clock.Advance(time.Second)
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Update did not return once the cancel was confirmed")
+	}
+	assert.False(t, s.Triggered())
+	assert.Equal(t, "stop2", s.OrderId())
+	assert.True(t, D(105).Equal(s.StopPrice()))
+}
+
+func TestStopTriggeredWhileCancelIsConfirmedIsNotReposted(t *testing.T) {
+
+	ctx := context.Background()
+	clock := utiltime.NewSimulatedClock(time.Unix(1000, 0))
+
+	client := new(mockery.Client).TSetup(t)
+	client.On("CounterPrecision").Return(int32(2))
+	client.On("PostStopLimitOrder", mock.Anything, mock.Anything).Return("stop1", nil).Once()
+
+	s, err := trailingstop.New(ctx, client, trailingstop.Order{
+		Side:        exchangesdk.OrderBookSideAsk,
+		Volume:      D(1),
+		TrailAmount: D(5),
+	}, D(100), trailingstop.WithClock(clock))
+	require.NoError(t, err)
+
+	client.On("CancelOrder", mock.Anything, "stop1").Return(nil).Once()
+	client.On("GetOrderStatus", mock.Anything, "stop1").Return(exchangesdk.OrderStatus{
+		State: exchangesdk.OrderStateInOrderBook,
+	}, nil).Once()
+	client.On("GetOrderStatus", mock.Anything, "stop1").Return(exchangesdk.OrderStatus{
+		State:          exchangesdk.OrderStateFilled,
+		FillAmountBase: D(1),
+	}, nil).Once()
+
+	done := make(chan error)
+	go func() {
+		done <- s.Update(ctx, D(110))
+	}()
+
+	require.Eventually(t, func() bool {
+		return clock.Waiters() == 1
+	}, time.Second, time.Millisecond)
+	clock.Advance(time.Second)
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Update did not return once the cancel was confirmed")
+	}
+	assert.True(t, s.Triggered())
+	assert.Equal(t, "stop1", s.OrderId())
+}
+
+func TestFollowLatestPriceIsTimedByClock(t *testing.T) {
+
+	ctx := context.Background()
+	clock := utiltime.NewSimulatedClock(time.Unix(1000, 0))
+
+	sim, err := dummyclient.NewClient("", "", crypto.Exchange{})
+	require.NoError(t, err)
+	sim.UpdateOrderBook(exchangesdk.OrderBook{
+		Bids: []exchangesdk.OrderBookOrder{{Price: 99, Volume: 100}},
+		Asks: []exchangesdk.OrderBookOrder{{Price: 101, Volume: 100}},
+	})
+	sim.AddTrade(exchangesdk.OrderBookTrade{Price: 100, Volume: 1})
+
+	s, err := trailingstop.New(ctx, sim, trailingstop.Order{
+		Side:        exchangesdk.OrderBookSideAsk,
+		Volume:      D(1),
+		TrailAmount: D(2),
+		LimitOffset: D(10),
+	}, D(100), trailingstop.WithClock(clock))
+	require.NoError(t, err)
+
+	done := make(chan error)
+	go func() {
+		done <- s.FollowLatestPrice(ctx, time.Minute)
+	}()
+
+	require.Eventually(t, func() bool {
+		return clock.Waiters() == 1
+	}, time.Second, time.Millisecond)
+
+	// The stop only moves once the clock has passed the interval
+	sim.AddTrade(exchangesdk.OrderBookTrade{Price: 103, Volume: 1})
+	assert.True(t, D(98).Equal(s.StopPrice()))
+
+	clock.Advance(time.Minute)
+	require.Eventually(t, func() bool {
+		return D(101).Equal(s.StopPrice())
+	}, time.Second, time.Millisecond)
+
+	sim.AddTrade(exchangesdk.OrderBookTrade{Price: 101, Volume: 1})
+	clock.Advance(time.Minute)
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("FollowLatestPrice did not return once triggered")
+	}
+	assert.True(t, s.Triggered())
+}
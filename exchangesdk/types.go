@@ -1,6 +1,7 @@
 package exchangesdk
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/shopspring/decimal"
@@ -8,6 +9,7 @@ import (
 
 //go:generate enumer -type=OrderBookSide -trimprefix=OrderBookSide -json -text -transform=snake
 //go:generate enumer -type=OrderState -trimprefix=OrderState -json -text -transform=snake
+//go:generate enumer -type=OrderUpdateType -trimprefix=OrderUpdateType -json -text -transform=snake
 
 type OrderBook struct {
 	Timestamp time.Time
@@ -42,6 +44,41 @@ const (
 	OrderStateSentinal
 )
 
+type OrderUpdateType int
+
+const (
+	OrderUpdateTypeUnknown OrderUpdateType = iota
+	// OrderUpdateTypeStateChange is sent when an order is placed and on each
+	// later change of its OrderState
+	OrderUpdateTypeStateChange
+	// OrderUpdateTypeFill is sent for each fill of an order
+	OrderUpdateTypeFill
+	OrderUpdateTypeSentinal
+)
+
+// OrderUpdate is an event on one of our orders, as streamed by
+// OrderUpdatesClient
+type OrderUpdate struct {
+	Type      OrderUpdateType
+	OrderId   string
+	Timestamp time.Time
+
+	// State is the state of the order following the update
+	State OrderState
+
+	// FillAmountBase and FillAmountCounter are the total amounts filled so
+	// far (as in OrderStatus)
+	FillAmountBase    decimal.Decimal
+	FillAmountCounter decimal.Decimal
+
+	// FillPrice, FillVolume, BaseFee and CounterFee describe the fill of an
+	// OrderUpdateTypeFill update
+	FillPrice  decimal.Decimal
+	FillVolume decimal.Decimal
+	BaseFee    decimal.Decimal
+	CounterFee decimal.Decimal
+}
+
 // OrderBookTrade represents a trade as seen in the OrderBook
 type OrderBookTrade struct {
 	MakerSide OrderBookSide
@@ -57,6 +94,44 @@ type StopLimitOrder struct {
 	Volume     decimal.Decimal
 }
 
+// OCOOrder is a one-cancels-other pair of orders for the same volume on the
+// same side; a limit order (e.g. a take profit) and a stop limit order (e.g.
+// a stop loss). Once either is filled or triggered the other is cancelled.
+//
+// For an ask LimitPrice must be above StopPrice, and for a bid below it.
+type OCOOrder struct {
+	Side           OrderBookSide   `json:"side"`
+	Volume         decimal.Decimal `json:"volume"`
+	LimitPrice     decimal.Decimal `json:"limit_price"`
+	StopPrice      decimal.Decimal `json:"stop_price"`
+	StopLimitPrice decimal.Decimal `json:"stop_limit_price"`
+}
+
+// Validate returns an error if the prices of o are on the wrong sides of
+// each other for its side
+func (o OCOOrder) Validate() error {
+
+	switch o.Side {
+	case OrderBookSideAsk:
+		if !o.LimitPrice.GreaterThan(o.StopPrice) {
+			return fmt.Errorf("OCO ask limit price %s must be above its stop price %s", o.LimitPrice, o.StopPrice)
+		}
+	case OrderBookSideBid:
+		if !o.LimitPrice.LessThan(o.StopPrice) {
+			return fmt.Errorf("OCO bid limit price %s must be below its stop price %s", o.LimitPrice, o.StopPrice)
+		}
+	default:
+		return fmt.Errorf("OCO order has invalid side %s", o.Side)
+	}
+	return nil
+}
+
+// OCOOrderIds are the ids of the two legs of a placed OCOOrder
+type OCOOrderIds struct {
+	LimitOrderId string `json:"limit_order_id"`
+	StopOrderId  string `json:"stop_order_id"`
+}
+
 type OrderStatus struct {
 	State             OrderState
 	Type              OrderType
@@ -64,6 +139,20 @@ type OrderStatus struct {
 	FillAmountCounter decimal.Decimal
 }
 
+// ReplaceResult reports which legs of a replace succeeded; the cancel of the
+// original order and the post of its replacement
+type ReplaceResult struct {
+	// Cancelled is set once the original order is known to have been
+	// cancelled. Original is its final status once it is known to be out of
+	// the order book, whether it was cancelled or filled.
+	Cancelled bool
+	Original  OrderStatus
+
+	// NewOrderId is the id of the replacement order, or empty if it was not
+	// posted
+	NewOrderId string
+}
+
 func (os OrderStatus) AverageFillPrice() decimal.Decimal {
 
 	if os.FillAmountBase.IsZero() {
@@ -73,6 +162,17 @@ func (os OrderStatus) AverageFillPrice() decimal.Decimal {
 	return os.FillAmountCounter.Div(os.FillAmountBase)
 }
 
+// OpenOrder is one of our orders which is open; either resting in the order
+// book, or (for stop orders) awaiting its trigger
+type OpenOrder struct {
+	Id             string
+	Side           OrderBookSide
+	State          OrderState
+	LimitPrice     decimal.Decimal
+	Volume         decimal.Decimal
+	FillAmountBase decimal.Decimal
+}
+
 
 type Trade struct {
 	OrderId    string `json:"order_id"`
@@ -83,3 +183,14 @@ type Trade struct {
 	CounterFee decimal.Decimal `json:"counter_fee"`
 	Type       OrderType `json:"type"`
 }
+
+// Candle represents the open, high, low, close and volume (OHLCV) market
+// data for a single interval, starting at Timestamp
+type Candle struct {
+	Timestamp time.Time `json:"timestamp"`
+	Open      float64   `json:"open"`
+	High      float64   `json:"high"`
+	Low       float64   `json:"low"`
+	Close     float64   `json:"close"`
+	Volume    float64   `json:"volume"`
+}
@@ -4,6 +4,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thecodedproject/crypto"
 	"github.com/thecodedproject/crypto/io"
 )
 
@@ -11,6 +13,10 @@ func TestReadAuthFile(t *testing.T) {
 
 	authFile := "example_api_auth.json"
 
-	_, err := io.ReadAuthFile(authFile)
-	assert.NoError(t, err)
+	keys, err := io.ReadAuthFile(authFile)
+	require.NoError(t, err)
+
+	assert.Equal(t, crypto.ApiProviderLuno, keys["dummy_creds"].Provider)
+	assert.Equal(t, crypto.ApiProviderBinance, keys["dummy_creds_2"].Provider)
+	assert.Equal(t, crypto.ApiProviderBinanceTestnet, keys["dummy_creds_3"].Provider)
 }
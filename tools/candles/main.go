@@ -0,0 +1,192 @@
+package main
+
+// candles downloads historical candle (OHLCV) data from an exchange
+// and writes it to a CSV or JSON file for offline use (e.g. backtesting).
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	goio "io"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/factory"
+	"github.com/thecodedproject/crypto/io"
+)
+
+var (
+	providerName = flag.String("provider", "binance", "Api provider to download candles from")
+	pairName     = flag.String("pair", "btceur", "Exchange pair to download candles for")
+	authName     = flag.String("api_auth", "", "API auth name to use (required for providers which need auth, e.g. luno)")
+	authPath     = flag.String("auth_path", "api_auth.json", "Auth file path")
+	interval     = flag.Duration("interval", time.Minute, "Candle interval")
+	fromStr      = flag.String("from", "", "Start time (RFC3339)")
+	toStr        = flag.String("to", "", "End time (RFC3339); defaults to now")
+	format       = flag.String("format", "csv", "Output format [csv|json]")
+	outPath      = flag.String("out", "", "Output file path; defaults to stdout")
+)
+
+func parseTimeRange() (time.Time, time.Time, error) {
+
+	if *fromStr == "" {
+		return time.Time{}, time.Time{}, fmt.Errorf("from is required")
+	}
+
+	from, err := time.Parse(time.RFC3339, *fromStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	to := time.Now()
+	if *toStr != "" {
+		to, err = time.Parse(time.RFC3339, *toStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from (%s) must be before to (%s)", from, to)
+	}
+
+	return from, to, nil
+}
+
+func newCandlesClient(exchange crypto.Exchange) (exchangesdk.HistoricalCandlesClient, error) {
+
+	var auth crypto.AuthConfig
+	if *authName != "" {
+		var err error
+		auth, err = io.GetAuthConfigByName(*authPath, *authName)
+		if err != nil {
+			return nil, err
+		}
+		if auth.Provider != exchange.Provider {
+			return nil, fmt.Errorf(
+				"api auth `%s` is for provider %s; expected %s",
+				*authName,
+				auth.Provider,
+				exchange.Provider,
+			)
+		}
+	}
+
+	c, err := factory.NewClient(exchange, auth.Key, auth.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	candlesClient, ok := c.(exchangesdk.HistoricalCandlesClient)
+	if !ok {
+		return nil, fmt.Errorf("provider %s does not support historical candles", exchange.Provider)
+	}
+
+	return candlesClient, nil
+}
+
+func writeCsv(w goio.Writer, candles []exchangesdk.Candle) error {
+
+	csvWriter := csv.NewWriter(w)
+
+	err := csvWriter.Write([]string{"timestamp", "open", "high", "low", "close", "volume"})
+	if err != nil {
+		return err
+	}
+
+	formatFloat := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	for _, c := range candles {
+		err := csvWriter.Write([]string{
+			c.Timestamp.UTC().Format(time.RFC3339),
+			formatFloat(c.Open),
+			formatFloat(c.High),
+			formatFloat(c.Low),
+			formatFloat(c.Close),
+			formatFloat(c.Volume),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+func writeJson(w goio.Writer, candles []exchangesdk.Candle) error {
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(candles)
+}
+
+func writeCandles(candles []exchangesdk.Candle) error {
+
+	var w goio.Writer = os.Stdout
+	if *outPath != "" {
+		f, err := os.Create(*outPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	switch *format {
+	case "csv":
+		return writeCsv(w, candles)
+	case "json":
+		return writeJson(w, candles)
+	default:
+		return fmt.Errorf("Unknown output format `%s`", *format)
+	}
+}
+
+func main() {
+
+	flag.Parse()
+
+	provider, err := crypto.ApiProviderString(*providerName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	pair, err := crypto.PairString(*pairName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	from, to, err := parseTimeRange()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	c, err := newCandlesClient(crypto.Exchange{
+		Provider: provider,
+		Pair:     pair,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	candles, err := c.HistoricalCandles(context.Background(), *interval, from, to)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = writeCandles(candles)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Wrote %d candles\n", len(candles))
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+)
+
+// ANSI escape codes used to draw the ladder
+const (
+	ansiReset   = "\x1b[0m"
+	ansiRed     = "\x1b[31m"
+	ansiGreen   = "\x1b[32m"
+	ansiDim     = "\x1b[2m"
+	ansiReverse = "\x1b[7m"
+
+	ansiClearScreen = "\x1b[H\x1b[2J"
+	ansiHideCursor  = "\x1b[?25l"
+	ansiShowCursor  = "\x1b[?25h"
+)
+
+// ladder is the state of the market which is drawn; it is updated by the
+// market follower and the open orders poller, and rendered periodically
+type ladder struct {
+	exchange crypto.Exchange
+
+	// depth is the number of levels shown on each side of the book, and
+	// maxTrades the number of recent trades shown
+	depth     int
+	maxTrades int
+
+	priceDecimals  int
+	volumeDecimals int
+	colour         bool
+
+	mu       sync.Mutex
+	book     *exchangesdk.OrderBook
+	trades   []exchangesdk.OrderBookTrade
+	orders   []exchangesdk.OpenOrder
+	ordersOk bool
+	// ordersStatus describes why our orders are not shown, if they are not
+	ordersStatus string
+}
+
+// level is a row of the ladder
+type level struct {
+	price float64
+	// volume is the market volume at the price, and cumulative the market
+	// volume at the price and all better prices
+	volume     float64
+	cumulative float64
+	// ours is the remaining volume of our orders at the price
+	ours float64
+}
+
+func (l *ladder) setOrderBook(ob exchangesdk.OrderBook) {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.book = &ob
+}
+
+// addTrade adds a market trade, keeping only the most recent
+func (l *ladder) addTrade(trade exchangesdk.OrderBookTrade) {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.trades = append([]exchangesdk.OrderBookTrade{trade}, l.trades...)
+	if len(l.trades) > l.maxTrades {
+		l.trades = l.trades[:l.maxTrades]
+	}
+}
+
+// setOpenOrders sets our open orders, or the reason that they are not
+// available if err is not nil
+func (l *ladder) setOpenOrders(orders []exchangesdk.OpenOrder, err error) {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err != nil {
+		l.ordersOk = false
+		l.ordersStatus = fmt.Sprintf("failed to fetch our orders: %v", err)
+		return
+	}
+	l.orders = orders
+	l.ordersOk = true
+	l.ordersStatus = ""
+}
+
+func (l *ladder) setOrdersUnavailable(reason string) {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.ordersOk = false
+	l.ordersStatus = reason
+}
+
+// levels returns the rows of one side of the ladder, best price first: the
+// top depth levels of the book, and the levels of our resting orders on
+// that side
+func (l *ladder) levels(side exchangesdk.OrderBookSide) []level {
+
+	var bookOrders []exchangesdk.OrderBookOrder
+	if l.book != nil {
+		bookOrders = l.book.Bids
+		if side == exchangesdk.OrderBookSideAsk {
+			bookOrders = l.book.Asks
+		}
+	}
+	better := func(a, b float64) bool {
+		if side == exchangesdk.OrderBookSideBid {
+			return a > b
+		}
+		return a < b
+	}
+
+	byPrice := make(map[float64]*level)
+	for i, o := range bookOrders {
+		if i == l.depth {
+			break
+		}
+		byPrice[o.Price] = &level{price: o.Price, volume: o.Volume}
+	}
+
+	if l.ordersOk {
+		for _, o := range l.orders {
+			if o.Side != side || o.State != exchangesdk.OrderStateInOrderBook {
+				continue
+			}
+			price, _ := o.LimitPrice.Float64()
+			remaining, _ := o.Volume.Sub(o.FillAmountBase).Float64()
+			lvl, ok := byPrice[price]
+			if !ok {
+				lvl = &level{price: price}
+				byPrice[price] = lvl
+			}
+			lvl.ours += remaining
+		}
+	}
+
+	levels := make([]level, 0, len(byPrice))
+	for _, lvl := range byPrice {
+		levels = append(levels, *lvl)
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		return better(levels[i].price, levels[j].price)
+	})
+
+	// The cumulative volume includes the whole book, including any levels
+	// which are better than our orders but beyond the depth shown
+	var cumulative float64
+	next := 0
+	for i := range levels {
+		for next < len(bookOrders) && !better(levels[i].price, bookOrders[next].Price) {
+			cumulative += bookOrders[next].Volume
+			next++
+		}
+		levels[i].cumulative = cumulative
+	}
+	return levels
+}
+
+// render draws the ladder to w
+func (l *ladder) render(w io.Writer, now time.Time) {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s  %s\n\n", l.exchange, now.Format("2006-01-02 15:04:05"))
+	if l.book == nil {
+		fmt.Fprintf(&b, "Waiting for order book...\n")
+		io.WriteString(w, b.String())
+		return
+	}
+
+	fmt.Fprintf(&b, "%-4s %12s %14s %14s %14s\n", "", "Ours", "Volume", "Cumulative", "Price")
+
+	asks := l.levels(exchangesdk.OrderBookSideAsk)
+	bids := l.levels(exchangesdk.OrderBookSideBid)
+
+	// Asks are drawn from the worst price down to the best, so that the best
+	// bid and ask meet in the middle of the ladder
+	for i := len(asks) - 1; i >= 0; i-- {
+		l.writeLevel(&b, "ASK", ansiRed, asks[i])
+	}
+	b.WriteString(l.spreadLine())
+	for _, lvl := range bids {
+		l.writeLevel(&b, "BID", ansiGreen, lvl)
+	}
+
+	b.WriteString("\nRecent trades\n")
+	for _, trade := range l.trades {
+		l.writeTrade(&b, trade)
+	}
+
+	b.WriteString("\nOur orders\n")
+	switch {
+	case !l.ordersOk:
+		fmt.Fprintf(&b, "  %s\n", l.ordersStatus)
+	case len(l.orders) == 0:
+		b.WriteString("  none\n")
+	default:
+		for _, o := range l.orders {
+			fmt.Fprintf(
+				&b,
+				"  %-20s %-4s %-16s %14s @ %s\n",
+				o.Id,
+				o.Side,
+				o.State,
+				o.Volume.Sub(o.FillAmountBase).StringFixed(int32(l.volumeDecimals)),
+				o.LimitPrice.StringFixed(int32(l.priceDecimals)),
+			)
+		}
+	}
+
+	io.WriteString(w, b.String())
+}
+
+func (l *ladder) writeLevel(b *strings.Builder, label, colour string, lvl level) {
+
+	ours := ""
+	if lvl.ours > 0 {
+		ours = l.volume(lvl.ours)
+	}
+	volume := ""
+	if lvl.volume > 0 {
+		volume = l.volume(lvl.volume)
+	}
+
+	row := fmt.Sprintf(
+		"%-4s %12s %14s %14s %14s",
+		label,
+		ours,
+		volume,
+		l.volume(lvl.cumulative),
+		l.price(lvl.price),
+	)
+
+	if lvl.ours > 0 {
+		// Our orders are highlighted, and marked for terminals without colour
+		row = l.style(ansiReverse+colour, row) + " <"
+	} else {
+		row = l.style(colour, row)
+	}
+	b.WriteString(row + "\n")
+}
+
+func (l *ladder) spreadLine() string {
+
+	if len(l.book.Bids) == 0 || len(l.book.Asks) == 0 {
+		return l.style(ansiDim, "---- one sided book ----") + "\n"
+	}
+
+	bid := l.book.Bids[0].Price
+	ask := l.book.Asks[0].Price
+	line := fmt.Sprintf(
+		"---- spread %s  mid %s ----",
+		l.price(ask-bid),
+		l.price((ask+bid)/2),
+	)
+	return l.style(ansiDim, line) + "\n"
+}
+
+// writeTrade writes a market trade, coloured by the side of its taker: a
+// trade with an ask maker was a buy, and one with a bid maker was a sell
+func (l *ladder) writeTrade(b *strings.Builder, trade exchangesdk.OrderBookTrade) {
+
+	side, colour := "", ""
+	switch trade.MakerSide {
+	case exchangesdk.OrderBookSideAsk:
+		side, colour = "BUY", ansiGreen
+	case exchangesdk.OrderBookSideBid:
+		side, colour = "SELL", ansiRed
+	default:
+		side = "?"
+	}
+
+	row := fmt.Sprintf(
+		"  %s %-4s %14s %14s",
+		trade.Timestamp.Format("15:04:05"),
+		side,
+		l.volume(trade.Volume),
+		l.price(trade.Price),
+	)
+	b.WriteString(l.style(colour, row) + "\n")
+}
+
+func (l *ladder) price(p float64) string {
+
+	return fmt.Sprintf("%.*f", l.priceDecimals, p)
+}
+
+func (l *ladder) volume(v float64) string {
+
+	return fmt.Sprintf("%.*f", l.volumeDecimals, v)
+}
+
+// style wraps s in the ANSI style, if colour is enabled
+func (l *ladder) style(style, s string) string {
+
+	if !l.colour || style == "" {
+		return s
+	}
+	return style + s + ansiReset
+}
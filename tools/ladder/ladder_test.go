@@ -0,0 +1,156 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+)
+
+func newTestLadder() *ladder {
+
+	return &ladder{
+		exchange:       crypto.Exchange{Provider: crypto.ApiProviderDummyExchange, Pair: crypto.PairBTCEUR},
+		depth:          2,
+		maxTrades:      2,
+		priceDecimals:  2,
+		volumeDecimals: 2,
+	}
+}
+
+func renderLines(l *ladder) []string {
+
+	var b strings.Builder
+	l.render(&b, time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC))
+
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		lines = append(lines, strings.Join(strings.Fields(line), " "))
+	}
+	return lines
+}
+
+func TestRenderLadderWithOurOrders(t *testing.T) {
+
+	l := newTestLadder()
+	l.setOrderBook(exchangesdk.OrderBook{
+		Bids: []exchangesdk.OrderBookOrder{
+			{Price: 100, Volume: 1},
+			{Price: 99, Volume: 2},
+			{Price: 98, Volume: 4},
+		},
+		Asks: []exchangesdk.OrderBookOrder{
+			{Price: 101, Volume: 0.5},
+			{Price: 102, Volume: 1.5},
+		},
+	})
+	l.addTrade(exchangesdk.OrderBookTrade{
+		MakerSide: exchangesdk.OrderBookSideBid,
+		Price:     100,
+		Volume:    0.1,
+		Timestamp: time.Date(2021, 3, 1, 11, 59, 58, 0, time.UTC),
+	})
+	l.addTrade(exchangesdk.OrderBookTrade{
+		MakerSide: exchangesdk.OrderBookSideAsk,
+		Price:     101,
+		Volume:    0.2,
+		Timestamp: time.Date(2021, 3, 1, 11, 59, 59, 0, time.UTC),
+	})
+	l.setOpenOrders([]exchangesdk.OpenOrder{
+		{
+			Id:             "bid-1",
+			Side:           exchangesdk.OrderBookSideBid,
+			State:          exchangesdk.OrderStateInOrderBook,
+			LimitPrice:     decimal.NewFromFloat(99),
+			Volume:         decimal.NewFromFloat(0.5),
+			FillAmountBase: decimal.NewFromFloat(0.2),
+		},
+		{
+			// Beyond the depth shown, but still drawn as a level
+			Id:         "bid-2",
+			Side:       exchangesdk.OrderBookSideBid,
+			State:      exchangesdk.OrderStateInOrderBook,
+			LimitPrice: decimal.NewFromFloat(97.5),
+			Volume:     decimal.NewFromFloat(1),
+		},
+		{
+			// Stops are listed, but are not in the ladder
+			Id:         "stop-1",
+			Side:       exchangesdk.OrderBookSideAsk,
+			State:      exchangesdk.OrderStateAwaitingTrigger,
+			LimitPrice: decimal.NewFromFloat(95),
+			Volume:     decimal.NewFromFloat(1),
+		},
+	}, nil)
+
+	assert.Equal(t, []string{
+		"dummy_exchange__btceur 2021-03-01 12:00:00",
+		"",
+		"Ours Volume Cumulative Price",
+		"ASK 1.50 2.00 102.00",
+		"ASK 0.50 0.50 101.00",
+		"---- spread 1.00 mid 100.50 ----",
+		"BID 1.00 1.00 100.00",
+		"BID 0.30 2.00 3.00 99.00 <",
+		"BID 1.00 7.00 97.50 <",
+		"",
+		"Recent trades",
+		"11:59:59 BUY 0.20 101.00",
+		"11:59:58 SELL 0.10 100.00",
+		"",
+		"Our orders",
+		"bid-1 bid in_order_book 0.30 @ 99.00",
+		"bid-2 bid in_order_book 1.00 @ 97.50",
+		"stop-1 ask awaiting_trigger 1.00 @ 95.00",
+	}, renderLines(l))
+}
+
+func TestRenderLadderColoursAndHighlights(t *testing.T) {
+
+	l := newTestLadder()
+	l.colour = true
+	l.setOrderBook(exchangesdk.OrderBook{
+		Bids: []exchangesdk.OrderBookOrder{{Price: 100, Volume: 1}},
+		Asks: []exchangesdk.OrderBookOrder{{Price: 101, Volume: 1}},
+	})
+	l.setOpenOrders([]exchangesdk.OpenOrder{{
+		Id:         "ask-1",
+		Side:       exchangesdk.OrderBookSideAsk,
+		State:      exchangesdk.OrderStateInOrderBook,
+		LimitPrice: decimal.NewFromFloat(101),
+		Volume:     decimal.NewFromFloat(0.5),
+	}}, nil)
+	l.addTrade(exchangesdk.OrderBookTrade{
+		MakerSide: exchangesdk.OrderBookSideBid,
+		Price:     100,
+		Volume:    0.1,
+	})
+
+	var b strings.Builder
+	l.render(&b, time.Now())
+	out := b.String()
+
+	assert.Contains(t, out, ansiReverse+ansiRed+"ASK")
+	assert.Contains(t, out, ansiGreen+"BID")
+	assert.Contains(t, out, ansiRed+"  00:00:00 SELL")
+}
+
+func TestRenderLadderWithoutOrders(t *testing.T) {
+
+	l := newTestLadder()
+	l.setOrdersUnavailable("not shown; no api_auth given")
+
+	lines := renderLines(l)
+	assert.Equal(t, "Waiting for order book...", lines[len(lines)-1])
+
+	l.setOrderBook(exchangesdk.OrderBook{
+		Bids: []exchangesdk.OrderBookOrder{{Price: 100, Volume: 1}},
+	})
+	lines = renderLines(l)
+	assert.Contains(t, lines, "---- one sided book ----")
+	assert.Equal(t, "not shown; no api_auth given", lines[len(lines)-1])
+}
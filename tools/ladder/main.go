@@ -0,0 +1,302 @@
+package main
+
+// ladder is a terminal viewer of a market's live price ladder: the levels of
+// the order book with their cumulative depth, the spread and mid price, the
+// recent trades and our own resting orders, which are highlighted in the
+// ladder.
+//
+// It runs against any provider of factory.NewMarketFollower, including the
+// dummy and replay providers, which need no network; with those, --demo_orders
+// places orders on the simulated exchange so that they are shown.
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/factory"
+	"github.com/thecodedproject/crypto/exchangesdk/recording"
+	"github.com/thecodedproject/crypto/io"
+)
+
+var (
+	providerName   = flag.String("provider", "dummy_exchange", "Api provider to follow")
+	pairName       = flag.String("pair", "btceur", "Exchange pair to follow")
+	authName       = flag.String("api_auth", "", "API auth name to use; required for luno, and to show our orders on real exchanges")
+	authPath       = flag.String("auth_path", "api_auth.json", "Auth file path")
+	replayPath     = flag.String("replay", "", "Path of recording to replay when using the replay provider")
+	depth          = flag.Int("depth", 10, "Number of levels to show on each side of the book")
+	maxTrades      = flag.Int("trades", 10, "Number of recent trades to show")
+	priceDecimals  = flag.Int("price_decimals", 2, "Decimal places of prices")
+	volumeDecimals = flag.Int("volume_decimals", 4, "Decimal places of volumes")
+	refresh        = flag.Duration("refresh", 250*time.Millisecond, "Period at which the ladder is redrawn")
+	ordersPeriod   = flag.Duration("orders_period", 2*time.Second, "Period at which our open orders are fetched")
+	noColour       = flag.Bool("no_colour", false, "Draw the ladder without colour")
+	demoOrders     = flag.Bool("demo_orders", false, "Place a bid and an ask at the top of the book (simulated providers only)")
+)
+
+func isSimulated(provider crypto.ApiProvider) bool {
+
+	switch provider {
+	case crypto.ApiProviderDummyExchange,
+		crypto.ApiProviderDummyExchangeBinanceMarket,
+		crypto.ApiProviderReplay:
+		return true
+	default:
+		return false
+	}
+}
+
+func parseExchange() (crypto.Exchange, error) {
+
+	provider, err := crypto.ApiProviderString(*providerName)
+	if err != nil {
+		return crypto.Exchange{}, err
+	}
+	pair, err := crypto.PairString(*pairName)
+	if err != nil {
+		return crypto.Exchange{}, err
+	}
+	return crypto.Exchange{
+		Provider: provider,
+		Pair:     pair,
+	}, nil
+}
+
+func getApiAuth(exchange crypto.Exchange) (crypto.AuthConfig, error) {
+
+	if *authName == "" {
+		if exchange.Provider == crypto.ApiProviderLuno {
+			return crypto.AuthConfig{}, fmt.Errorf("api_auth is required for %s", exchange.Provider)
+		}
+		return crypto.AuthConfig{Provider: exchange.Provider}, nil
+	}
+
+	auth, err := io.GetAuthConfigByName(*authPath, *authName)
+	if err != nil {
+		return crypto.AuthConfig{}, err
+	}
+	if auth.Provider != exchange.Provider {
+		return crypto.AuthConfig{}, fmt.Errorf(
+			"api auth `%s` is for provider %s; expected %s",
+			*authName,
+			auth.Provider,
+			exchange.Provider,
+		)
+	}
+	return auth, nil
+}
+
+// newOpenOrdersClient returns the client which our orders are fetched with,
+// or the reason that they cannot be
+func newOpenOrdersClient(
+	exchange crypto.Exchange,
+	apiAuth crypto.AuthConfig,
+) (exchangesdk.Client, string) {
+
+	if apiAuth.Key == "" && !isSimulated(exchange.Provider) {
+		return nil, "not shown; no api_auth given"
+	}
+
+	// Simulated providers share their exchange between the client and the
+	// market follower, so that orders placed with the client are shown
+	client, err := factory.NewClient(exchange, apiAuth.Key, apiAuth.Secret)
+	if err != nil {
+		return nil, fmt.Sprintf("not shown; failed to create client: %v", err)
+	}
+
+	if _, ok := client.(exchangesdk.OpenOrdersClient); !ok {
+		return nil, fmt.Sprintf("not shown; %s cannot list open orders", exchange.Provider)
+	}
+	return client, ""
+}
+
+// followOpenOrders fetches our open orders into l every ordersPeriod until
+// ctx is cancelled
+func followOpenOrders(
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	client exchangesdk.OpenOrdersClient,
+	l *ladder,
+) {
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(*ordersPeriod)
+		defer ticker.Stop()
+
+		for {
+			l.setOpenOrders(client.OpenOrders(ctx))
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// placeDemoOrders places a bid at the best bid and an ask at the best ask
+// of ob, so that they rest at the top of the book
+func placeDemoOrders(
+	ctx context.Context,
+	client exchangesdk.Client,
+	ob exchangesdk.OrderBook,
+) error {
+
+	if len(ob.Bids) == 0 || len(ob.Asks) == 0 {
+		return fmt.Errorf("cannot place demo orders in a one sided book")
+	}
+
+	orders := []exchangesdk.Order{
+		{
+			Type:   exchangesdk.OrderTypeBid,
+			Price:  decimal.NewFromFloat(ob.Bids[0].Price),
+			Volume: decimal.New(1, -2),
+		},
+		{
+			Type:   exchangesdk.OrderTypeAsk,
+			Price:  decimal.NewFromFloat(ob.Asks[0].Price),
+			Volume: decimal.New(2, -2),
+		},
+	}
+	for _, o := range orders {
+		_, err := client.PostLimitOrder(ctx, o)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func run(ctx context.Context, exchange crypto.Exchange) error {
+
+	if *demoOrders && !isSimulated(exchange.Provider) {
+		return fmt.Errorf("demo_orders can only be used with simulated providers")
+	}
+
+	apiAuth, err := getApiAuth(exchange)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+
+	wg.Add(1)
+	obf, tradeStream, err := factory.NewMarketFollower(
+		ctx,
+		&wg,
+		exchange,
+		apiAuth,
+		factory.WithReplayFile(*replayPath),
+		factory.WithReplaySpeed(recording.ReplaySpeedRecorded),
+	)
+	if err != nil {
+		wg.Done()
+		return fmt.Errorf("failed to create market follower: %w", err)
+	}
+
+	l := &ladder{
+		exchange:       exchange,
+		depth:          *depth,
+		maxTrades:      *maxTrades,
+		priceDecimals:  *priceDecimals,
+		volumeDecimals: *volumeDecimals,
+		colour:         !*noColour,
+	}
+
+	client, reason := newOpenOrdersClient(exchange, apiAuth)
+	if client != nil {
+		followOpenOrders(ctx, &wg, client.(exchangesdk.OpenOrdersClient), l)
+	} else {
+		l.setOrdersUnavailable(reason)
+	}
+
+	placeDemo := *demoOrders && client != nil
+
+	os.Stdout.WriteString(ansiHideCursor)
+	defer os.Stdout.WriteString(ansiShowCursor)
+
+	redraw := time.NewTicker(*refresh)
+	defer redraw.Stop()
+
+	for obf != nil || tradeStream != nil {
+		select {
+		case ob, more := <-obf:
+			if !more {
+				obf = nil
+				continue
+			}
+			l.setOrderBook(ob)
+
+			if placeDemo {
+				placeDemo = false
+				err := placeDemoOrders(ctx, client, ob)
+				if err != nil {
+					l.setOrdersUnavailable(fmt.Sprintf("failed to place demo orders: %v", err))
+				}
+			}
+		case trade, more := <-tradeStream:
+			if !more {
+				tradeStream = nil
+				continue
+			}
+			l.addTrade(trade)
+		case now := <-redraw.C:
+			var b bytes.Buffer
+			b.WriteString(ansiClearScreen)
+			l.render(&b, now)
+			os.Stdout.Write(b.Bytes())
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	// The market has ended (e.g. a replay has finished); draw it a final time
+	var b bytes.Buffer
+	b.WriteString(ansiClearScreen)
+	l.render(&b, time.Now())
+	b.WriteString("\nMarket follower closed\n")
+	os.Stdout.Write(b.Bytes())
+	return nil
+}
+
+func main() {
+
+	flag.Parse()
+
+	exchange, err := parseExchange()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		<-ch
+		cancel()
+	}()
+
+	err = run(ctx, exchange)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/io"
+)
+
+const (
+	defaultPeriod      = 10 * time.Second
+	defaultVolumePrice = 1.0
+)
+
+// Config is the configuration of the monitor, as read from a JSON file, e.g.
+//
+//	{
+//	  "auth_path": "api_auth.json",
+//	  "markets": [
+//	    {"exchange": {"provider": "luno", "pair": "btceur"}, "api_auth": "luno_readonly"},
+//	    {"exchange": {"provider": "binance", "pair": "btceur"}}
+//	  ],
+//	  "period": "10s",
+//	  "stats": [
+//	    {"series": "mid", "stat": "mean", "window": "1m"},
+//	    {"series": "spread", "stat": "quantile", "window": "5m", "quantile": 0.9}
+//	  ],
+//	  "sinks": [{"type": "log"}, {"type": "csv", "path": "stats.csv"}],
+//	  "metrics_addr": ":9100"
+//	}
+type Config struct {
+	// AuthPath is the file which api auth names are read from
+	AuthPath string `json:"auth_path"`
+
+	Markets []MarketConfig `json:"markets"`
+
+	// Period is how often the stats are output
+	Period Duration `json:"period"`
+
+	// VolumePrice is the volume for which the volume buy and sell prices
+	// are calculated
+	VolumePrice float64 `json:"volume_price"`
+
+	// Stats are the stats which are output for each market; by default those
+	// of defaultStats
+	Stats []StatConfig `json:"stats"`
+
+	// Sinks are where the stats are output to; by default the log
+	Sinks []SinkConfig `json:"sinks"`
+
+	// MetricsAddr, if set, is the address at which metrics of the market
+	// followers are served on `/metrics` (e.g. ":9100")
+	MetricsAddr string `json:"metrics_addr"`
+}
+
+// MarketConfig is a market which is followed by the monitor
+type MarketConfig struct {
+	Exchange crypto.Exchange `json:"exchange"`
+
+	// ApiAuth is the name of the api auth to follow the market with; it is
+	// only needed by providers which require auth (e.g. Luno)
+	ApiAuth string `json:"api_auth"`
+
+	// Record is a path to record the market's order books and trades to
+	Record string `json:"record"`
+
+	// Replay is the recording which is replayed by the replay provider
+	Replay string `json:"replay"`
+}
+
+// StatConfig is a statistic of a series which is output for each market
+type StatConfig struct {
+	// Name is the name of the stat in the output; by default
+	// <series>_<stat>_<window>
+	Name string `json:"name"`
+
+	// Series is the series the stat is of (see seriesNames)
+	Series string `json:"series"`
+
+	// Stat is the statistic (see statNames)
+	Stat string `json:"stat"`
+
+	// Window is the duration before now which the stat is of; it is unused
+	// by the latest stat
+	Window Duration `json:"window"`
+
+	// Quantile is the quantile (in [0, 1]) of the quantile stat
+	Quantile float64 `json:"quantile"`
+
+	// HalfLife is the half life of the ema stat
+	HalfLife Duration `json:"half_life"`
+}
+
+// SinkConfig is an output for the stats; Type is one of log, jsonl and csv,
+// and Path is the file which the jsonl and csv sinks write to
+type SinkConfig struct {
+	Type string `json:"type"`
+	Path string `json:"path"`
+}
+
+// Duration is a time.Duration which is read from JSON as a string (e.g.
+// "1m30s")
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return fmt.Errorf("duration should be a string, got %s", data)
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// defaultStats are the stats which are output when none are configured
+var defaultStats = []StatConfig{
+	{Name: "VolSell", Series: SeriesVolumeSellPrice, Stat: StatMean, Window: Duration(time.Minute)},
+	{Name: "VolSell(grad)", Series: SeriesVolumeSellPrice, Stat: StatGradient, Window: Duration(time.Minute)},
+	{Name: "BestBid", Series: SeriesBestBid, Stat: StatLatest},
+	{Name: "BestBid(grad)", Series: SeriesBestBid, Stat: StatGradient, Window: Duration(time.Minute)},
+	{Name: "BestAsk", Series: SeriesBestAsk, Stat: StatLatest},
+	{Name: "BestAsk(grad)", Series: SeriesBestAsk, Stat: StatGradient, Window: Duration(time.Minute)},
+	{Name: "VolBuy", Series: SeriesVolumeBuyPrice, Stat: StatMean, Window: Duration(time.Minute)},
+	{Name: "VolBuy(grad)", Series: SeriesVolumeBuyPrice, Stat: StatGradient, Window: Duration(time.Minute)},
+	{Name: "BSWeight(1m)", Series: SeriesBuySellWeight, Stat: StatSum, Window: Duration(time.Minute)},
+	{Name: "BSWeight(5m)", Series: SeriesBuySellWeight, Stat: StatSum, Window: Duration(5 * time.Minute)},
+}
+
+// ReadConfig reads a Config from a JSON file, and validates it
+func ReadConfig(path string) (Config, error) {
+
+	var c Config
+	err := io.UnmarshalJsonFile(path, &c)
+	if err != nil {
+		return Config{}, err
+	}
+
+	err = c.Validate()
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Validate checks the config, and sets the defaults of any unset fields
+func (c *Config) Validate() error {
+
+	if len(c.Markets) == 0 {
+		return fmt.Errorf("no markets")
+	}
+	for i, m := range c.Markets {
+		if m.Exchange.Provider == crypto.ApiProviderUnknown {
+			return fmt.Errorf("markets[%d] has no provider", i)
+		}
+		if m.Exchange.Pair == crypto.PairUnknown {
+			return fmt.Errorf("markets[%d] has no pair", i)
+		}
+		if m.Exchange.Provider == crypto.ApiProviderLuno && m.ApiAuth == "" {
+			return fmt.Errorf("markets[%d] (%s) needs an api_auth", i, m.Exchange)
+		}
+		if m.Exchange.Provider == crypto.ApiProviderReplay && m.Replay == "" {
+			return fmt.Errorf("markets[%d] (%s) needs a replay path", i, m.Exchange)
+		}
+	}
+
+	if c.AuthPath == "" {
+		c.AuthPath = "api_auth.json"
+	}
+	if c.Period == 0 {
+		c.Period = Duration(defaultPeriod)
+	}
+	if c.Period < 0 {
+		return fmt.Errorf("period must be positive, got %s", time.Duration(c.Period))
+	}
+	if c.VolumePrice == 0 {
+		c.VolumePrice = defaultVolumePrice
+	}
+	if c.VolumePrice < 0 {
+		return fmt.Errorf("volume_price must be positive, got %v", c.VolumePrice)
+	}
+
+	if len(c.Stats) == 0 {
+		c.Stats = append([]StatConfig(nil), defaultStats...)
+	}
+	names := make(map[string]bool)
+	for i := range c.Stats {
+		err := c.Stats[i].validate()
+		if err != nil {
+			return fmt.Errorf("stats[%d]: %w", i, err)
+		}
+		if names[c.Stats[i].Name] {
+			return fmt.Errorf("stats[%d]: duplicate name %s", i, c.Stats[i].Name)
+		}
+		names[c.Stats[i].Name] = true
+	}
+
+	if len(c.Sinks) == 0 {
+		c.Sinks = []SinkConfig{{Type: SinkLog}}
+	}
+	for i, s := range c.Sinks {
+		switch s.Type {
+		case SinkLog:
+		case SinkJsonl, SinkCsv:
+			if s.Path == "" {
+				return fmt.Errorf("sinks[%d] (%s) needs a path", i, s.Type)
+			}
+		default:
+			return fmt.Errorf("sinks[%d] has unknown type `%s`", i, s.Type)
+		}
+	}
+
+	return nil
+}
+
+func (s *StatConfig) validate() error {
+
+	if !seriesNames[s.Series] {
+		return fmt.Errorf("unknown series `%s`", s.Series)
+	}
+	if !statNames[s.Stat] {
+		return fmt.Errorf("unknown stat `%s`", s.Stat)
+	}
+	if s.Stat != StatLatest && s.Window <= 0 {
+		return fmt.Errorf("%s stat needs a positive window", s.Stat)
+	}
+	if s.Stat == StatQuantile && (s.Quantile < 0 || s.Quantile > 1) {
+		return fmt.Errorf("quantile (%v) is outside of [0, 1]", s.Quantile)
+	}
+	if s.Stat == StatEMA && s.HalfLife <= 0 {
+		return fmt.Errorf("ema stat needs a positive half_life")
+	}
+
+	if s.Name == "" {
+		s.Name = s.Series + "_" + s.Stat
+		if s.Stat != StatLatest {
+			s.Name += "_" + time.Duration(s.Window).String()
+		}
+	}
+	return nil
+}
+
+// cacheDuration returns how long the series must keep values for, to
+// calculate all of the stats
+func (c Config) cacheDuration() time.Duration {
+
+	var longest time.Duration
+	for _, s := range c.Stats {
+		if time.Duration(s.Window) > longest {
+			longest = time.Duration(s.Window)
+		}
+	}
+	// Keep an extra period of values, so that the longest window is always
+	// within the cache
+	return longest + time.Duration(c.Period)
+}
@@ -1,233 +1,186 @@
 package main
 
+// market_follower follows one or more markets, and periodically outputs
+// stats of their order books and trades to the log, JSONL or CSV files.
+//
+// The markets, stats and outputs are either read from a config file (see
+// Config), or given on the command line, e.g.
+//
+//	market_follower --api_auth luno_readonly luno__btceur binance__btceur
+
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/thecodedproject/crypto"
-	"github.com/thecodedproject/crypto/exchangesdk"
-	"github.com/thecodedproject/crypto/exchangesdk/factory"
-	"github.com/thecodedproject/crypto/exchangesdk/market_stats"
-	"github.com/thecodedproject/crypto/io"
-	"github.com/thecodedproject/crypto/util"
+	utiltime "github.com/thecodedproject/crypto/util/time"
 )
 
-var logPeriod = 10 * time.Second
-var volumePrice = 1.0
-
-type Stats struct {
-	BestBid util.MovingStats
-	BestAsk util.MovingStats
-
-	VolumeBuyPrice  util.MovingStats
-	VolumeSellPrice util.MovingStats
+var (
+	configPath  = flag.String("config", "", "Path of the monitor config; if set, the other flags and args are not used")
+	authName    = flag.String("api_auth", "", "API auth name to use for markets whose providers need auth (e.g. luno)")
+	authPath    = flag.String("auth_path", "api_auth.json", "Auth file path")
+	pairName    = flag.String("pair", "btceur", "Pair of markets given only by provider")
+	period      = flag.Duration("period", defaultPeriod, "Period at which stats are output")
+	volumePrice = flag.Float64("volume_price", defaultVolumePrice, "Volume for which volume prices are calculated")
+	recordPath  = flag.String("record", "", "Path of file to record the market follower output to (single market only)")
+	replayPath  = flag.String("replay", "", "Path of recording to replay when using the replay provider (single market only)")
+	jsonlPath   = flag.String("jsonl", "", "Path of a JSONL file to also output stats to")
+	csvPath     = flag.String("csv", "", "Path of a CSV file to also output stats to")
+	metricsAddr = flag.String("metrics_addr", "", "Address to serve metrics of the market followers on at /metrics (e.g. :9100)")
+)
 
-	BuySellWeight util.MovingStats
-}
+const usage = "Usage: market_follower --config <path>\n" +
+	"   or: market_follower [flags] <exchange>...\n" +
+	"where each exchange is either <provider>__<pair> (e.g. binance__btceur) or a provider of --pair"
 
-func updateStatsWithOrderBook(stats *Stats, ob *exchangesdk.OrderBook) {
+// parseExchange parses an exchange given on the command line; a bare
+// provider name is taken to be the market of the --pair flag
+func parseExchange(s string) (crypto.Exchange, error) {
 
-	stats.BestBid.Add(ob.Timestamp, ob.Bids[0].Price)
-	stats.BestAsk.Add(ob.Timestamp, ob.Asks[0].Price)
+	if strings.Contains(s, "__") {
+		return crypto.ExchangeString(s)
+	}
 
-	buyPrice, sellPrice, err := market_stats.CalcPricePerVolumeStats(ob, volumePrice)
+	provider, err := crypto.ApiProviderString(s)
 	if err != nil {
-		log.Fatal(err)
+		return crypto.Exchange{}, err
 	}
-
-	stats.VolumeBuyPrice.Add(ob.Timestamp, buyPrice)
-	stats.VolumeSellPrice.Add(ob.Timestamp, sellPrice)
+	pair, err := crypto.PairString(*pairName)
+	if err != nil {
+		return crypto.Exchange{}, err
+	}
+	return crypto.Exchange{
+		Provider: provider,
+		Pair:     pair,
+	}, nil
 }
 
-func updateStatsWithTrade(stats *Stats, trade *exchangesdk.OrderBookTrade) {
+// configFromFlags builds a config from the command line flags and args
+func configFromFlags() (Config, error) {
 
-	weight := trade.Volume
-	if trade.MakerSide == exchangesdk.OrderBookSideBid {
-		weight = -weight
+	if flag.NArg() == 0 {
+		return Config{}, fmt.Errorf("no markets given")
 	}
-
-	if stats.BuySellWeight == nil {
-		log.Fatal("updateStatesWithTrade nil")
+	if flag.NArg() > 1 && (*recordPath != "" || *replayPath != "") {
+		return Config{}, fmt.Errorf("record and replay can only be used with a single market; use a config to record several")
 	}
 
-	stats.BuySellWeight.Add(trade.Timestamp, weight)
-}
-
-func minutesAgo(i int) time.Time {
-
-	return time.Now().Add(time.Duration(-i) * time.Minute)
-}
-
-func logStats(stats *Stats) {
-
-	statsTime := minutesAgo(1)
-
-	bsWeight1min, err := stats.BuySellWeight.Sum(statsTime)
-	if err != nil {
-		log.Fatal(err)
+	c := Config{
+		AuthPath:    *authPath,
+		Period:      Duration(*period),
+		VolumePrice: *volumePrice,
+		Sinks:       []SinkConfig{{Type: SinkLog}},
+		MetricsAddr: *metricsAddr,
 	}
 
-	bsWeight5min, err := stats.BuySellWeight.Sum(minutesAgo(5))
-	if err != nil {
-		log.Fatal(err)
-	}
+	for _, arg := range flag.Args() {
+		exchange, err := parseExchange(arg)
+		if err != nil {
+			return Config{}, err
+		}
 
-	bestBid := stats.BestBid.Latest() //Mean(statsTime)
-	bestBidGrad, err := stats.BestBid.Gradient(statsTime)
-	if err != nil {
-		log.Fatal(err)
-	}
-	bestAsk := stats.BestAsk.Latest() //Mean(statsTime)
-	bestAskGrad, err := stats.BestAsk.Gradient(statsTime)
-	if err != nil {
-		log.Fatal(err)
-	}
-	avSellPrice, err := stats.VolumeSellPrice.Mean(statsTime)
-	if err != nil {
-		log.Fatal(err)
+		market := MarketConfig{
+			Exchange: exchange,
+			Record:   *recordPath,
+			Replay:   *replayPath,
+		}
+		if exchange.Provider == crypto.ApiProviderLuno {
+			market.ApiAuth = *authName
+		}
+		c.Markets = append(c.Markets, market)
 	}
-	avSellGrad, err := stats.VolumeSellPrice.Gradient(statsTime)
-	if err != nil {
-		log.Fatal(err)
+
+	if *jsonlPath != "" {
+		c.Sinks = append(c.Sinks, SinkConfig{Type: SinkJsonl, Path: *jsonlPath})
 	}
-	avBuyPrice, err := stats.VolumeBuyPrice.Mean(statsTime)
-	if err != nil {
-		log.Fatal(err)
+	if *csvPath != "" {
+		c.Sinks = append(c.Sinks, SinkConfig{Type: SinkCsv, Path: *csvPath})
 	}
-	avBuyGrad, err := stats.VolumeBuyPrice.Gradient(statsTime)
+
+	err := c.Validate()
 	if err != nil {
-		log.Fatal(err)
+		return Config{}, err
 	}
-
-	log.Printf("%.2f (%.2f)\t\t%.2f (%.2f)\t\t%.2f (%.2f)\t\t%.2f (%.2f)\t\t%.2f\t\t%.2f\n",
-		avSellPrice,
-		avSellGrad,
-		bestBid,
-		bestBidGrad,
-		bestAsk,
-		bestAskGrad,
-		avBuyPrice,
-		avBuyGrad,
-		bsWeight1min,
-		bsWeight5min,
-	)
+	return c, nil
 }
 
-func nextLogPeriod() time.Duration {
-
-	oneEpoch := logPeriod
-	startOfNextEpoch := time.Now().Truncate(oneEpoch).Add(oneEpoch)
-	return startOfNextEpoch.Sub(time.Now())
-}
+func run(ctx context.Context, c Config) error {
 
-func logStatsForever(
-	ctx context.Context,
-	wg *sync.WaitGroup,
-	apiAuth crypto.AuthConfig,
-) {
-
-	wg.Add(1)
-	obf, tradeStream, err := factory.NewMarketFollower(
-		ctx,
-		wg,
-		crypto.Exchange{
-			Provider: apiAuth.Provider,
-			Pair:     crypto.PairBTCEUR,
-		},
-		apiAuth,
-	)
-	if err != nil {
-		log.Fatal("failed to create market follower:", err)
+	names := c.statNames()
+	var sinks []Sink
+	for _, sc := range c.Sinks {
+		s, err := newSink(sc, names)
+		if err != nil {
+			for _, s := range sinks {
+				s.Close()
+			}
+			return fmt.Errorf("failed to create %s sink: %w", sc.Type, err)
+		}
+		sinks = append(sinks, s)
 	}
 
-	log.Printf("VolSell (var.)\t\tBestBid (var.)\t\tBestAsk (var.)\t\tVolBuy (var.)\t\tBSWeight(1m)\t\tBSWeight(5m)\n")
-
-	var stats Stats
-	stats.BuySellWeight = util.NewMovingStats(6 * time.Minute)
-	stats.VolumeBuyPrice = util.NewMovingStats(6 * time.Minute)
-	stats.VolumeSellPrice = util.NewMovingStats(6 * time.Minute)
-	stats.BestBid = util.NewMovingStats(6 * time.Minute)
-	stats.BestAsk = util.NewMovingStats(6 * time.Minute)
-
-	for {
-		select {
-		case ob, more := <-obf:
-			if more {
-				updateStatsWithOrderBook(&stats, &ob)
-			} else {
-				log.Println("obf closed")
-				wg.Done()
-				return
-			}
-		case trade, more := <-tradeStream:
-			if more {
-				updateStatsWithTrade(&stats, &trade)
-			} else {
-				log.Println("trade stream closed")
-				wg.Done()
-				return
-			}
-		case <-time.After(nextLogPeriod()):
-			logStats(&stats)
-		case <-ctx.Done():
-			wg.Done()
-			return
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+
+	m := newMonitor(c, utiltime.Real, sinks)
+	err := m.follow(ctx, &wg)
+	if err != nil {
+		for _, s := range sinks {
+			s.Close()
 		}
+		return err
 	}
+
+	m.run(ctx)
+	return nil
 }
 
 func main() {
 
 	flag.Parse()
-	if flag.NArg() != 1 {
-		log.Fatal("Usage: market_follower [luno|binance|dummy]")
-	}
 
-	var apiCreds crypto.AuthConfig
-	switch flag.Arg(0) {
-	case "luno":
-		var err error
-		apiCreds, err = io.GetAuthConfigByName(
-			"api_auth.json",
-			"luno_jcooper_readonly_test_20201210",
-		)
-		if err != nil {
-			log.Fatal(err)
-		}
-	case "binance":
-		// Binance doesnt require api creds
-		apiCreds = crypto.AuthConfig{
-			Provider: crypto.ApiProviderBinance,
-		}
-	case "dummy":
-		// Dummy exchange doesnt require api creds
-		apiCreds = crypto.AuthConfig{
-			Provider: crypto.ApiProviderDummyExchange,
-		}
-	default:
-		log.Fatal("Unknown exchange:", flag.Arg(0))
+	var c Config
+	var err error
+	if *configPath != "" {
+		c, err = ReadConfig(*configPath)
+	} else {
+		c, err = configFromFlags()
+	}
+	if err != nil {
+		log.Println(err)
+		log.Fatal(usage)
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
-	var wg sync.WaitGroup
-
-	wg.Add(1)
-	go logStatsForever(ctx, &wg, apiCreds)
 
 	ch := make(chan os.Signal, 1)
 	signal.Notify(ch, syscall.SIGTERM, syscall.SIGINT)
-
-	select {
-	case sig := <-ch:
+	go func() {
+		sig := <-ch
 		log.Println("Received OS signal:", sig.String())
 		cancel()
-		wg.Wait()
-		return
+	}()
+
+	log.Printf(
+		"Following %d market(s); outputting %d stats every %s\n",
+		len(c.Markets),
+		len(c.Stats),
+		time.Duration(c.Period),
+	)
+
+	err = run(ctx, c)
+	if err != nil {
+		log.Fatal(err)
 	}
 }
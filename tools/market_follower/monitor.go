@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/factory"
+	"github.com/thecodedproject/crypto/exchangesdk/metrics"
+	"github.com/thecodedproject/crypto/exchangesdk/recording"
+	"github.com/thecodedproject/crypto/io"
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+// monitor follows the markets of a config, and periodically outputs the
+// stats of each market to the sinks
+type monitor struct {
+	config Config
+	clock  utiltime.Clock
+	sinks  []Sink
+
+	// metrics instrument the market followers, if the config has a metrics
+	// address
+	metrics *metrics.Metrics
+
+	markets []*marketStats
+
+	// failing is whether each stat of each market could not be calculated
+	// when it was last output, so that the reason is only logged when a stat
+	// starts failing rather than every period
+	failing [][]bool
+}
+
+func newMonitor(c Config, clock utiltime.Clock, sinks []Sink) *monitor {
+
+	m := &monitor{
+		config: c,
+		clock:  clock,
+		sinks:  sinks,
+	}
+	if c.MetricsAddr != "" {
+		m.metrics = metrics.New()
+	}
+	for range c.Markets {
+		m.markets = append(m.markets, newMarketStats(c.cacheDuration(), c.VolumePrice, clock))
+		m.failing = append(m.failing, make([]bool, len(c.Stats)))
+	}
+	return m
+}
+
+// statNames returns the names of the config's stats, in order
+func (c Config) statNames() []string {
+
+	var names []string
+	for _, s := range c.Stats {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+// follow starts following each of the markets, and serving their metrics if
+// the config has a metrics address
+func (m *monitor) follow(ctx context.Context, wg *sync.WaitGroup) error {
+
+	if m.metrics != nil {
+		wg.Add(1)
+		err := m.metrics.ListenAndServe(ctx, wg, m.config.MetricsAddr)
+		if err != nil {
+			wg.Done()
+			return fmt.Errorf("failed to serve metrics: %w", err)
+		}
+	}
+
+	for i, market := range m.config.Markets {
+		obf, tradeStream, err := m.newMarketFollower(ctx, wg, market)
+		if err != nil {
+			return fmt.Errorf("failed to follow %s: %w", market.Exchange, err)
+		}
+
+		wg.Add(1)
+		go func(stats *marketStats, exchange crypto.Exchange) {
+			defer wg.Done()
+
+			for obf != nil || tradeStream != nil {
+				select {
+				case ob, more := <-obf:
+					if !more {
+						log.Println(exchange, "order book follower closed")
+						obf = nil
+						continue
+					}
+					stats.addOrderBook(&ob)
+				case trade, more := <-tradeStream:
+					if !more {
+						log.Println(exchange, "trade stream closed")
+						tradeStream = nil
+						continue
+					}
+					stats.addTrade(&trade)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(m.markets[i], market.Exchange)
+	}
+	return nil
+}
+
+func (m *monitor) newMarketFollower(
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	market MarketConfig,
+) (<-chan exchangesdk.OrderBook, <-chan exchangesdk.OrderBookTrade, error) {
+
+	apiAuth := crypto.AuthConfig{
+		Provider: market.Exchange.Provider,
+	}
+	if market.ApiAuth != "" {
+		var err error
+		apiAuth, err = io.GetAuthConfigByName(m.config.AuthPath, market.ApiAuth)
+		if err != nil {
+			return nil, nil, err
+		}
+		if apiAuth.Provider != market.Exchange.Provider {
+			return nil, nil, fmt.Errorf(
+				"api auth `%s` is for provider %s; expected %s",
+				market.ApiAuth,
+				apiAuth.Provider,
+				market.Exchange.Provider,
+			)
+		}
+	}
+
+	opts := []factory.Option{
+		factory.WithReplayFile(market.Replay),
+		factory.WithReplaySpeed(recording.ReplaySpeedRecorded),
+	}
+	if m.metrics != nil {
+		opts = append(opts, factory.WithMetrics(m.metrics))
+	}
+
+	wg.Add(1)
+	obf, tradeStream, err := factory.NewMarketFollower(
+		ctx,
+		wg,
+		market.Exchange,
+		apiAuth,
+		opts...,
+	)
+	if err != nil {
+		wg.Done()
+		return nil, nil, err
+	}
+
+	if market.Record == "" {
+		return obf, tradeStream, nil
+	}
+
+	wg.Add(1)
+	obf, tradeStream, err = factory.RecordMarketFollower(
+		ctx,
+		wg,
+		market.Record,
+		obf,
+		tradeStream,
+		opts...,
+	)
+	if err != nil {
+		wg.Done()
+		return nil, nil, fmt.Errorf("failed to create market recorder: %w", err)
+	}
+	return obf, tradeStream, nil
+}
+
+// run outputs the stats at the start of each period until ctx is cancelled,
+// and then closes the sinks
+func (m *monitor) run(ctx context.Context) {
+
+	defer func() {
+		for _, s := range m.sinks {
+			err := s.Close()
+			if err != nil {
+				log.Println("Failed to close sink:", err)
+			}
+		}
+	}()
+
+	period := time.Duration(m.config.Period)
+	for {
+		now := m.clock.Now()
+		nextPeriod := now.Truncate(period).Add(period)
+
+		select {
+		case <-m.clock.After(nextPeriod.Sub(now)):
+			m.output()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// output writes the current stats of each market to the sinks; stats which
+// cannot be calculated are output as missing values
+func (m *monitor) output() {
+
+	now := m.clock.Now()
+	for i, market := range m.config.Markets {
+		values, errs := m.markets[i].evaluate(m.config.Stats)
+		for j, err := range errs {
+			if err != nil && !m.failing[i][j] {
+				log.Printf("%s: cannot calculate %v\n", market.Exchange, err)
+			}
+			m.failing[i][j] = err != nil
+		}
+
+		row := Row{
+			Time:     now,
+			Exchange: market.Exchange,
+			Values:   values,
+		}
+		for _, s := range m.sinks {
+			err := s.Write(row)
+			if err != nil {
+				log.Println("Failed to write stats:", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thecodedproject/crypto"
+	"github.com/thecodedproject/crypto/exchangesdk"
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+var (
+	start   = time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	binance = crypto.Exchange{Provider: crypto.ApiProviderBinance, Pair: crypto.PairBTCEUR}
+)
+
+func TestValidateSetsDefaults(t *testing.T) {
+
+	c := Config{
+		Markets: []MarketConfig{{Exchange: binance}},
+		Stats: []StatConfig{
+			{Series: SeriesMid, Stat: StatMean, Window: Duration(time.Minute)},
+			{Series: SeriesSpread, Stat: StatLatest},
+		},
+	}
+	require.NoError(t, c.Validate())
+
+	assert.Equal(t, Duration(defaultPeriod), c.Period)
+	assert.Equal(t, defaultVolumePrice, c.VolumePrice)
+	assert.Equal(t, []string{"mid_mean_1m0s", "spread_latest"}, c.statNames())
+	assert.Equal(t, []SinkConfig{{Type: SinkLog}}, c.Sinks)
+	assert.Equal(t, time.Minute+defaultPeriod, c.cacheDuration())
+}
+
+func TestValidateInvalidConfigReturnsError(t *testing.T) {
+
+	testCases := []struct {
+		name   string
+		config Config
+	}{
+		{
+			name: "no markets",
+		},
+		{
+			name: "luno without api auth",
+			config: Config{
+				Markets: []MarketConfig{{
+					Exchange: crypto.Exchange{Provider: crypto.ApiProviderLuno, Pair: crypto.PairBTCEUR},
+				}},
+			},
+		},
+		{
+			name: "unknown series",
+			config: Config{
+				Markets: []MarketConfig{{Exchange: binance}},
+				Stats:   []StatConfig{{Series: "volume", Stat: StatLatest}},
+			},
+		},
+		{
+			name: "stat without window",
+			config: Config{
+				Markets: []MarketConfig{{Exchange: binance}},
+				Stats:   []StatConfig{{Series: SeriesMid, Stat: StatMean}},
+			},
+		},
+		{
+			name: "duplicate stat names",
+			config: Config{
+				Markets: []MarketConfig{{Exchange: binance}},
+				Stats: []StatConfig{
+					{Series: SeriesMid, Stat: StatLatest},
+					{Series: SeriesMid, Stat: StatLatest},
+				},
+			},
+		},
+		{
+			name: "file sink without path",
+			config: Config{
+				Markets: []MarketConfig{{Exchange: binance}},
+				Sinks:   []SinkConfig{{Type: SinkCsv}},
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Error(t, test.config.Validate())
+		})
+	}
+}
+
+func TestEvaluateEmptyWindowIsMissingValue(t *testing.T) {
+
+	clock := utiltime.NewSimulatedClock(start)
+	c := Config{
+		Markets: []MarketConfig{{Exchange: binance}},
+		Stats: []StatConfig{
+			{Series: SeriesMid, Stat: StatMean, Window: Duration(time.Minute)},
+			{Series: SeriesBuySellWeight, Stat: StatSum, Window: Duration(time.Minute)},
+			{Series: SeriesVolumeBuyPrice, Stat: StatMean, Window: Duration(time.Minute)},
+		},
+	}
+	require.NoError(t, c.Validate())
+	stats := newMarketStats(c.cacheDuration(), c.VolumePrice, clock)
+
+	// The book is not deep enough for the volume prices, which are skipped
+	stats.addOrderBook(&exchangesdk.OrderBook{
+		Timestamp: start,
+		Bids:      []exchangesdk.OrderBookOrder{{Price: 99, Volume: 0.1}},
+		Asks:      []exchangesdk.OrderBookOrder{{Price: 101, Volume: 0.1}},
+	})
+	clock.Advance(30 * time.Second)
+
+	values, errs := stats.evaluate(c.Stats)
+	assert.Equal(t, 100.0, values[0])
+	assert.NoError(t, errs[0])
+	assert.Equal(t, 0.0, values[1])
+	assert.NoError(t, errs[1])
+	assert.True(t, math.IsNaN(values[2]))
+	assert.Error(t, errs[2])
+
+	// Once the book is outside of the window, the mean has no values
+	clock.Advance(time.Minute)
+
+	values, errs = stats.evaluate(c.Stats)
+	assert.True(t, math.IsNaN(values[0]))
+	assert.EqualError(t, errs[0], "mid_mean_1m0s: no values in window")
+}
+
+type nopCloser struct {
+	bytes.Buffer
+}
+
+func (nopCloser) Close() error {
+
+	return nil
+}
+
+func TestSinksWriteMissingValues(t *testing.T) {
+
+	names := []string{"mid", "spread"}
+	row := Row{
+		Time:     start,
+		Exchange: binance,
+		Values:   []float64{100.5, math.NaN()},
+	}
+
+	var csvOut nopCloser
+	csv, err := newCsvSink(&csvOut, names)
+	require.NoError(t, err)
+	require.NoError(t, csv.Write(row))
+	require.NoError(t, csv.Close())
+	assert.Equal(t,
+		"time,exchange,mid,spread\n"+
+			"2021-03-01T12:00:00Z,binance__btceur,100.5,\n",
+		csvOut.String(),
+	)
+
+	var jsonlOut nopCloser
+	jsonl := newJsonlSink(&jsonlOut, names)
+	require.NoError(t, jsonl.Write(row))
+	require.NoError(t, jsonl.Close())
+	assert.Equal(t,
+		`{"time":"2021-03-01T12:00:00Z","exchange":"binance__btceur","stats":{"mid":100.5,"spread":null}}`+"\n",
+		jsonlOut.String(),
+	)
+
+	var lines []string
+	logSink := newLogSink(func(format string, v ...interface{}) {
+		lines = append(lines, strings.Fields(strings.TrimSpace(
+			strings.Replace(format, "%s", v[0].(string), 1),
+		))...)
+		lines = append(lines, "|")
+	}, names)
+	require.NoError(t, logSink.Write(row))
+	assert.Equal(t, []string{
+		"Market", "mid", "spread", "|",
+		"binance__btceur", "100.50", "-", "|",
+	}, lines)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	goio "io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thecodedproject/crypto"
+)
+
+// Sink types
+const (
+	SinkLog   = "log"
+	SinkJsonl = "jsonl"
+	SinkCsv   = "csv"
+)
+
+// Row is the value of each stat of a market at a time; values which could
+// not be calculated are NaN
+type Row struct {
+	Time     time.Time
+	Exchange crypto.Exchange
+	Values   []float64
+}
+
+// Sink outputs rows of stats; the stats' names are given when the sink is
+// created, in the same order as the values of each row
+type Sink interface {
+	Write(row Row) error
+	Close() error
+}
+
+func newSink(c SinkConfig, names []string) (Sink, error) {
+
+	switch c.Type {
+	case SinkLog:
+		return newLogSink(log.Printf, names), nil
+	case SinkJsonl, SinkCsv:
+		f, err := os.Create(c.Path)
+		if err != nil {
+			return nil, err
+		}
+		if c.Type == SinkJsonl {
+			return newJsonlSink(f, names), nil
+		}
+		return newCsvSink(f, names)
+	default:
+		return nil, fmt.Errorf("unknown sink type `%s`", c.Type)
+	}
+}
+
+// logSink writes rows as a table to the log
+type logSink struct {
+	printf func(format string, v ...interface{})
+	names  []string
+	header bool
+}
+
+func newLogSink(printf func(format string, v ...interface{}), names []string) *logSink {
+
+	return &logSink{
+		printf: printf,
+		names:  names,
+	}
+}
+
+func (s *logSink) Write(row Row) error {
+
+	if !s.header {
+		cells := []string{fmt.Sprintf("%-24s", "Market")}
+		for _, name := range s.names {
+			cells = append(cells, fmt.Sprintf("%14s", name))
+		}
+		s.printf("%s\n", strings.Join(cells, " "))
+		s.header = true
+	}
+
+	cells := []string{fmt.Sprintf("%-24s", row.Exchange)}
+	for _, v := range row.Values {
+		if math.IsNaN(v) {
+			cells = append(cells, fmt.Sprintf("%14s", "-"))
+			continue
+		}
+		cells = append(cells, fmt.Sprintf("%14.2f", v))
+	}
+	s.printf("%s\n", strings.Join(cells, " "))
+	return nil
+}
+
+func (s *logSink) Close() error {
+
+	return nil
+}
+
+// jsonlSink writes each row as a line of JSON, with values which could not
+// be calculated as null
+type jsonlSink struct {
+	w     goio.WriteCloser
+	enc   *json.Encoder
+	names []string
+}
+
+func newJsonlSink(w goio.WriteCloser, names []string) *jsonlSink {
+
+	return &jsonlSink{
+		w:     w,
+		enc:   json.NewEncoder(w),
+		names: names,
+	}
+}
+
+func (s *jsonlSink) Write(row Row) error {
+
+	stats := make(map[string]*float64, len(s.names))
+	for i, name := range s.names {
+		v := row.Values[i]
+		if math.IsNaN(v) {
+			stats[name] = nil
+			continue
+		}
+		stats[name] = &v
+	}
+
+	return s.enc.Encode(struct {
+		Time     time.Time           `json:"time"`
+		Exchange crypto.Exchange     `json:"exchange"`
+		Stats    map[string]*float64 `json:"stats"`
+	}{
+		Time:     row.Time,
+		Exchange: row.Exchange,
+		Stats:    stats,
+	})
+}
+
+func (s *jsonlSink) Close() error {
+
+	return s.w.Close()
+}
+
+// csvSink writes rows as CSV with a header, with values which could not be
+// calculated left empty
+type csvSink struct {
+	w   goio.WriteCloser
+	csv *csv.Writer
+}
+
+func newCsvSink(w goio.WriteCloser, names []string) (*csvSink, error) {
+
+	s := &csvSink{
+		w:   w,
+		csv: csv.NewWriter(w),
+	}
+
+	err := s.csv.Write(append([]string{"time", "exchange"}, names...))
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *csvSink) Write(row Row) error {
+
+	record := []string{row.Time.Format(time.RFC3339), row.Exchange.String()}
+	for _, v := range row.Values {
+		if math.IsNaN(v) {
+			record = append(record, "")
+			continue
+		}
+		record = append(record, strconv.FormatFloat(v, 'f', -1, 64))
+	}
+
+	err := s.csv.Write(record)
+	if err != nil {
+		return err
+	}
+	// Flush each row, so that the file can be followed while the monitor
+	// runs
+	s.csv.Flush()
+	return s.csv.Error()
+}
+
+func (s *csvSink) Close() error {
+
+	s.csv.Flush()
+	err := s.csv.Error()
+	if err != nil {
+		s.w.Close()
+		return err
+	}
+	return s.w.Close()
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/thecodedproject/crypto/exchangesdk"
+	"github.com/thecodedproject/crypto/exchangesdk/market_stats"
+	"github.com/thecodedproject/crypto/util"
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+// Series which stats can be output for
+const (
+	SeriesBestBid         = "best_bid"
+	SeriesBestAsk         = "best_ask"
+	SeriesMid             = "mid"
+	SeriesSpread          = "spread"
+	SeriesVolumeBuyPrice  = "volume_buy_price"
+	SeriesVolumeSellPrice = "volume_sell_price"
+	SeriesBuySellWeight   = "buy_sell_weight"
+)
+
+var seriesNames = map[string]bool{
+	SeriesBestBid:         true,
+	SeriesBestAsk:         true,
+	SeriesMid:             true,
+	SeriesSpread:          true,
+	SeriesVolumeBuyPrice:  true,
+	SeriesVolumeSellPrice: true,
+	SeriesBuySellWeight:   true,
+}
+
+// Stats which can be output for a series
+const (
+	StatLatest    = "latest"
+	StatMean      = "mean"
+	StatSum       = "sum"
+	StatMax       = "max"
+	StatMin       = "min"
+	StatVariation = "variation"
+	StatGradient  = "gradient"
+	StatStdDev    = "stddev"
+	StatZScore    = "zscore"
+	StatMedian    = "median"
+	StatQuantile  = "quantile"
+	StatSlope     = "slope"
+	StatEMA       = "ema"
+)
+
+var statNames = map[string]bool{
+	StatLatest:    true,
+	StatMean:      true,
+	StatSum:       true,
+	StatMax:       true,
+	StatMin:       true,
+	StatVariation: true,
+	StatGradient:  true,
+	StatStdDev:    true,
+	StatZScore:    true,
+	StatMedian:    true,
+	StatQuantile:  true,
+	StatSlope:     true,
+	StatEMA:       true,
+}
+
+// marketStats are the series of a market
+type marketStats struct {
+	clock       utiltime.Clock
+	volumePrice float64
+
+	mu     sync.Mutex
+	series map[string]util.RollingStats
+
+	// latest is the time of the latest value of each series, to tell which
+	// stats have no values in their windows
+	latest map[string]time.Time
+}
+
+func newMarketStats(
+	cacheDuration time.Duration,
+	volumePrice float64,
+	clock utiltime.Clock,
+) *marketStats {
+
+	s := &marketStats{
+		clock:       clock,
+		volumePrice: volumePrice,
+		series:      make(map[string]util.RollingStats),
+		latest:      make(map[string]time.Time),
+	}
+	for name := range seriesNames {
+		s.series[name] = util.NewRollingStats(cacheDuration, util.WithClock(clock))
+	}
+	return s
+}
+
+// addOrderBook adds the prices of an order book to the series; the series
+// which cannot be calculated from the book (e.g. because one side of it is
+// empty, or is not deep enough for the volume prices) are left unchanged
+func (s *marketStats) addOrderBook(ob *exchangesdk.OrderBook) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(ob.Bids) > 0 {
+		s.add(SeriesBestBid, ob.Timestamp, ob.Bids[0].Price)
+	}
+	if len(ob.Asks) > 0 {
+		s.add(SeriesBestAsk, ob.Timestamp, ob.Asks[0].Price)
+	}
+	if len(ob.Bids) > 0 && len(ob.Asks) > 0 {
+		s.add(SeriesMid, ob.Timestamp, (ob.Bids[0].Price+ob.Asks[0].Price)/2)
+		s.add(SeriesSpread, ob.Timestamp, ob.Asks[0].Price-ob.Bids[0].Price)
+	}
+
+	buyPrice, sellPrice, err := market_stats.CalcPricePerVolumeStats(ob, s.volumePrice)
+	if err == nil {
+		s.add(SeriesVolumeBuyPrice, ob.Timestamp, buyPrice)
+		s.add(SeriesVolumeSellPrice, ob.Timestamp, sellPrice)
+	}
+}
+
+// addTrade adds a trade to the buy/sell weight: the volume of trades which
+// took asks (i.e. buys) less the volume of trades which took bids
+func (s *marketStats) addTrade(trade *exchangesdk.OrderBookTrade) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	weight := trade.Volume
+	if trade.MakerSide == exchangesdk.OrderBookSideBid {
+		weight = -weight
+	}
+	s.add(SeriesBuySellWeight, trade.Timestamp, weight)
+}
+
+func (s *marketStats) add(series string, t time.Time, v float64) {
+
+	s.series[series].Add(t, v)
+	if t.After(s.latest[series]) {
+		s.latest[series] = t
+	}
+}
+
+// evaluate returns the value of each stat now; the value of a stat which
+// cannot be calculated (e.g. because its window has no values) is NaN, with
+// the reason in errs.
+// Trades are sparse, so the buy/sell weight of a window without trades is
+// zero rather than missing.
+func (s *marketStats) evaluate(stats []StatConfig) ([]float64, []error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	values := make([]float64, len(stats))
+	errs := make([]error, len(stats))
+	for i, stat := range stats {
+		var v float64
+		var err error
+		if s.emptyWindow(stat, now) {
+			err = fmt.Errorf("no values in window")
+		} else {
+			v, err = stat.evaluate(s.series[stat.Series], now)
+		}
+		if err == nil && math.IsNaN(v) {
+			err = fmt.Errorf("value is NaN")
+		}
+		if err != nil {
+			values[i] = math.NaN()
+			errs[i] = fmt.Errorf("%s: %w", stat.Name, err)
+			continue
+		}
+		values[i] = v
+	}
+	return values, errs
+}
+
+// emptyWindow returns whether the series of stat has no values in the
+// stat's window (or, for the latest stat, at all)
+func (s *marketStats) emptyWindow(stat StatConfig, now time.Time) bool {
+
+	latest, ok := s.latest[stat.Series]
+	if !ok {
+		return stat.Series != SeriesBuySellWeight
+	}
+	if stat.Stat == StatLatest || stat.Series == SeriesBuySellWeight {
+		return false
+	}
+	return !latest.After(now.Add(-time.Duration(stat.Window)))
+}
+
+func (c StatConfig) evaluate(rs util.RollingStats, now time.Time) (float64, error) {
+
+	since := now.Add(-time.Duration(c.Window))
+	switch c.Stat {
+	case StatLatest:
+		return rs.Latest(), nil
+	case StatMean:
+		return rs.Mean(since)
+	case StatSum:
+		return rs.Sum(since)
+	case StatMax:
+		return rs.Max(since)
+	case StatMin:
+		return rs.Min(since)
+	case StatVariation:
+		return rs.Variation(since)
+	case StatGradient:
+		return rs.Gradient(since)
+	case StatStdDev:
+		return rs.StdDev(since)
+	case StatZScore:
+		return rs.ZScore(since)
+	case StatMedian:
+		return rs.Median(since)
+	case StatQuantile:
+		return rs.Quantile(since, c.Quantile)
+	case StatSlope:
+		return rs.Slope(since)
+	case StatEMA:
+		return rs.EMA(since, time.Duration(c.HalfLife))
+	default:
+		return 0.0, fmt.Errorf("unknown stat `%s`", c.Stat)
+	}
+}
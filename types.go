@@ -1,9 +1,12 @@
 package crypto
 
-//go:generate enumer -type=ApiProvider -trimprefix=ApiProvider -json -text -transform=snake
 //go:generate enumer -type=Pair -trimprefix=Pair -json -text -transform=snake
 
 //ApiProvider represents the company that provides an API (e.g. Luno or Binance)
+//
+// Its string methods are defined in apiprovider.go rather than generated, so
+// that further providers can be added with RegisterApiProvider; a new built
+// in provider must be added to both the constants and the names there.
 type ApiProvider int
 
 const (
@@ -12,7 +15,9 @@ const (
 	ApiProviderLuno                       ApiProvider = 2
 	ApiProviderBinance                    ApiProvider = 3
 	ApiProviderDummyExchangeBinanceMarket ApiProvider = 4
-	ApiProviderSentinal                   ApiProvider = 5
+	ApiProviderReplay                     ApiProvider = 5
+	ApiProviderBinanceTestnet             ApiProvider = 6
+	ApiProviderSentinal                   ApiProvider = 7
 )
 
 type AuthConfig struct {
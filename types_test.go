@@ -37,3 +37,62 @@ func TestUnmarshalExchangeFromJson(t *testing.T) {
 	require.Equal(t, crypto.ApiProviderBinance, e.Provider)
 	require.Equal(t, crypto.PairLTCBTC, e.Pair)
 }
+
+func TestRegisterApiProviderParsesAndFormatsLikeBuiltInProviders(t *testing.T) {
+
+	p := crypto.RegisterApiProvider("registered_venue")
+	require.True(t, p.IsAApiProvider())
+	require.Equal(t, "registered_venue", p.String())
+	require.Equal(t, p, crypto.RegisterApiProvider("registered_venue"))
+	require.Contains(t, crypto.ApiProviderValues(), p)
+
+	e, err := crypto.ExchangeString("registered_venue__btceur")
+	require.NoError(t, err)
+	require.Equal(t, crypto.Exchange{Provider: p, Pair: crypto.PairBTCEUR}, e)
+
+	b, err := json.Marshal(crypto.AuthConfig{Provider: p})
+	require.NoError(t, err)
+	var auth crypto.AuthConfig
+	require.NoError(t, json.Unmarshal(b, &auth))
+	require.Equal(t, p, auth.Provider)
+}
+
+func TestBuiltInApiProviderNames(t *testing.T) {
+
+	require.Equal(t, "dummy_exchange_binance_market", crypto.ApiProviderDummyExchangeBinanceMarket.String())
+	require.Equal(t, "binance_testnet", crypto.ApiProviderBinanceTestnet.String())
+	require.Equal(t, "ApiProvider(-1)", crypto.ApiProvider(-1).String())
+
+	p, err := crypto.ApiProviderString("luno")
+	require.NoError(t, err)
+	require.Equal(t, crypto.ApiProviderLuno, p)
+
+	_, err = crypto.ApiProviderString("not_a_venue")
+	require.Error(t, err)
+}
+
+func TestApiProviderSentinalIsNotAProvider(t *testing.T) {
+
+	_, err := crypto.ApiProviderString(crypto.ApiProviderSentinal.String())
+	require.Error(t, err)
+
+	var auth crypto.AuthConfig
+	err = json.Unmarshal([]byte(`{"provider":"sentinal"}`), &auth)
+	require.Error(t, err)
+
+	_, err = crypto.ExchangeString("sentinal__btceur")
+	require.Error(t, err)
+
+	require.False(t, crypto.ApiProviderSentinal.IsAApiProvider())
+	require.NotContains(t, crypto.ApiProviderValues(), crypto.ApiProviderSentinal)
+}
+
+func TestRegisterApiProviderPanicsForReservedNames(t *testing.T) {
+
+	require.Panics(t, func() {
+		crypto.RegisterApiProvider("sentinal")
+	})
+	require.Panics(t, func() {
+		crypto.RegisterApiProvider("unknown")
+	})
+}
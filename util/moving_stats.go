@@ -3,7 +3,10 @@ package util
 import (
 	"fmt"
 	"math"
+	"sort"
 	"time"
+
+	utiltime "github.com/thecodedproject/crypto/util/time"
 )
 
 type MovingStats interface {
@@ -19,346 +22,473 @@ type MovingStats interface {
 	Gradient(since time.Time) (float64, error)
 }
 
+// minRingCapacity is the initial capacity of a movingStats ring buffer
+const minRingCapacity = 16
+
+// sample is a value added to a movingStats
+type sample struct {
+	t time.Time
+	v float64
+
+	// sumBefore is the running total of the values of all samples before
+	// this one, since the totals were last rebased; the sum of any run of
+	// samples is then the difference of two totals
+	sumBefore float64
+}
+
+// movingStats keeps its samples in a time ordered ring buffer, along with
+// running totals and monotonic deques of the samples for max and min, so
+// that adding a value is amortised O(1) and each statistic is O(log n) (to
+// find the first sample after the since time).
 type movingStats struct {
-	values           map[time.Time]float64
 	maxCacheDuration time.Duration
+	clock            utiltime.Clock
+
+	samples []sample
+	// head is the index in samples of the oldest sample
+	head  int
+	count int
+
+	// Each sample has a sequence number, which is used by the deques; first
+	// is the sequence number of the oldest sample
+	first int64
+
+	// maxs holds the sequence numbers of the samples which are the max of
+	// all samples after them (so their values are decreasing), and mins the
+	// same for the min
+	maxs seqDeque
+	mins seqDeque
 }
 
-func NewMovingStats(maxCacheDuration time.Duration) MovingStats {
+func NewMovingStats(
+	maxCacheDuration time.Duration,
+	opts ...MovingStatsOption,
+) MovingStats {
 
-	return &movingStats{
-		values:           make(map[time.Time]float64),
-		maxCacheDuration: maxCacheDuration,
-	}
+	return NewRollingStats(maxCacheDuration, opts...)
 }
 
-func (ma *movingStats) Add(t time.Time, v float64) {
-
-	ma.values[t] = v
-
-	for valueTime := range ma.values {
-		if valueTime.Before(t.Add(-ma.maxCacheDuration)) {
-			delete(ma.values, valueTime)
-		}
+// Add adds value v at time t, and evicts any values older than the max cache
+// duration before t.
+// Values are expected to be added in time order; adding a value at or before
+// the latest time (which replaces any value at the same time) is supported,
+// but is O(n).
+func (ms *movingStats) Add(t time.Time, v float64) {
+
+	if ms.count > 0 && !t.After(ms.at(ms.count-1).t) {
+		ms.addOutOfOrder(t, v)
+	} else {
+		ms.pushBack(t, v)
 	}
+
+	ms.evictBefore(t.Add(-ms.maxCacheDuration))
 }
 
-func (ma *movingStats) Latest() float64 {
+func (ms *movingStats) Latest() float64 {
 
-	if len(ma.values) == 0 {
+	if ms.count == 0 {
 		return 0.0
 	}
-
-	var latestT time.Time
-	for t := range ma.values {
-		if t.After(latestT) {
-			latestT = t
-		}
-	}
-
-	return ma.values[latestT]
+	return ms.at(ms.count - 1).v
 }
 
-func (ma *movingStats) MeanLatest(d time.Duration) (float64, error) {
+func (ms *movingStats) MeanLatest(d time.Duration) (float64, error) {
 
-	return ma.Mean(timeAgo(d))
+	return ms.Mean(timeAgo(ms.clock, d))
 }
 
-func (ma *movingStats) MeanLatestOrNan(d time.Duration) float64 {
+func (ms *movingStats) MeanLatestOrNan(d time.Duration) float64 {
 
-	return ma.MeanOrNan(timeAgo(d))
+	return ms.MeanOrNan(timeAgo(ms.clock, d))
 }
 
-func (ma *movingStats) Mean(since time.Time) (float64, error) {
+func (ms *movingStats) Mean(since time.Time) (float64, error) {
 
-	v := ma.MeanOrNan(since)
-	if math.IsNaN(v) {
-		return 0.0, fmt.Errorf(
-			"time since (%s) excceeds maxCacheDuration (%s)",
-			time.Now().Sub(since),
-			ma.maxCacheDuration,
-		)
-	}
-	return v, nil
+	return ms.orErr(ms.MeanOrNan(since), since)
 }
 
-func (ma *movingStats) MeanOrNan(since time.Time) float64 {
+func (ms *movingStats) MeanOrNan(since time.Time) float64 {
 
-	if timeOutsideOfCache(since, ma.maxCacheDuration) {
+	if timeOutsideOfCache(ms.clock, since, ms.maxCacheDuration) {
 		return math.NaN()
 	}
 
-	if len(ma.values) == 0 {
+	if ms.count == 0 {
 		return 0.0
 	}
 
-	var sum float64
-	var count int64
-	for t, v := range ma.values {
-		if t.After(since) {
-			sum += v
-			count++
-		}
-	}
-
-	return sum / float64(count)
+	i := ms.indexAfter(since)
+	return ms.sumFrom(i) / float64(ms.count-i)
 }
 
-func (ma *movingStats) SumLatest(d time.Duration) (float64, error) {
+func (ms *movingStats) SumLatest(d time.Duration) (float64, error) {
 
-	return ma.Sum(timeAgo(d))
+	return ms.Sum(timeAgo(ms.clock, d))
 }
 
-func (ma *movingStats) SumLatestOrNan(d time.Duration) float64 {
+func (ms *movingStats) SumLatestOrNan(d time.Duration) float64 {
 
-	return ma.SumOrNan(timeAgo(d))
+	return ms.SumOrNan(timeAgo(ms.clock, d))
 }
 
-func (ma *movingStats) Sum(since time.Time) (float64, error) {
+func (ms *movingStats) Sum(since time.Time) (float64, error) {
 
-	v := ma.SumOrNan(since)
-	if math.IsNaN(v) {
-		return 0.0, fmt.Errorf(
-			"time since (%s) excceeds maxCacheDuration (%s)",
-			time.Now().Sub(since),
-			ma.maxCacheDuration,
-		)
-	}
-	return v, nil
+	return ms.orErr(ms.SumOrNan(since), since)
 }
 
-func (ma *movingStats) SumOrNan(since time.Time) float64 {
+func (ms *movingStats) SumOrNan(since time.Time) float64 {
 
-	if timeOutsideOfCache(since, ma.maxCacheDuration) {
+	if timeOutsideOfCache(ms.clock, since, ms.maxCacheDuration) {
 		return math.NaN()
 	}
 
-	if len(ma.values) == 0 {
-		return 0.0
-	}
+	return ms.sumFrom(ms.indexAfter(since))
+}
 
-	var sum float64
-	for t, v := range ma.values {
-		if t.After(since) {
-			sum += v
-		}
-	}
+func (ms *movingStats) MaxLatest(d time.Duration) (float64, error) {
 
-	return sum
+	return ms.Max(timeAgo(ms.clock, d))
 }
 
-func (ma *movingStats) MaxLatest(d time.Duration) (float64, error) {
+func (ms *movingStats) MaxLatestOrNan(d time.Duration) float64 {
 
-	return ma.Max(timeAgo(d))
+	return ms.MaxOrNan(timeAgo(ms.clock, d))
 }
 
-func (ma *movingStats) MaxLatestOrNan(d time.Duration) float64 {
+func (ms *movingStats) Max(since time.Time) (float64, error) {
 
-	return ma.MaxOrNan(timeAgo(d))
+	return ms.orErr(ms.MaxOrNan(since), since)
 }
 
-func (ma *movingStats) Max(since time.Time) (float64, error) {
+func (ms *movingStats) MaxOrNan(since time.Time) float64 {
 
-	v := ma.MaxOrNan(since)
-	if math.IsNaN(v) {
-		return 0.0, fmt.Errorf(
-			"time since (%s) excceeds maxCacheDuration (%s)",
-			time.Now().Sub(since),
-			ma.maxCacheDuration,
-		)
+	if timeOutsideOfCache(ms.clock, since, ms.maxCacheDuration) {
+		return math.NaN()
 	}
-	return v, nil
+
+	return ms.extremeAfter(&ms.maxs, since)
+}
+
+func (ms *movingStats) MinLatest(d time.Duration) (float64, error) {
+
+	return ms.Min(timeAgo(ms.clock, d))
 }
 
-func (ma *movingStats) MaxOrNan(since time.Time) float64 {
+func (ms *movingStats) MinLatestOrNan(d time.Duration) float64 {
+
+	return ms.MinOrNan(timeAgo(ms.clock, d))
+}
 
-	if timeOutsideOfCache(since, ma.maxCacheDuration) {
+func (ms *movingStats) Min(since time.Time) (float64, error) {
+
+	return ms.orErr(ms.MinOrNan(since), since)
+}
+
+func (ms *movingStats) MinOrNan(since time.Time) float64 {
+
+	if timeOutsideOfCache(ms.clock, since, ms.maxCacheDuration) {
 		return math.NaN()
 	}
 
-	if len(ma.values) == 0 {
-		return 0.0
-	}
+	return ms.extremeAfter(&ms.mins, since)
+}
 
-	var max float64
-	var maxSet bool
-	for t, v := range ma.values {
-		if !t.After(since) {
-			continue
-		}
-		if !maxSet {
-			max = v
-			maxSet = true
-		} else if t.After(since) && v > max {
-			max = v
-		}
-	}
+func (ms *movingStats) VariationLatest(d time.Duration) (float64, error) {
+
+	return ms.Variation(timeAgo(ms.clock, d))
+}
+
+func (ms *movingStats) VariationLatestOrNan(d time.Duration) float64 {
+
+	return ms.VariationOrNan(timeAgo(ms.clock, d))
+}
+
+func (ms *movingStats) Variation(since time.Time) (float64, error) {
 
-	return max
+	return ms.orErr(ms.VariationOrNan(since), since)
 }
 
-func (ma *movingStats) MinLatest(d time.Duration) (float64, error) {
+func (ms *movingStats) VariationOrNan(since time.Time) float64 {
 
-	return ma.Min(timeAgo(d))
+	return ms.MaxOrNan(since) - ms.MinOrNan(since)
 }
 
-func (ma *movingStats) MinLatestOrNan(d time.Duration) float64 {
+func (ms *movingStats) GradientLatest(d time.Duration) (float64, error) {
+
+	return ms.Gradient(timeAgo(ms.clock, d))
+}
+
+func (ms *movingStats) GradientLatestOrNan(d time.Duration) float64 {
+
+	return ms.GradientOrNan(timeAgo(ms.clock, d))
+}
 
-	return ma.MinOrNan(timeAgo(d))
+func (ms *movingStats) Gradient(since time.Time) (float64, error) {
+
+	return ms.orErr(ms.GradientOrNan(since), since)
+}
+
+func (ms *movingStats) GradientOrNan(since time.Time) float64 {
+
+	if timeOutsideOfCache(ms.clock, since, ms.maxCacheDuration) {
+		return math.NaN()
+	}
+
+	i := ms.indexAfter(since)
+	if i == ms.count {
+		return 0.0
+	}
+	return ms.at(ms.count-1).v - ms.at(i).v
 }
 
-func (ma *movingStats) Min(since time.Time) (float64, error) {
+// orErr returns v, or an error if v is NaN because since is outside of the
+// cache
+func (ms *movingStats) orErr(v float64, since time.Time) (float64, error) {
 
-	v := ma.MinOrNan(since)
 	if math.IsNaN(v) {
 		return 0.0, fmt.Errorf(
 			"time since (%s) excceeds maxCacheDuration (%s)",
-			time.Now().Sub(since),
-			ma.maxCacheDuration,
+			ms.clock.Now().Sub(since),
+			ms.maxCacheDuration,
 		)
 	}
 	return v, nil
 }
 
-func (ma *movingStats) MinOrNan(since time.Time) float64 {
+// at returns the i'th oldest sample
+func (ms *movingStats) at(i int) *sample {
 
-	if timeOutsideOfCache(since, ma.maxCacheDuration) {
-		return math.NaN()
+	return &ms.samples[(ms.head+i)%len(ms.samples)]
+}
+
+func (ms *movingStats) atSeq(seq int64) *sample {
+
+	return ms.at(int(seq - ms.first))
+}
+
+// indexAfter returns the index of the oldest sample after since, or count if
+// there are none
+func (ms *movingStats) indexAfter(since time.Time) int {
+
+	return sort.Search(ms.count, func(i int) bool {
+		return ms.at(i).t.After(since)
+	})
+}
+
+// sumFrom returns the sum of the values of the samples from index i
+func (ms *movingStats) sumFrom(i int) float64 {
+
+	if i >= ms.count {
+		return 0.0
 	}
 
-	if len(ma.values) == 0 {
+	latest := ms.at(ms.count - 1)
+	return latest.sumBefore + latest.v - ms.at(i).sumBefore
+}
+
+// extremeAfter returns the value of the first sample in the monotonic deque
+// d which is after since (i.e. the max or min of all samples after since),
+// or zero if there are none
+func (ms *movingStats) extremeAfter(d *seqDeque, since time.Time) float64 {
+
+	j := sort.Search(d.len(), func(j int) bool {
+		return ms.atSeq(d.at(j)).t.After(since)
+	})
+	if j == d.len() {
 		return 0.0
 	}
+	return ms.atSeq(d.at(j)).v
+}
 
-	var min float64
-	var minSet bool
-	for t, v := range ma.values {
-		if !t.After(since) {
-			continue
-		}
-		if !minSet {
-			min = v
-			minSet = true
-		} else if t.After(since) && v < min {
-			min = v
-		}
+// pushBack adds a sample after all existing samples
+func (ms *movingStats) pushBack(t time.Time, v float64) {
+
+	if ms.count == len(ms.samples) {
+		ms.grow()
 	}
 
-	return min
-}
+	s := sample{t: t, v: v}
+	if ms.count > 0 {
+		latest := ms.at(ms.count - 1)
+		s.sumBefore = latest.sumBefore + latest.v
+	}
+
+	seq := ms.first + int64(ms.count)
+	*ms.at(ms.count) = s
+	ms.count++
 
-func (ma *movingStats) VariationLatest(d time.Duration) (float64, error) {
+	for ms.maxs.len() > 0 && ms.atSeq(ms.maxs.back()).v <= v {
+		ms.maxs.popBack()
+	}
+	ms.maxs.pushBack(seq)
 
-	return ma.Variation(timeAgo(d))
+	for ms.mins.len() > 0 && ms.atSeq(ms.mins.back()).v >= v {
+		ms.mins.popBack()
+	}
+	ms.mins.pushBack(seq)
 }
 
-func (ma *movingStats) VariationLatestOrNan(d time.Duration) float64 {
+// evictBefore removes all samples before cutoff
+func (ms *movingStats) evictBefore(cutoff time.Time) {
+
+	for ms.count > 0 && ms.at(0).t.Before(cutoff) {
+		ms.head = (ms.head + 1) % len(ms.samples)
+		ms.count--
+		ms.first++
 
-	return ma.VariationOrNan(timeAgo(d))
+		// Rebase the running totals once per cycle of the ring, so that they
+		// don't grow (and lose precision) without bound
+		if ms.head == 0 {
+			ms.rebase()
+		}
+	}
+
+	for ms.maxs.len() > 0 && ms.maxs.front() < ms.first {
+		ms.maxs.popFront()
+	}
+	for ms.mins.len() > 0 && ms.mins.front() < ms.first {
+		ms.mins.popFront()
+	}
 }
 
-func (ma *movingStats) Variation(since time.Time) (float64, error) {
+// addOutOfOrder inserts (or replaces) a sample which is not after the latest
+// sample, by rebuilding the buffer
+func (ms *movingStats) addOutOfOrder(t time.Time, v float64) {
 
-	v := ma.VariationOrNan(since)
-	if math.IsNaN(v) {
-		return 0.0, fmt.Errorf(
-			"time since (%s) excceeds maxCacheDuration (%s)",
-			time.Now().Sub(since),
-			ma.maxCacheDuration,
-		)
+	samples := make([]sample, 0, ms.count+1)
+	for i := 0; i < ms.count; i++ {
+		samples = append(samples, *ms.at(i))
+	}
+
+	i := sort.Search(len(samples), func(i int) bool {
+		return !samples[i].t.Before(t)
+	})
+	if i < len(samples) && samples[i].t.Equal(t) {
+		samples[i].v = v
+	} else {
+		samples = append(samples, sample{})
+		copy(samples[i+1:], samples[i:])
+		samples[i] = sample{t: t, v: v}
+	}
+
+	ms.head = 0
+	ms.count = 0
+	ms.first = 0
+	ms.maxs = seqDeque{}
+	ms.mins = seqDeque{}
+	for _, s := range samples {
+		ms.pushBack(s.t, s.v)
 	}
-	return v, nil
 }
 
-func (ma *movingStats) VariationOrNan(since time.Time) float64 {
+func (ms *movingStats) grow() {
 
-	if timeOutsideOfCache(since, ma.maxCacheDuration) {
-		return math.NaN()
+	samples := make([]sample, 2*len(ms.samples))
+	for i := 0; i < ms.count; i++ {
+		samples[i] = *ms.at(i)
 	}
+	ms.samples = samples
+	ms.head = 0
+	ms.rebase()
+}
 
-	min := ma.MinOrNan(since)
-	if min == math.NaN() {
-		return math.NaN()
+// rebase makes the running totals relative to the oldest sample
+func (ms *movingStats) rebase() {
+
+	if ms.count == 0 {
+		return
 	}
-	max := ma.MaxOrNan(since)
-	if max == math.NaN() {
-		return math.NaN()
+
+	oldest := *ms.at(0)
+	for i := 0; i < ms.count; i++ {
+		s := ms.at(i)
+		s.sumBefore -= oldest.sumBefore
 	}
-	return max - min
 }
 
-func (ma *movingStats) GradientLatest(d time.Duration) (float64, error) {
+// seqDeque is a double ended queue of sample sequence numbers
+type seqDeque struct {
+	seqs  []int64
+	start int
+}
+
+func (d *seqDeque) len() int {
 
-	return ma.Gradient(timeAgo(d))
+	return len(d.seqs) - d.start
 }
 
-func (ma *movingStats) GradientLatestOrNan(d time.Duration) float64 {
+func (d *seqDeque) at(i int) int64 {
 
-	return ma.GradientOrNan(timeAgo(d))
+	return d.seqs[d.start+i]
 }
 
-func (ma *movingStats) Gradient(since time.Time) (float64, error) {
+func (d *seqDeque) front() int64 {
 
-	v := ma.GradientOrNan(since)
-	if math.IsNaN(v) {
-		return 0.0, fmt.Errorf(
-			"time since (%s) excceeds maxCacheDuration (%s)",
-			time.Now().Sub(since),
-			ma.maxCacheDuration,
-		)
-	}
-	return v, nil
+	return d.seqs[d.start]
 }
 
-func (ma *movingStats) GradientOrNan(since time.Time) float64 {
+func (d *seqDeque) back() int64 {
 
-	if timeOutsideOfCache(since, ma.maxCacheDuration) {
-		return math.NaN()
-	}
+	return d.seqs[len(d.seqs)-1]
+}
 
-	if len(ma.values) == 0 {
-		return 0.0
+func (d *seqDeque) pushBack(seq int64) {
+
+	// Reclaim the space of popped items once they are half of the slice
+	if d.start > 0 && d.start >= len(d.seqs)/2 {
+		n := copy(d.seqs, d.seqs[d.start:])
+		d.seqs = d.seqs[:n]
+		d.start = 0
 	}
+	d.seqs = append(d.seqs, seq)
+}
 
-	var firstValue float64
-	var firstValueTime time.Time
-	var lastValue float64
-	var lastValueTime time.Time
-	var initalValuesSet bool
-	for t, v := range ma.values {
+func (d *seqDeque) popBack() {
 
-		if !t.After(since) {
-			continue
-		}
-		if !initalValuesSet {
-			firstValue = v
-			firstValueTime = t
-			lastValue = v
-			lastValueTime = t
-			initalValuesSet = true
-			continue
-		}
-		if t.Before(firstValueTime) {
-			firstValue = v
-			firstValueTime = t
-			continue
-		}
-		if t.After(lastValueTime) {
-			lastValue = v
-			lastValueTime = t
-			continue
-		}
+	d.seqs = d.seqs[:len(d.seqs)-1]
+}
+
+func (d *seqDeque) popFront() {
+
+	d.start++
+}
+
+type statsOptions struct {
+	clock utiltime.Clock
+}
+
+type MovingStatsOption func(*statsOptions)
+
+// WithClock sets the clock used to tell how long ago a since time is (and to
+// resolve ...Latest durations); by default the real clock is used
+func WithClock(clock utiltime.Clock) MovingStatsOption {
+
+	return func(o *statsOptions) {
+		o.clock = clock
 	}
+}
+
+func resolveStatsOptions(opts []MovingStatsOption) statsOptions {
 
-	return lastValue - firstValue
+	o := statsOptions{
+		clock: utiltime.Real,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
 }
 
-func timeOutsideOfCache(t time.Time, maxCacheDuration time.Duration) bool {
+func timeOutsideOfCache(
+	clock utiltime.Clock,
+	t time.Time,
+	maxCacheDuration time.Duration,
+) bool {
 
-	return time.Now().Sub(t) > maxCacheDuration
+	return clock.Now().Sub(t) > maxCacheDuration
 }
 
-func timeAgo(d time.Duration) time.Time {
+func timeAgo(clock utiltime.Clock, d time.Duration) time.Time {
 
-	return time.Now().Add(-d)
+	return clock.Now().Add(-d)
 }
@@ -0,0 +1,75 @@
+package util_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/thecodedproject/crypto/util"
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+// benchmarkSamples is the number of samples in the cache, about the number of
+// Binance depth updates in the 6 minute cache of tools/market_follower
+const benchmarkSamples = 3600
+
+var benchmarkResult float64
+
+func benchmarkMovingStats(
+	b *testing.B,
+	newMovingStats func(time.Duration, ...util.MovingStatsOption) util.MovingStats,
+	query func(ms util.MovingStats, since time.Time),
+) {
+
+	interval := 100 * time.Millisecond
+	clock := utiltime.NewSimulatedClock(time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC))
+	ms := newMovingStats(benchmarkSamples*interval, util.WithClock(clock))
+
+	t := clock.Now()
+	for i := 0; i < benchmarkSamples; i++ {
+		t = t.Add(interval)
+		clock.Set(t)
+		ms.Add(t, float64(i%100))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Each sample is added at a later time, evicting the oldest
+		t = t.Add(interval)
+		clock.Set(t)
+		ms.Add(t, float64(i%100))
+
+		if query != nil {
+			query(ms, t.Add(-time.Minute))
+		}
+	}
+}
+
+func queryAll(ms util.MovingStats, since time.Time) {
+
+	benchmarkResult = ms.Latest()
+	benchmarkResult, _ = ms.Mean(since)
+	benchmarkResult, _ = ms.Sum(since)
+	benchmarkResult, _ = ms.Max(since)
+	benchmarkResult, _ = ms.Min(since)
+	benchmarkResult, _ = ms.Gradient(since)
+}
+
+func BenchmarkMovingStatsAdd(b *testing.B) {
+
+	benchmarkMovingStats(b, util.NewMovingStats, nil)
+}
+
+func BenchmarkMapMovingStatsAdd(b *testing.B) {
+
+	benchmarkMovingStats(b, util.NewMapMovingStats, nil)
+}
+
+func BenchmarkMovingStatsAddAndQuery(b *testing.B) {
+
+	benchmarkMovingStats(b, util.NewMovingStats, queryAll)
+}
+
+func BenchmarkMapMovingStatsAddAndQuery(b *testing.B) {
+
+	benchmarkMovingStats(b, util.NewMapMovingStats, queryAll)
+}
@@ -0,0 +1,352 @@
+package util
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+type mapMovingStats struct {
+	values           map[time.Time]float64
+	maxCacheDuration time.Duration
+	clock            utiltime.Clock
+}
+
+// NewMapMovingStats returns a MovingStats which keeps its values in a map,
+// and so scans every value on each call.
+// It is the implementation which NewMovingStats replaced, and is only built
+// for tests, as a reference for them and the benchmarks.
+func NewMapMovingStats(
+	maxCacheDuration time.Duration,
+	opts ...MovingStatsOption,
+) MovingStats {
+
+	return &mapMovingStats{
+		values:           make(map[time.Time]float64),
+		maxCacheDuration: maxCacheDuration,
+		clock:            resolveStatsOptions(opts).clock,
+	}
+}
+
+func (ma *mapMovingStats) Add(t time.Time, v float64) {
+
+	ma.values[t] = v
+
+	for valueTime := range ma.values {
+		if valueTime.Before(t.Add(-ma.maxCacheDuration)) {
+			delete(ma.values, valueTime)
+		}
+	}
+}
+
+func (ma *mapMovingStats) Latest() float64 {
+
+	if len(ma.values) == 0 {
+		return 0.0
+	}
+
+	var latestT time.Time
+	for t := range ma.values {
+		if t.After(latestT) {
+			latestT = t
+		}
+	}
+
+	return ma.values[latestT]
+}
+
+func (ma *mapMovingStats) MeanLatest(d time.Duration) (float64, error) {
+
+	return ma.Mean(timeAgo(ma.clock, d))
+}
+
+func (ma *mapMovingStats) MeanLatestOrNan(d time.Duration) float64 {
+
+	return ma.MeanOrNan(timeAgo(ma.clock, d))
+}
+
+func (ma *mapMovingStats) Mean(since time.Time) (float64, error) {
+
+	v := ma.MeanOrNan(since)
+	if math.IsNaN(v) {
+		return 0.0, fmt.Errorf(
+			"time since (%s) excceeds maxCacheDuration (%s)",
+			ma.clock.Now().Sub(since),
+			ma.maxCacheDuration,
+		)
+	}
+	return v, nil
+}
+
+func (ma *mapMovingStats) MeanOrNan(since time.Time) float64 {
+
+	if timeOutsideOfCache(ma.clock, since, ma.maxCacheDuration) {
+		return math.NaN()
+	}
+
+	if len(ma.values) == 0 {
+		return 0.0
+	}
+
+	var sum float64
+	var count int64
+	for t, v := range ma.values {
+		if t.After(since) {
+			sum += v
+			count++
+		}
+	}
+
+	return sum / float64(count)
+}
+
+func (ma *mapMovingStats) SumLatest(d time.Duration) (float64, error) {
+
+	return ma.Sum(timeAgo(ma.clock, d))
+}
+
+func (ma *mapMovingStats) SumLatestOrNan(d time.Duration) float64 {
+
+	return ma.SumOrNan(timeAgo(ma.clock, d))
+}
+
+func (ma *mapMovingStats) Sum(since time.Time) (float64, error) {
+
+	v := ma.SumOrNan(since)
+	if math.IsNaN(v) {
+		return 0.0, fmt.Errorf(
+			"time since (%s) excceeds maxCacheDuration (%s)",
+			ma.clock.Now().Sub(since),
+			ma.maxCacheDuration,
+		)
+	}
+	return v, nil
+}
+
+func (ma *mapMovingStats) SumOrNan(since time.Time) float64 {
+
+	if timeOutsideOfCache(ma.clock, since, ma.maxCacheDuration) {
+		return math.NaN()
+	}
+
+	if len(ma.values) == 0 {
+		return 0.0
+	}
+
+	var sum float64
+	for t, v := range ma.values {
+		if t.After(since) {
+			sum += v
+		}
+	}
+
+	return sum
+}
+
+func (ma *mapMovingStats) MaxLatest(d time.Duration) (float64, error) {
+
+	return ma.Max(timeAgo(ma.clock, d))
+}
+
+func (ma *mapMovingStats) MaxLatestOrNan(d time.Duration) float64 {
+
+	return ma.MaxOrNan(timeAgo(ma.clock, d))
+}
+
+func (ma *mapMovingStats) Max(since time.Time) (float64, error) {
+
+	v := ma.MaxOrNan(since)
+	if math.IsNaN(v) {
+		return 0.0, fmt.Errorf(
+			"time since (%s) excceeds maxCacheDuration (%s)",
+			ma.clock.Now().Sub(since),
+			ma.maxCacheDuration,
+		)
+	}
+	return v, nil
+}
+
+func (ma *mapMovingStats) MaxOrNan(since time.Time) float64 {
+
+	if timeOutsideOfCache(ma.clock, since, ma.maxCacheDuration) {
+		return math.NaN()
+	}
+
+	if len(ma.values) == 0 {
+		return 0.0
+	}
+
+	var max float64
+	var maxSet bool
+	for t, v := range ma.values {
+		if !t.After(since) {
+			continue
+		}
+		if !maxSet {
+			max = v
+			maxSet = true
+		} else if t.After(since) && v > max {
+			max = v
+		}
+	}
+
+	return max
+}
+
+func (ma *mapMovingStats) MinLatest(d time.Duration) (float64, error) {
+
+	return ma.Min(timeAgo(ma.clock, d))
+}
+
+func (ma *mapMovingStats) MinLatestOrNan(d time.Duration) float64 {
+
+	return ma.MinOrNan(timeAgo(ma.clock, d))
+}
+
+func (ma *mapMovingStats) Min(since time.Time) (float64, error) {
+
+	v := ma.MinOrNan(since)
+	if math.IsNaN(v) {
+		return 0.0, fmt.Errorf(
+			"time since (%s) excceeds maxCacheDuration (%s)",
+			ma.clock.Now().Sub(since),
+			ma.maxCacheDuration,
+		)
+	}
+	return v, nil
+}
+
+func (ma *mapMovingStats) MinOrNan(since time.Time) float64 {
+
+	if timeOutsideOfCache(ma.clock, since, ma.maxCacheDuration) {
+		return math.NaN()
+	}
+
+	if len(ma.values) == 0 {
+		return 0.0
+	}
+
+	var min float64
+	var minSet bool
+	for t, v := range ma.values {
+		if !t.After(since) {
+			continue
+		}
+		if !minSet {
+			min = v
+			minSet = true
+		} else if t.After(since) && v < min {
+			min = v
+		}
+	}
+
+	return min
+}
+
+func (ma *mapMovingStats) VariationLatest(d time.Duration) (float64, error) {
+
+	return ma.Variation(timeAgo(ma.clock, d))
+}
+
+func (ma *mapMovingStats) VariationLatestOrNan(d time.Duration) float64 {
+
+	return ma.VariationOrNan(timeAgo(ma.clock, d))
+}
+
+func (ma *mapMovingStats) Variation(since time.Time) (float64, error) {
+
+	v := ma.VariationOrNan(since)
+	if math.IsNaN(v) {
+		return 0.0, fmt.Errorf(
+			"time since (%s) excceeds maxCacheDuration (%s)",
+			ma.clock.Now().Sub(since),
+			ma.maxCacheDuration,
+		)
+	}
+	return v, nil
+}
+
+func (ma *mapMovingStats) VariationOrNan(since time.Time) float64 {
+
+	if timeOutsideOfCache(ma.clock, since, ma.maxCacheDuration) {
+		return math.NaN()
+	}
+
+	min := ma.MinOrNan(since)
+	if min == math.NaN() {
+		return math.NaN()
+	}
+	max := ma.MaxOrNan(since)
+	if max == math.NaN() {
+		return math.NaN()
+	}
+	return max - min
+}
+
+func (ma *mapMovingStats) GradientLatest(d time.Duration) (float64, error) {
+
+	return ma.Gradient(timeAgo(ma.clock, d))
+}
+
+func (ma *mapMovingStats) GradientLatestOrNan(d time.Duration) float64 {
+
+	return ma.GradientOrNan(timeAgo(ma.clock, d))
+}
+
+func (ma *mapMovingStats) Gradient(since time.Time) (float64, error) {
+
+	v := ma.GradientOrNan(since)
+	if math.IsNaN(v) {
+		return 0.0, fmt.Errorf(
+			"time since (%s) excceeds maxCacheDuration (%s)",
+			ma.clock.Now().Sub(since),
+			ma.maxCacheDuration,
+		)
+	}
+	return v, nil
+}
+
+func (ma *mapMovingStats) GradientOrNan(since time.Time) float64 {
+
+	if timeOutsideOfCache(ma.clock, since, ma.maxCacheDuration) {
+		return math.NaN()
+	}
+
+	if len(ma.values) == 0 {
+		return 0.0
+	}
+
+	var firstValue float64
+	var firstValueTime time.Time
+	var lastValue float64
+	var lastValueTime time.Time
+	var initalValuesSet bool
+	for t, v := range ma.values {
+
+		if !t.After(since) {
+			continue
+		}
+		if !initalValuesSet {
+			firstValue = v
+			firstValueTime = t
+			lastValue = v
+			lastValueTime = t
+			initalValuesSet = true
+			continue
+		}
+		if t.Before(firstValueTime) {
+			firstValue = v
+			firstValueTime = t
+			continue
+		}
+		if t.After(lastValueTime) {
+			lastValue = v
+			lastValueTime = t
+			continue
+		}
+	}
+
+	return lastValue - firstValue
+}
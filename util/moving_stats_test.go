@@ -1,17 +1,24 @@
 package util_test
 
 import (
+	"math/rand"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/thecodedproject/crypto/util"
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+// testClock is the clock of the stats under test, which stays at a fixed time
+var testClock = utiltime.NewSimulatedClock(
+	time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC),
 )
 
 func secondsAgo(i int) time.Time {
 
-	return time.Now().Add(time.Duration(-i) * time.Second)
+	return testClock.Now().Add(time.Duration(-i) * time.Second)
 }
 
 func TestMovingStatsLatest(t *testing.T) {
@@ -42,7 +49,7 @@ func TestMovingStatsLatest(t *testing.T) {
 	for _, test := range testCases {
 		t.Run(test.Name, func(t *testing.T) {
 
-			ms := util.NewMovingStats(time.Minute)
+			ms := util.NewMovingStats(time.Minute, util.WithClock(testClock))
 
 			for _, v := range test.Values {
 				ms.Add(v.Time, v.Value)
@@ -120,7 +127,7 @@ func TestMovingStatsMean(t *testing.T) {
 	for _, test := range testCases {
 		t.Run(test.Name, func(t *testing.T) {
 
-			ma := util.NewMovingStats(test.MaxDuration)
+			ma := util.NewMovingStats(test.MaxDuration, util.WithClock(testClock))
 
 			for _, v := range test.Values {
 				ma.Add(v.Time, v.Value)
@@ -203,7 +210,7 @@ func TestMovingStatsSum(t *testing.T) {
 	for _, test := range testCases {
 		t.Run(test.Name, func(t *testing.T) {
 
-			ma := util.NewMovingStats(test.MaxDuration)
+			ma := util.NewMovingStats(test.MaxDuration, util.WithClock(testClock))
 
 			for _, v := range test.Values {
 				ma.Add(v.Time, v.Value)
@@ -298,7 +305,7 @@ func TestMovingStatsMax(t *testing.T) {
 	for _, test := range testCases {
 		t.Run(test.Name, func(t *testing.T) {
 
-			ma := util.NewMovingStats(test.MaxDuration)
+			ma := util.NewMovingStats(test.MaxDuration, util.WithClock(testClock))
 
 			for _, v := range test.Values {
 				ma.Add(v.Time, v.Value)
@@ -393,7 +400,7 @@ func TestMovingStatsMin(t *testing.T) {
 	for _, test := range testCases {
 		t.Run(test.Name, func(t *testing.T) {
 
-			ma := util.NewMovingStats(test.MaxDuration)
+			ma := util.NewMovingStats(test.MaxDuration, util.WithClock(testClock))
 
 			for _, v := range test.Values {
 				ma.Add(v.Time, v.Value)
@@ -488,7 +495,7 @@ func TestMovingStatsVariation(t *testing.T) {
 	for _, test := range testCases {
 		t.Run(test.Name, func(t *testing.T) {
 
-			ma := util.NewMovingStats(test.MaxDuration)
+			ma := util.NewMovingStats(test.MaxDuration, util.WithClock(testClock))
 
 			for _, v := range test.Values {
 				ma.Add(v.Time, v.Value)
@@ -584,7 +591,7 @@ func TestMovingStatsGradient(t *testing.T) {
 	for _, test := range testCases {
 		t.Run(test.Name, func(t *testing.T) {
 
-			ma := util.NewMovingStats(test.MaxDuration)
+			ma := util.NewMovingStats(test.MaxDuration, util.WithClock(testClock))
 
 			for _, v := range test.Values {
 				ma.Add(v.Time, v.Value)
@@ -601,3 +608,77 @@ func TestMovingStatsGradient(t *testing.T) {
 		})
 	}
 }
+
+func TestMovingStatsMatchesMapMovingStats(t *testing.T) {
+
+	rnd := rand.New(rand.NewSource(1))
+	// The clock follows the latest value, with about 1000 values in the
+	// cache
+	maxDuration := time.Second
+	clock := utiltime.NewSimulatedClock(time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC))
+
+	ms := util.NewMovingStats(maxDuration, util.WithClock(clock))
+	legacy := util.NewMapMovingStats(maxDuration, util.WithClock(clock))
+
+	add := func(t time.Time, v float64) {
+		ms.Add(t, v)
+		legacy.Add(t, v)
+	}
+
+	type stat func(util.MovingStats, time.Time) (float64, error)
+	stats := map[string]stat{
+		"mean":      util.MovingStats.Mean,
+		"sum":       util.MovingStats.Sum,
+		"max":       util.MovingStats.Max,
+		"min":       util.MovingStats.Min,
+		"variation": util.MovingStats.Variation,
+		"gradient":  util.MovingStats.Gradient,
+	}
+
+	var compared int
+	latest := clock.Now()
+	for i := 0; i < 5000; i++ {
+		switch {
+		case i%97 == 0 && i > 0:
+			// Replace an existing value
+			add(latest, rnd.Float64()*100)
+		case i%89 == 0 && i > 0:
+			// Add a value before the latest
+			add(latest.Add(-time.Duration(rnd.Intn(500000))*time.Microsecond), rnd.Float64()*100)
+		default:
+			latest = latest.Add(time.Duration(1+rnd.Intn(1000)) * time.Microsecond)
+			clock.Set(latest)
+			add(latest, rnd.Float64()*100)
+		}
+
+		if i%250 != 0 {
+			continue
+		}
+
+		require.Equal(t, legacy.Latest(), ms.Latest(), i)
+		for name, f := range stats {
+			for _, ago := range []time.Duration{
+				0,
+				100 * time.Millisecond,
+				500 * time.Millisecond,
+				900 * time.Millisecond,
+				1100 * time.Millisecond,
+			} {
+				since := latest.Add(-ago)
+				expected, expectedErr := f(legacy, since)
+				actual, err := f(ms, since)
+				if expectedErr != nil {
+					assert.Error(t, err, "%s %d %s", name, i, ago)
+					continue
+				}
+				require.NoError(t, err, "%s %d %s", name, i, ago)
+				assert.InDelta(t, expected, actual, 1e-6, "%s %d %s", name, i, ago)
+				compared++
+			}
+		}
+	}
+
+	// Queries for times more than the cache duration ago return errors, so
+	// check that enough were within it to be compared
+	assert.Greater(t, compared, 400)
+}
@@ -0,0 +1,282 @@
+package util
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// RollingStats is a MovingStats with additional statistics of the values
+// since a given time.
+// As with Sum, Max and Min, each statistic is zero when there are no values
+// since the given time, and returns an error (or NaN) when the time is
+// further ago than the max cache duration.
+type RollingStats interface {
+	MovingStats
+
+	// EMA returns the exponential moving average of the values since the
+	// given time, where the weight of each value halves every halfLife
+	// before the latest value
+	EMA(since time.Time, halfLife time.Duration) (float64, error)
+	EMALatestOrNan(l time.Duration, halfLife time.Duration) float64
+
+	// StdDev returns the (population) standard deviation of the values
+	StdDev(since time.Time) (float64, error)
+	StdDevLatestOrNan(l time.Duration) float64
+
+	// ZScore returns the number of standard deviations which the latest
+	// value is from the mean of the values
+	ZScore(since time.Time) (float64, error)
+	ZScoreLatestOrNan(l time.Duration) float64
+
+	// Quantile returns the q'th quantile (for q in [0, 1]) of the values,
+	// interpolating linearly between values
+	Quantile(since time.Time, q float64) (float64, error)
+	QuantileLatestOrNan(l time.Duration, q float64) float64
+	Median(since time.Time) (float64, error)
+	MedianLatestOrNan(l time.Duration) float64
+
+	// Slope returns the gradient, per second, of the least squares linear
+	// regression of the values against time
+	Slope(since time.Time) (float64, error)
+	SlopeLatestOrNan(l time.Duration) float64
+}
+
+func NewRollingStats(
+	maxCacheDuration time.Duration,
+	opts ...MovingStatsOption,
+) RollingStats {
+
+	return &movingStats{
+		maxCacheDuration: maxCacheDuration,
+		clock:            resolveStatsOptions(opts).clock,
+		samples:          make([]sample, minRingCapacity),
+	}
+}
+
+func (ms *movingStats) EMALatest(d, halfLife time.Duration) (float64, error) {
+
+	return ms.EMA(timeAgo(ms.clock, d), halfLife)
+}
+
+func (ms *movingStats) EMALatestOrNan(d, halfLife time.Duration) float64 {
+
+	return ms.EMAOrNan(timeAgo(ms.clock, d), halfLife)
+}
+
+func (ms *movingStats) EMA(since time.Time, halfLife time.Duration) (float64, error) {
+
+	return ms.orErr(ms.EMAOrNan(since, halfLife), since)
+}
+
+func (ms *movingStats) EMAOrNan(since time.Time, halfLife time.Duration) float64 {
+
+	if timeOutsideOfCache(ms.clock, since, ms.maxCacheDuration) {
+		return math.NaN()
+	}
+
+	i := ms.indexAfter(since)
+	if i == ms.count {
+		return 0.0
+	}
+
+	ema := ms.at(i).v
+	prev := ms.at(i).t
+	for j := i + 1; j < ms.count; j++ {
+		s := ms.at(j)
+		// The weight of the average so far decays by the time since the
+		// previous value, so that irregularly spaced values are handled
+		decay := math.Exp2(-float64(s.t.Sub(prev)) / float64(halfLife))
+		ema = s.v + decay*(ema-s.v)
+		prev = s.t
+	}
+	return ema
+}
+
+func (ms *movingStats) StdDevLatest(d time.Duration) (float64, error) {
+
+	return ms.StdDev(timeAgo(ms.clock, d))
+}
+
+func (ms *movingStats) StdDevLatestOrNan(d time.Duration) float64 {
+
+	return ms.StdDevOrNan(timeAgo(ms.clock, d))
+}
+
+func (ms *movingStats) StdDev(since time.Time) (float64, error) {
+
+	return ms.orErr(ms.StdDevOrNan(since), since)
+}
+
+func (ms *movingStats) StdDevOrNan(since time.Time) float64 {
+
+	if timeOutsideOfCache(ms.clock, since, ms.maxCacheDuration) {
+		return math.NaN()
+	}
+
+	_, stdDev := ms.meanAndStdDevFrom(ms.indexAfter(since))
+	return stdDev
+}
+
+func (ms *movingStats) ZScoreLatest(d time.Duration) (float64, error) {
+
+	return ms.ZScore(timeAgo(ms.clock, d))
+}
+
+func (ms *movingStats) ZScoreLatestOrNan(d time.Duration) float64 {
+
+	return ms.ZScoreOrNan(timeAgo(ms.clock, d))
+}
+
+func (ms *movingStats) ZScore(since time.Time) (float64, error) {
+
+	return ms.orErr(ms.ZScoreOrNan(since), since)
+}
+
+func (ms *movingStats) ZScoreOrNan(since time.Time) float64 {
+
+	if timeOutsideOfCache(ms.clock, since, ms.maxCacheDuration) {
+		return math.NaN()
+	}
+
+	mean, stdDev := ms.meanAndStdDevFrom(ms.indexAfter(since))
+	if stdDev == 0 {
+		return 0.0
+	}
+	return (ms.Latest() - mean) / stdDev
+}
+
+func (ms *movingStats) QuantileLatest(d time.Duration, q float64) (float64, error) {
+
+	return ms.Quantile(timeAgo(ms.clock, d), q)
+}
+
+func (ms *movingStats) QuantileLatestOrNan(d time.Duration, q float64) float64 {
+
+	return ms.QuantileOrNan(timeAgo(ms.clock, d), q)
+}
+
+func (ms *movingStats) Quantile(since time.Time, q float64) (float64, error) {
+
+	if q < 0 || q > 1 {
+		return 0.0, fmt.Errorf("quantile (%v) is outside of [0, 1]", q)
+	}
+	return ms.orErr(ms.QuantileOrNan(since, q), since)
+}
+
+func (ms *movingStats) QuantileOrNan(since time.Time, q float64) float64 {
+
+	if timeOutsideOfCache(ms.clock, since, ms.maxCacheDuration) || q < 0 || q > 1 {
+		return math.NaN()
+	}
+
+	i := ms.indexAfter(since)
+	if i == ms.count {
+		return 0.0
+	}
+
+	values := make([]float64, 0, ms.count-i)
+	for j := i; j < ms.count; j++ {
+		values = append(values, ms.at(j).v)
+	}
+	sort.Float64s(values)
+
+	pos := q * float64(len(values)-1)
+	lower := int(math.Floor(pos))
+	upper := int(math.Ceil(pos))
+	frac := pos - float64(lower)
+	return values[lower] + frac*(values[upper]-values[lower])
+}
+
+func (ms *movingStats) MedianLatest(d time.Duration) (float64, error) {
+
+	return ms.Median(timeAgo(ms.clock, d))
+}
+
+func (ms *movingStats) MedianLatestOrNan(d time.Duration) float64 {
+
+	return ms.MedianOrNan(timeAgo(ms.clock, d))
+}
+
+func (ms *movingStats) Median(since time.Time) (float64, error) {
+
+	return ms.Quantile(since, 0.5)
+}
+
+func (ms *movingStats) MedianOrNan(since time.Time) float64 {
+
+	return ms.QuantileOrNan(since, 0.5)
+}
+
+func (ms *movingStats) SlopeLatest(d time.Duration) (float64, error) {
+
+	return ms.Slope(timeAgo(ms.clock, d))
+}
+
+func (ms *movingStats) SlopeLatestOrNan(d time.Duration) float64 {
+
+	return ms.SlopeOrNan(timeAgo(ms.clock, d))
+}
+
+func (ms *movingStats) Slope(since time.Time) (float64, error) {
+
+	return ms.orErr(ms.SlopeOrNan(since), since)
+}
+
+func (ms *movingStats) SlopeOrNan(since time.Time) float64 {
+
+	if timeOutsideOfCache(ms.clock, since, ms.maxCacheDuration) {
+		return math.NaN()
+	}
+
+	i := ms.indexAfter(since)
+	n := float64(ms.count - i)
+	if n < 2 {
+		return 0.0
+	}
+
+	// Times are taken relative to the first value, to keep the sums small
+	origin := ms.at(i).t
+	var sumX, sumY, sumXX, sumXY float64
+	for j := i; j < ms.count; j++ {
+		s := ms.at(j)
+		x := s.t.Sub(origin).Seconds()
+		sumX += x
+		sumY += s.v
+		sumXX += x * x
+		sumXY += x * s.v
+	}
+
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0.0
+	}
+	return (n*sumXY - sumX*sumY) / denominator
+}
+
+// meanAndStdDevFrom returns the mean and population standard deviation of
+// the values of the samples from index i.
+// The variance is taken from the deviations of the values from the mean,
+// rather than from running totals of the squares, which lose all precision
+// for large values (e.g. prices) with a small spread.
+func (ms *movingStats) meanAndStdDevFrom(i int) (float64, float64) {
+
+	if i >= ms.count {
+		return 0.0, 0.0
+	}
+
+	n := float64(ms.count - i)
+	var sum float64
+	for j := i; j < ms.count; j++ {
+		sum += ms.at(j).v
+	}
+	mean := sum / n
+
+	var sumSquares float64
+	for j := i; j < ms.count; j++ {
+		d := ms.at(j).v - mean
+		sumSquares += d * d
+	}
+	return mean, math.Sqrt(sumSquares / n)
+}
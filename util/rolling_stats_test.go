@@ -0,0 +1,401 @@
+package util_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thecodedproject/crypto/util"
+)
+
+type timeValue struct {
+	Time  time.Time
+	Value float64
+}
+
+func newRollingStats(maxDuration time.Duration, values []timeValue) util.RollingStats {
+
+	rs := util.NewRollingStats(maxDuration, util.WithClock(testClock))
+	for _, v := range values {
+		rs.Add(v.Time, v.Value)
+	}
+	return rs
+}
+
+func TestRollingStatsEMA(t *testing.T) {
+
+	testCases := []struct {
+		Name      string
+		Values    []timeValue
+		SinceTime time.Time
+		HalfLife  time.Duration
+		Expected  float64
+	}{
+		{
+			Name:      "No values",
+			SinceTime: secondsAgo(10),
+			HalfLife:  time.Second,
+		},
+		{
+			Name: "Single value",
+			Values: []timeValue{
+				{secondsAgo(1), 20.0},
+			},
+			SinceTime: secondsAgo(10),
+			HalfLife:  time.Second,
+			Expected:  20.0,
+		},
+		{
+			Name: "Value one half life before latest has half weight",
+			Values: []timeValue{
+				{secondsAgo(2), 0.0},
+				{secondsAgo(1), 10.0},
+			},
+			SinceTime: secondsAgo(10),
+			HalfLife:  time.Second,
+			Expected:  5.0,
+		},
+		{
+			Name: "Weights decay with time between values",
+			Values: []timeValue{
+				{secondsAgo(2), 0.0},
+				{secondsAgo(1), 10.0},
+			},
+			SinceTime: secondsAgo(10),
+			HalfLife:  2 * time.Second,
+			Expected:  10.0 - 10.0/math.Sqrt2,
+		},
+		{
+			Name: "Only values since time are averaged",
+			Values: []timeValue{
+				{secondsAgo(20), 100.0},
+				{secondsAgo(3), 0.0},
+				{secondsAgo(2), 0.0},
+				{secondsAgo(1), 8.0},
+			},
+			SinceTime: secondsAgo(10),
+			HalfLife:  time.Second,
+			Expected:  4.0,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+
+			rs := newRollingStats(time.Hour, test.Values)
+
+			ema, err := rs.EMA(test.SinceTime, test.HalfLife)
+			require.NoError(t, err)
+			assert.InDelta(t, test.Expected, ema, 1e-6)
+		})
+	}
+}
+
+func TestRollingStatsStdDevAndZScore(t *testing.T) {
+
+	testCases := []struct {
+		Name           string
+		Values         []timeValue
+		SinceTime      time.Time
+		ExpectedStdDev float64
+		ExpectedZScore float64
+	}{
+		{
+			Name:      "No values",
+			SinceTime: secondsAgo(10),
+		},
+		{
+			Name: "Equal values",
+			Values: []timeValue{
+				{secondsAgo(2), 1e5},
+				{secondsAgo(1), 1e5},
+			},
+			SinceTime: secondsAgo(10),
+		},
+		{
+			Name: "Multiple values",
+			Values: []timeValue{
+				{secondsAgo(8), 9.0},
+				{secondsAgo(7), 2.0},
+				{secondsAgo(6), 4.0},
+				{secondsAgo(5), 4.0},
+				{secondsAgo(4), 5.0},
+				{secondsAgo(3), 5.0},
+				{secondsAgo(2), 4.0},
+				{secondsAgo(1), 7.0},
+			},
+			SinceTime:      secondsAgo(10),
+			ExpectedStdDev: 2.0,
+			ExpectedZScore: 1.0,
+		},
+		{
+			Name: "Only values since time are used",
+			Values: []timeValue{
+				{secondsAgo(20), 1000.0},
+				{secondsAgo(3), 10.0},
+				{secondsAgo(2), 20.0},
+				{secondsAgo(1), 30.0},
+			},
+			SinceTime:      secondsAgo(10),
+			ExpectedStdDev: math.Sqrt(200.0 / 3.0),
+			ExpectedZScore: 10.0 / math.Sqrt(200.0/3.0),
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+
+			rs := newRollingStats(time.Hour, test.Values)
+
+			stdDev, err := rs.StdDev(test.SinceTime)
+			require.NoError(t, err)
+			assert.InDelta(t, test.ExpectedStdDev, stdDev, 1e-6)
+
+			zScore, err := rs.ZScore(test.SinceTime)
+			require.NoError(t, err)
+			assert.InDelta(t, test.ExpectedZScore, zScore, 1e-6)
+		})
+	}
+}
+
+func TestRollingStatsStdDevOfLargeValuesWithSmallSpread(t *testing.T) {
+
+	const n = 200000
+	rs := util.NewRollingStats(time.Hour, util.WithClock(testClock))
+	values := make([]float64, 0, n)
+	for i := 0; i < n; i++ {
+		v := 50000.0 - 0.01
+		if i%2 == 0 {
+			v = 50000.0 + 0.01
+		}
+		if i%3 == 0 {
+			v = 50000.0
+		}
+		rs.Add(testClock.Now().Add(time.Duration(i-n)*time.Millisecond), v)
+		values = append(values, v)
+	}
+
+	// Two pass reference
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / n
+	var sumSquares float64
+	for _, v := range values {
+		sumSquares += (v - mean) * (v - mean)
+	}
+	expectedStdDev := math.Sqrt(sumSquares / n)
+	expectedZScore := (values[n-1] - mean) / expectedStdDev
+
+	stdDev, err := rs.StdDev(secondsAgo(1000))
+	require.NoError(t, err)
+	assert.InDelta(t, expectedStdDev, stdDev, 1e-9)
+
+	zScore, err := rs.ZScore(secondsAgo(1000))
+	require.NoError(t, err)
+	assert.InDelta(t, expectedZScore, zScore, 1e-6)
+}
+
+func TestRollingStatsQuantile(t *testing.T) {
+
+	values := []timeValue{
+		{secondsAgo(20), 100.0},
+		{secondsAgo(5), 3.0},
+		{secondsAgo(4), 5.0},
+		{secondsAgo(3), 1.0},
+		{secondsAgo(2), 4.0},
+		{secondsAgo(1), 2.0},
+	}
+
+	testCases := []struct {
+		Name      string
+		Values    []timeValue
+		Quantile  float64
+		Expected  float64
+		ExpectErr bool
+	}{
+		{
+			Name:     "No values",
+			Quantile: 0.5,
+		},
+		{
+			Name:     "Zero is min",
+			Values:   values,
+			Quantile: 0,
+			Expected: 1.0,
+		},
+		{
+			Name:     "One is max",
+			Values:   values,
+			Quantile: 1,
+			Expected: 5.0,
+		},
+		{
+			Name:     "Half is median",
+			Values:   values,
+			Quantile: 0.5,
+			Expected: 3.0,
+		},
+		{
+			Name:     "Between values is interpolated",
+			Values:   values,
+			Quantile: 0.1,
+			Expected: 1.4,
+		},
+		{
+			Name:      "Quantile outside of zero to one returns error",
+			Values:    values,
+			Quantile:  1.5,
+			ExpectErr: true,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+
+			rs := newRollingStats(time.Hour, test.Values)
+
+			q, err := rs.Quantile(secondsAgo(10), test.Quantile)
+			if test.ExpectErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.InDelta(t, test.Expected, q, 1e-9)
+		})
+	}
+}
+
+func TestRollingStatsMedianOfEvenNumberOfValues(t *testing.T) {
+
+	rs := newRollingStats(time.Hour, []timeValue{
+		{secondsAgo(4), 4.0},
+		{secondsAgo(3), 1.0},
+		{secondsAgo(2), 3.0},
+		{secondsAgo(1), 2.0},
+	})
+
+	median, err := rs.Median(secondsAgo(10))
+	require.NoError(t, err)
+	assert.Equal(t, 2.5, median)
+
+	assert.Equal(t, 2.5, rs.MedianLatestOrNan(10*time.Second))
+}
+
+func TestRollingStatsSlope(t *testing.T) {
+
+	testCases := []struct {
+		Name     string
+		Values   []timeValue
+		Expected float64
+	}{
+		{
+			Name: "No values",
+		},
+		{
+			Name: "Single value",
+			Values: []timeValue{
+				{secondsAgo(1), 20.0},
+			},
+		},
+		{
+			Name: "Linear values",
+			Values: []timeValue{
+				{secondsAgo(4), 0.0},
+				{secondsAgo(3), 2.0},
+				{secondsAgo(2), 4.0},
+				{secondsAgo(1), 6.0},
+			},
+			Expected: 2.0,
+		},
+		{
+			Name: "Slope uses all values and not only the endpoints",
+			Values: []timeValue{
+				{secondsAgo(4), 0.0},
+				{secondsAgo(3), 10.0},
+				{secondsAgo(2), 0.0},
+				{secondsAgo(1), 0.0},
+			},
+			Expected: -1.0,
+		},
+		{
+			Name: "Only values since time are used",
+			Values: []timeValue{
+				{secondsAgo(20), 1000.0},
+				{secondsAgo(2), 10.0},
+				{secondsAgo(1), 5.0},
+			},
+			Expected: -5.0,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+
+			rs := newRollingStats(time.Hour, test.Values)
+
+			slope, err := rs.Slope(secondsAgo(10))
+			require.NoError(t, err)
+			assert.InDelta(t, test.Expected, slope, 1e-6)
+		})
+	}
+}
+
+func TestRollingStatsSinceOlderThanMaxDuration(t *testing.T) {
+
+	rs := newRollingStats(time.Second, []timeValue{
+		{secondsAgo(1), 20.0},
+	})
+	since := secondsAgo(10)
+
+	testCases := []struct {
+		Name  string
+		Stat  func() (float64, error)
+		OrNan func() float64
+	}{
+		{
+			Name:  "EMA",
+			Stat:  func() (float64, error) { return rs.EMA(since, time.Second) },
+			OrNan: func() float64 { return rs.EMALatestOrNan(10*time.Second, time.Second) },
+		},
+		{
+			Name:  "StdDev",
+			Stat:  func() (float64, error) { return rs.StdDev(since) },
+			OrNan: func() float64 { return rs.StdDevLatestOrNan(10 * time.Second) },
+		},
+		{
+			Name:  "ZScore",
+			Stat:  func() (float64, error) { return rs.ZScore(since) },
+			OrNan: func() float64 { return rs.ZScoreLatestOrNan(10 * time.Second) },
+		},
+		{
+			Name:  "Quantile",
+			Stat:  func() (float64, error) { return rs.Quantile(since, 0.9) },
+			OrNan: func() float64 { return rs.QuantileLatestOrNan(10*time.Second, 0.9) },
+		},
+		{
+			Name:  "Median",
+			Stat:  func() (float64, error) { return rs.Median(since) },
+			OrNan: func() float64 { return rs.MedianLatestOrNan(10 * time.Second) },
+		},
+		{
+			Name:  "Slope",
+			Stat:  func() (float64, error) { return rs.Slope(since) },
+			OrNan: func() float64 { return rs.SlopeLatestOrNan(10 * time.Second) },
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+
+			_, err := test.Stat()
+			assert.Error(t, err)
+
+			assert.True(t, math.IsNaN(test.OrNan()))
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package time
+
+import (
+	"sort"
+	"sync"
+	gotime "time"
+)
+
+// Clock tells the time, and creates timers and tickers which fire as it
+// passes; time-dependent code which takes a Clock can be driven by a
+// SimulatedClock (e.g. from historical data) rather than the system clock.
+type Clock interface {
+	Now() gotime.Time
+	After(d gotime.Duration) <-chan gotime.Time
+	NewTimer(d gotime.Duration) Timer
+	NewTicker(d gotime.Duration) Ticker
+}
+
+// Timer is a single event, as time.Timer
+type Timer interface {
+	C() <-chan gotime.Time
+	Stop() bool
+}
+
+// Ticker is a repeating event, as time.Ticker
+type Ticker interface {
+	C() <-chan gotime.Time
+	Stop()
+}
+
+// Real is the system clock. Its Now is Now, so that it follows any func set
+// by SetTimeNowFunc.
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() gotime.Time {
+
+	return Now()
+}
+
+func (realClock) After(d gotime.Duration) <-chan gotime.Time {
+
+	return gotime.After(d)
+}
+
+func (realClock) NewTimer(d gotime.Duration) Timer {
+
+	return realTimer{gotime.NewTimer(d)}
+}
+
+func (realClock) NewTicker(d gotime.Duration) Ticker {
+
+	return realTicker{gotime.NewTicker(d)}
+}
+
+type realTimer struct {
+	t *gotime.Timer
+}
+
+func (t realTimer) C() <-chan gotime.Time {
+
+	return t.t.C
+}
+
+func (t realTimer) Stop() bool {
+
+	return t.t.Stop()
+}
+
+type realTicker struct {
+	t *gotime.Ticker
+}
+
+func (t realTicker) C() <-chan gotime.Time {
+
+	return t.t.C
+}
+
+func (t realTicker) Stop() {
+
+	t.t.Stop()
+}
+
+// SimulatedClock is a Clock whose time only passes when it is moved by Set
+// or Advance, which fire any timers and tickers which become due.
+// As with the system clock, a ticker which is not read drops ticks.
+type SimulatedClock struct {
+	mu      sync.Mutex
+	now     gotime.Time
+	waiters []*simulatedWaiter
+}
+
+var _ Clock = (*SimulatedClock)(nil)
+
+func NewSimulatedClock(now gotime.Time) *SimulatedClock {
+
+	return &SimulatedClock{
+		now: now,
+	}
+}
+
+func (c *SimulatedClock) Now() gotime.Time {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// Set moves the clock forward to t, firing the timers and tickers which are
+// due at or before t in order of when they are due (with the clock set to
+// that time when each fires). Times before the current time are ignored, as
+// the clock never goes backwards.
+func (c *SimulatedClock) Set(t gotime.Time) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for {
+		sort.SliceStable(c.waiters, func(i, j int) bool {
+			return c.waiters[i].due.Before(c.waiters[j].due)
+		})
+		if len(c.waiters) == 0 || c.waiters[0].due.After(t) {
+			break
+		}
+
+		w := c.waiters[0]
+		if w.due.After(c.now) {
+			c.now = w.due
+		}
+
+		select {
+		case w.c <- c.now:
+		default:
+		}
+
+		if w.period > 0 {
+			w.due = w.due.Add(w.period)
+		} else {
+			c.waiters = c.waiters[1:]
+		}
+	}
+
+	if t.After(c.now) {
+		c.now = t
+	}
+}
+
+// Advance moves the clock forward by d (see Set)
+func (c *SimulatedClock) Advance(d gotime.Duration) {
+
+	c.Set(c.Now().Add(d))
+}
+
+// Waiters returns the number of timers and tickers which are waiting to
+// fire; tests may wait for it to reach the number they expect before
+// advancing the clock, so that code under test has started waiting
+func (c *SimulatedClock) Waiters() int {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.waiters)
+}
+
+func (c *SimulatedClock) After(d gotime.Duration) <-chan gotime.Time {
+
+	return c.NewTimer(d).C()
+}
+
+func (c *SimulatedClock) NewTimer(d gotime.Duration) Timer {
+
+	return c.add(d, 0)
+}
+
+func (c *SimulatedClock) NewTicker(d gotime.Duration) Ticker {
+
+	if d <= 0 {
+		panic("non-positive interval for SimulatedClock.NewTicker")
+	}
+	return simulatedTicker{c.add(d, d)}
+}
+
+func (c *SimulatedClock) add(d, period gotime.Duration) *simulatedWaiter {
+
+	c.mu.Lock()
+	w := &simulatedWaiter{
+		clock:  c,
+		due:    c.now.Add(d),
+		period: period,
+		c:      make(chan gotime.Time, 1),
+	}
+	c.waiters = append(c.waiters, w)
+	c.mu.Unlock()
+
+	// Timers which are already due fire immediately
+	if d <= 0 {
+		c.Set(c.Now())
+	}
+	return w
+}
+
+// remove removes w from the waiters, returning false if it had already
+// fired (or been removed)
+func (c *SimulatedClock) remove(w *simulatedWaiter) bool {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := range c.waiters {
+		if c.waiters[i] == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// simulatedWaiter is a timer (with a zero period) or ticker of a
+// SimulatedClock
+type simulatedWaiter struct {
+	clock  *SimulatedClock
+	due    gotime.Time
+	period gotime.Duration
+	c      chan gotime.Time
+}
+
+func (w *simulatedWaiter) C() <-chan gotime.Time {
+
+	return w.c
+}
+
+func (w *simulatedWaiter) Stop() bool {
+
+	return w.clock.remove(w)
+}
+
+type simulatedTicker struct {
+	*simulatedWaiter
+}
+
+func (t simulatedTicker) Stop() {
+
+	t.simulatedWaiter.Stop()
+}
@@ -0,0 +1,117 @@
+package time_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	utiltime "github.com/thecodedproject/crypto/util/time"
+)
+
+var clockStart = time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func receive(t *testing.T, c <-chan time.Time) time.Time {
+
+	select {
+	case fired := <-c:
+		return fired
+	default:
+		require.Fail(t, "channel has not fired")
+		return time.Time{}
+	}
+}
+
+func assertNotFired(t *testing.T, c <-chan time.Time) {
+
+	select {
+	case fired := <-c:
+		assert.Fail(t, "channel fired", fired)
+	default:
+	}
+}
+
+func TestRealClockNowFollowsSetTimeNowFunc(t *testing.T) {
+
+	reset := utiltime.SetTimeNowForTesting(t, clockStart)
+	defer reset()
+
+	assert.Equal(t, clockStart, utiltime.Real.Now())
+}
+
+func TestSimulatedClockOnlyMovesForward(t *testing.T) {
+
+	clock := utiltime.NewSimulatedClock(clockStart)
+	assert.Equal(t, clockStart, clock.Now())
+
+	clock.Advance(time.Minute)
+	assert.Equal(t, clockStart.Add(time.Minute), clock.Now())
+
+	clock.Set(clockStart)
+	assert.Equal(t, clockStart.Add(time.Minute), clock.Now())
+}
+
+func TestSimulatedClockFiresTimersWhenDue(t *testing.T) {
+
+	clock := utiltime.NewSimulatedClock(clockStart)
+
+	after := clock.After(time.Second)
+	timer := clock.NewTimer(2 * time.Second)
+	assert.Equal(t, 2, clock.Waiters())
+
+	clock.Advance(999 * time.Millisecond)
+	assertNotFired(t, after)
+	assertNotFired(t, timer.C())
+
+	clock.Advance(time.Millisecond)
+	assert.Equal(t, clockStart.Add(time.Second), receive(t, after))
+	assertNotFired(t, timer.C())
+
+	// Timers fire with the time at which they were due
+	clock.Advance(time.Hour)
+	assert.Equal(t, clockStart.Add(2*time.Second), receive(t, timer.C()))
+	assert.Equal(t, clockStart.Add(time.Hour+time.Second), clock.Now())
+
+	assert.Equal(t, 0, clock.Waiters())
+	assert.False(t, timer.Stop())
+}
+
+func TestSimulatedClockStoppedTimerDoesNotFire(t *testing.T) {
+
+	clock := utiltime.NewSimulatedClock(clockStart)
+
+	timer := clock.NewTimer(time.Second)
+	assert.True(t, timer.Stop())
+
+	clock.Advance(time.Minute)
+	assertNotFired(t, timer.C())
+}
+
+func TestSimulatedClockTimerWhichIsDueFiresImmediately(t *testing.T) {
+
+	clock := utiltime.NewSimulatedClock(clockStart)
+
+	assert.Equal(t, clockStart, receive(t, clock.After(0)))
+}
+
+func TestSimulatedClockTickerRepeatsAndDropsTicks(t *testing.T) {
+
+	clock := utiltime.NewSimulatedClock(clockStart)
+	ticker := clock.NewTicker(time.Second)
+
+	clock.Advance(time.Second)
+	assert.Equal(t, clockStart.Add(time.Second), receive(t, ticker.C()))
+
+	clock.Advance(time.Second)
+	assert.Equal(t, clockStart.Add(2*time.Second), receive(t, ticker.C()))
+
+	// Ticks which are not read are dropped, as with time.Ticker
+	clock.Advance(3 * time.Second)
+	assert.Equal(t, clockStart.Add(3*time.Second), receive(t, ticker.C()))
+	assertNotFired(t, ticker.C())
+
+	ticker.Stop()
+	clock.Advance(time.Minute)
+	assertNotFired(t, ticker.C())
+	assert.Equal(t, 0, clock.Waiters())
+}
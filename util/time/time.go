@@ -12,7 +12,10 @@ func Now() gotime.Time {
 	return nowFunc()
 }
 
-func SetTimeNowFuncForTesting(_ *testing.T, now func() gotime.Time) func() {
+// SetTimeNowFunc replaces the function used by Now (e.g. with a simulated
+// clock) and returns a func which restores the previous one.
+// It is not safe to call concurrently with Now.
+func SetTimeNowFunc(now func() gotime.Time) func() {
 
 	oldNowFunc := nowFunc
 	nowFunc = now
@@ -21,6 +24,11 @@ func SetTimeNowFuncForTesting(_ *testing.T, now func() gotime.Time) func() {
 	}
 }
 
+func SetTimeNowFuncForTesting(_ *testing.T, now func() gotime.Time) func() {
+
+	return SetTimeNowFunc(now)
+}
+
 func SetTimeNowForTesting(t *testing.T, time gotime.Time) func() {
 
 	return SetTimeNowFuncForTesting(t, func() gotime.Time {